@@ -0,0 +1,455 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Config is the optional JSON configuration file of Blitz.
+// Secrets such as Spotify credentials stay in environment variables.
+type Config struct {
+	// Locale is the language of messages shown to users, e.g. "de"; empty uses $LANG
+	Locale  string  `json:"locale"`
+	Paths   Paths   `json:"paths"`
+	Ducking Ducking `json:"ducking"`
+	// PauseFade fades the music out before pausing instead of cutting it off
+	PauseFade PauseFade `json:"pauseFade"`
+	History   History   `json:"history"`
+	Latency   Latency   `json:"latency"`
+	Logging   Logging   `json:"logging"`
+	// Display renders media_info into text for clients that can't format it
+	Display Display `json:"display"`
+	// Compact broadcasts a tiny summary for microcontroller displays
+	Compact Compact `json:"compact"`
+	// Screen broadcasts a small preview of the desktop to remote clients
+	Screen Screen `json:"screen"`
+	// Pointer tunes the remote trackpad
+	Pointer Pointer `json:"pointer"`
+	// Normalize cleans up track metadata before it is stored
+	Normalize Normalize `json:"normalize"`
+	// Stats records plays for the listening statistics
+	Stats Stats `json:"stats"`
+	// Upstreams are other Blitz instances whose broadcasts are relayed to our clients
+	Upstreams []Upstream `json:"upstreams"`
+	// Plugins are external programs adding topics and commands
+	Plugins []Plugin `json:"plugins"`
+	// TCP serves the line protocol for embedded clients
+	TCP TCP `json:"tcp"`
+	// TLS serves HTTPS and wss:// instead of plain HTTP
+	TLS TLS `json:"tls"`
+	// Origins are the web pages besides Blitz's own that may open WebSockets
+	Origins Origins `json:"origins"`
+	// Compression compresses WebSocket messages for clients that offer it
+	Compression Compression `json:"compression"`
+	// LowBandwidth slows down the broadcasts of clients saving data
+	LowBandwidth LowBandwidth `json:"lowBandwidth"`
+	// Artwork sets the images shown for tracks without a cover
+	Artwork Artwork `json:"artwork"`
+	// Outbound configures the connections Blitz makes to Spotify, artwork
+	// sites, push services, upstreams and the relay
+	Outbound Outbound `json:"outbound"`
+	// Relay makes Blitz reachable from outside the LAN through a relay server
+	Relay Relay `json:"relay"`
+	// AutoPause pauses the player when the sound would otherwise move to the speakers
+	AutoPause AutoPause `json:"autoPause"`
+	// Radio lists station presets and the directory searched for more
+	Radio Radio `json:"radio"`
+	// Zones groups players on different backends so one command reaches all of them
+	Zones map[string][]ZoneMember `json:"zones"`
+	// Clock configures the NTP drift check
+	Clock Clock `json:"clock"`
+	// Push forwards important broadcasts to phones and notification servers
+	Push Push `json:"push"`
+	// QuietHours holds back pushes at night
+	QuietHours QuietHours `json:"quietHours"`
+	// Blackouts are windows in which pollers don't run, e.g. the latency
+	// checks overnight
+	Blackouts []Blackout `json:"blackouts"`
+	// Modules turns groups of features on and off
+	Modules Modules `json:"modules"`
+	// MaxClients turns away connections beyond this many, telling them when
+	// to come back; 0 allows any number
+	MaxClients int `json:"maxClients"`
+	// Guest lists what tokens with the guest scope may do
+	Guest Guest `json:"guest"`
+	// Profiles are named lists of commands run together, written like
+	// normal messages: {"command": "volume", "volume": 40}
+	Profiles map[string][]map[string]interface{} `json:"profiles"`
+}
+
+// Paths overrides where Blitz keeps its files. Empty values use the
+// environment variables or XDG defaults.
+type Paths struct {
+	Data  string `json:"data"`  // Persistent state such as history and device tokens
+	Cache string `json:"cache"` // Downloaded artwork; safe to delete
+}
+
+// Artwork configures the placeholders of tracks without a cover, which
+// fall back to an image bundled with Blitz
+type Artwork struct {
+	Placeholder string            `json:"placeholder"` // Image for any player
+	Players     map[string]string `json:"players"`     // Images by player name without the instance suffix, e.g. "firefox"
+}
+
+// Ducking configures lowering the player volume during announcements and notifications
+type Ducking struct {
+	Auto   bool `json:"auto"`   // Duck automatically while a notification or TTS stream plays
+	Level  int  `json:"level"`  // Player volume while ducked, in percent of the normal volume
+	FadeMs int  `json:"fadeMs"` // Duration of the fade into and out of ducking
+}
+
+// PauseFade configures the fade of pause and stop, and of resuming with play
+type PauseFade struct {
+	Ms int `json:"ms"` // Fade duration; 0 pauses at once. Clients can override it per command.
+}
+
+// History configures the recently played tracks list
+type History struct {
+	Persist bool `json:"persist"` // Keep the list across restarts in the data directory
+}
+
+// Stats configures the listening statistics
+type Stats struct {
+	Enabled bool `json:"enabled"` // Record every play in the data directory
+}
+
+// Display configures the text rendered into media_info. Templates use Go's
+// text/template syntax over the media_info fields, e.g. "{{.Artist}} — {{.Title}}".
+type Display struct {
+	Template  string            `json:"template"`  // Rendered into display_text
+	Templates map[string]string `json:"templates"` // Named variants rendered into display_texts, e.g. for a narrow ticker
+}
+
+// Compact configures the compact topic for clients like ESP32 displays that
+// can't parse the full payloads
+type Compact struct {
+	Enabled     bool `json:"enabled"`
+	IntervalSec int  `json:"intervalSec"` // Time between broadcasts
+	MaxLength   int  `json:"maxLength"`   // Characters of track text at most
+}
+
+// Screen configures the screen topic, a thumbnail of the desktop for remote
+// clients to preview before they take control
+type Screen struct {
+	Enabled     bool `json:"enabled"`
+	IntervalSec int  `json:"intervalSec"` // Time between thumbnails
+	Width       int  `json:"width"`       // Thumbnail width in pixels; the height keeps the aspect ratio
+	Quality     int  `json:"quality"`     // JPEG quality, 1 to 100
+}
+
+// Pointer configures moving the mouse pointer from a client used as a trackpad
+type Pointer struct {
+	Sensitivity float64 `json:"sensitivity"` // Pixels the pointer moves per unit the client sends
+	MaxRate     int     `json:"maxRate"`     // Moves injected per second at most; faster ones are merged
+}
+
+// Normalize configures the metadata cleanup applied before tracks are stored
+type Normalize struct {
+	Enabled          bool     `json:"enabled"`
+	StripPatterns    []string `json:"stripPatterns"`    // Regular expressions removed from titles and albums (case-insensitive)
+	ArtistSeparators []string `json:"artistSeparators"` // Strings that separate the artists of a multi-artist string
+}
+
+// Compression configures permessage-deflate on WebSocket connections. It
+// shrinks media_info, which carries its artwork base64 encoded, to a
+// fraction for remote clients at some CPU cost.
+type Compression struct {
+	Enabled bool `json:"enabled"`
+	Level   int  `json:"level"` // flate level from 1 (fastest) to 9 (smallest)
+}
+
+// LowBandwidth configures the broadcasts of clients that asked to save data,
+// e.g. phones on a metered connection through the relay
+type LowBandwidth struct {
+	// Rates are the broadcasts per second such clients get on these topics,
+	// unless they asked for other rates
+	Rates map[string]float64 `json:"rates"`
+}
+
+// Upstream is another Blitz instance to relay
+type Upstream struct {
+	Name string `json:"name"` // Prefix of the relayed messages, e.g. "laptop"
+	URL  string `json:"url"`  // WebSocket endpoint, e.g. "ws://laptop.local:8765/ws"
+	// Token is the upstream's BLITZ_TOKEN, proven with the HMAC challenge
+	// rather than sent
+	Token string `json:"token,omitempty"`
+}
+
+// Plugin is an external program speaking JSON lines over stdin and stdout
+type Plugin struct {
+	Name    string   `json:"name"`    // Prefix of the plugin's topics, e.g. "weather"
+	Command []string `json:"command"` // Executable and arguments, e.g. ["/usr/local/bin/blitz-weather", "--city", "Pune"]
+}
+
+// Radio configures internet radio
+type Radio struct {
+	Directory string        `json:"directory"` // RadioBrowser API server
+	Presets   []RadioPreset `json:"presets"`
+}
+
+// RadioPreset is a station played by name with play_radio
+type RadioPreset struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`     // Stream URL
+	Favicon string `json:"favicon"` // Logo shown as artwork
+}
+
+// Clock configures how the host clock is checked
+type Clock struct {
+	NTPServer string `json:"ntpServer"` // Server the clock is compared with; empty disables the check
+}
+
+// Push configures where important broadcasts are delivered besides the
+// WebSocket, which phones close when the app is in the background
+type Push struct {
+	Sinks  []PushSink `json:"sinks"`  // Every sink receives every pushed broadcast
+	Events []string   `json:"events"` // Broadcast topics that are pushed
+}
+
+// PushSink is a notification service. Secrets come from the environment: the
+// ntfy access token from $BLITZ_NTFY_TOKEN, the Gotify application token from
+// $BLITZ_GOTIFY_TOKEN and the FCM service account from $GOOGLE_APPLICATION_CREDENTIALS.
+type PushSink struct {
+	Provider    string `json:"provider"`    // "ntfy", "gotify" or "fcm"
+	Server      string `json:"server"`      // ntfy or Gotify server; ntfy defaults to https://ntfy.sh
+	Topic       string `json:"topic"`       // ntfy topic, or the FCM topic the phones subscribed to
+	Token       string `json:"token"`       // FCM registration token of a single phone, instead of a topic
+	MinPriority string `json:"minPriority"` // Skip notifications below "default" or "high"
+}
+
+// QuietHours are time windows in which broadcasts aren't pushed
+type QuietHours struct {
+	Windows []QuietWindow `json:"windows"`
+	Allow   []string      `json:"allow"` // Broadcast topics pushed even during quiet hours
+}
+
+// QuietWindow is a daily time window in local time, like an iCal event
+// repeating weekly
+type QuietWindow struct {
+	Days  []string `json:"days"`  // iCal weekdays the window starts on ("MO", "TU", ...); empty means every day
+	Start string   `json:"start"` // "22:00"
+	End   string   `json:"end"`   // "07:00"; an end before the start is on the next day
+}
+
+// Blackout pauses pollers during a daily window
+type Blackout struct {
+	Pollers []string `json:"pollers"` // e.g. "latency", "peripherals"
+	QuietWindow
+}
+
+// Modules enables the feature groups that depend on external tools or
+// services. Disabled modules neither poll nor accept commands.
+type Modules struct {
+	Spotify   bool `json:"spotify"`   // Spotify Web API: Connect devices, radio, podcasts and playlists
+	Bluetooth bool `json:"bluetooth"` // Bluetooth devices and their batteries
+	WiFi      bool `json:"wifi"`      // Wi-Fi status, roaming events and saved networks
+	System    bool `json:"system"`    // Host info, clock, peripherals, gamepads, sensors and network usage
+	Launcher  bool `json:"launcher"`  // Starting applications
+	Radio     bool `json:"radio"`     // Radio presets and the RadioBrowser directory
+	Receiver  bool `json:"receiver"`  // Playing URLs on the host through mpv
+	Display   bool `json:"display"`   // Turning screens on and off and switching monitor inputs
+	Pointer   bool `json:"pointer"`   // Moving the mouse pointer and clicking from a phone, off by default
+	Keyboard  bool `json:"keyboard"`  // Typing text from a phone, off by default
+}
+
+// Guest configures the read-only guest scope
+type Guest struct {
+	Commands []string `json:"commands"` // Commands guests may run besides watching
+}
+
+// TCP configures the newline-delimited JSON listener, which speaks the
+// WebSocket protocol without the framing
+type TCP struct {
+	Listen string `json:"listen"` // Address to listen on, e.g. ":8766"; empty disables it
+}
+
+// TLS configures serving HTTPS, so dashboards loaded over HTTPS may open
+// wss:// connections without being blocked as mixed content
+type TLS struct {
+	// Both files must be set; empty serves plain HTTP
+	CertFile string `json:"certFile"` // PEM certificate, including the intermediates
+	KeyFile  string `json:"keyFile"`  // PEM private key of the certificate
+}
+
+// Origins configures which web pages may connect. Apps and scripts send no
+// Origin header and are not affected.
+type Origins struct {
+	// Allowed lists origins like "https://dash.example.com"; * matches any
+	// text without slashes, e.g. "https://*.example.com" or "http://192.168.1.*:*"
+	Allowed []string `json:"allowed"`
+	Any     bool     `json:"any"` // Accept every origin, for development
+}
+
+// Outbound configures connections to the internet. Proxies come from
+// $HTTPS_PROXY, $HTTP_PROXY and $NO_PROXY like for other programs.
+type Outbound struct {
+	CAFile string `json:"caFile"` // PEM bundle of CAs trusted besides the system's
+}
+
+// Relay configures the outbound connection to a relay server. The relay only
+// forwards traffic; clients still authenticate with Blitz itself.
+type Relay struct {
+	URL string `json:"url"` // Base URL of the relay, e.g. "wss://relay.example.com"
+	ID  string `json:"id"`  // Name this instance registers under at the relay
+}
+
+// AutoPause configures the built-in pause rules
+type AutoPause struct {
+	Headphones bool `json:"headphones"` // Pause when headphones (Bluetooth or wired) disconnect
+}
+
+// Logging configures writing the log to disk
+type Logging struct {
+	File       bool   `json:"file"`       // Write the log to a file as well as stderr
+	Dir        string `json:"dir"`        // Log directory, defaults to logs/ in the data directory
+	MaxSizeMB  int    `json:"maxSizeMB"`  // Rotate once the file grows beyond this size
+	MaxAgeDays int    `json:"maxAgeDays"` // Rotate once the file is older than this
+	MaxFiles   int    `json:"maxFiles"`   // Rotated files to keep
+}
+
+// Latency configures the ping monitor
+type Latency struct {
+	Enabled     bool     `json:"enabled"`
+	Targets     []string `json:"targets"`     // Hosts to ping; "gateway" means the default gateway
+	IntervalSec int      `json:"intervalSec"` // Time between ping rounds
+	Count       int      `json:"count"`       // Pings per target and round
+	// An alert is raised when a target loses at least AlertLossPercent of its
+	// pings for AlertRounds rounds in a row
+	AlertLossPercent int `json:"alertLossPercent"`
+	AlertRounds      int `json:"alertRounds"`
+}
+
+// ZoneMember is one player of a zone
+type ZoneMember struct {
+	Provider string `json:"provider"` // "mpris", "spotify_connect" or "chromecast"
+	Player   string `json:"player"`   // MPRIS player name, Spotify Connect device or Chromecast name
+}
+
+var (
+	current *Config
+	once    sync.Once
+)
+
+// Path returns the config file location: $BLITZ_CONFIG, else
+// $XDG_CONFIG_HOME/blitz/config.json, else ~/.config/blitz/config.json
+func Path() string {
+	if path := os.Getenv("BLITZ_CONFIG"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "blitz", "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "config.json"
+	}
+	return filepath.Join(home, ".config", "blitz", "config.json")
+}
+
+// Get returns the configuration, loading it on first use.
+// A missing or invalid file falls back to the defaults.
+func Get() *Config {
+	once.Do(func() {
+		current = defaults()
+
+		data, err := os.ReadFile(Path())
+		if errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		if err != nil {
+			log.Println("Failed to read config, using defaults:", err)
+			return
+		}
+
+		loaded := defaults()
+		if err := json.Unmarshal(data, loaded); err != nil {
+			log.Println("Invalid config file, using defaults:", err)
+			return
+		}
+		current = loaded
+		log.Println("Loaded config from", Path())
+	})
+	return current
+}
+
+func defaults() *Config {
+	return &Config{
+		Ducking: Ducking{
+			Level:  30,
+			FadeMs: 400,
+		},
+		Display: Display{
+			Template: "♪ {{if .Artist}}{{.Artist}} — {{end}}{{.Title}}",
+		},
+		Compression: Compression{
+			Enabled: true,
+			Level:   1,
+		},
+		LowBandwidth: LowBandwidth{
+			Rates: map[string]float64{"media_info": 0.2, "sensors": 0.1, "host": 0.1, "latency": 0.1},
+		},
+		Compact: Compact{
+			IntervalSec: 10,
+			MaxLength:   64,
+		},
+		Screen: Screen{
+			IntervalSec: 5,
+			Width:       320,
+			Quality:     60,
+		},
+		Pointer: Pointer{
+			Sensitivity: 1,
+			MaxRate:     30,
+		},
+		Normalize: Normalize{
+			Enabled: true,
+			StripPatterns: []string{
+				`\s*[(\[][^)\]]*\bremaster(ed)?\b[^)\]]*[)\]]`,
+				`\s+-\s+([^-]*\s)?remaster(ed)?\b.*$`,
+				`\s*[(\[](deluxe|expanded|anniversary)( edition| version)?[)\]]`,
+				`\s*[(\[]official (music |lyric )?video[)\]]`,
+				`\s*[(\[](lyrics|audio|hd|hq)[)\]]`,
+			},
+			ArtistSeparators: []string{", ", "; ", " / ", " feat. "},
+		},
+		Latency: Latency{
+			Targets:          []string{"gateway", "1.1.1.1"},
+			IntervalSec:      10,
+			Count:            5,
+			AlertLossPercent: 20,
+			AlertRounds:      3,
+		},
+		Logging: Logging{
+			MaxSizeMB:  10,
+			MaxAgeDays: 7,
+			MaxFiles:   5,
+		},
+		Clock: Clock{
+			NTPServer: "pool.ntp.org",
+		},
+		Radio: Radio{
+			Directory: "https://all.api.radio-browser.info",
+		},
+		Push: Push{
+			Events: []string{"peripheral_battery_low", "latency_alert", "notification"},
+		},
+		Modules: Modules{
+			Spotify:   true,
+			Bluetooth: true,
+			WiFi:      true,
+			System:    true,
+			Launcher:  true,
+			Radio:     true,
+			Display:   true,
+		},
+		MaxClients: 64,
+		Guest: Guest{
+			Commands: []string{"play", "pause", "play-pause", "volume"},
+		},
+	}
+}
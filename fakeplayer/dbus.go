@@ -0,0 +1,522 @@
+package fakeplayer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Just enough of the D-Bus wire protocol to own a name on the session bus,
+// answer method calls and emit signals. Blitz otherwise reaches D-Bus
+// through playerctl and dbus-send, which can't offer a service.
+
+// Message types
+const (
+	methodCall   = 1
+	methodReturn = 2
+	errorReply   = 3
+	signal       = 4
+)
+
+// noReplyExpected is the flag of method calls that don't want an answer
+const noReplyExpected = 0x1
+
+// Types that pick their D-Bus type in variants
+type (
+	objectPath string
+	signature  string
+)
+
+// variant is a value sent as a D-Bus variant
+type variant struct {
+	value any
+}
+
+// message is a D-Bus message; its body stays encoded until it is read
+type message struct {
+	kind        byte
+	flags       byte
+	serial      uint32
+	path        string
+	iface       string
+	member      string
+	errorName   string
+	replySerial uint32
+	destination string
+	sender      string
+	signature   string
+	body        []byte
+}
+
+// encoder writes D-Bus values in little-endian byte order. Alignment is
+// relative to the start of the buffer, and bodies start 8-aligned in a
+// message, so a body can be encoded on its own.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.align(8)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+func (e *encoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(append(e.buf, s...), 0)
+}
+
+func (e *encoder) signature(s string) {
+	e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+}
+
+// array writes the elements after the array's length, which doesn't count
+// the padding up to the first element
+func (e *encoder) array(elementAlign int, elements func()) {
+	e.align(4)
+	at := len(e.buf)
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	e.align(elementAlign)
+	start := len(e.buf)
+	elements()
+	binary.LittleEndian.PutUint32(e.buf[at:], uint32(len(e.buf)-start))
+}
+
+// signatureOf returns the D-Bus type a Go value is sent as
+func signatureOf(v any) string {
+	switch v.(type) {
+	case string:
+		return "s"
+	case objectPath:
+		return "o"
+	case signature:
+		return "g"
+	case bool:
+		return "b"
+	case int32:
+		return "i"
+	case uint32:
+		return "u"
+	case int64:
+		return "x"
+	case float64:
+		return "d"
+	case []string:
+		return "as"
+	case map[string]any:
+		return "a{sv}"
+	case variant:
+		return "v"
+	}
+	panic(fmt.Sprintf("fakeplayer: no D-Bus type for %T", v))
+}
+
+// value writes a value of one of the types signatureOf knows
+func (e *encoder) value(v any) {
+	switch v := v.(type) {
+	case string:
+		e.string(v)
+	case objectPath:
+		e.string(string(v))
+	case signature:
+		e.signature(string(v))
+	case bool:
+		if v {
+			e.uint32(1)
+		} else {
+			e.uint32(0)
+		}
+	case int32:
+		e.uint32(uint32(v))
+	case uint32:
+		e.uint32(v)
+	case int64:
+		e.uint64(uint64(v))
+	case float64:
+		e.uint64(math.Float64bits(v))
+	case []string:
+		e.array(4, func() {
+			for _, s := range v {
+				e.string(s)
+			}
+		})
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		e.array(8, func() {
+			for _, key := range keys {
+				e.align(8)
+				e.string(key)
+				e.variant(v[key])
+			}
+		})
+	case variant:
+		e.variant(v.value)
+	default:
+		signatureOf(v)
+	}
+}
+
+func (e *encoder) variant(v any) {
+	e.signature(signatureOf(v))
+	e.value(v)
+}
+
+// body encodes the arguments of a message and returns them with their signature
+func body(args ...any) ([]byte, string) {
+	e := &encoder{}
+	var sig strings.Builder
+	for _, arg := range args {
+		sig.WriteString(signatureOf(arg))
+		e.value(arg)
+	}
+	return e.buf, sig.String()
+}
+
+func (m *message) marshal() []byte {
+	e := &encoder{buf: []byte{'l', m.kind, m.flags, 1}}
+	e.uint32(uint32(len(m.body)))
+	e.uint32(m.serial)
+
+	e.array(8, func() {
+		field := func(code byte, v any) {
+			e.align(8)
+			e.buf = append(e.buf, code)
+			e.variant(v)
+		}
+		if m.path != "" {
+			field(1, objectPath(m.path))
+		}
+		if m.iface != "" {
+			field(2, m.iface)
+		}
+		if m.member != "" {
+			field(3, m.member)
+		}
+		if m.errorName != "" {
+			field(4, m.errorName)
+		}
+		if m.replySerial != 0 {
+			field(5, m.replySerial)
+		}
+		if m.destination != "" {
+			field(6, m.destination)
+		}
+		if m.signature != "" {
+			field(8, signature(m.signature))
+		}
+	})
+	e.align(8)
+	return append(e.buf, m.body...)
+}
+
+var errShortMessage = errors.New("dbus: message too short")
+
+// decoder reads the D-Bus values of a little-endian message
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (d *decoder) align(n int) {
+	d.pos = (d.pos + n - 1) / n * n
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || d.pos+n > len(d.buf) {
+		d.err = errShortMessage
+		return make([]byte, max(n, 0))
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) uint32() uint32 {
+	d.align(4)
+	return binary.LittleEndian.Uint32(d.take(4))
+}
+
+func (d *decoder) uint64() uint64 {
+	d.align(8)
+	return binary.LittleEndian.Uint64(d.take(8))
+}
+
+func (d *decoder) string() string {
+	n := d.uint32()
+	s := string(d.take(int(n)))
+	d.take(1)
+	return s
+}
+
+func (d *decoder) signature() string {
+	n := d.take(1)[0]
+	s := string(d.take(int(n)))
+	d.take(1)
+	return s
+}
+
+// value reads a value of a basic type or a variant holding one; that is all
+// the calls a media player gets carry
+func (d *decoder) value(sig string) any {
+	switch sig {
+	case "s":
+		return d.string()
+	case "o":
+		return objectPath(d.string())
+	case "g":
+		return signature(d.signature())
+	case "y":
+		return d.take(1)[0]
+	case "b":
+		return d.uint32() != 0
+	case "i":
+		return int32(d.uint32())
+	case "u":
+		return d.uint32()
+	case "x":
+		return int64(d.uint64())
+	case "t":
+		return d.uint64()
+	case "d":
+		return math.Float64frombits(d.uint64())
+	case "v":
+		return d.value(d.signature())
+	}
+	if d.err == nil {
+		d.err = fmt.Errorf("dbus: unsupported type %q", sig)
+	}
+	return nil
+}
+
+// args decodes the body of a message of the given signature
+func (m *message) args() ([]any, error) {
+	d := &decoder{buf: m.body}
+	var args []any
+	for i := 0; i < len(m.signature); i++ {
+		sig := m.signature[i : i+1]
+		args = append(args, d.value(sig))
+	}
+	return args, d.err
+}
+
+func readMessage(r io.Reader) (*message, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if head[0] != 'l' {
+		return nil, errors.New("dbus: big-endian messages are not supported")
+	}
+	bodyLength := binary.LittleEndian.Uint32(head[4:])
+	fieldsLength := binary.LittleEndian.Uint32(head[12:])
+	if bodyLength > 1<<27 || fieldsLength > 1<<26 {
+		return nil, errors.New("dbus: message too long")
+	}
+
+	headerLength := (16 + int(fieldsLength) + 7) / 8 * 8
+	raw := make([]byte, headerLength+int(bodyLength))
+	copy(raw, head)
+	if _, err := io.ReadFull(r, raw[16:]); err != nil {
+		return nil, err
+	}
+
+	m := &message{kind: head[1], flags: head[2], serial: binary.LittleEndian.Uint32(head[8:]), body: raw[headerLength:]}
+	d := &decoder{buf: raw[:16+fieldsLength], pos: 16}
+	for d.pos < len(d.buf) && d.err == nil {
+		d.align(8)
+		code := d.take(1)[0]
+		value := d.value("v")
+		switch v := value.(type) {
+		case objectPath:
+			if code == 1 {
+				m.path = string(v)
+			}
+		case string:
+			switch code {
+			case 2:
+				m.iface = v
+			case 3:
+				m.member = v
+			case 4:
+				m.errorName = v
+			case 6:
+				m.destination = v
+			case 7:
+				m.sender = v
+			}
+		case uint32:
+			if code == 5 {
+				m.replySerial = v
+			}
+		case signature:
+			if code == 8 {
+				m.signature = string(v)
+			}
+		}
+	}
+	return m, d.err
+}
+
+// conn is a connection to a message bus
+type conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+	serial uint32
+}
+
+// sessionBusAddress returns the socket of the session bus
+func sessionBusAddress() (string, error) {
+	address := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if address == "" {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			return filepath.Join(dir, "bus"), nil
+		}
+		return "", errors.New("no session bus: DBUS_SESSION_BUS_ADDRESS is not set")
+	}
+
+	for _, candidate := range strings.Split(address, ";") {
+		transport, params, _ := strings.Cut(candidate, ":")
+		if transport != "unix" {
+			continue
+		}
+		for _, param := range strings.Split(params, ",") {
+			key, value, _ := strings.Cut(param, "=")
+			switch key {
+			case "path":
+				return value, nil
+			case "abstract":
+				return "@" + value, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no usable session bus address in %q", address)
+}
+
+// dialSessionBus connects and authenticates to the session bus
+func dialSessionBus() (*conn, error) {
+	address, err := sessionBusAddress()
+	if err != nil {
+		return nil, err
+	}
+	c, err := net.Dial("unix", address)
+	if err != nil {
+		return nil, err
+	}
+
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := c.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		c.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(c)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		c.Close()
+		return nil, fmt.Errorf("dbus: authentication failed: %s", strings.TrimSpace(line))
+	}
+	if _, err := c.Write([]byte("BEGIN\r\n")); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &conn{conn: c, reader: reader}, nil
+}
+
+func (c *conn) send(m *message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serial++
+	m.serial = c.serial
+	_, err := c.conn.Write(m.marshal())
+	return err
+}
+
+// callBus calls a method of the bus itself and waits for its reply. Only
+// used before serving, when nothing else is read.
+func (c *conn) callBus(member string, args ...any) ([]any, error) {
+	b, sig := body(args...)
+	call := &message{
+		kind:        methodCall,
+		path:        "/org/freedesktop/DBus",
+		iface:       "org.freedesktop.DBus",
+		member:      member,
+		destination: "org.freedesktop.DBus",
+		signature:   sig,
+		body:        b,
+	}
+	if err := c.send(call); err != nil {
+		return nil, err
+	}
+
+	for {
+		reply, err := readMessage(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		if reply.replySerial != call.serial {
+			continue
+		}
+		if reply.kind == errorReply {
+			return nil, fmt.Errorf("dbus: %s failed: %s", member, reply.errorName)
+		}
+		return reply.args()
+	}
+}
+
+// reply answers a method call, unless the caller doesn't want an answer
+func (c *conn) reply(call *message, args ...any) error {
+	if call.flags&noReplyExpected != 0 {
+		return nil
+	}
+	b, sig := body(args...)
+	return c.send(&message{kind: methodReturn, replySerial: call.serial, destination: call.sender, signature: sig, body: b})
+}
+
+// fail answers a method call with an error
+func (c *conn) fail(call *message, name, text string) error {
+	if call.flags&noReplyExpected != 0 {
+		return nil
+	}
+	b, sig := body(text)
+	return c.send(&message{kind: errorReply, errorName: name, replySerial: call.serial, destination: call.sender, signature: sig, body: b})
+}
+
+// emit sends a signal from an object
+func (c *conn) emit(path, iface, member string, args ...any) error {
+	b, sig := body(args...)
+	return c.send(&message{kind: signal, path: path, iface: iface, member: member, signature: sig, body: b})
+}
+
+func (c *conn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,489 @@
+// Package fakeplayer is a simulated MPRIS media player. It cycles through
+// made-up tracks with generated artwork, so the media pipeline can be tested
+// end to end and demoed on machines that play nothing, like CI runners.
+package fakeplayer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BusName is the name the fake player owns; playerctl lists it as "blitz_fake"
+const BusName = "org.mpris.MediaPlayer2.blitz_fake"
+
+const (
+	mprisPath        = "/org/mpris/MediaPlayer2"
+	rootInterface    = "org.mpris.MediaPlayer2"
+	playerInterface  = "org.mpris.MediaPlayer2.Player"
+	propsInterface   = "org.freedesktop.DBus.Properties"
+	unknownMethod    = "org.freedesktop.DBus.Error.UnknownMethod"
+	unknownProperty  = "org.freedesktop.DBus.Error.UnknownProperty"
+	invalidArgs      = "org.freedesktop.DBus.Error.InvalidArgs"
+	propertyReadOnly = "org.freedesktop.DBus.Error.PropertyReadOnly"
+)
+
+type track struct {
+	title  string
+	artist string
+	album  string
+	length time.Duration
+	color  color.RGBA // Of the generated artwork
+}
+
+// tracks are short, so a demo goes through a few of them
+var tracks = []track{
+	{"Midnight Compile", "The Race Conditions", "Undefined Behaviour", 74 * time.Second, color.RGBA{0x3b, 0x82, 0xf6, 0xff}},
+	{"Segfault Serenade", "Null Pointer Orchestra", "Core Dumped", 58 * time.Second, color.RGBA{0xef, 0x44, 0x44, 0xff}},
+	{"Garbage Collector Blues", "Heap & The Stack", "Mark and Sweep", 91 * time.Second, color.RGBA{0x22, 0xc5, 0x5e, 0xff}},
+	{"Merge Conflict", "Rebase", "Detached HEAD", 66 * time.Second, color.RGBA{0xa8, 0x55, 0xf7, 0xff}},
+	{"Off By One", "The Fencepost Errors", "Undefined Behaviour", 49 * time.Second, color.RGBA{0xf5, 0x9e, 0x0b, 0xff}},
+}
+
+// player is the state the fake player shows over MPRIS
+type player struct {
+	mu       sync.Mutex
+	bus      *conn
+	artwork  string // Directory of the generated covers
+	index    int
+	status   string        // Playing, Paused or Stopped
+	position time.Duration // As of since
+	since    time.Time
+	volume   float64
+	loop     string
+	shuffle  bool
+}
+
+// Start runs the fake player on the session bus until the connection to the
+// bus breaks
+func Start() error {
+	artwork, err := writeArtwork()
+	if err != nil {
+		return fmt.Errorf("fake player artwork: %w", err)
+	}
+
+	bus, err := dialSessionBus()
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+
+	if _, err := bus.callBus("Hello"); err != nil {
+		return err
+	}
+	// Don't queue for the name: a second fake player has nothing to add
+	reply, err := bus.callBus("RequestName", BusName, uint32(4))
+	if err != nil {
+		return err
+	}
+	if len(reply) != 1 || reply[0] != uint32(1) {
+		return fmt.Errorf("%s is already taken", BusName)
+	}
+
+	p := &player{bus: bus, artwork: artwork, status: "Playing", since: time.Now(), volume: 1, loop: "None"}
+	log.Printf("🎭 Fake player %s is playing %q", BusName, tracks[0].title)
+	go p.advance()
+	return p.serve()
+}
+
+// writeArtwork draws a cover for every track into a temporary directory
+func writeArtwork() (string, error) {
+	dir := filepath.Join(os.TempDir(), "blitz-fake-player")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	const size = 300
+	for i, t := range tracks {
+		img := image.NewRGBA(image.Rect(0, 0, size, size))
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				// Fade to black towards the bottom right corner
+				shade := 1 - float64(x+y)/(2*size)*0.8
+				img.Set(x, y, color.RGBA{
+					R: uint8(float64(t.color.R) * shade),
+					G: uint8(float64(t.color.G) * shade),
+					B: uint8(float64(t.color.B) * shade),
+					A: 0xff,
+				})
+			}
+		}
+
+		file, err := os.Create(artworkPath(dir, i))
+		if err != nil {
+			return "", err
+		}
+		err = png.Encode(file, img)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func artworkPath(dir string, index int) string {
+	return filepath.Join(dir, fmt.Sprintf("track-%d.png", index))
+}
+
+// serve answers the method calls sent to the player
+func (p *player) serve() error {
+	for {
+		m, err := readMessage(p.bus.reader)
+		if err != nil {
+			return err
+		}
+		if m.kind != methodCall {
+			continue
+		}
+		if err := p.handle(m); err != nil {
+			return err
+		}
+	}
+}
+
+// advance moves on to the next track when one ends
+func (p *player) advance() {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.mu.Lock()
+		ended := p.status == "Playing" && p.currentPosition() >= tracks[p.index].length
+		repeat := p.loop == "Track"
+		p.mu.Unlock()
+
+		switch {
+		case ended && repeat:
+			p.seek(0)
+		case ended:
+			p.skip(1)
+		}
+	}
+}
+
+func (p *player) handle(m *message) error {
+	if m.path != mprisPath {
+		return p.bus.fail(m, unknownMethod, "No such object "+m.path)
+	}
+	args, err := m.args()
+	if err != nil {
+		return p.bus.fail(m, invalidArgs, err.Error())
+	}
+
+	switch m.iface + "." + m.member {
+	case "org.freedesktop.DBus.Introspectable.Introspect":
+		return p.bus.reply(m, introspection)
+	case "org.freedesktop.DBus.Peer.Ping":
+		return p.bus.reply(m)
+	case propsInterface + ".Get":
+		if len(args) != 2 {
+			return p.bus.fail(m, invalidArgs, "Get takes an interface and a property")
+		}
+		iface, _ := args[0].(string)
+		name, _ := args[1].(string)
+		value, ok := p.properties(iface)[name]
+		if !ok {
+			return p.bus.fail(m, unknownProperty, "No such property "+name)
+		}
+		return p.bus.reply(m, variant{value})
+	case propsInterface + ".GetAll":
+		if len(args) != 1 {
+			return p.bus.fail(m, invalidArgs, "GetAll takes an interface")
+		}
+		iface, _ := args[0].(string)
+		return p.bus.reply(m, p.properties(iface))
+	case propsInterface + ".Set":
+		if len(args) != 3 {
+			return p.bus.fail(m, invalidArgs, "Set takes an interface, a property and a value")
+		}
+		name, _ := args[1].(string)
+		if errName, text := p.set(name, args[2]); errName != "" {
+			return p.bus.fail(m, errName, text)
+		}
+		return p.bus.reply(m)
+	case rootInterface + ".Raise", rootInterface + ".Quit":
+		return p.bus.reply(m)
+	case playerInterface + ".Play":
+		p.setStatus("Playing")
+	case playerInterface + ".Pause":
+		p.setStatus("Paused")
+	case playerInterface + ".Stop":
+		p.setStatus("Stopped")
+	case playerInterface + ".PlayPause":
+		p.mu.Lock()
+		status := "Playing"
+		if p.status == "Playing" {
+			status = "Paused"
+		}
+		p.mu.Unlock()
+		p.setStatus(status)
+	case playerInterface + ".Next":
+		p.skip(1)
+	case playerInterface + ".Previous":
+		p.skip(-1)
+	case playerInterface + ".Seek":
+		if offset, ok := argAt[int64](args, 0); ok {
+			p.mu.Lock()
+			position := p.currentPosition() + time.Duration(offset)*time.Microsecond
+			p.mu.Unlock()
+			p.seek(position)
+		}
+	case playerInterface + ".SetPosition":
+		trackID, _ := argAt[objectPath](args, 0)
+		if position, ok := argAt[int64](args, 1); ok && trackID == p.trackID() {
+			p.seek(time.Duration(position) * time.Microsecond)
+		}
+	case playerInterface + ".OpenUri":
+		return p.bus.fail(m, "org.mpris.MediaPlayer2.Player.Error.NotSupported", "The fake player only plays its own tracks")
+	default:
+		return p.bus.fail(m, unknownMethod, "No such method "+m.member)
+	}
+	return p.bus.reply(m)
+}
+
+func argAt[T any](args []any, i int) (T, bool) {
+	var zero T
+	if i >= len(args) {
+		return zero, false
+	}
+	v, ok := args[i].(T)
+	return v, ok
+}
+
+// currentPosition is where playback is; p.mu must be held
+func (p *player) currentPosition() time.Duration {
+	if p.status != "Playing" {
+		return p.position
+	}
+	return p.position + time.Since(p.since)
+}
+
+func (p *player) trackID() objectPath {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return trackPath(p.index)
+}
+
+func trackPath(index int) objectPath {
+	return objectPath(fmt.Sprintf("/org/blitz/fake/track/%d", index))
+}
+
+// metadata describes the current track; p.mu must be held
+func (p *player) metadata() map[string]any {
+	t := tracks[p.index]
+	return map[string]any{
+		"mpris:trackid": trackPath(p.index),
+		"mpris:length":  t.length.Microseconds(),
+		"mpris:artUrl":  "file://" + artworkPath(p.artwork, p.index),
+		"xesam:title":   t.title,
+		"xesam:artist":  []string{t.artist},
+		"xesam:album":   t.album,
+	}
+}
+
+// properties returns the properties of an interface
+func (p *player) properties(iface string) map[string]any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch iface {
+	case rootInterface:
+		return map[string]any{
+			"CanQuit":             false,
+			"CanRaise":            false,
+			"HasTrackList":        false,
+			"Identity":            "Blitz Fake Player",
+			"SupportedUriSchemes": []string{},
+			"SupportedMimeTypes":  []string{},
+		}
+	case playerInterface:
+		return map[string]any{
+			"PlaybackStatus": p.status,
+			"LoopStatus":     p.loop,
+			"Shuffle":        p.shuffle,
+			"Rate":           1.0,
+			"MinimumRate":    1.0,
+			"MaximumRate":    1.0,
+			"Metadata":       p.metadata(),
+			"Volume":         p.volume,
+			"Position":       p.currentPosition().Microseconds(),
+			"CanGoNext":      true,
+			"CanGoPrevious":  true,
+			"CanPlay":        true,
+			"CanPause":       true,
+			"CanSeek":        true,
+			"CanControl":     true,
+		}
+	}
+	return map[string]any{}
+}
+
+// set changes a writable property and returns the D-Bus error if it can't
+func (p *player) set(name string, value any) (string, string) {
+	p.mu.Lock()
+	switch name {
+	case "Volume":
+		volume, ok := value.(float64)
+		if !ok {
+			p.mu.Unlock()
+			return invalidArgs, "Volume is a double"
+		}
+		p.volume = min(max(volume, 0), 1)
+		value = p.volume
+	case "LoopStatus":
+		loop, ok := value.(string)
+		if !ok || (loop != "None" && loop != "Track" && loop != "Playlist") {
+			p.mu.Unlock()
+			return invalidArgs, "LoopStatus is None, Track or Playlist"
+		}
+		p.loop = loop
+	case "Shuffle":
+		shuffle, ok := value.(bool)
+		if !ok {
+			p.mu.Unlock()
+			return invalidArgs, "Shuffle is a boolean"
+		}
+		p.shuffle = shuffle
+	default:
+		p.mu.Unlock()
+		return propertyReadOnly, name + " can't be set"
+	}
+	p.mu.Unlock()
+
+	p.changed(map[string]any{name: value})
+	return "", ""
+}
+
+func (p *player) setStatus(status string) {
+	p.mu.Lock()
+	if p.status == status {
+		p.mu.Unlock()
+		return
+	}
+	p.position = p.currentPosition()
+	if status == "Stopped" {
+		p.position = 0
+	}
+	p.since = time.Now()
+	p.status = status
+	p.mu.Unlock()
+
+	p.changed(map[string]any{"PlaybackStatus": status})
+}
+
+// skip moves by a number of tracks, wrapping around the list
+func (p *player) skip(by int) {
+	p.mu.Lock()
+	p.index = (p.index + by + len(tracks)) % len(tracks)
+	p.position = 0
+	p.since = time.Now()
+	if p.status == "Stopped" {
+		p.status = "Playing"
+	}
+	changed := map[string]any{"Metadata": p.metadata(), "PlaybackStatus": p.status}
+	p.mu.Unlock()
+
+	p.changed(changed)
+}
+
+func (p *player) seek(position time.Duration) {
+	p.mu.Lock()
+	length := tracks[p.index].length
+	p.position = min(max(position, 0), length)
+	p.since = time.Now()
+	position = p.position
+	p.mu.Unlock()
+
+	if err := p.bus.emit(mprisPath, playerInterface, "Seeked", position.Microseconds()); err != nil {
+		log.Println("❌ Fake player:", err)
+	}
+}
+
+// changed tells listeners like playerctl about changed player properties
+func (p *player) changed(properties map[string]any) {
+	if err := p.bus.emit(mprisPath, propsInterface, "PropertiesChanged", playerInterface, properties, []string{}); err != nil {
+		log.Println("❌ Fake player:", err)
+	}
+}
+
+const introspection = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+<node>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect"><arg name="xml" type="s" direction="out"/></method>
+  </interface>
+  <interface name="org.freedesktop.DBus.Peer">
+    <method name="Ping"/>
+  </interface>
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="properties" type="a{sv}" direction="out"/>
+    </method>
+    <method name="Set">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="in"/>
+    </method>
+    <signal name="PropertiesChanged">
+      <arg name="interface" type="s"/>
+      <arg name="changed" type="a{sv}"/>
+      <arg name="invalidated" type="as"/>
+    </signal>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2">
+    <method name="Raise"/>
+    <method name="Quit"/>
+    <property name="CanQuit" type="b" access="read"/>
+    <property name="CanRaise" type="b" access="read"/>
+    <property name="HasTrackList" type="b" access="read"/>
+    <property name="Identity" type="s" access="read"/>
+    <property name="SupportedUriSchemes" type="as" access="read"/>
+    <property name="SupportedMimeTypes" type="as" access="read"/>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2.Player">
+    <method name="Next"/>
+    <method name="Previous"/>
+    <method name="Pause"/>
+    <method name="PlayPause"/>
+    <method name="Stop"/>
+    <method name="Play"/>
+    <method name="Seek"><arg name="Offset" type="x" direction="in"/></method>
+    <method name="SetPosition">
+      <arg name="TrackId" type="o" direction="in"/>
+      <arg name="Position" type="x" direction="in"/>
+    </method>
+    <method name="OpenUri"><arg name="Uri" type="s" direction="in"/></method>
+    <signal name="Seeked"><arg name="Position" type="x"/></signal>
+    <property name="PlaybackStatus" type="s" access="read"/>
+    <property name="LoopStatus" type="s" access="readwrite"/>
+    <property name="Rate" type="d" access="read"/>
+    <property name="Shuffle" type="b" access="readwrite"/>
+    <property name="Metadata" type="a{sv}" access="read"/>
+    <property name="Volume" type="d" access="readwrite"/>
+    <property name="Position" type="x" access="read"/>
+    <property name="MinimumRate" type="d" access="read"/>
+    <property name="MaximumRate" type="d" access="read"/>
+    <property name="CanGoNext" type="b" access="read"/>
+    <property name="CanGoPrevious" type="b" access="read"/>
+    <property name="CanPlay" type="b" access="read"/>
+    <property name="CanPause" type="b" access="read"/>
+    <property name="CanSeek" type="b" access="read"/>
+    <property name="CanControl" type="b" access="read"/>
+  </interface>
+</node>
+`
@@ -0,0 +1,75 @@
+// Package i18n translates the strings Blitz shows to users, such as error
+// messages, into the configured locale
+package i18n
+
+import (
+	"Blitz/config"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// locales holds one catalog per language, mapping the English text to its
+// translation. Missing entries stay English.
+//
+//go:embed locales/*.json
+var locales embed.FS
+
+var (
+	catalog     map[string]string
+	catalogOnce sync.Once
+)
+
+// Locale returns the language messages are shown in: the locale setting,
+// else the language of $LC_ALL, $LC_MESSAGES or $LANG, else "en"
+func Locale() string {
+	locale := config.Get().Locale
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale != "" {
+			break
+		}
+		locale = os.Getenv(name)
+	}
+
+	// de_DE.UTF-8 -> de
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "_")
+	locale = strings.ToLower(locale)
+	if locale == "" || locale == "c" || locale == "posix" {
+		return "en"
+	}
+	return locale
+}
+
+// T translates an English format string and formats it with args like
+// fmt.Sprintf
+func T(format string, args ...any) string {
+	catalogOnce.Do(loadCatalog)
+	if translated, ok := catalog[format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+func loadCatalog() {
+	locale := Locale()
+	if locale == "en" {
+		return
+	}
+
+	data, err := locales.ReadFile("locales/" + locale + ".json")
+	if err != nil {
+		log.Printf("No translations for locale %q, using English", locale)
+		return
+	}
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		log.Printf("Invalid translations for locale %q: %v", locale, err)
+	}
+}
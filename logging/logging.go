@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"Blitz/config"
+	"Blitz/store"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// recentSize is how many log lines are kept in memory for logs_tail
+	recentSize = 500
+	// maxLineLength truncates the kept lines; some log whole messages,
+	// inline artwork included
+	maxLineLength = 2000
+)
+
+var (
+	recent      []string // oldest first
+	subscribers = map[chan string]struct{}{}
+	mu          sync.Mutex
+)
+
+// Setup sends the standard logger to stderr, the in-memory buffer behind
+// Tail and, when enabled in the config, a rotated file on disk
+func Setup() {
+	writers := []io.Writer{os.Stderr, writerFunc(record)}
+
+	settings := config.Get().Logging
+	if settings.File {
+		file, err := openRotating(Dir(), settings)
+		if err != nil {
+			log.Println("❌ File logging disabled:", err)
+		} else {
+			writers = append(writers, file)
+		}
+	}
+
+	log.SetOutput(io.MultiWriter(writers...))
+	if settings.File {
+		log.Println("Logging to", filepath.Join(Dir(), logFileName))
+	}
+}
+
+// Dir returns the log directory: logging.dir from the config, else logs/ in
+// the data directory
+func Dir() string {
+	if dir := config.Get().Logging.Dir; dir != "" {
+		return dir
+	}
+	return filepath.Join(store.DataDir(), "logs")
+}
+
+// Tail returns up to n of the most recent log lines, oldest first
+func Tail(n int) []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if n <= 0 || n > len(recent) {
+		n = len(recent)
+	}
+	return append([]string(nil), recent[len(recent)-n:]...)
+}
+
+// Subscribe returns a channel receiving every new log line until cancel is
+// called. Lines are dropped while the subscriber falls behind, so a slow
+// reader never blocks logging.
+func Subscribe() (<-chan string, func()) {
+	lines := make(chan string, 64)
+
+	mu.Lock()
+	subscribers[lines] = struct{}{}
+	mu.Unlock()
+
+	var once sync.Once
+	return lines, func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(subscribers, lines)
+			mu.Unlock()
+			close(lines)
+		})
+	}
+}
+
+// record keeps the lines of one log write and hands them to the subscribers
+func record(p []byte) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if len(line) > maxLineLength {
+			line = line[:maxLineLength] + "…"
+		}
+		recent = append(recent, line)
+		for lines := range subscribers {
+			select {
+			case lines <- line:
+			default:
+			}
+		}
+	}
+	if len(recent) > recentSize {
+		recent = append([]string(nil), recent[len(recent)-recentSize:]...)
+	}
+}
+
+type writerFunc func(p []byte)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	f(p)
+	return len(p), nil
+}
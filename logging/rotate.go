@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"Blitz/config"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// logFileName is the active log file; rotated ones get a .1, .2, ... suffix
+const logFileName = "blitz.log"
+
+// rotatingFile is a log file that is rotated once it grows too large or too old
+type rotatingFile struct {
+	dir      string
+	maxSize  int64
+	maxAge   time.Duration
+	maxFiles int
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func openRotating(dir string, settings config.Logging) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+
+	r := &rotatingFile{
+		dir:      dir,
+		maxSize:  int64(settings.MaxSizeMB) * 1024 * 1024,
+		maxAge:   time.Duration(settings.MaxAgeDays) * 24 * time.Hour,
+		maxFiles: settings.MaxFiles,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.due(len(p)) {
+		if err := r.rotate(); err != nil {
+			// Keep writing to the old file rather than losing lines
+			fmt.Fprintln(os.Stderr, "Failed to rotate log:", err)
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file must be rotated before writing n more bytes
+func (r *rotatingFile) due(n int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.maxSize > 0 && r.size+int64(n) > r.maxSize {
+		return true
+	}
+	return r.maxAge > 0 && time.Since(r.openedAt) > r.maxAge
+}
+
+// open appends to the active file, continuing its size and age
+func (r *rotatingFile) open() error {
+	path := filepath.Join(r.dir, logFileName)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = time.Now()
+	if r.size > 0 {
+		r.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+// rotate shifts blitz.log to blitz.log.1, blitz.log.1 to blitz.log.2 and so
+// on, dropping the files beyond maxFiles, then starts a new blitz.log
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+
+	base := filepath.Join(r.dir, logFileName)
+	os.Remove(fmt.Sprintf("%s.%d", base, r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", base, i), fmt.Sprintf("%s.%d", base, i+1))
+	}
+	if r.maxFiles > 0 {
+		os.Rename(base, base+".1")
+	} else {
+		os.Remove(base)
+	}
+
+	return r.open()
+}
@@ -1,29 +1,132 @@
 package main
 
 import (
+	"Blitz/config"
+	"Blitz/fakeplayer"
+	"Blitz/logging"
+	"Blitz/store"
+	"Blitz/utils"
+	"Blitz/utils/poller"
 	"Blitz/utils/websocket"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
+	withFakePlayer := flag.Bool("with-fake-player", false, "run a simulated MPRIS player, for end-to-end tests and demos")
+	flag.Parse()
+
 	fmt.Println("Hello Blitz Server ...")
+	logging.Setup()
+	build := utils.GetBuildInfo()
+	log.Printf("Blitz %s (%s, built %s)", build.Version, build.Commit, build.BuildDate)
+	if *withFakePlayer {
+		go func() {
+			if err := fakeplayer.Start(); err != nil {
+				log.Println("❌ Fake player stopped:", err)
+			}
+		}()
+	}
+	store.PrepareCache()
+	utils.CheckModules()
 
 	// Setup HTTP routes
 	http.HandleFunc("/ws", websocket.Handle)
+	http.HandleFunc("/artwork/", utils.HandleArtwork)
+	http.HandleFunc("/spotify/auth", utils.HandleSpotifyAuth)
+	http.HandleFunc("/spotify/callback", utils.HandleSpotifyCallback)
+	http.HandleFunc("/api/devices", websocket.HandleDevices)
+	http.HandleFunc("/api/devices/", websocket.HandleDevices)
+	http.HandleFunc("/api/clients", websocket.RequireScope(websocket.ScopeAdmin, websocket.HandleClients))
+	http.HandleFunc("/api/clients/", websocket.RequireScope(websocket.ScopeAdmin, websocket.HandleClients))
+	http.HandleFunc("/api/stats", websocket.RequireScope(websocket.ScopeControl, utils.HandleStats))
+	http.HandleFunc("/api/stats/", websocket.RequireScope(websocket.ScopeControl, utils.HandleStats))
+	http.HandleFunc("/api/export", websocket.RequireScope(websocket.ScopeAdmin, websocket.HandleBackup))
+	http.HandleFunc("/api/import", websocket.RequireScope(websocket.ScopeAdmin, websocket.HandleBackup))
+	websocket.RegisterCommandRoutes(http.DefaultServeMux)
+	http.HandleFunc("/status", websocket.RequireScope(websocket.ScopeControl, utils.HandleStatus))
+	http.HandleFunc("/devices", serveDevices)
 	http.HandleFunc("/", serveHome)
 
-	// Start the server (this blocks forever)
-	fmt.Println("Starting server on http://0.0.0.0:8765")
-	fmt.Println("WebSocket endpoint: ws://localhost:8765/ws")
+	// Forward poller updates to every connected client
+	go websocket.StartBroadcaster()
+	websocket.StartUpstreams()
+	websocket.StartPlugins()
+	go websocket.StartRelay()
+	go websocket.StartTCP()
+	go poller.Handle()
+	go poller.HandleZones()
+	go poller.HandleNetwork()
+	go poller.HandleLatency()
+	go poller.HandlePeripherals()
+	go poller.HandleGamepads()
+	go poller.HandleSensors()
+	go poller.HandleScreen()
+	go poller.HandleSpotifyAuth()
+	go poller.HandleHost()
+	go poller.HandleClock()
+	go poller.HandleCompact()
+	go poller.HandleAlarms()
+	go poller.HandleGoroutines()
+	go utils.StartAutoDucking()
+	go utils.StartAutoPause()
+
+	server := &http.Server{Addr: "0.0.0.0:8765"}
+	go shutdownOnSignal(server)
+
+	tls := config.Get().TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		log.Fatal("tls.certFile and tls.keyFile must be set together")
+	}
+	scheme, wsScheme := "http", "ws"
+	if tls.CertFile != "" {
+		scheme, wsScheme = "https", "wss"
+	}
+
+	// Start the server (this blocks until shutdown)
+	fmt.Printf("Starting server on %s://0.0.0.0:8765\n", scheme)
+	fmt.Printf("WebSocket endpoint: %s://localhost:8765/ws\n", wsScheme)
 	fmt.Println("Press Ctrl+C to stop the server")
 
-	if err := http.ListenAndServe("0.0.0.0:8765", nil); err != nil {
+	var err error
+	if tls.CertFile != "" {
+		err = server.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal("Server error:", err)
 	}
 }
 
+// shutdownOnSignal stops polling, saves the playback positions, delivers
+// what is queued for the clients, tells them when to reconnect and stops the
+// server on Ctrl+C or when systemd stops Blitz
+func shutdownOnSignal(server *http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	log.Println("Shutting down ...")
+	poller.Stop()
+	utils.FlushPlaybackPositions()
+	websocket.DisconnectAll()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("Shutdown error:", err)
+	}
+}
+
 func serveHome(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.Error(w, "Not found", http.StatusNotFound)
@@ -35,3 +138,11 @@ func serveHome(w http.ResponseWriter, r *http.Request) {
 	}
 	http.ServeFile(w, r, "web/index.html")
 }
+
+func serveDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.ServeFile(w, r, "web/devices.html")
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"Blitz/i18n"
+	"errors"
+)
+
+// ErrorCode classifies a failed command for clients. The codes are stable;
+// the accompanying messages are meant to be shown to end users.
+type ErrorCode string
+
+const (
+	ErrPlayerNotFound         ErrorCode = "player_not_found"        // No (matching) media player or Spotify device
+	ErrExternalToolMissing    ErrorCode = "external_tool_missing"   // playerctl, pactl, catt, ... is not installed
+	ErrSpotifyUnauthenticated ErrorCode = "spotify_unauthenticated" // Spotify is not configured or not logged in
+	ErrRateLimited            ErrorCode = "rate_limited"            // An upstream API asked us to slow down
+	ErrInvalidParams          ErrorCode = "invalid_params"          // A parameter is missing or has a bad value
+	ErrUnknownCommand         ErrorCode = "unknown_command"         // The command doesn't exist
+	ErrUnauthorized           ErrorCode = "unauthorized"            // The client hasn't authenticated
+	ErrForbidden              ErrorCode = "forbidden"               // The client's token lacks the scope the command needs
+	ErrTimeout                ErrorCode = "timeout"                 // The command didn't finish in time
+	ErrModuleDisabled         ErrorCode = "module_disabled"         // The command belongs to a module turned off in the config
+	ErrUnavailable            ErrorCode = "unavailable"             // Blitz is shutting down or has too many clients; reconnect later
+	ErrCommandFailed          ErrorCode = "command_failed"          // Anything else
+)
+
+// Error is an error with a code and a message safe to show to end users.
+// The underlying cause is kept for logging but never sent to clients.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// RetryAfter is how many seconds the client should wait before trying
+	// again, for errors that go away by themselves; 0 means no hint
+	RetryAfter int   `json:"retry_after,omitempty"`
+	Cause      error `json:"-"`
+	// Param names the parameter that is missing or invalid, for
+	// invalid_params errors about a single parameter
+	Param string `json:"param,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Cause }
+
+// NewError creates an Error with an optional underlying cause
+func NewError(code ErrorCode, message string, cause error) *Error {
+	return &Error{Code: code, Message: message, Cause: cause}
+}
+
+// NewParamError creates an invalid_params Error about a single parameter,
+// which clients can point at
+func NewParamError(param, message string) *Error {
+	return &Error{Code: ErrInvalidParams, Message: message, Param: param}
+}
+
+// AsError returns the Error inside err, or a command_failed Error with a
+// generic message when err isn't one. Such errors may carry the output of
+// external tools or paths, so their text is only kept as the Cause for the
+// server log.
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Code: ErrCommandFailed, Message: i18n.T("command failed"), Cause: err}
+}
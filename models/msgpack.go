@@ -0,0 +1,416 @@
+package models
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// MarshalMsgpack encodes a message as MessagePack for clients that asked for
+// the binary encoding, with the field names, omissions and key order
+// encoding/json would give it. Base64 data URIs, like the artwork, and byte
+// slices go out as binary, saving the third base64 adds.
+func MarshalMsgpack(v any) ([]byte, error) {
+	out, err := appendMsgpackReflect(nil, reflect.ValueOf(v))
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	return out, nil
+}
+
+func appendMsgpackReflect(out []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(out, 0xc0), nil
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return append(out, 0xc0), nil
+	}
+
+	// Types encoding themselves, like time.Time, are encoded as they would be
+	// in JSON
+	marshaler := v
+	if v.Kind() != reflect.Pointer && v.CanAddr() {
+		marshaler = v.Addr()
+	}
+	switch {
+	case marshaler.Type().Implements(jsonMarshalerType):
+		data, err := marshaler.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		packed, err := MsgpackFromJSON(data)
+		return append(out, packed...), err
+	case marshaler.Type().Implements(textMarshalerType):
+		text, err := marshaler.Interface().(encoding.TextMarshaler).MarshalText()
+		return appendMsgpackString(out, string(text)), err
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return appendMsgpackReflect(out, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(out, 0xc3), nil
+		}
+		return append(out, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(out, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendMsgpackUint(out, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return appendMsgpackFloat(out, v.Float()), nil
+	case reflect.String:
+		return appendMsgpackText(out, v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(out, 0xc0), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendMsgpackBinary(out, v.Bytes()), nil
+		}
+		fallthrough
+	case reflect.Array:
+		out = appendMsgpackHeader(out, v.Len(), 0x90, 0xdc)
+		for i := range v.Len() {
+			var err error
+			if out, err = appendMsgpackReflect(out, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case reflect.Map:
+		return appendMsgpackMap(out, v)
+	case reflect.Struct:
+		return appendMsgpackStruct(out, v)
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// appendMsgpackMap writes a map with its keys sorted, like encoding/json
+func appendMsgpackMap(out []byte, v reflect.Value) ([]byte, error) {
+	if v.IsNil() {
+		return append(out, 0xc0), nil
+	}
+
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		key, err := msgpackMapKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.key, b.key) })
+
+	out = appendMsgpackHeader(out, len(entries), 0x80, 0xde)
+	for _, e := range entries {
+		var err error
+		out = appendMsgpackString(out, e.key)
+		if out, err = appendMsgpackReflect(out, e.value); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func msgpackMapKey(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+	if key.Type().Implements(textMarshalerType) {
+		text, err := key.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %s", key.Type())
+}
+
+func appendMsgpackStruct(out []byte, v reflect.Value) ([]byte, error) {
+	// Omitted fields aren't known until they are reached, so the fields go
+	// first into a buffer of their own
+	var elements []byte
+	count := 0
+	for _, field := range msgpackFieldsOf(v.Type()) {
+		value, err := v.FieldByIndexErr(field.index)
+		if err != nil {
+			// A field of a nil embedded struct pointer
+			continue
+		}
+		if field.omitEmpty && msgpackEmpty(value) {
+			continue
+		}
+		elements = appendMsgpackString(elements, field.name)
+		if elements, err = appendMsgpackReflect(elements, value); err != nil {
+			return nil, err
+		}
+		count++
+	}
+	out = appendMsgpackHeader(out, count, 0x80, 0xde)
+	return append(out, elements...), nil
+}
+
+// msgpackEmpty reports whether omitempty leaves a value out, which it does
+// for the same values as encoding/json
+func msgpackEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Struct:
+		return false
+	}
+	return v.IsZero()
+}
+
+// msgpackField is a struct field as encoding/json sees it: named by its json
+// tag, and with the fields of embedded structs promoted
+type msgpackField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// msgpackFields caches the fields of the struct types encoded so far
+var msgpackFields sync.Map // reflect.Type -> []msgpackField
+
+func msgpackFieldsOf(t reflect.Type) []msgpackField {
+	if fields, ok := msgpackFields.Load(t); ok {
+		return fields.([]msgpackField)
+	}
+
+	var fields []msgpackField
+	collectMsgpackFields(t, nil, &fields)
+	// A field shadows the ones of the same name promoted from deeper down
+	fields = slices.DeleteFunc(fields, func(f msgpackField) bool {
+		return slices.ContainsFunc(fields, func(other msgpackField) bool {
+			return other.name == f.name && len(other.index) < len(f.index)
+		})
+	})
+	msgpackFields.Store(t, fields)
+	return fields
+}
+
+func collectMsgpackFields(t reflect.Type, index []int, fields *[]msgpackField) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldIndex := append(slices.Clone(index), i)
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				collectMsgpackFields(embedded, fieldIndex, fields)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		*fields = append(*fields, msgpackField{
+			name:      name,
+			index:     fieldIndex,
+			omitEmpty: slices.Contains(strings.Split(options, ","), "omitempty"),
+		})
+	}
+}
+
+// MsgpackFromJSON re-encodes a JSON message as MessagePack, for replies that
+// only exist as JSON, like the JSON-RPC ones. Going through JSON keeps the
+// field names and the order of object keys.
+func MsgpackFromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	out, err := appendMsgpackValue(make([]byte, 0, len(data)), dec)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	return out, nil
+}
+
+func appendMsgpackValue(out []byte, dec *json.Decoder) ([]byte, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		// Containers start with their length, so the elements go first into
+		// a buffer of their own
+		var elements []byte
+		count := 0
+		for dec.More() {
+			if value == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				elements = appendMsgpackString(elements, key.(string))
+			}
+			if elements, err = appendMsgpackValue(elements, dec); err != nil {
+				return nil, err
+			}
+			count++
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		if value == '{' {
+			out = appendMsgpackHeader(out, count, 0x80, 0xde)
+		} else {
+			out = appendMsgpackHeader(out, count, 0x90, 0xdc)
+		}
+		return append(out, elements...), nil
+	case string:
+		return appendMsgpackText(out, value), nil
+	case json.Number:
+		return appendMsgpackNumber(out, value), nil
+	case bool:
+		if value {
+			return append(out, 0xc3), nil
+		}
+		return append(out, 0xc2), nil
+	case nil:
+		return append(out, 0xc0), nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", token)
+}
+
+// appendMsgpackHeader writes the length of a map or array: fixed is the
+// type byte of up to 15 elements, wide the one with a 16-bit length; the
+// 32-bit length's type byte follows it
+func appendMsgpackHeader(out []byte, count int, fixed, wide byte) []byte {
+	switch {
+	case count < 16:
+		return append(out, fixed|byte(count))
+	case count <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(out, wide), uint16(count))
+	}
+	return binary.BigEndian.AppendUint32(append(out, wide+1), uint32(count))
+}
+
+func appendMsgpackString(out []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		out = append(out, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		out = append(out, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		out = binary.BigEndian.AppendUint16(append(out, 0xda), uint16(n))
+	default:
+		out = binary.BigEndian.AppendUint32(append(out, 0xdb), uint32(n))
+	}
+	return append(out, s...)
+}
+
+// appendMsgpackText writes a string, or the bytes of a base64 data URI,
+// like the artwork, as binary. Clients tell the image type from its first
+// bytes.
+func appendMsgpackText(out []byte, s string) []byte {
+	if rest, ok := strings.CutPrefix(s, "data:"); ok {
+		if _, encoded, ok := strings.Cut(rest, ";base64,"); ok {
+			if data, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+				return appendMsgpackBinary(out, data)
+			}
+		}
+	}
+	return appendMsgpackString(out, s)
+}
+
+func appendMsgpackBinary(out []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		out = append(out, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		out = binary.BigEndian.AppendUint16(append(out, 0xc5), uint16(n))
+	default:
+		out = binary.BigEndian.AppendUint32(append(out, 0xc6), uint32(n))
+	}
+	return append(out, data...)
+}
+
+// appendMsgpackNumber writes integers in the fewest bytes and everything
+// else as a float64
+func appendMsgpackNumber(out []byte, number json.Number) []byte {
+	if i, err := strconv.ParseInt(string(number), 10, 64); err == nil {
+		return appendMsgpackInt(out, i)
+	}
+	if u, err := strconv.ParseUint(string(number), 10, 64); err == nil {
+		return appendMsgpackUint(out, u)
+	}
+
+	f, _ := number.Float64()
+	return binary.BigEndian.AppendUint64(append(out, 0xcb), math.Float64bits(f))
+}
+
+// appendMsgpackFloat writes whole numbers as integers, as JSON readers see
+// them, and everything else as a float64
+func appendMsgpackFloat(out []byte, f float64) []byte {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return appendMsgpackInt(out, int64(f))
+	}
+	return binary.BigEndian.AppendUint64(append(out, 0xcb), math.Float64bits(f))
+}
+
+func appendMsgpackUint(out []byte, u uint64) []byte {
+	if u <= math.MaxInt64 {
+		return appendMsgpackInt(out, int64(u))
+	}
+	return binary.BigEndian.AppendUint64(append(out, 0xcf), u)
+}
+
+// appendMsgpackInt writes an integer in the fewest bytes
+func appendMsgpackInt(out []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		return append(out, byte(i))
+	case i >= -32 && i < 0:
+		return append(out, byte(int8(i)))
+	case i >= 0 && i <= math.MaxUint8:
+		return append(out, 0xcc, byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(out, 0xcd), uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(out, 0xce), uint32(i))
+	case i >= math.MinInt8 && i < 0:
+		return append(out, 0xd0, byte(int8(i)))
+	case i >= math.MinInt16 && i < 0:
+		return binary.BigEndian.AppendUint16(append(out, 0xd1), uint16(int16(i)))
+	case i >= math.MinInt32 && i < 0:
+		return binary.BigEndian.AppendUint32(append(out, 0xd2), uint32(int32(i)))
+	}
+	return binary.BigEndian.AppendUint64(append(out, 0xd3), uint64(i))
+}
@@ -1,8 +1,95 @@
 package models
 
+import "time"
 
+// Envelope types
+const (
+	TypeEvent    = "event"    // A broadcast on a topic
+	TypeResponse = "response" // The result of a command
+	TypeError    = "error"    // A failed command
+)
+
+// ServerResponse is the envelope of every message sent to clients. Type,
+// Topic, Version, Seq and TS describe the message; Status and Message are
+// kept for clients written before the envelope existed.
 type ServerResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
-	Data    any    `json:"data,omitempty"`
-}
\ No newline at end of file
+	Type    string    `json:"type,omitempty"`
+	Topic   string    `json:"topic,omitempty"` // Broadcast topic, or the command answered
+	Version int       `json:"v,omitempty"`     // Version of the payload's shape
+	Seq     uint64    `json:"seq,omitempty"`   // Increases with every broadcast, so clients notice dropped ones
+	TS      int64     `json:"ts,omitempty"`    // Unix milliseconds when the message was sent
+	Status  string    `json:"status"`
+	Message string    `json:"message"`
+	Code    ErrorCode `json:"code,omitempty"` // Set on errors
+	// RetryAfter is the seconds to wait before retrying or reconnecting
+	RetryAfter int `json:"retry_after,omitempty"`
+	Data       any `json:"data,omitempty"`
+	ID         any `json:"id,omitempty"` // Echoes the id of the command this answers
+	// Formatted holds readable versions of fields of Data, for clients that
+	// sent formatting preferences
+	Formatted map[string]string `json:"formatted,omitempty"`
+	// Param names the parameter an invalid_params error is about
+	Param string `json:"param,omitempty"`
+	// Delta marks a Data that holds only the fields changed since the last
+	// message on Topic, for clients saving bandwidth
+	Delta bool `json:"delta,omitempty"`
+}
+
+// Payload is the typed data of a broadcast topic. Version is raised whenever
+// the shape changes incompatibly, so clients can tell the shapes apart.
+type Payload interface {
+	Topic() string
+	Version() int
+}
+
+// NewEvent wraps a payload for broadcasting on its topic
+func NewEvent(payload Payload) ServerResponse {
+	return ServerResponse{
+		Type:    TypeEvent,
+		Topic:   payload.Topic(),
+		Version: payload.Version(),
+		Status:  "success",
+		Message: payload.Topic(),
+		Data:    payload,
+	}
+}
+
+// NewTopicEvent wraps data whose shape Blitz doesn't know, such as what
+// plugins emit, for broadcasting on topic
+func NewTopicEvent(topic string, data any) ServerResponse {
+	return ServerResponse{
+		Type:    TypeEvent,
+		Topic:   topic,
+		Status:  "success",
+		Message: topic,
+		Data:    data,
+	}
+}
+
+// NewResponse answers command with its result
+func NewResponse(command string, data any, id any) ServerResponse {
+	return ServerResponse{
+		Type:    TypeResponse,
+		Topic:   command,
+		TS:      time.Now().UnixMilli(),
+		Status:  "success",
+		Message: command,
+		Data:    data,
+		ID:      id,
+	}
+}
+
+// NewErrorResponse reports a failed command
+func NewErrorResponse(command string, err *Error, id any) ServerResponse {
+	return ServerResponse{
+		Type:       TypeError,
+		Topic:      command,
+		TS:         time.Now().UnixMilli(),
+		Status:     "error",
+		Message:    err.Message,
+		Code:       err.Code,
+		RetryAfter: err.RetryAfter,
+		Param:      err.Param,
+		ID:         id,
+	}
+}
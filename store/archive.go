@@ -0,0 +1,184 @@
+package store
+
+import (
+	"Blitz/config"
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// archiveConfig is the config file's name inside an archive
+	archiveConfig = "config.json"
+	// archiveDataDir holds the data directory's documents inside an archive
+	archiveDataDir = "data/"
+	// tokensDocument holds the device tokens, only exported on request
+	tokensDocument = "devices.json"
+	// maxArchiveFile bounds a single file read from an imported archive
+	maxArchiveFile = 64 << 20
+)
+
+// Export writes a gzipped tar archive of the config file and the documents
+// and logs of the data directory (history, plays, positions, ...), to move
+// Blitz to another machine or back it up. Device tokens are secrets and are
+// only included with tokens set. It returns the names of the archived files.
+func Export(w io.Writer, tokens bool) ([]string, error) {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	var files []string
+
+	add := func(name, source string) error {
+		data, err := os.ReadFile(source)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(data); err != nil {
+			return err
+		}
+		files = append(files, name)
+		return nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := add(archiveConfig, config.Path()); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(DataDir())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !archivable(name) || (name == tokensDocument && !tokens) || filepath.Join(DataDir(), name) == config.Path() {
+			continue
+		}
+		if err := add(archiveDataDir+name, filepath.Join(DataDir(), name)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return files, gz.Close()
+}
+
+// Import restores an archive written by Export, replacing the config file
+// and the documents it contains. The config takes effect after a restart;
+// callers must drop the documents they hold in memory so they're read again.
+// Plugins are programs Blitz runs, so those of an imported config are only
+// kept with plugins set. It returns the names of the restored files and
+// whether plugins were dropped.
+func Import(r io.Reader, plugins bool) ([]string, bool, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, false, fmt.Errorf("not a Blitz archive: %v", err)
+	}
+	archive := tar.NewReader(gz)
+
+	// Read everything first, so a broken archive changes nothing
+	contents := map[string][]byte{}
+	var files []string
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("broken archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(header.Name)
+		if _, ok := importTarget(name); !ok {
+			return nil, false, fmt.Errorf("unexpected file in archive: %s", header.Name)
+		}
+		if header.Size > maxArchiveFile {
+			return nil, false, fmt.Errorf("%s is too large", name)
+		}
+		data, err := io.ReadAll(io.LimitReader(archive, maxArchiveFile))
+		if err != nil {
+			return nil, false, fmt.Errorf("broken archive: %v", err)
+		}
+		contents[name] = data
+		files = append(files, name)
+	}
+
+	dropped := false
+	if data, ok := contents[archiveConfig]; ok && !plugins {
+		if contents[archiveConfig], dropped, err = withoutPlugins(data); err != nil {
+			return nil, false, fmt.Errorf("broken config in archive: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, name := range files {
+		target, _ := importTarget(name)
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return nil, false, err
+		}
+		if err := writeAtomic(target, contents[name]); err != nil {
+			return nil, false, fmt.Errorf("failed to restore %s: %v", name, err)
+		}
+	}
+	return files, dropped, nil
+}
+
+// withoutPlugins removes the plugins from a config file, reporting whether
+// it had any
+func withoutPlugins(data []byte) ([]byte, bool, error) {
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, false, err
+	}
+	var plugins []json.RawMessage
+	json.Unmarshal(settings["plugins"], &plugins)
+	if len(plugins) == 0 {
+		return data, false, nil
+	}
+	delete(settings, "plugins")
+	data, err := json.MarshalIndent(settings, "", "  ")
+	return data, true, err
+}
+
+// importTarget maps an archive entry to the file it restores
+func importTarget(name string) (string, bool) {
+	if name == archiveConfig {
+		return config.Path(), true
+	}
+	base, ok := strings.CutPrefix(name, archiveDataDir)
+	if !ok || base != filepath.Base(base) || !archivable(base) {
+		return "", false
+	}
+	// A config kept in the data directory is only restored as the config
+	target := filepath.Join(DataDir(), base)
+	return target, target != config.Path()
+}
+
+// archivable reports whether a data directory file belongs in an archive
+func archivable(name string) bool {
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".jsonl")
+}
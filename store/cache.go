@@ -0,0 +1,132 @@
+package store
+
+import (
+	"Blitz/config"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cache kinds, each kept in its own subdirectory of the cache directory
+const (
+	CacheSpotify  = "spotify"  // Artwork from the Spotify CDN
+	CacheArtwork  = "artwork"  // Artwork from anywhere else
+	CacheLocal    = "local"    // Copies of local artwork files, served over HTTP
+	CacheEmbedded = "embedded" // Artwork players sent inline as data URIs
+)
+
+// cacheKinds lists the kinds for the startup cleanup and migration
+var cacheKinds = []string{CacheSpotify, CacheArtwork, CacheLocal, CacheEmbedded}
+
+// CacheDir returns the directory for cached files of kind:
+// $BLITZ_CACHE_DIR, else paths.cache from the config, else
+// $XDG_CACHE_HOME/blitz, else ~/.cache/blitz, followed by the kind
+func CacheDir(kind string) string {
+	return filepath.Join(cacheRoot(), kind)
+}
+
+func cacheRoot() string {
+	if dir := os.Getenv("BLITZ_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	if dir := config.Get().Paths.Cache; dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "blitz")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "blitz")
+	}
+	return filepath.Join(home, ".cache", "blitz")
+}
+
+// WriteCache stores the contents of r as the file name of kind and returns its
+// path. The data goes to a temporary file first and is renamed into place, so
+// a crash mid-download never leaves a truncated file that looks cached.
+func WriteCache(kind, name string, r io.Reader) (string, error) {
+	dir := CacheDir(kind)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create cache file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name)
+	return path, os.Rename(tmp.Name(), path)
+}
+
+// PrepareCache removes downloads a crash left unfinished and moves the files
+// older versions cached in temp/ below the working directory
+func PrepareCache() {
+	for _, kind := range cacheKinds {
+		dir := CacheDir(kind)
+		if entries, err := os.ReadDir(dir); err == nil {
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".tmp") {
+					os.Remove(filepath.Join(dir, entry.Name()))
+				}
+			}
+		}
+
+		migrateCache(filepath.Join("temp", kind), dir)
+	}
+	// Only removed when the migration emptied it
+	os.Remove("temp")
+}
+
+// migrateCache moves the files of a legacy cache directory to dir
+func migrateCache(legacy, dir string) {
+	entries, err := os.ReadDir(legacy)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Println("Failed to read legacy cache:", err)
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Println("Failed to create cache directory:", err)
+		return
+	}
+
+	moved := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		from := filepath.Join(legacy, entry.Name())
+		to := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(to); err == nil {
+			os.Remove(from)
+			continue
+		}
+		// Fails across filesystems; those files are simply downloaded again
+		if err := os.Rename(from, to); err != nil {
+			os.Remove(from)
+			continue
+		}
+		moved++
+	}
+	os.Remove(legacy)
+
+	log.Printf("Moved %d cached files from %s to %s", moved, legacy, dir)
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"Blitz/config"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// mu serializes writes so concurrent saves of the same document can't interleave
+var mu sync.Mutex
+
+// DataDir returns the directory Blitz keeps persistent state in:
+// $BLITZ_DATA_DIR, else paths.data from the config, else $XDG_DATA_HOME/blitz,
+// else ~/.local/share/blitz
+func DataDir() string {
+	if dir := os.Getenv("BLITZ_DATA_DIR"); dir != "" {
+		return dir
+	}
+	if dir := config.Get().Paths.Data; dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "blitz")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "data"
+	}
+	return filepath.Join(home, ".local", "share", "blitz")
+}
+
+// Load reads the JSON document stored under name into v.
+// A missing document is not an error and leaves v untouched.
+func Load(name string, v any) error {
+	data, err := os.ReadFile(filepath.Join(DataDir(), name+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Save writes v as the JSON document name. The file is replaced atomically so a
+// crash mid-write never leaves a truncated document behind.
+func Save(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	dir := DataDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+	return writeAtomic(filepath.Join(dir, name+".json"), data)
+}
+
+// writeAtomic replaces target through a temporary file in the same directory
+func writeAtomic(target string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// Append adds v as one JSON line to the log name, for records that only ever
+// grow, such as the plays behind the listening statistics
+func Append(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	dir := DataDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, name+".jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadLines calls fn with every line of the log name. A missing log is not
+// an error. fn should skip lines it can't decode, such as one cut short by a crash.
+func LoadLines(name string, fn func(line []byte)) error {
+	file, err := os.Open(filepath.Join(DataDir(), name+".jsonl"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Bytes())
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,326 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/store"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// alarmsDocument is the store document holding the alarms
+	alarmsDocument = "alarms"
+	// alarmGrace still rings alarms that fell due while Blitz was restarting;
+	// older ones are skipped rather than going off at the wrong time
+	alarmGrace = 10 * time.Minute
+	// maxAlarms keeps clients from filling the disk
+	maxAlarms = 50
+	// alarmStreamWait is how long a player may take to open its audio stream
+	alarmStreamWait = 15 * time.Second
+)
+
+// Alarm starts playback at a time of day, raising the volume gradually
+type Alarm struct {
+	ID    string   `json:"id"`
+	Label string   `json:"label,omitempty"`
+	Time  string   `json:"time"`           // Local time of day, "07:00"
+	Days  []string `json:"days,omitempty"` // iCal weekdays it repeats on ("MO", "TU", ...); empty rings once
+	// What to play: a Spotify playlist or album URI, or a radio preset,
+	// directory station or stream URL
+	Playlist string `json:"playlist,omitempty"`
+	Preset   string `json:"preset,omitempty"`
+	Station  string `json:"station,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Player   string `json:"player,omitempty"` // Spotify Connect device ID for playlists, MPRIS player for radio
+	Volume   int    `json:"volume"`           // Volume in percent the ramp ends at
+	RampSec  int    `json:"rampSec"`          // Seconds the volume rises over; 0 starts at full volume
+	Next     int64  `json:"next"`             // Unix seconds it rings next
+}
+
+// AlarmRinging is broadcast as "alarm" when an alarm goes off
+type AlarmRinging struct {
+	Alarm
+	Error string `json:"error,omitempty"` // Why playback didn't start
+}
+
+// Topic implements models.Payload
+func (AlarmRinging) Topic() string { return "alarm" }
+
+// Version implements models.Payload
+func (AlarmRinging) Version() int { return 1 }
+
+var (
+	alarms       []Alarm
+	alarmsLoaded bool
+	alarmsMu     sync.Mutex
+)
+
+func loadAlarms() {
+	if alarmsLoaded {
+		return
+	}
+	alarmsLoaded = true
+	if err := store.Load(alarmsDocument, &alarms); err != nil {
+		log.Println("Failed to load alarms:", err)
+	}
+}
+
+func saveAlarms() {
+	if err := store.Save(alarmsDocument, alarms); err != nil {
+		log.Println("Failed to save alarms:", err)
+	}
+}
+
+// nextAlarmRing returns when an alarm rings next after a point in time
+func nextAlarmRing(alarm Alarm, after time.Time) (time.Time, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(alarm.Time, "%d:%d", &hour, &minute); err != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return time.Time{}, models.NewParamError("time", i18n.T("time must be a time of day like 07:00"))
+	}
+	days, err := parseWeekdays(alarm.Days)
+	if err != nil {
+		return time.Time{}, models.NewParamError("days", err.Error())
+	}
+
+	year, month, date := after.Date()
+	for offset := 0; offset <= 7; offset++ {
+		ring := time.Date(year, month, date+offset, hour, minute, 0, 0, after.Location())
+		if ring.After(after) && (days == nil || days[ring.Weekday()]) {
+			return ring, nil
+		}
+	}
+	return time.Time{}, models.NewParamError("time", i18n.T("time must be a time of day like 07:00"))
+}
+
+// checkAlarmSource makes sure the alarm plays exactly one thing and the
+// module playing it is usable
+func checkAlarmSource(alarm Alarm) error {
+	sources := 0
+	for _, source := range []string{alarm.Playlist, alarm.Preset, alarm.Station, alarm.URL} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return models.NewError(models.ErrInvalidParams, i18n.T("an alarm plays one of playlist, preset, station or url"), nil)
+	}
+
+	if alarm.Playlist != "" {
+		if !strings.HasPrefix(alarm.Playlist, "spotify:") {
+			return models.NewParamError("playlist", i18n.T("playlist must be a Spotify URI"))
+		}
+		return ModuleError(ModuleSpotify)
+	}
+	if err := ModuleError(ModuleRadio); err != nil {
+		return err
+	}
+	if alarm.URL != "" {
+		if err := checkStreamURL(alarm.URL); err != nil {
+			return err
+		}
+	}
+	if alarm.Player == "" {
+		return ModuleError(ModuleReceiver)
+	}
+	return nil
+}
+
+// SetAlarm adds an alarm, or replaces the one with the same ID
+func SetAlarm(alarm Alarm) (*Alarm, error) {
+	if alarm.Volume < 0 || alarm.Volume > 100 {
+		return nil, models.NewParamError("volume", i18n.T("volume must be between 0 and 100"))
+	}
+	if alarm.RampSec < 0 || alarm.RampSec > 3600 {
+		return nil, models.NewParamError("rampSec", i18n.T("rampSec must be between 0 and 3600"))
+	}
+	if err := checkAlarmSource(alarm); err != nil {
+		return nil, err
+	}
+	next, err := nextAlarmRing(alarm, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	alarm.Next = next.Unix()
+
+	alarmsMu.Lock()
+	defer alarmsMu.Unlock()
+	loadAlarms()
+
+	index := slices.IndexFunc(alarms, func(existing Alarm) bool { return existing.ID == alarm.ID })
+	switch {
+	case alarm.ID != "" && index >= 0:
+		alarms[index] = alarm
+	case alarm.ID != "":
+		return nil, models.NewParamError("id", i18n.T("no alarm with id %s", alarm.ID))
+	case len(alarms) >= maxAlarms:
+		return nil, models.NewError(models.ErrInvalidParams, i18n.T("at most %d alarms can be set", maxAlarms), nil)
+	default:
+		id := make([]byte, 4)
+		rand.Read(id)
+		alarm.ID = hex.EncodeToString(id)
+		alarms = append(alarms, alarm)
+	}
+	saveAlarms()
+
+	log.Printf("⏰ Alarm %s set for %s", alarm.ID, next.Format(time.DateTime))
+	return &alarm, nil
+}
+
+// GetAlarms lists the alarms, the next one to ring first
+func GetAlarms() []Alarm {
+	alarmsMu.Lock()
+	defer alarmsMu.Unlock()
+	loadAlarms()
+
+	list := slices.Clone(alarms)
+	slices.SortFunc(list, func(a, b Alarm) int { return cmp.Compare(a.Next, b.Next) })
+	if list == nil {
+		list = []Alarm{}
+	}
+	return list
+}
+
+// DeleteAlarm removes an alarm
+func DeleteAlarm(id string) error {
+	alarmsMu.Lock()
+	defer alarmsMu.Unlock()
+	loadAlarms()
+
+	index := slices.IndexFunc(alarms, func(alarm Alarm) bool { return alarm.ID == id })
+	if index < 0 {
+		return models.NewParamError("id", i18n.T("no alarm with id %s", id))
+	}
+	alarms = slices.Delete(alarms, index, index+1)
+	saveAlarms()
+	return nil
+}
+
+// TakeDueAlarms returns the alarms that should ring now and schedules their
+// next ring. Alarms that ring once are removed.
+func TakeDueAlarms() []Alarm {
+	alarmsMu.Lock()
+	defer alarmsMu.Unlock()
+	loadAlarms()
+
+	now := time.Now()
+	var due []Alarm
+	changed := false
+	remaining := alarms[:0]
+	for _, alarm := range alarms {
+		ring := time.Unix(alarm.Next, 0)
+		if ring.After(now) {
+			remaining = append(remaining, alarm)
+			continue
+		}
+		changed = true
+
+		if now.Sub(ring) <= alarmGrace {
+			due = append(due, alarm)
+		} else {
+			log.Printf("⏰ Skipping alarm %s missed at %s", alarm.ID, ring.Format(time.DateTime))
+		}
+		if len(alarm.Days) == 0 {
+			continue
+		}
+		next, err := nextAlarmRing(alarm, now)
+		if err != nil {
+			log.Printf("❌ Dropping alarm %s: %v", alarm.ID, err)
+			continue
+		}
+		alarm.Next = next.Unix()
+		remaining = append(remaining, alarm)
+	}
+	alarms = remaining
+	if changed {
+		saveAlarms()
+	}
+	return due
+}
+
+// RingAlarm starts the alarm's playback, quietly first when it ramps up
+func RingAlarm(alarm Alarm) AlarmRinging {
+	log.Printf("⏰ Alarm %s is ringing", alarm.ID)
+	ramp := time.Duration(alarm.RampSec) * time.Second
+
+	var err error
+	if alarm.Playlist != "" {
+		err = ringSpotifyAlarm(alarm, ramp)
+	} else {
+		err = ringRadioAlarm(alarm, ramp)
+	}
+
+	ringing := AlarmRinging{Alarm: alarm}
+	if err != nil {
+		log.Printf("❌ Alarm %s failed: %v", alarm.ID, err)
+		ringing.Error = err.Error()
+	}
+	return ringing
+}
+
+func ringSpotifyAlarm(alarm Alarm, ramp time.Duration) error {
+	client, err := requireSpotify()
+	if err != nil {
+		return err
+	}
+
+	start := alarm.Volume
+	if ramp > 0 {
+		start = 0
+	}
+	if err := client.PlayContext(alarm.Playlist, "", alarm.Player); err != nil {
+		return err
+	}
+	if err := client.SetVolume(context.Background(), start, alarm.Player); err != nil {
+		return err
+	}
+	if ramp <= 0 {
+		return nil
+	}
+
+	// Every step is a Web API call, so there is at most one per second
+	go func() {
+		steps := max(min(alarm.Volume, alarm.RampSec), 1)
+		ticker := time.NewTicker(ramp / time.Duration(steps))
+		defer ticker.Stop()
+		for i := 1; i <= steps; i++ {
+			<-ticker.C
+			if err := client.SetVolume(context.Background(), alarm.Volume*i/steps, alarm.Player); err != nil {
+				log.Printf("Alarm %s stopped its volume ramp: %v", alarm.ID, err)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func ringRadioAlarm(alarm Alarm, ramp time.Duration) error {
+	if _, err := PlayRadio(alarm.Preset, alarm.Station, alarm.URL, alarm.Player); err != nil {
+		return err
+	}
+	player := alarm.Player
+	if player == "" {
+		player = ReceiverPlayer
+	}
+
+	// The stream whose volume ramps only appears once the player buffered
+	var err error
+	for deadline := time.Now().Add(alarmStreamWait); time.Now().Before(deadline); time.Sleep(500 * time.Millisecond) {
+		if err = SetStreamVolume(player, 0); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	_, err = FadeTo(player, alarm.Volume, ramp, nil)
+	return err
+}
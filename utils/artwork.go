@@ -1,33 +1,82 @@
 package utils
 
 import (
+	"Blitz/store"
+	"bytes"
 	"crypto/md5"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
+const (
+	// artworkDownloadTimeout bounds a whole artwork download, body included
+	artworkDownloadTimeout = 15 * time.Second
+	// maxArtworkRedirects is how many redirects an artwork URL may take
+	maxArtworkRedirects = 3
+	// artworkRetryAfter is how long a URL whose download failed is left alone
+	artworkRetryAfter = 5 * time.Minute
+)
+
+var (
+	failedArtwork   = map[string]time.Time{}
+	failedArtworkMu sync.Mutex
+)
+
+// artworkClient downloads artwork from whatever URL a player reports, so it
+// gives up on slow servers and long redirect chains
+var artworkClient = func() *http.Client {
+	client := NewHTTPClient(DependencyArtwork, artworkDownloadTimeout)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxArtworkRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxArtworkRedirects)
+		}
+		return nil
+	}
+	return client
+}()
+
+// HandleArtworkRequest returns the artwork at a URL or local path as a data URI
 func HandleArtworkRequest(artworkPath string) (string, error) {
+	file, err := ArtworkFile(artworkPath)
+	if err != nil {
+		return "", err
+	}
+	return ArtworkDataURI(file)
+}
+
+// ArtworkFile returns a local file holding the artwork at a URL or local
+// path, downloading and caching remote artwork first
+func ArtworkFile(artworkPath string) (string, error) {
 	// Handle HTTP/HTTPS URLs (download and cache them)
 	if strings.HasPrefix(artworkPath, "http://") || strings.HasPrefix(artworkPath, "https://") {
-		cachedPath, err := downloadAndCacheArtwork(artworkPath)
-		if err != nil {
-			return "", err
-		}
-		artworkPath = cachedPath
+		return downloadAndCacheArtwork(artworkPath)
+	}
+
+	// Players like Lollypop hand over embedded art inline
+	if strings.HasPrefix(artworkPath, "data:") {
+		return cacheDataURIArtwork(artworkPath)
 	}
 
 	if artworkPath == "" {
-		artworkPath = "/home/swap/Downloads/vector-music-note-icon.jpg"
+		return PlaceholderArtwork("")
 	}
 
-	artworkPath = strings.TrimPrefix(artworkPath, "file://")
-	imageBuffer, err := os.ReadFile(artworkPath)
+	return localArtworkPath(artworkPath), nil
+}
+
+// ArtworkDataURI reads an artwork file and returns it as a data URI
+func ArtworkDataURI(artworkPath string) (string, error) {
+	imageBuffer, err := readArtwork(artworkPath)
 	if err != nil {
 		fmt.Println("Something went wrong while reading the file", err)
 		return "", err
@@ -55,6 +104,27 @@ func HandleArtworkRequest(artworkPath string) (string, error) {
 	return "data:image/" + imageExtension + ";base64," + base64.StdEncoding.EncodeToString(imageBuffer), nil
 }
 
+// readArtwork reads an artwork file, refusing oversized ones and
+// sanitizing SVGs
+func readArtwork(artworkPath string) ([]byte, error) {
+	info, err := os.Stat(artworkPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxArtworkBytes {
+		return nil, fmt.Errorf("artwork is larger than %d bytes", maxArtworkBytes)
+	}
+
+	data, err := os.ReadFile(artworkPath)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(artworkPath), ".svg") {
+		return sanitizeSVG(data)
+	}
+	return data, nil
+}
+
 // downloadAndCacheArtwork downloads artwork from URL and caches it locally
 func downloadAndCacheArtwork(url string) (string, error) {
 	// Extract unique ID from URL
@@ -66,73 +136,128 @@ func downloadAndCacheArtwork(url string) (string, error) {
 	}
 
 	// Determine cache directory based on source
-	var cacheDir string
+	kind := store.CacheArtwork
 	if strings.Contains(url, "scdn.co") || strings.Contains(url, "spotify") {
-		cacheDir = "temp/spotify"
-	} else {
-		cacheDir = "temp/artwork"
+		kind = store.CacheSpotify
 	}
+	cacheDir := store.CacheDir(kind)
 
-	// Create cache directory if it doesn't exist
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create cache directory: %v", err)
-	}
-
-	// Determine file extension from URL or content-type
+	// Guess the file extension from the URL
 	ext := ".jpg"
 	if strings.Contains(url, ".png") {
 		ext = ".png"
 	} else if strings.Contains(url, ".webp") {
 		ext = ".webp"
+	} else if strings.Contains(url, ".svg") {
+		ext = ".svg"
 	}
 
-	// Build cache file path
-	cachedPath := filepath.Join(cacheDir, imageID+ext)
-
-	// Check if already cached
-	if _, err := os.Stat(cachedPath); err == nil {
+	// Check if already cached, under the extension the URL suggests or
+	// the one the downloaded image turned out to have
+	if cachedPath := cachedArtwork(cacheDir, imageID, ext); cachedPath != "" {
 		return cachedPath, nil
 	}
 
-	// Download the image
-	resp, err := http.Get(url)
+	if failedArtworkRecently(url) {
+		return "", fmt.Errorf("artwork download failed recently: %s", url)
+	}
+	data, ext, err := downloadArtwork(url)
 	if err != nil {
-		return "", fmt.Errorf("failed to download artwork: %v", err)
+		recordFailedArtwork(url, err)
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download artwork: HTTP %d", resp.StatusCode)
+	// Write the image data
+	cachedPath, err := store.WriteCache(kind, imageID+ext, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to write image data: %v", err)
 	}
 
-	// Detect content type and adjust extension if needed
-	contentType := resp.Header.Get("Content-Type")
-	if strings.Contains(contentType, "png") {
-		ext = ".png"
-		cachedPath = filepath.Join(cacheDir, imageID+ext)
-	} else if strings.Contains(contentType, "webp") {
-		ext = ".webp"
-		cachedPath = filepath.Join(cacheDir, imageID+ext)
-	} else if strings.Contains(contentType, "jpeg") || strings.Contains(contentType, "jpg") {
-		ext = ".jpg"
-		cachedPath = filepath.Join(cacheDir, imageID+ext)
+	return cachedPath, nil
+}
+
+// cachedArtwork returns the cached file of an image, or "" if there is none
+func cachedArtwork(cacheDir, imageID, ext string) string {
+	if _, err := os.Stat(filepath.Join(cacheDir, imageID+ext)); err == nil {
+		return filepath.Join(cacheDir, imageID+ext)
+	}
+	for _, other := range artworkExtensions {
+		if _, err := os.Stat(filepath.Join(cacheDir, imageID+other)); err == nil {
+			return filepath.Join(cacheDir, imageID+other)
+		}
 	}
+	return ""
+}
+
+// failedArtworkRecently reports whether downloading an artwork URL failed
+// less than artworkRetryAfter ago
+func failedArtworkRecently(url string) bool {
+	failedArtworkMu.Lock()
+	defer failedArtworkMu.Unlock()
+	failed, ok := failedArtwork[url]
+	return ok && time.Since(failed) < artworkRetryAfter
+}
 
-	// Create the file
-	outFile, err := os.Create(cachedPath)
+// recordFailedArtwork remembers a failed download, so the poller doesn't try
+// again every second
+func recordFailedArtwork(url string, err error) {
+	log.Println("Artwork download failed:", err)
+
+	failedArtworkMu.Lock()
+	defer failedArtworkMu.Unlock()
+	for failedURL, failed := range failedArtwork {
+		if time.Since(failed) >= artworkRetryAfter {
+			delete(failedArtwork, failedURL)
+		}
+	}
+	failedArtwork[url] = time.Now()
+}
+
+// downloadArtwork downloads an image of at most maxArtworkBytes and returns
+// it with the cache extension of its type. Players report any URL as their
+// artUrl, so the content has to be an image, whatever the server claims.
+func downloadArtwork(url string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download artwork: %v", err)
+	}
+	resp, err := DoWithRetry(artworkClient, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cache file: %v", err)
+		return nil, "", fmt.Errorf("failed to download artwork: %v", err)
 	}
-	defer outFile.Close()
+	defer resp.Body.Close()
 
-	// Write the image data
-	_, err = io.Copy(outFile, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download artwork: HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxArtworkBytes {
+		return nil, "", fmt.Errorf("artwork is larger than %d bytes", maxArtworkBytes)
+	}
+
+	// Servers that don't know the type send application/octet-stream
+	declared, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if declared != "" && declared != "application/octet-stream" && !strings.HasPrefix(declared, "image/") {
+		return nil, "", fmt.Errorf("artwork URL returned %s, not an image", declared)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxArtworkBytes+1))
 	if err != nil {
-		os.Remove(cachedPath) // Clean up on error
-		return "", fmt.Errorf("failed to write image data: %v", err)
+		return nil, "", fmt.Errorf("failed to download artwork: %v", err)
+	}
+	if len(data) > maxArtworkBytes {
+		return nil, "", fmt.Errorf("artwork is larger than %d bytes", maxArtworkBytes)
 	}
 
-	return cachedPath, nil
+	sniffed := http.DetectContentType(data)
+	if ext, ok := artworkExtensions[sniffed]; ok {
+		return data, ext, nil
+	}
+	// SVG is text, which sniffing can't tell apart from other XML
+	if declared == "image/svg+xml" {
+		data, err := sanitizeSVG(data)
+		return data, ".svg", err
+	}
+	return nil, "", fmt.Errorf("artwork URL returned %s, not an image", strings.SplitN(sniffed, ";", 2)[0])
 }
 
 // extractImageID extracts the unique image ID from various CDN URLs
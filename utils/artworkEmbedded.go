@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"Blitz/store"
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxArtworkBytes bounds the artwork Blitz reads or decodes
+	maxArtworkBytes = 10 << 20
+	// maxSVGBytes bounds SVG artwork, which is parsed by every client showing it
+	maxSVGBytes = 512 << 10
+)
+
+var (
+	svgScriptRegex  = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<script\b[^>]*/>`)
+	svgForeignRegex = regexp.MustCompile(`(?is)<foreignObject\b.*?</foreignObject\s*>`)
+	// Event handlers such as onload="..." and links to scripts or other documents
+	svgHandlerRegex = regexp.MustCompile(`(?i)\son\w+\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	svgHrefRegex    = regexp.MustCompile(`(?i)\s(?:xlink:)?href\s*=\s*("\s*(?:javascript|https?|file):[^"]*"|'\s*(?:javascript|https?|file):[^']*')`)
+)
+
+// artworkExtensions maps image MIME types to the file extensions of the cache
+var artworkExtensions = map[string]string{
+	"image/png":     ".png",
+	"image/jpeg":    ".jpg",
+	"image/jpg":     ".jpg",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/bmp":     ".bmp",
+	"image/svg+xml": ".svg",
+}
+
+// cacheDataURIArtwork stores artwork a player sent inline as a data URI
+// (mpris:artUrl "data:image/png;base64,...") and returns the cached file
+func cacheDataURIArtwork(uri string) (string, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return "", fmt.Errorf("malformed artwork data URI")
+	}
+
+	mediaType, encoding, _ := strings.Cut(header, ";")
+	ext, ok := artworkExtensions[strings.ToLower(strings.TrimSpace(mediaType))]
+	if !ok {
+		return "", fmt.Errorf("unsupported artwork type %q", mediaType)
+	}
+	if len(payload) > maxArtworkBytes*4/3+4 {
+		return "", fmt.Errorf("artwork data URI is too large")
+	}
+
+	var data []byte
+	var err error
+	if strings.Contains(strings.ToLower(encoding), "base64") {
+		data, err = base64.StdEncoding.DecodeString(payload)
+	} else {
+		var unescaped string
+		unescaped, err = url.PathUnescape(payload)
+		data = []byte(unescaped)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to decode artwork data URI: %v", err)
+	}
+
+	if ext == ".svg" {
+		if data, err = sanitizeSVG(data); err != nil {
+			return "", err
+		}
+	}
+
+	name := fmt.Sprintf("%x%s", md5.Sum(data), ext)
+	return store.WriteCache(store.CacheEmbedded, name, bytes.NewReader(data))
+}
+
+// localArtworkPath turns a file:// URL into a path, undoing the percent
+// escaping players apply to spaces and non-ASCII names. Plain paths pass as is.
+func localArtworkPath(artworkPath string) string {
+	if !strings.HasPrefix(artworkPath, "file://") {
+		return artworkPath
+	}
+	parsed, err := url.Parse(artworkPath)
+	if err != nil {
+		return strings.TrimPrefix(artworkPath, "file://")
+	}
+	return parsed.Path
+}
+
+// sanitizeSVG removes what would let an SVG cover run code or load other
+// resources in a client: scripts, embedded HTML, event handlers and links to
+// javascript:, remote or local URLs
+func sanitizeSVG(data []byte) ([]byte, error) {
+	if len(data) > maxSVGBytes {
+		return nil, fmt.Errorf("SVG artwork is larger than %d bytes", maxSVGBytes)
+	}
+
+	data = svgScriptRegex.ReplaceAll(data, nil)
+	data = svgForeignRegex.ReplaceAll(data, nil)
+	data = svgHandlerRegex.ReplaceAll(data, nil)
+	data = svgHrefRegex.ReplaceAll(data, nil)
+	return data, nil
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// maxArtworkLookups bounds how many releases' covers are remembered
+	maxArtworkLookups = 1000
+	// artworkLookupRetry is how long a release without a cover found is
+	// remembered, whether the APIs had none or couldn't be reached
+	artworkLookupRetry = 10 * time.Minute
+)
+
+var (
+	artworkLookupClient = NewHTTPClient(DependencyArtworkLookup, 10*time.Second)
+	artworkLookupCache  = newLookupCache[string](maxArtworkLookups, artworkLookupRetry)
+)
+
+// ResolveMediaArtwork returns the artwork data URI for a media snapshot. When the
+// player reports no artUrl (common for browser players) the cover is looked up online.
+func ResolveMediaArtwork(info MediaInfo) (string, error) {
+	file, err := ResolveMediaArtworkFile(info)
+	if err != nil {
+		return "", err
+	}
+	return ArtworkDataURI(file)
+}
+
+// ResolveMediaArtworkFile is ResolveMediaArtwork returning the local artwork
+// file instead of its contents. Local files without an artUrl get the cover
+// embedded in their tags before it is looked up online, and tracks without
+// a usable cover the player's placeholder.
+func ResolveMediaArtworkFile(info MediaInfo) (string, error) {
+	artwork := info.Artwork
+	if artwork == "" && strings.HasPrefix(info.URL, "file://") {
+		if cover, err := EmbeddedCover(localArtworkPath(info.URL)); err == nil {
+			return cover, nil
+		}
+	}
+	if artwork == "" && info.Artist != "" {
+		if found := LookupArtworkURL(info.Artist, info.Album, info.Title); found != "" {
+			artwork = found
+		}
+	}
+	if artwork == "" {
+		return PlaceholderArtwork(info.Player)
+	}
+	if file, err := ArtworkFile(artwork); err == nil {
+		return file, nil
+	}
+	return PlaceholderArtwork(info.Player)
+}
+
+// LookupArtworkURL finds a cover image URL for a release, trying the MusicBrainz
+// Cover Art Archive first and the iTunes Search API second. Covers found are
+// remembered so each release is only looked up once; misses are retried after
+// artworkLookupRetry.
+func LookupArtworkURL(artist, album, title string) string {
+	key := strings.ToLower(artist + "|" + album + "|" + title)
+
+	if cached, ok := artworkLookupCache.get(key); ok {
+		return cached
+	}
+
+	found := ""
+	if album != "" {
+		if coverURL, err := lookupCoverArtArchive(artist, album); err == nil {
+			found = coverURL
+		} else {
+			log.Println("MusicBrainz artwork lookup failed:", err)
+		}
+	}
+	if found == "" {
+		if coverURL, err := lookupITunesArtwork(artist, album, title); err == nil {
+			found = coverURL
+		} else {
+			log.Println("iTunes artwork lookup failed:", err)
+		}
+	}
+
+	artworkLookupCache.put(key, found, found != "")
+	return found
+}
+
+// lookupCoverArtArchive searches MusicBrainz for the release group and returns its Cover Art Archive front image
+func lookupCoverArtArchive(artist, album string) (string, error) {
+	query := fmt.Sprintf(`artist:"%s" AND releasegroup:"%s"`, escapeLucene(artist), escapeLucene(album))
+	endpoint := "https://musicbrainz.org/ws/2/release-group/?fmt=json&limit=1&query=" + url.QueryEscape(query)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	// MusicBrainz rejects requests without a meaningful User-Agent
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := artworkLookupClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("musicbrainz search: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		ReleaseGroups []struct {
+			ID    string `json:"id"`
+			Score int    `json:"score"`
+		} `json:"release-groups"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if len(result.ReleaseGroups) == 0 || result.ReleaseGroups[0].Score < 90 {
+		return "", fmt.Errorf("no release group found for %s - %s", artist, album)
+	}
+
+	coverURL := "https://coverartarchive.org/release-group/" + result.ReleaseGroups[0].ID + "/front-500"
+
+	// Not every release group has cover art, check before handing the URL on
+	head, err := artworkLookupClient.Head(coverURL)
+	if err != nil {
+		return "", err
+	}
+	head.Body.Close()
+	if head.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("no cover art for release group %s", result.ReleaseGroups[0].ID)
+	}
+
+	return coverURL, nil
+}
+
+// lookupITunesArtwork searches the iTunes catalog by album, or by song when the album is unknown
+func lookupITunesArtwork(artist, album, title string) (string, error) {
+	params := url.Values{}
+	params.Set("limit", "1")
+	params.Set("media", "music")
+	if album != "" {
+		params.Set("entity", "album")
+		params.Set("term", artist+" "+album)
+	} else if title != "" {
+		params.Set("entity", "song")
+		params.Set("term", artist+" "+title)
+	} else {
+		return "", fmt.Errorf("no album or title to search for")
+	}
+
+	resp, err := artworkLookupClient.Get("https://itunes.apple.com/search?" + params.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("itunes search: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Results []struct {
+			ArtworkURL100 string `json:"artworkUrl100"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if len(result.Results) == 0 || result.Results[0].ArtworkURL100 == "" {
+		return "", fmt.Errorf("no iTunes result for %s - %s", artist, album)
+	}
+
+	// The API only advertises 100x100 thumbnails, but larger sizes exist at the same path
+	return strings.Replace(result.Results[0].ArtworkURL100, "100x100bb", "600x600bb", 1), nil
+}
+
+// escapeLucene escapes quotes and backslashes for a MusicBrainz phrase query
+func escapeLucene(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/store"
+	"bytes"
+	_ "embed"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// bundledPlaceholder is shown when neither artwork.players nor
+// artwork.placeholder has an image that exists
+//
+//go:embed assets/placeholder.svg
+var bundledPlaceholder []byte
+
+const bundledPlaceholderName = "placeholder.svg"
+
+var (
+	missingPlaceholders   = map[string]bool{} // Configured images already reported missing
+	missingPlaceholdersMu sync.Mutex
+)
+
+// PlaceholderArtwork returns the image shown for tracks of a player that have
+// no artwork: the player's fallback from artwork.players (e.g. a Firefox logo
+// for browser audio), else artwork.placeholder, else the bundled placeholder
+func PlaceholderArtwork(player string) (string, error) {
+	settings := config.Get().Artwork
+
+	// Instance suffixes (firefox.instance_1_84) don't matter here
+	name := strings.ToLower(strings.SplitN(player, ".", 2)[0])
+	candidates := []string{}
+	if fallback := settings.Players[name]; name != "" && fallback != "" {
+		candidates = append(candidates, fallback)
+	}
+	if settings.Placeholder != "" {
+		candidates = append(candidates, settings.Placeholder)
+	}
+
+	for _, candidate := range candidates {
+		file := localArtworkPath(candidate)
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+		reportMissingPlaceholder(file)
+	}
+	return bundledPlaceholderFile()
+}
+
+// reportMissingPlaceholder logs a configured image that doesn't exist, once
+func reportMissingPlaceholder(file string) {
+	missingPlaceholdersMu.Lock()
+	defer missingPlaceholdersMu.Unlock()
+	if !missingPlaceholders[file] {
+		missingPlaceholders[file] = true
+		log.Println("Placeholder artwork not found, falling back:", file)
+	}
+}
+
+// bundledPlaceholderFile writes the bundled placeholder into the cache, again
+// if the cache was cleared, and returns its path
+func bundledPlaceholderFile() (string, error) {
+	file := filepath.Join(store.CacheDir(store.CacheArtwork), bundledPlaceholderName)
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+	return store.WriteCache(store.CacheArtwork, bundledPlaceholderName, bytes.NewReader(bundledPlaceholder))
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	preloadedFor string // track the last preload ran for
+	preloadMu    sync.Mutex
+
+	objectPathRegex  = regexp.MustCompile(`object path "([^"]*)"`)
+	trackArtURLRegex = regexp.MustCompile(`string "mpris:artUrl"\s*\n\s*variant\s+string "([^"]*)"`)
+)
+
+// PreloadNextArtwork caches the cover of the track after the current one, so
+// it is ready the moment the track changes. The next track comes from the
+// Spotify queue, or from the MPRIS TrackList of players that implement it.
+// Runs at most once per current track.
+func PreloadNextArtwork(info MediaInfo) {
+	key := info.Player + "|" + info.TrackID + "|" + info.Title
+	preloadMu.Lock()
+	if key == preloadedFor {
+		preloadMu.Unlock()
+		return
+	}
+	preloadedFor = key
+	preloadMu.Unlock()
+
+	next := nextSpotifyArtwork(info)
+	if next == "" {
+		next = nextTrackListArtwork(info)
+	}
+	if next == "" {
+		return
+	}
+
+	file, err := ArtworkFile(next)
+	if err != nil {
+		log.Println("Failed to preload next artwork:", err)
+		return
+	}
+	// Local covers are copied into the cache when they are first served
+	ArtworkURL(file)
+}
+
+// nextSpotifyArtwork returns the cover URL of the first queued Spotify item
+func nextSpotifyArtwork(info MediaInfo) string {
+	client := GetSpotifyClient()
+	if SpotifyTrackID(info.TrackID) == "" || client == nil || !client.IsAuthenticated() {
+		return ""
+	}
+
+	queue, err := client.GetQueue()
+	if err != nil {
+		log.Println("Failed to get Spotify queue:", err)
+		return ""
+	}
+	if len(queue) == 0 {
+		return ""
+	}
+	return queue[0].AlbumArt
+}
+
+// nextTrackListArtwork returns the artUrl of the track after the current one
+// in the player's MPRIS TrackList, or "" when the player has none
+func nextTrackListArtwork(info MediaInfo) string {
+	if info.Player == "" || info.TrackID == "" {
+		return ""
+	}
+
+	dest := "--dest=org.mpris.MediaPlayer2." + info.Player
+	tracks, err := SpawnProcess("dbus-send", []string{
+		"--session", "--print-reply", dest, "/org/mpris/MediaPlayer2",
+		"org.freedesktop.DBus.Properties.Get",
+		"string:org.mpris.MediaPlayer2.TrackList", "string:Tracks",
+	})
+	if err != nil {
+		return ""
+	}
+
+	ids := objectPathRegex.FindAllStringSubmatch(string(tracks), -1)
+	for i, id := range ids {
+		if id[1] != info.TrackID || i+1 >= len(ids) {
+			continue
+		}
+
+		metadata, err := SpawnProcess("dbus-send", []string{
+			"--session", "--print-reply", dest, "/org/mpris/MediaPlayer2",
+			"org.mpris.MediaPlayer2.TrackList.GetTracksMetadata",
+			"array:objpath:" + ids[i+1][1],
+		})
+		if err != nil {
+			return ""
+		}
+		return parseTrackListArtwork(string(metadata))
+	}
+	return ""
+}
+
+// parseTrackListArtwork returns the artUrl in GetTracksMetadata output
+func parseTrackListArtwork(output string) string {
+	if match := trackArtURLRegex.FindStringSubmatch(output); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	return ""
+}
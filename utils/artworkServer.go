@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"Blitz/store"
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// artworkMaxAge is how long browsers may use a cover without revalidating.
+// Cached files are named after the image, so a name never changes its content.
+const artworkMaxAge = 24 * 60 * 60
+
+// artworkKinds are the cache kinds served under /artwork/
+var artworkKinds = []string{store.CacheSpotify, store.CacheArtwork, store.CacheLocal, store.CacheEmbedded}
+
+// ArtworkURL returns the path /artwork/<kind>/<file> serving an artwork file.
+// Files outside the cache, such as covers local players extract, are copied
+// into it first. Returns "" when the file can't be served.
+func ArtworkURL(file string) string {
+	for _, kind := range artworkKinds {
+		if filepath.Dir(file) == store.CacheDir(kind) {
+			return "/artwork/" + kind + "/" + filepath.Base(file)
+		}
+	}
+
+	name, err := publishLocalArtwork(file)
+	if err != nil {
+		return ""
+	}
+	return "/artwork/" + store.CacheLocal + "/" + name
+}
+
+// publishLocalArtwork copies a local artwork file into the cache, named after
+// its path, unless the copy is already up to date
+func publishLocalArtwork(file string) (string, error) {
+	source, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%x%s", md5.Sum([]byte(file)), strings.ToLower(filepath.Ext(file)))
+	if copied, err := os.Stat(filepath.Join(store.CacheDir(store.CacheLocal), name)); err == nil && !copied.ModTime().Before(source.ModTime()) {
+		return name, nil
+	}
+
+	data, err := readArtwork(file)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = store.WriteCache(store.CacheLocal, name, bytes.NewReader(data))
+	return name, err
+}
+
+// HandleArtwork serves cached artwork at /artwork/<kind>/<file> with an ETag
+// and Cache-Control, answering If-None-Match and If-Modified-Since with
+// 304 Not Modified so browsers don't download unchanged covers again
+func HandleArtwork(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kind, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/artwork/"), "/")
+	if !ok || !slices.Contains(artworkKinds, kind) || name == "" || name != filepath.Base(name) || strings.HasSuffix(name, ".tmp") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(store.CacheDir(kind), name))
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%s"`, strconv.FormatInt(info.Size(), 36), strconv.FormatInt(info.ModTime().UnixNano(), 36)))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", artworkMaxAge))
+	// SVGs opened directly must not run scripts or load anything, even if the
+	// sanitizer missed something
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// ServeContent checks the conditional headers against the ETag and modification time
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
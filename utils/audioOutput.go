@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// outputCacheTTL limits how often the poller asks PipeWire for the player's sink
+const outputCacheTTL = 3 * time.Second
+
+// AudioOutput is a PipeWire sink (headphones, speakers, HDMI, ...)
+type AudioOutput struct {
+	Index       int    `json:"index"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+// pactlSink and pactlSinkInput are the parts of `pactl --format=json` output we use
+type pactlSink struct {
+	Index       int    `json:"index"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ActivePort  string `json:"active_port"`
+}
+
+type pactlSinkInput struct {
+	Index      int               `json:"index"`
+	Sink       int               `json:"sink"`
+	Properties map[string]string `json:"properties"`
+	Volume     map[string]struct {
+		ValuePercent string `json:"value_percent"`
+	} `json:"volume"`
+}
+
+var (
+	playerOutput    string
+	playerOutputKey string
+	playerOutputAt  time.Time
+	playerOutputMu  sync.Mutex
+)
+
+// ListAudioOutputs returns the available sinks, marking the default one
+func ListAudioOutputs() ([]AudioOutput, error) {
+	sinks, err := listPactlSinks()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultSink := ""
+	if output, err := SpawnProcess("pactl", []string{"get-default-sink"}); err == nil {
+		defaultSink = strings.TrimSpace(string(output))
+	}
+
+	outputs := make([]AudioOutput, 0, len(sinks))
+	for _, sink := range sinks {
+		outputs = append(outputs, AudioOutput{
+			Index:       sink.Index,
+			Name:        sink.Name,
+			Description: sink.Description,
+			Default:     sink.Name == defaultSink,
+		})
+	}
+	return outputs, nil
+}
+
+// SetPlayerOutput moves a player's audio streams to a sink, given by index,
+// name or (part of) its description. An empty player means the active player.
+func SetPlayerOutput(player, sink string) (*AudioOutput, error) {
+	if player == "" {
+		info, err := GetPlayerInfo()
+		if err != nil {
+			return nil, err
+		}
+		player = info.Player
+	}
+	if player == "" {
+		return nil, models.NewError(models.ErrPlayerNotFound, i18n.T("no active player"), nil)
+	}
+
+	sinks, err := listPactlSinks()
+	if err != nil {
+		return nil, err
+	}
+	target, err := matchSink(sinks, sink)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, err := playerSinkInputs(player)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%s has no audio stream to move", player)
+	}
+
+	for _, input := range inputs {
+		if _, err := SpawnProcess("pactl", []string{"move-sink-input", strconv.Itoa(input.Index), strconv.Itoa(target.Index)}); err != nil {
+			return nil, fmt.Errorf("failed to move %s to %s: %v", player, target.Description, err)
+		}
+	}
+
+	// Make the next poll report the new output
+	playerOutputMu.Lock()
+	playerOutputKey = ""
+	playerOutputMu.Unlock()
+
+	return &AudioOutput{Index: target.Index, Name: target.Name, Description: target.Description}, nil
+}
+
+// GetPlayerOutput returns the description of the sink a player is playing to,
+// or "" when it has no stream
+func GetPlayerOutput(player string) string {
+	if player == "" {
+		return ""
+	}
+
+	playerOutputMu.Lock()
+	defer playerOutputMu.Unlock()
+
+	if player == playerOutputKey && time.Since(playerOutputAt) < outputCacheTTL {
+		return playerOutput
+	}
+
+	playerOutputKey = player
+	playerOutputAt = time.Now()
+	playerOutput = ""
+
+	inputs, err := playerSinkInputs(player)
+	if err != nil || len(inputs) == 0 {
+		return ""
+	}
+	sinks, err := listPactlSinks()
+	if err != nil {
+		return ""
+	}
+	for _, sink := range sinks {
+		if sink.Index == inputs[0].Sink {
+			playerOutput = sink.Description
+			break
+		}
+	}
+	return playerOutput
+}
+
+func listPactlSinks() ([]pactlSink, error) {
+	output, err := SpawnProcess("pactl", []string{"--format=json", "list", "sinks"})
+	if err != nil {
+		return nil, err
+	}
+	var sinks []pactlSink
+	if err := json.Unmarshal(output, &sinks); err != nil {
+		return nil, fmt.Errorf("failed to parse pactl sinks: %v", err)
+	}
+	return sinks, nil
+}
+
+// listPactlSinkInputs returns every playback stream
+func listPactlSinkInputs() ([]pactlSinkInput, error) {
+	output, err := SpawnProcess("pactl", []string{"--format=json", "list", "sink-inputs"})
+	if err != nil {
+		return nil, err
+	}
+	var inputs []pactlSinkInput
+	if err := json.Unmarshal(output, &inputs); err != nil {
+		return nil, fmt.Errorf("failed to parse pactl sink inputs: %v", err)
+	}
+	return inputs, nil
+}
+
+// playerSinkInputs finds the streams belonging to an MPRIS player by matching
+// its name against the stream's application properties
+func playerSinkInputs(player string) ([]pactlSinkInput, error) {
+	inputs, err := listPactlSinkInputs()
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(strings.SplitN(player, ".", 2)[0])
+	matched := []pactlSinkInput{}
+	for _, input := range inputs {
+		for _, property := range []string{"application.name", "application.process.binary", "application.id"} {
+			if strings.Contains(strings.ToLower(input.Properties[property]), name) {
+				matched = append(matched, input)
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+// matchSink resolves a sink by index, exact name or case-insensitive description match
+func matchSink(sinks []pactlSink, query string) (*pactlSink, error) {
+	if query == "" {
+		return nil, models.NewParamError("sink", i18n.T("no output given"))
+	}
+	if index, err := strconv.Atoi(query); err == nil {
+		for i := range sinks {
+			if sinks[i].Index == index {
+				return &sinks[i], nil
+			}
+		}
+	}
+	for i := range sinks {
+		if sinks[i].Name == query {
+			return &sinks[i], nil
+		}
+	}
+	lower := strings.ToLower(query)
+	for i := range sinks {
+		if strings.Contains(strings.ToLower(sinks[i].Description), lower) {
+			return &sinks[i], nil
+		}
+	}
+	return nil, models.NewParamError("sink", i18n.T("no audio output matches %q", query))
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"Blitz/config"
+	"bufio"
+	"context"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// headphoneMarkers identify headphone outputs in sink names, descriptions and ports
+var headphoneMarkers = []string{"bluez", "headphone", "headset", "earbud", "buds"}
+
+// StartAutoPause pauses the active player when headphones disconnect and the
+// sound would move to the speakers, like phones do. Enabled with
+// autoPause.headphones in the config.
+func StartAutoPause() {
+	if !config.Get().AutoPause.Headphones {
+		return
+	}
+
+	for {
+		if err := watchOutputChanges(); err != nil {
+			log.Println("Auto pause watcher stopped:", err)
+		}
+		// pactl exits when PipeWire restarts; try again shortly
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func watchOutputChanges() error {
+	cmd := exec.Command("pactl", "subscribe")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer cmd.Wait()
+
+	onHeadphones := defaultOutputIsHeadphones()
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		// The default sink changes with "server" events, a wired jack with "sink" ones
+		line := scanner.Text()
+		if !strings.Contains(line, "on sink #") && !strings.Contains(line, "on server") {
+			continue
+		}
+
+		wasOnHeadphones := onHeadphones
+		onHeadphones = defaultOutputIsHeadphones()
+		if wasOnHeadphones && !onHeadphones {
+			pauseForHeadphones()
+		}
+	}
+	return scanner.Err()
+}
+
+// defaultOutputIsHeadphones reports whether the default sink, on its active
+// port, plays to headphones
+func defaultOutputIsHeadphones() bool {
+	output, err := SpawnProcess("pactl", []string{"get-default-sink"})
+	if err != nil {
+		return false
+	}
+	defaultSink := strings.TrimSpace(string(output))
+
+	sinks, err := listPactlSinks()
+	if err != nil {
+		return false
+	}
+	for _, sink := range sinks {
+		if sink.Name != defaultSink {
+			continue
+		}
+		text := strings.ToLower(sink.Name + " " + sink.Description + " " + sink.ActivePort)
+		for _, marker := range headphoneMarkers {
+			if strings.Contains(text, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pauseForHeadphones() {
+	info, err := GetPlayerInfo()
+	if err != nil || info.Status != "Playing" {
+		return
+	}
+	if err := (MPRISProvider{Player: info.Player}).Control(context.Background(), "pause"); err != nil {
+		log.Println("Failed to pause after headphones disconnected:", err)
+		return
+	}
+	log.Println("⏸️ Headphones disconnected, paused", info.Player)
+}
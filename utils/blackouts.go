@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pollers that blackouts can pause
+const (
+	PollerMedia       = "media"
+	PollerAlarms      = "alarms"
+	PollerClock       = "clock"
+	PollerCompact     = "compact"
+	PollerGamepads    = "gamepads"
+	PollerGoroutines  = "goroutines"
+	PollerHost        = "host"
+	PollerLatency     = "latency"
+	PollerNetwork     = "network"
+	PollerPeripherals = "peripherals"
+	PollerScreen      = "screen"
+	PollerSensors     = "sensors"
+	PollerSpotifyAuth = "spotify_auth"
+	PollerZones       = "zones"
+)
+
+var pollerNames = []string{
+	PollerMedia, PollerAlarms, PollerClock, PollerCompact, PollerGamepads, PollerGoroutines,
+	PollerHost, PollerLatency, PollerNetwork, PollerPeripherals, PollerScreen, PollerSensors,
+	PollerSpotifyAuth, PollerZones,
+}
+
+// maxBlackout bounds the blackouts set with the blackout command
+const maxBlackout = 24 * time.Hour
+
+// blackoutWindow is a parsed config.Blackout
+type blackoutWindow struct {
+	pollers []string
+	window  quietWindow
+}
+
+// BlackoutState reports a paused poller in the blackout command
+type BlackoutState struct {
+	Poller string `json:"poller"`
+	Until  int64  `json:"until"`  // Unix seconds the poller runs again
+	Source string `json:"source"` // "config" for a configured window, "command" for the blackout command
+}
+
+var (
+	blackoutWindows     []blackoutWindow
+	blackoutWindowsOnce sync.Once
+
+	// manualBlackouts holds the blackouts set at runtime, by poller
+	manualBlackouts   = map[string]time.Time{}
+	manualBlackoutsMu sync.Mutex
+)
+
+// parsedBlackouts parses the configured blackouts once, skipping invalid ones
+func parsedBlackouts() []blackoutWindow {
+	blackoutWindowsOnce.Do(func() {
+		for _, cfg := range config.Get().Blackouts {
+			if err := checkPollerNames(cfg.Pollers); err != nil {
+				log.Println("❌ Ignoring blackout:", err)
+				continue
+			}
+			window, err := parseQuietWindow(cfg.QuietWindow)
+			if err != nil {
+				log.Println("❌ Ignoring blackout:", err)
+				continue
+			}
+			blackoutWindows = append(blackoutWindows, blackoutWindow{pollers: cfg.Pollers, window: window})
+		}
+	})
+	return blackoutWindows
+}
+
+// checkPollerNames fails on names that aren't pollers
+func checkPollerNames(pollers []string) error {
+	if len(pollers) == 0 {
+		return models.NewParamError("pollers", i18n.T("no pollers given"))
+	}
+	for _, poller := range pollers {
+		if !slices.Contains(pollerNames, poller) {
+			return models.NewParamError("pollers", i18n.T("unknown poller %q, use %s", poller, strings.Join(pollerNames, ", ")))
+		}
+	}
+	return nil
+}
+
+// blackedOutUntil returns when the blackout a poller is in ends, and what
+// set it
+func blackedOutUntil(poller string, now time.Time) (time.Time, string, bool) {
+	manualBlackoutsMu.Lock()
+	until, ok := manualBlackouts[poller]
+	if ok && !now.Before(until) {
+		delete(manualBlackouts, poller)
+		ok = false
+	}
+	manualBlackoutsMu.Unlock()
+	if ok {
+		return until, "command", true
+	}
+
+	for _, blackout := range parsedBlackouts() {
+		if !slices.Contains(blackout.pollers, poller) {
+			continue
+		}
+		if to, ok := blackout.window.until(now); ok {
+			return to, "config", true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// InBlackout reports whether a poller is paused right now
+func InBlackout(poller string) bool {
+	_, _, paused := blackedOutUntil(poller, time.Now())
+	return paused
+}
+
+// SetBlackout pauses pollers for minutes, e.g. the media poller while the
+// screen is recorded; 0 minutes resumes them. Configured windows still apply.
+func SetBlackout(pollers []string, minutes int) ([]BlackoutState, error) {
+	if err := checkPollerNames(pollers); err != nil {
+		return nil, models.NewError(models.ErrInvalidParams, err.Error(), nil)
+	}
+	duration := time.Duration(minutes) * time.Minute
+	if minutes < 0 || duration > maxBlackout {
+		return nil, models.NewError(models.ErrInvalidParams, i18n.T("minutes must be between 0 and %d", int(maxBlackout/time.Minute)), nil)
+	}
+
+	manualBlackoutsMu.Lock()
+	for _, poller := range pollers {
+		if minutes == 0 {
+			delete(manualBlackouts, poller)
+		} else {
+			manualBlackouts[poller] = time.Now().Add(duration)
+		}
+	}
+	manualBlackoutsMu.Unlock()
+
+	if minutes == 0 {
+		log.Println("🌙 Blackout lifted for", strings.Join(pollers, ", "))
+	} else {
+		log.Printf("🌙 Blackout for %s during %d minutes", strings.Join(pollers, ", "), minutes)
+	}
+	return GetBlackouts(), nil
+}
+
+// GetBlackouts lists the pollers that are paused right now
+func GetBlackouts() []BlackoutState {
+	now := time.Now()
+	states := []BlackoutState{}
+	for _, poller := range pollerNames {
+		if until, source, paused := blackedOutUntil(poller, now); paused {
+			states = append(states, BlackoutState{Poller: poller, Until: until.Unix(), Source: source})
+		}
+	}
+	return states
+}
@@ -1,13 +1,17 @@
 package utils
 
 import (
+	"Blitz/i18n"
+	"Blitz/models"
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 type BluetoothDevice struct {
-	Name         string `json:"name"`
+	Name         string `json:"name"`  // Name the device advertises
+	Alias        string `json:"alias"` // User-chosen name, same as Name unless set`
 	MACAddress   string `json:"mac"`
 	Battery      int    `json:"battery"`      // Average battery, -1 if not available
 	BatteryLeft  int    `json:"batteryLeft"`  // Left earbud battery, -1 if not available
@@ -17,6 +21,19 @@ type BluetoothDevice struct {
 	Connected    bool   `json:"connected"`
 }
 
+// knownBluetoothDevices remembers, per connected device, the last queried info
+// and whether it ever reported a battery level. Devices that don't are not
+// queried again until they reconnect.
+var (
+	knownBluetoothDevices = map[string]*knownBluetoothDevice{}
+	knownBluetoothMu      sync.Mutex
+)
+
+type knownBluetoothDevice struct {
+	device         BluetoothDevice
+	reportsBattery bool
+}
+
 // GetBluetoothDevices returns a list of connected Bluetooth devices with battery info
 func GetBluetoothDevices() ([]BluetoothDevice, error) {
 	// Get list of connected devices
@@ -25,94 +42,131 @@ func GetBluetoothDevices() ([]BluetoothDevice, error) {
 		return nil, err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	devices := []BluetoothDevice{}
 
-	// Parse each device
-	for _, line := range lines {
-		if line == "" {
+	knownBluetoothMu.Lock()
+	defer knownBluetoothMu.Unlock()
+	connected := map[string]bool{}
+
+	for _, device := range parseBluetoothDevices(string(output)) {
+		mac := device.MACAddress
+		connected[mac] = true
+
+		// Skip the subprocesses for devices that have shown they have no battery to report
+		if known, ok := knownBluetoothDevices[mac]; ok && !known.reportsBattery {
+			devices = append(devices, known.device)
 			continue
 		}
 
-		// Format: "Device MAC_ADDRESS Device_Name"
+		// Get detailed info for this device (including battery)
+		infoOutput, err := SpawnProcess("bluetoothctl", []string{"info", mac})
+		if err == nil {
+			parseBluetoothInfo(&device, string(infoOutput))
+
+			// Try to get individual battery info using GalaxyBudsClient or earbuds CLI
+			if strings.Contains(strings.ToLower(device.Name), "galaxy buds") ||
+				strings.Contains(strings.ToLower(device.Name), "buds") {
+				tryGalaxyBudsTools(&device, mac)
+			}
+		}
+
+		knownBluetoothDevices[mac] = &knownBluetoothDevice{
+			device: device,
+			reportsBattery: device.Battery >= 0 || device.BatteryLeft >= 0 ||
+				device.BatteryRight >= 0 || device.BatteryCase >= 0,
+		}
+		devices = append(devices, device)
+	}
+
+	// Forget disconnected devices, so they are queried again when they reconnect
+	for mac := range knownBluetoothDevices {
+		if !connected[mac] {
+			delete(knownBluetoothDevices, mac)
+		}
+	}
+
+	return devices, nil
+}
+
+// parseBluetoothDevices parses `bluetoothctl devices Connected`, one
+// "Device MAC_ADDRESS Device_Name" line per device. Batteries are unknown
+// until the device's info is parsed.
+func parseBluetoothDevices(output string) []BluetoothDevice {
+	devices := []BluetoothDevice{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
 		parts := strings.Fields(line)
-		if len(parts) < 3 {
+		if len(parts) < 3 || parts[0] != "Device" {
 			continue
 		}
 
-		mac := parts[1]
 		name := strings.Join(parts[2:], " ")
-
-		// Get device info (including battery)
-		device := BluetoothDevice{
+		devices = append(devices, BluetoothDevice{
 			Name:         name,
-			MACAddress:   mac,
+			Alias:        name,
+			MACAddress:   parts[1],
 			Battery:      -1, // default: not available
 			BatteryLeft:  -1,
 			BatteryRight: -1,
 			BatteryCase:  -1,
 			Icon:         "bluetooth",
 			Connected:    true,
+		})
+	}
+	return devices
+}
+
+// parseBluetoothInfo fills in what `bluetoothctl info MAC` reports about a
+// device: its batteries, names and icon
+func parseBluetoothInfo(device *BluetoothDevice, infoStr string) {
+	// Extract battery percentage if available (average/main)
+	batteryRegex := regexp.MustCompile(`Battery Percentage: [^\(]*\((\d+)\)`)
+	if matches := batteryRegex.FindStringSubmatch(infoStr); len(matches) > 1 {
+		battery := 0
+		if _, err := fmt.Sscanf(matches[1], "%d", &battery); err == nil {
+			device.Battery = battery
 		}
+	}
 
-		// Get detailed info for this device
-		infoOutput, err := SpawnProcess("bluetoothctl", []string{"info", mac})
-		if err == nil {
-			infoStr := string(infoOutput)
-
-			// Extract battery percentage if available (average/main)
-			batteryRegex := regexp.MustCompile(`Battery Percentage: [^\(]*\((\d+)\)`)
-			if matches := batteryRegex.FindStringSubmatch(infoStr); len(matches) > 1 {
-				battery := 0
-				if _, err := fmt.Sscanf(matches[1], "%d", &battery); err == nil {
-					device.Battery = battery
+	// Extract individual battery percentages for Galaxy Buds and similar devices
+	// Look for patterns like "Battery Percentage: 0x00nn (nn)" for left, right, case
+	batteryLines := strings.Split(infoStr, "\n")
+	for _, line := range batteryLines {
+		if strings.Contains(line, "Battery Percentage") {
+			// Try to extract multiple battery values
+			// Galaxy Buds format can vary, try different patterns
+			if strings.Contains(strings.ToLower(line), "left") {
+				if matches := batteryRegex.FindStringSubmatch(line); len(matches) > 1 {
+					fmt.Sscanf(matches[1], "%d", &device.BatteryLeft)
 				}
-			}
-
-			// Extract individual battery percentages for Galaxy Buds and similar devices
-			// Look for patterns like "Battery Percentage: 0x00nn (nn)" for left, right, case
-			batteryLines := strings.Split(infoStr, "\n")
-			for _, line := range batteryLines {
-				if strings.Contains(line, "Battery Percentage") {
-					// Try to extract multiple battery values
-					// Galaxy Buds format can vary, try different patterns
-					if strings.Contains(strings.ToLower(line), "left") {
-						if matches := batteryRegex.FindStringSubmatch(line); len(matches) > 1 {
-							fmt.Sscanf(matches[1], "%d", &device.BatteryLeft)
-						}
-					} else if strings.Contains(strings.ToLower(line), "right") {
-						if matches := batteryRegex.FindStringSubmatch(line); len(matches) > 1 {
-							fmt.Sscanf(matches[1], "%d", &device.BatteryRight)
-						}
-					} else if strings.Contains(strings.ToLower(line), "case") {
-						if matches := batteryRegex.FindStringSubmatch(line); len(matches) > 1 {
-							fmt.Sscanf(matches[1], "%d", &device.BatteryCase)
-						}
-					}
+			} else if strings.Contains(strings.ToLower(line), "right") {
+				if matches := batteryRegex.FindStringSubmatch(line); len(matches) > 1 {
+					fmt.Sscanf(matches[1], "%d", &device.BatteryRight)
+				}
+			} else if strings.Contains(strings.ToLower(line), "case") {
+				if matches := batteryRegex.FindStringSubmatch(line); len(matches) > 1 {
+					fmt.Sscanf(matches[1], "%d", &device.BatteryCase)
 				}
-			}
-
-			// For Galaxy Buds, try using the 'Battery' GATT characteristic directly
-			// This might require parsing UUID-based battery info
-			parseGalaxyBudsBattery(&device, infoStr)
-
-			// Try to get individual battery info using GalaxyBudsClient or earbuds CLI
-			if strings.Contains(strings.ToLower(device.Name), "galaxy buds") ||
-				strings.Contains(strings.ToLower(device.Name), "buds") {
-				tryGalaxyBudsTools(&device, mac)
-			}
-
-			// Extract icon if available
-			iconRegex := regexp.MustCompile(`Icon: (.+)`)
-			if matches := iconRegex.FindStringSubmatch(infoStr); len(matches) > 1 {
-				device.Icon = strings.TrimSpace(matches[1])
 			}
 		}
+	}
 
-		devices = append(devices, device)
+	// For Galaxy Buds, try using the 'Battery' GATT characteristic directly
+	// This might require parsing UUID-based battery info
+	parseGalaxyBudsBattery(device, infoStr)
+
+	// `devices` lists aliases; the original name is only in `info`
+	if matches := regexp.MustCompile(`(?m)^\s*Name: (.+)$`).FindStringSubmatch(infoStr); len(matches) > 1 {
+		device.Name = strings.TrimSpace(matches[1])
+	}
+	if matches := regexp.MustCompile(`(?m)^\s*Alias: (.+)$`).FindStringSubmatch(infoStr); len(matches) > 1 {
+		device.Alias = strings.TrimSpace(matches[1])
 	}
 
-	return devices, nil
+	// Extract icon if available
+	iconRegex := regexp.MustCompile(`Icon: (.+)`)
+	if matches := iconRegex.FindStringSubmatch(infoStr); len(matches) > 1 {
+		device.Icon = strings.TrimSpace(matches[1])
+	}
 }
 
 // parseGalaxyBudsBattery attempts to extract individual battery info for Galaxy Buds
@@ -241,3 +295,31 @@ func tryDirectGalaxyBudsRead(device *BluetoothDevice, mac string) {
 	_ = device
 	_ = mac
 }
+
+// SetBluetoothAlias renames a device through the BlueZ Alias property.
+// An empty alias restores the name the device advertises.
+func SetBluetoothAlias(mac, alias string) error {
+	if !regexp.MustCompile(`^([0-9A-Fa-f]{2}:){5}[0-9A-Fa-f]{2}$`).MatchString(mac) {
+		return models.NewParamError("mac", i18n.T("invalid bluetooth address %q", mac))
+	}
+
+	_, err := SpawnProcess("dbus-send", []string{
+		"--system",
+		"--print-reply",
+		"--dest=org.bluez",
+		fmt.Sprintf("/org/bluez/hci0/dev_%s", strings.ReplaceAll(strings.ToUpper(mac), ":", "_")),
+		"org.freedesktop.DBus.Properties.Set",
+		"string:org.bluez.Device1",
+		"string:Alias",
+		"variant:string:" + alias,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Make the next listing pick up the new alias
+	knownBluetoothMu.Lock()
+	delete(knownBluetoothDevices, strings.ToUpper(mac))
+	knownBluetoothMu.Unlock()
+	return nil
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BrowserPlayer is a web player (YouTube, SoundCloud, ...) as reported by the
+// companion browser extension, which reads the page instead of relying on
+// the browser's sparse MPRIS metadata
+type BrowserPlayer struct {
+	ID           string             `json:"id"`   // Chosen by the extension, e.g. the tab id
+	Site         string             `json:"site"` // e.g. "youtube"
+	Title        string             `json:"title"`
+	Artist       string             `json:"artist"`
+	Album        string             `json:"album"`
+	Artwork      string             `json:"artwork"` // URL of the cover or thumbnail
+	URL          string             `json:"url"`
+	PositionMs   int64              `json:"positionMs"`
+	LengthMs     int64              `json:"lengthMs"`
+	Playing      bool               `json:"playing"`
+	Volume       int                `json:"volume"` // 0-100
+	Capabilities PlayerCapabilities `json:"capabilities"`
+	Closed       bool               `json:"closed,omitempty"` // The tab closed or stopped playing media
+
+	owner    string
+	reported time.Time
+	send     BrowserSender
+}
+
+// BrowserControl is sent to the extension to control one of its players
+type BrowserControl struct {
+	Player string `json:"player"`
+	Action string `json:"action"` // A player action, "seek" or "volume"
+	Value  int64  `json:"value,omitempty"`
+}
+
+// Topic implements models.Payload
+func (BrowserControl) Topic() string { return "browser_control" }
+
+// Version implements models.Payload
+func (BrowserControl) Version() int { return 1 }
+
+// BrowserSender delivers a control message to the extension that reported a player
+type BrowserSender func(BrowserControl) error
+
+// browserPlayerNames are the MPRIS names of browsers, whose entries the
+// extension's reports replace
+var browserPlayerNames = []string{"firefox", "chromium", "chrome", "brave", "vivaldi", "opera", "edge", "plasma-browser-integration"}
+
+var (
+	browserPlayers   = map[string]*BrowserPlayer{}
+	browserPlayersMu sync.Mutex
+)
+
+// ReportBrowserPlayer records what the extension connected as owner reports
+// about one of its players. Reports with Closed remove the player.
+func ReportBrowserPlayer(owner string, report BrowserPlayer, send BrowserSender) {
+	browserPlayersMu.Lock()
+	defer browserPlayersMu.Unlock()
+
+	key := owner + "/" + report.ID
+	if report.Closed {
+		delete(browserPlayers, key)
+		return
+	}
+	report.owner, report.reported, report.send = owner, time.Now(), send
+	browserPlayers[key] = &report
+}
+
+// RemoveBrowserPlayers forgets the players of an extension that disconnected
+func RemoveBrowserPlayers(owner string) {
+	browserPlayersMu.Lock()
+	defer browserPlayersMu.Unlock()
+
+	for key, player := range browserPlayers {
+		if player.owner == owner {
+			delete(browserPlayers, key)
+		}
+	}
+}
+
+// activeBrowserPlayer returns a snapshot of the playing web player reported
+// last, else of the paused one reported last, or nil
+func activeBrowserPlayer() *BrowserPlayer {
+	browserPlayersMu.Lock()
+	defer browserPlayersMu.Unlock()
+
+	var active *BrowserPlayer
+	for _, player := range browserPlayers {
+		if active == nil || (player.Playing && !active.Playing) ||
+			(player.Playing == active.Playing && player.reported.After(active.reported)) {
+			active = player
+		}
+	}
+	if active == nil {
+		return nil
+	}
+	snapshot := *active
+	// The extension reports on changes, so extrapolate the position in between
+	if snapshot.Playing {
+		snapshot.PositionMs += time.Since(snapshot.reported).Milliseconds()
+		if snapshot.LengthMs > 0 {
+			snapshot.PositionMs = min(snapshot.PositionMs, snapshot.LengthMs)
+		}
+	}
+	return &snapshot
+}
+
+// isBrowserPlayer reports whether an MPRIS player name belongs to a browser,
+// e.g. "firefox.instance_1_42"
+func isBrowserPlayer(name string) bool {
+	name = strings.ToLower(name)
+	return slices.ContainsFunc(browserPlayerNames, func(browser string) bool {
+		return strings.HasPrefix(name, browser)
+	})
+}
+
+// MediaInfo converts the report to the shape of MPRIS players
+func (p *BrowserPlayer) MediaInfo() MediaInfo {
+	status := "Paused"
+	if p.Playing {
+		status = "Playing"
+	}
+	capabilities := p.Capabilities
+	return MediaInfo{
+		Title:        p.Title,
+		Artist:       p.Artist,
+		Album:        p.Album,
+		Artwork:      p.Artwork,
+		Position:     strconv.FormatInt(p.PositionMs*1000, 10),
+		Length:       strconv.FormatInt(p.LengthMs*1000, 10),
+		Status:       status,
+		Player:       "browser:" + p.Site,
+		TrackID:      p.URL,
+		URL:          p.URL,
+		Capabilities: &capabilities,
+	}
+}
+
+// BrowserProvider controls a web player through the browser extension
+type BrowserProvider struct {
+	player *BrowserPlayer
+}
+
+func (BrowserProvider) Name() string { return "browser" }
+
+func (p BrowserProvider) Control(_ context.Context, action string) error {
+	if !slices.Contains(PlayerActions, action) {
+		return fmt.Errorf("unsupported player action: %s", action)
+	}
+	return p.send(BrowserControl{Player: p.player.ID, Action: action}, func(player *BrowserPlayer) {
+		switch action {
+		case "play":
+			player.Playing = true
+		case "pause", "stop":
+			player.Playing = false
+		case "play-pause":
+			player.Playing = !player.Playing
+		}
+	})
+}
+
+func (p BrowserProvider) Seek(_ context.Context, positionMs int) error {
+	return p.send(BrowserControl{Player: p.player.ID, Action: "seek", Value: int64(positionMs)}, func(player *BrowserPlayer) {
+		player.PositionMs = int64(positionMs)
+	})
+}
+
+func (p BrowserProvider) SetVolume(_ context.Context, percent int) error {
+	return p.send(BrowserControl{Player: p.player.ID, Action: "volume", Value: int64(percent)}, func(player *BrowserPlayer) {
+		player.Volume = percent
+	})
+}
+
+// send delivers control to the extension and applies its expected effect to
+// the recorded player, so State is right before the extension reports again
+func (p BrowserProvider) send(control BrowserControl, effect func(*BrowserPlayer)) error {
+	if err := p.player.send(control); err != nil {
+		return models.NewError(models.ErrCommandFailed, i18n.T("the browser extension is not reachable"), err)
+	}
+
+	browserPlayersMu.Lock()
+	defer browserPlayersMu.Unlock()
+	if player, ok := browserPlayers[p.player.owner+"/"+p.player.ID]; ok {
+		if player.Playing {
+			player.PositionMs += time.Since(player.reported).Milliseconds()
+		}
+		player.reported = time.Now()
+		effect(player)
+	}
+	return nil
+}
+
+func (BrowserProvider) SetShuffle(_ context.Context, enabled bool) error {
+	return models.NewError(models.ErrCommandFailed, i18n.T("web players don't support %s", "shuffle"), nil)
+}
+
+func (BrowserProvider) SetRepeat(_ context.Context, mode string) error {
+	return models.NewError(models.ErrCommandFailed, i18n.T("web players don't support %s", "repeat"), nil)
+}
+
+// State returns the last report; the extension reports the change a command
+// caused shortly after
+func (p BrowserProvider) State() (*PlayerState, error) {
+	player := p.player
+	if current := activeBrowserPlayer(); current != nil && current.ID == player.ID && current.owner == player.owner {
+		player = current
+	}
+	return &PlayerState{Playing: player.Playing, PositionMs: player.PositionMs, Volume: player.Volume}, nil
+}
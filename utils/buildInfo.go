@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X Blitz/utils.Version=0.0.2 -X Blitz/utils.Commit=$(git rev-parse HEAD) -X Blitz/utils.BuildDate=$(date -u +%FT%TZ)"
+//
+// Commit and BuildDate fall back to the commit go build records from git
+// and its time.
+var (
+	Version   = "0.0.1"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// features are the optional parts compiled into this build. Blitz talks to
+// D-Bus through dbus-send and keeps its state in JSON documents, so neither a
+// native D-Bus client nor SQLite is linked in yet.
+var features = map[string]bool{
+	"dbus":   false,
+	"sqlite": false,
+}
+
+// BuildInfo says exactly which build is running, for bug reports
+type BuildInfo struct {
+	Version   string          `json:"version"`
+	Commit    string          `json:"commit,omitempty"`
+	Modified  bool            `json:"modified,omitempty"` // Built from a tree with uncommitted changes
+	BuildDate string          `json:"buildDate,omitempty"`
+	GoVersion string          `json:"goVersion"`
+	Platform  string          `json:"platform"` // GOOS/GOARCH
+	Features  map[string]bool `json:"features"`
+}
+
+// GetBuildInfo returns the embedded build information
+func GetBuildInfo() BuildInfo {
+	return buildInfo()
+}
+
+var buildInfo = sync.OnceValue(func() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Features:  features,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+})
+
+// userAgent identifies Blitz to the web APIs it calls
+func userAgent() string {
+	return "Blitz/" + Version + " ( https://github.com/codershubinc/Blitz )"
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChromecastProvider controls a Chromecast (or Google/Nest speaker) through catt
+type ChromecastProvider struct {
+	Device string
+}
+
+func (ChromecastProvider) Name() string { return "chromecast" }
+
+func (p ChromecastProvider) catt(ctx context.Context, args ...string) ([]byte, error) {
+	if p.Device != "" {
+		args = append([]string{"-d", p.Device}, args...)
+	}
+	return SpawnProcessContext(ctx, "catt", args)
+}
+
+func (p ChromecastProvider) Control(ctx context.Context, action string) error {
+	commands := map[string]string{
+		"play":       "play",
+		"pause":      "pause",
+		"play-pause": "play_toggle",
+		"stop":       "stop",
+		"next":       "skip",
+	}
+	command, ok := commands[action]
+	if !ok {
+		return fmt.Errorf("unsupported chromecast action: %s", action)
+	}
+	_, err := p.catt(ctx, command)
+	return err
+}
+
+func (p ChromecastProvider) Seek(ctx context.Context, positionMs int) error {
+	if positionMs < 0 {
+		return models.NewParamError("position_ms", i18n.T("position must not be negative"))
+	}
+	_, err := p.catt(ctx, "seek", strconv.Itoa(positionMs/1000))
+	return err
+}
+
+func (p ChromecastProvider) SetVolume(ctx context.Context, percent int) error {
+	if percent < 0 || percent > 100 {
+		return models.NewParamError("volume", i18n.T("volume must be between 0 and 100"))
+	}
+	_, err := p.catt(ctx, "volume", strconv.Itoa(percent))
+	return err
+}
+
+func (ChromecastProvider) SetShuffle(_ context.Context, enabled bool) error {
+	return fmt.Errorf("shuffle is not supported on chromecast")
+}
+
+func (ChromecastProvider) SetRepeat(_ context.Context, mode string) error {
+	return fmt.Errorf("repeat is not supported on chromecast")
+}
+
+// Status returns the cast state as reported by `catt status` ("Playing",
+// "Paused", ...), or "Stopped" when nothing is cast
+func (p ChromecastProvider) Status() (string, error) {
+	status, err := p.status()
+	if err != nil {
+		return "", err
+	}
+	state := strings.ToLower(status["State"])
+	if state == "" {
+		return "Stopped", nil
+	}
+	return strings.ToUpper(state[:1]) + state[1:], nil
+}
+
+func (p ChromecastProvider) State() (*PlayerState, error) {
+	status, err := p.status()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &PlayerState{Playing: strings.EqualFold(status["State"], "PLAYING")}
+	state.Volume, _ = strconv.Atoi(status["Volume"])
+	// Time: 0:01:23 / 0:04:00 (34%)
+	if elapsed, _, ok := strings.Cut(status["Time"], " / "); ok {
+		state.PositionMs = parseClockMs(elapsed)
+	}
+	return state, nil
+}
+
+// status parses the "Key: value" lines of `catt status`
+func (p ChromecastProvider) status() (map[string]string, error) {
+	output, err := p.catt(context.Background(), "status")
+	if err != nil {
+		return nil, err
+	}
+	status := map[string]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			status[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return status, nil
+}
+
+// parseClockMs converts "h:mm:ss" or "mm:ss" into milliseconds
+func parseClockMs(clock string) int64 {
+	var total int64
+	for _, part := range strings.Split(strings.TrimSpace(clock), ":") {
+		value, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + value
+	}
+	return total * 1000
+}
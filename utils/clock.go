@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"Blitz/config"
+	"encoding/binary"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// driftCheckInterval is how often the clock is compared with the NTP server
+	driftCheckInterval = 15 * time.Minute
+	// maxClockDrift is the offset from NTP time at which the clock counts as
+	// drifting; beyond it token expiry math and shared clocks go wrong
+	maxClockDrift = 2 * time.Second
+	// ntpEpochOffset is the seconds between the NTP epoch (1900) and the Unix epoch
+	ntpEpochOffset = 2208988800
+)
+
+// ClockInfo is the host's time and how far it can be trusted
+type ClockInfo struct {
+	Time            int64  `json:"time"`            // Unix milliseconds
+	Timezone        string `json:"timezone"`        // IANA name, e.g. "Europe/Berlin"
+	UTCOffset       int    `json:"utcOffset"`       // Seconds east of UTC
+	NTPSynchronized bool   `json:"ntpSynchronized"` // The system reports its clock as synchronized
+	DriftMs         *int64 `json:"driftMs,omitempty"`
+	DriftCheckedAt  int64  `json:"driftCheckedAt,omitempty"`
+	Drifting        bool   `json:"drifting"` // The clock is off by more than 2 seconds
+}
+
+// Topic implements models.Payload
+func (ClockInfo) Topic() string { return "clock" }
+
+// Version implements models.Payload
+func (ClockInfo) Version() int { return 1 }
+
+var (
+	clockDrift     *time.Duration
+	driftCheckedAt time.Time
+	clockMu        sync.Mutex
+)
+
+// GetClockInfo reports the host time, timezone and NTP status. The offset from
+// the NTP server in the config is measured every 15 minutes.
+func GetClockInfo() ClockInfo {
+	now := time.Now()
+	_, offset := now.Zone()
+	info := ClockInfo{
+		Time:            now.UnixMilli(),
+		Timezone:        timezoneName(),
+		UTCOffset:       offset,
+		NTPSynchronized: ntpSynchronized(),
+	}
+
+	clockMu.Lock()
+	defer clockMu.Unlock()
+
+	server := config.Get().Clock.NTPServer
+	if server != "" && time.Since(driftCheckedAt) > driftCheckInterval {
+		driftCheckedAt = now
+		drift, err := ntpOffset(server)
+		if err != nil {
+			log.Printf("Failed to query NTP server %s: %v", server, err)
+		} else {
+			if drift.Abs() > maxClockDrift && (clockDrift == nil || clockDrift.Abs() <= maxClockDrift) {
+				log.Printf("⚠️ The clock is off by %s", drift.Round(time.Millisecond))
+			}
+			clockDrift = &drift
+		}
+	}
+
+	if clockDrift != nil {
+		ms := clockDrift.Milliseconds()
+		info.DriftMs = &ms
+		info.DriftCheckedAt = driftCheckedAt.Unix()
+		info.Drifting = clockDrift.Abs() > maxClockDrift
+	}
+	return info
+}
+
+// timezoneName returns the IANA name of the local timezone: $TZ, else what
+// timedatectl reports, else the zoneinfo file /etc/localtime links to
+func timezoneName() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		return tz
+	}
+	if output, err := SpawnProcess("timedatectl", []string{"show", "-p", "Timezone", "--value"}); err == nil {
+		if tz := strings.TrimSpace(string(output)); tz != "" {
+			return tz
+		}
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if _, tz, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return tz
+		}
+	}
+	return time.Now().Location().String()
+}
+
+// ntpSynchronized asks systemd whether the clock is synchronized
+func ntpSynchronized() bool {
+	output, err := SpawnProcess("timedatectl", []string{"show", "-p", "NTPSynchronized", "--value"})
+	return err == nil && strings.TrimSpace(string(output)) == "yes"
+}
+
+// ntpOffset measures how far the local clock is ahead of an NTP server with
+// a single SNTP request
+func ntpOffset(server string) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(server, "123"), 2*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// LI 0, version 4, mode 3 (client)
+	request := make([]byte, 48)
+	request[0] = 0x23
+	sent := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+
+	response := make([]byte, 48)
+	if _, err := conn.Read(response); err != nil {
+		return 0, err
+	}
+	received := time.Now()
+
+	serverReceived := ntpTime(response[32:40])
+	serverSent := ntpTime(response[40:48])
+	// The server's time minus ours, averaged over both directions
+	ahead := (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2
+	return -ahead, nil
+}
+
+// ntpTime decodes a 64-bit NTP timestamp
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(seconds, fraction*int64(time.Second)>>32)
+}
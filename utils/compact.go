@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"Blitz/config"
+	"path/filepath"
+	"strconv"
+)
+
+// Compact play states
+const (
+	CompactStopped = 0
+	CompactPlaying = 1
+	CompactPaused  = 2
+)
+
+// Compact is a tiny summary with a fixed schema for microcontroller clients:
+// every field is always present and unknown numbers are -1
+type Compact struct {
+	Text    string `json:"t"` // Track text from the "compact" display template, else display_text
+	State   int    `json:"s"` // 0 stopped, 1 playing, 2 paused
+	Volume  int    `json:"v"` // Player volume in percent
+	Battery int    `json:"b"` // Host battery in percent, else the emptiest peripheral
+}
+
+// Topic implements models.Payload
+func (Compact) Topic() string { return "compact" }
+
+// Version implements models.Payload
+func (Compact) Version() int { return 1 }
+
+// GetCompact collects the compact summary
+func GetCompact() Compact {
+	compact := Compact{Volume: -1, Battery: compactBattery()}
+
+	info, err := GetPlayerInfo()
+	if err != nil || info.Player == "" {
+		return compact
+	}
+	if PrivacyMode() {
+		info = info.Private()
+	}
+	info = RenderDisplayText(info)
+
+	compact.Text = info.DisplayText
+	if text, ok := info.DisplayTexts["compact"]; ok {
+		compact.Text = text
+	}
+	if maxLength := config.Get().Compact.MaxLength; maxLength > 0 {
+		if runes := []rune(compact.Text); len(runes) > maxLength {
+			compact.Text = string(runes[:maxLength])
+		}
+	}
+
+	switch info.Status {
+	case "Playing":
+		compact.State = CompactPlaying
+	case "Paused":
+		compact.State = CompactPaused
+	}
+
+	var provider MusicProvider = MPRISProvider{Player: info.Player}
+	if browser := activeBrowserPlayer(); browser != nil && info.Player == browser.MediaInfo().Player {
+		provider = BrowserProvider{player: browser}
+	}
+	if state, err := provider.State(); err == nil {
+		compact.Volume = state.Volume
+	}
+	return compact
+}
+
+// compactBattery returns the charge of the host battery, or on machines
+// without one the emptiest peripheral, or -1
+func compactBattery() int {
+	batteries, _ := filepath.Glob("/sys/class/power_supply/BAT*")
+	for _, battery := range batteries {
+		if capacity, err := strconv.Atoi(readSysfs(filepath.Join(battery, "capacity"))); err == nil {
+			return capacity
+		}
+	}
+
+	if !ModuleAvailable(ModuleSystem) {
+		return -1
+	}
+	peripherals, err := GetPeripherals()
+	if err != nil {
+		return -1
+	}
+	lowest := -1
+	for _, peripheral := range peripherals {
+		if lowest < 0 || int(peripheral.Percentage) < lowest {
+			lowest = int(peripheral.Percentage)
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// External services Blitz talks to, as reported in /status
+const (
+	DependencySpotify       = "spotify"
+	DependencyArtwork       = "artwork"
+	DependencyArtworkLookup = "artwork_lookup"
+	DependencyPush          = "push"
+	DependencyRadio         = "radio"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"    // Requests go through
+	BreakerOpen     = "open"      // Requests fail right away until the cooldown is over
+	BreakerHalfOpen = "half_open" // One request tries whether the service is back
+)
+
+const (
+	breakerThreshold = 5                // Failures in a row that open the breaker
+	breakerCooldown  = 30 * time.Second // How long an open breaker rejects requests
+	dependencyWindow = 50               // Recent requests the error rate and latency cover
+)
+
+// ErrCircuitOpen is returned for requests to a service that kept failing,
+// without sending them
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// DependencyStatus reports an external service in /status, so dashboards
+// can say e.g. "Spotify API degraded"
+type DependencyStatus struct {
+	Name         string  `json:"name"`
+	State        string  `json:"state"` // "closed", "open" or "half_open"
+	Requests     int64   `json:"requests"`
+	Failures     int64   `json:"failures"`
+	ErrorRate    float64 `json:"errorRate"`    // Share of the last 50 requests that failed
+	AvgLatencyMs float64 `json:"avgLatencyMs"` // Of the last 50 requests
+	LastError    string  `json:"lastError,omitempty"`
+	OpenUntil    int64   `json:"openUntil,omitempty"` // Unix time the open breaker lets a request through again
+}
+
+// requestSample is the outcome of one request
+type requestSample struct {
+	latency time.Duration
+	failed  bool
+}
+
+// dependency keeps the metrics and the circuit breaker of one service
+type dependency struct {
+	name string
+
+	mu        sync.Mutex
+	requests  int64
+	failures  int64
+	recent    []requestSample // Ring of the last dependencyWindow requests
+	next      int
+	lastError string
+	failing   int       // Failures in a row
+	openUntil time.Time // Zero while the breaker is closed
+	probing   bool      // A half-open trial request is in flight
+}
+
+var (
+	dependencies   = map[string]*dependency{}
+	dependenciesMu sync.Mutex
+)
+
+// trackDependency returns the dependency called name, registering it on first use
+func trackDependency(name string) *dependency {
+	dependenciesMu.Lock()
+	defer dependenciesMu.Unlock()
+	if d, ok := dependencies[name]; ok {
+		return d
+	}
+	d := &dependency{name: name}
+	dependencies[name] = d
+	return d
+}
+
+// admit reports whether a request may be sent: always while the breaker is
+// closed, never while it is open, and one at a time once the cooldown is over
+func (d *dependency) admit() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.openUntil.IsZero() {
+		return nil
+	}
+	if time.Now().Before(d.openUntil) || d.probing {
+		return fmt.Errorf("%w for %s, retrying after %s", ErrCircuitOpen, d.name, d.openUntil.Format(time.TimeOnly))
+	}
+	d.probing = true
+	return nil
+}
+
+// record counts a request's outcome and opens or closes the breaker
+func (d *dependency) record(latency time.Duration, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.requests++
+	sample := requestSample{latency: latency, failed: err != nil}
+	if len(d.recent) < dependencyWindow {
+		d.recent = append(d.recent, sample)
+	} else {
+		d.recent[d.next] = sample
+		d.next = (d.next + 1) % dependencyWindow
+	}
+
+	if err == nil {
+		if !d.openUntil.IsZero() {
+			log.Printf("✅ %s is back, closing its circuit breaker", d.name)
+		}
+		d.failing = 0
+		d.openUntil = time.Time{}
+		d.probing = false
+		return
+	}
+
+	d.failures++
+	d.failing++
+	d.lastError = err.Error()
+	if d.probing || d.failing == breakerThreshold {
+		log.Printf("🔌 %s failed %d times in a row, pausing requests for %s: %v", d.name, d.failing, breakerCooldown, err)
+		d.openUntil = time.Now().Add(breakerCooldown)
+		d.probing = false
+	}
+}
+
+func (d *dependency) status() DependencyStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := DependencyStatus{
+		Name:      d.name,
+		State:     BreakerClosed,
+		Requests:  d.requests,
+		Failures:  d.failures,
+		LastError: d.lastError,
+	}
+	switch {
+	case d.openUntil.IsZero():
+	case time.Now().Before(d.openUntil):
+		status.State = BreakerOpen
+		status.OpenUntil = d.openUntil.Unix()
+	default:
+		status.State = BreakerHalfOpen
+	}
+
+	if len(d.recent) > 0 {
+		var total time.Duration
+		failed := 0
+		for _, sample := range d.recent {
+			total += sample.latency
+			if sample.failed {
+				failed++
+			}
+		}
+		status.ErrorRate = float64(failed) / float64(len(d.recent))
+		status.AvgLatencyMs = float64(total.Microseconds()) / float64(len(d.recent)) / 1000
+	}
+	return status
+}
+
+// roundTrip sends a request through the breaker and records how it went.
+// Server errors count as failures; requests the caller cancelled don't count.
+func (d *dependency) roundTrip(transport http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if err := d.admit(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	latency := time.Since(start)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		d.mu.Lock()
+		d.probing = false
+		d.mu.Unlock()
+	case err != nil:
+		d.record(latency, err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		d.record(latency, fmt.Errorf("%s answered %s", req.URL.Host, resp.Status))
+	default:
+		d.record(latency, nil)
+	}
+	return resp, err
+}
+
+// GetDependencyStatus reports the external services that were used, by name
+func GetDependencyStatus() []DependencyStatus {
+	dependenciesMu.Lock()
+	list := make([]DependencyStatus, 0, len(dependencies))
+	for _, d := range dependencies {
+		list = append(list, d.status())
+	}
+	dependenciesMu.Unlock()
+
+	slices.SortFunc(list, func(a, b DependencyStatus) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// displayInputs maps input names to the values of the DDC/CI input source
+// feature (VCP 0x60) most monitors and TVs use
+var displayInputs = map[string]int{
+	"vga1":  0x01,
+	"vga2":  0x02,
+	"dvi1":  0x03,
+	"dvi2":  0x04,
+	"dp1":   0x0f,
+	"dp2":   0x10,
+	"hdmi1": 0x11,
+	"hdmi2": 0x12,
+	"usbc":  0x1b,
+}
+
+// DisplayInput reports the input a monitor shows
+type DisplayInput struct {
+	Display int    `json:"display"` // ddcutil's display number
+	Input   string `json:"input"`   // e.g. "hdmi1", or the raw value as "0x1b" for unknown inputs
+	Value   int    `json:"value"`   // The VCP 0x60 value
+}
+
+// displayPowerCommand returns the command that turns the screens on or off
+// in the running session: the compositor's IPC on Wayland, DPMS on X11
+func displayPowerCommand(on bool) (string, []string, error) {
+	state := "off"
+	if on {
+		state = "on"
+	}
+	switch {
+	case os.Getenv("SWAYSOCK") != "":
+		return "swaymsg", []string{"output", "*", "power", state}, nil
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+		return "hyprctl", []string{"dispatch", "dpms", state}, nil
+	case strings.Contains(os.Getenv("XDG_CURRENT_DESKTOP"), "KDE") && os.Getenv("WAYLAND_DISPLAY") != "":
+		return "kscreen-doctor", []string{"--dpms", state}, nil
+	case os.Getenv("DISPLAY") != "":
+		return "xset", []string{"dpms", "force", state}, nil
+	}
+	return "", nil, models.NewError(models.ErrExternalToolMissing, i18n.T("no supported compositor or X server to blank the screen"), nil)
+}
+
+// SetDisplayPower turns the screens on or off, e.g. to wake the TV before playback
+func SetDisplayPower(on bool) error {
+	command, args, err := displayPowerCommand(on)
+	if err != nil {
+		return err
+	}
+	if _, err := SpawnProcess(command, args); err != nil {
+		return err
+	}
+	// X11 blanks again right away when the screensaver timed out meanwhile
+	if on && command == "xset" {
+		SpawnProcess("xset", []string{"s", "reset"})
+	}
+	return nil
+}
+
+// parseDisplayInput accepts an input name (HDMI-1, hdmi1, DP2, ...) or a
+// raw VCP 0x60 value (17, 0x11) for inputs with vendor specific values
+func parseDisplayInput(input string) (int, error) {
+	name := strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(input))
+	if value, ok := displayInputs[name]; ok {
+		return value, nil
+	}
+	if value, err := strconv.ParseInt(name, 0, 16); err == nil && value > 0 {
+		return int(value), nil
+	}
+	return 0, models.NewError(models.ErrInvalidParams, i18n.T("unknown input %q, use e.g. hdmi1, hdmi2, dp1, usbc or a VCP value", input), nil)
+}
+
+// displayInputName names a VCP 0x60 value
+func displayInputName(value int) string {
+	for name, known := range displayInputs {
+		if known == value {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%02x", value)
+}
+
+// SetDisplayInput switches a monitor to another input over DDC/CI, e.g. the
+// TV to the media PC's HDMI port. Display is ddcutil's display number.
+func SetDisplayInput(display int, input string) (DisplayInput, error) {
+	value, err := parseDisplayInput(input)
+	if err != nil {
+		return DisplayInput{}, err
+	}
+	if _, err := SpawnProcess("ddcutil", []string{"--display", strconv.Itoa(display), "setvcp", "60", fmt.Sprintf("0x%02x", value)}); err != nil {
+		return DisplayInput{}, err
+	}
+	return DisplayInput{Display: display, Input: displayInputName(value), Value: value}, nil
+}
+
+// GetDisplayInput reads which input a monitor shows over DDC/CI
+func GetDisplayInput(display int) (DisplayInput, error) {
+	output, err := SpawnProcess("ddcutil", []string{"--display", strconv.Itoa(display), "getvcp", "60", "--brief"})
+	if err != nil {
+		return DisplayInput{}, err
+	}
+	value, err := parseDDCInput(string(output))
+	if err != nil {
+		return DisplayInput{}, models.NewError(models.ErrCommandFailed, i18n.T("%s failed", "ddcutil"), err)
+	}
+	return DisplayInput{Display: display, Input: displayInputName(value), Value: value}, nil
+}
+
+// parseDDCInput reads the value from `ddcutil getvcp 60 --brief`, e.g.
+// "VCP 60 SNC x11"
+func parseDDCInput(output string) (int, error) {
+	fields := strings.Fields(output)
+	if len(fields) < 4 || fields[0] != "VCP" {
+		return 0, fmt.Errorf("unexpected ddcutil output %q", strings.TrimSpace(output))
+	}
+	value, err := strconv.ParseInt(strings.TrimPrefix(fields[3], "x"), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected ddcutil output %q", strings.TrimSpace(output))
+	}
+	return int(value), nil
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"Blitz/config"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+var (
+	displayTemplate  *template.Template
+	displayTemplates map[string]*template.Template
+	displayOnce      sync.Once
+)
+
+// displayFuncs are available in display templates besides the built-in ones
+var displayFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// truncate shortens s to n characters, ending with "…" when it was longer
+	"truncate": func(n int, s string) string {
+		runes := []rune(s)
+		if n <= 0 || len(runes) <= n {
+			return s
+		}
+		return string(runes[:n-1]) + "…"
+	},
+	// duration formats the microseconds of Position and Length as "3:07"
+	"duration": func(micros string) string {
+		value, err := strconv.ParseFloat(micros, 64)
+		if err != nil {
+			return ""
+		}
+		seconds := int(value / 1e6)
+		return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+	},
+}
+
+// loadDisplayTemplates parses the display templates once. An invalid template
+// is logged and left out.
+func loadDisplayTemplates() {
+	displayOnce.Do(func() {
+		cfg := config.Get().Display
+		if cfg.Template != "" {
+			parsed, err := template.New("display").Funcs(displayFuncs).Parse(cfg.Template)
+			if err != nil {
+				log.Println("❌ Invalid display.template:", err)
+			} else {
+				displayTemplate = parsed
+			}
+		}
+
+		displayTemplates = map[string]*template.Template{}
+		for name, text := range cfg.Templates {
+			parsed, err := template.New(name).Funcs(displayFuncs).Parse(text)
+			if err != nil {
+				log.Printf("❌ Invalid display template %s: %v", name, err)
+				continue
+			}
+			displayTemplates[name] = parsed
+		}
+	})
+}
+
+// RenderDisplayText fills DisplayText and DisplayTexts from the display
+// templates, so clients like OLED tickers and status bars need no formatting
+// logic. They stay empty while nothing is playing.
+func RenderDisplayText(info MediaInfo) MediaInfo {
+	loadDisplayTemplates()
+	if info.Title == "" && info.Artist == "" {
+		return info
+	}
+
+	info.DisplayText = renderDisplay(displayTemplate, info)
+	if len(displayTemplates) > 0 {
+		info.DisplayTexts = map[string]string{}
+		for name, tmpl := range displayTemplates {
+			info.DisplayTexts[name] = renderDisplay(tmpl, info)
+		}
+	}
+	return info
+}
+
+func renderDisplay(tmpl *template.Template, info MediaInfo) string {
+	if tmpl == nil {
+		return ""
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, info); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(text.String())
+}
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"bufio"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// announcementRoles are PipeWire media roles of streams that should duck the music
+var announcementRoles = map[string]bool{
+	"event":        true,
+	"notification": true,
+	"announcement": true,
+	"phone":        true,
+	"a11y":         true,
+}
+
+// announcementApps are text-to-speech engines that usually don't set a media role
+var announcementApps = []string{"speech-dispatcher", "espeak", "piper", "festival", "spd-say"}
+
+var (
+	duckCount   int
+	duckPlayer  string
+	duckRestore int
+	duckingMu   sync.Mutex
+)
+
+// Duck lowers the active player's stream volume to the configured level.
+// Ducks nest: the volume comes back once every Duck has been matched by Unduck.
+func Duck() error {
+	duckingMu.Lock()
+	defer duckingMu.Unlock()
+
+	duckCount++
+	if duckCount > 1 {
+		return nil
+	}
+
+	info, err := GetPlayerInfo()
+	if err != nil || info.Player == "" {
+		duckCount = 0
+		return models.NewError(models.ErrPlayerNotFound, i18n.T("no active player to duck"), nil)
+	}
+
+	settings := config.Get().Ducking
+	restore, err := GetStreamVolume(info.Player)
+	if err != nil {
+		duckCount = 0
+		return err
+	}
+
+	target := restore * settings.Level / 100
+	if _, err := FadeTo(info.Player, target, time.Duration(settings.FadeMs)*time.Millisecond, nil); err != nil {
+		duckCount = 0
+		return err
+	}
+
+	duckPlayer = info.Player
+	duckRestore = restore
+	return nil
+}
+
+// Unduck restores the volume lowered by Duck
+func Unduck() error {
+	duckingMu.Lock()
+	defer duckingMu.Unlock()
+
+	if duckCount == 0 {
+		return nil
+	}
+	duckCount--
+	if duckCount > 0 {
+		return nil
+	}
+
+	settings := config.Get().Ducking
+	_, err := FadeTo(duckPlayer, duckRestore, time.Duration(settings.FadeMs)*time.Millisecond, nil)
+	duckPlayer = ""
+	return err
+}
+
+// IsDucked reports whether the music is currently ducked
+func IsDucked() bool {
+	duckingMu.Lock()
+	defer duckingMu.Unlock()
+	return duckCount > 0
+}
+
+// StartAutoDucking ducks the player while a notification or TTS stream is
+// playing, when enabled in the config. It follows `pactl subscribe` so nothing
+// is polled.
+func StartAutoDucking() {
+	if !config.Get().Ducking.Auto {
+		return
+	}
+
+	for {
+		if err := watchAnnouncements(); err != nil {
+			log.Println("Auto ducking watcher stopped:", err)
+		}
+		// pactl exits when PipeWire restarts; try again shortly
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func watchAnnouncements() error {
+	cmd := exec.Command("pactl", "subscribe")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer cmd.Wait()
+
+	autoDucked := false
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, "sink-input") || strings.Contains(line, "'change'") {
+			continue
+		}
+
+		active := announcementPlaying()
+		if active && !autoDucked {
+			if err := Duck(); err == nil {
+				autoDucked = true
+			}
+		} else if !active && autoDucked {
+			autoDucked = false
+			if err := Unduck(); err != nil {
+				log.Println("Failed to restore volume after ducking:", err)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// announcementPlaying reports whether any notification or TTS stream exists
+func announcementPlaying() bool {
+	inputs, err := listPactlSinkInputs()
+	if err != nil {
+		return false
+	}
+	for _, input := range inputs {
+		if announcementRoles[strings.ToLower(input.Properties["media.role"])] {
+			return true
+		}
+		binary := strings.ToLower(input.Properties["application.process.binary"])
+		for _, app := range announcementApps {
+			if strings.Contains(binary, app) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,390 @@
+package utils
+
+import (
+	"Blitz/store"
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxTagBytes bounds the tag or metadata block read from a media file
+const maxTagBytes = 32 << 20
+
+// errNoCover is returned for files without embedded artwork
+var errNoCover = errors.New("no embedded cover")
+
+// embeddedCover remembers the extraction result for one file version
+type embeddedCover struct {
+	modTime time.Time
+	file    string
+	err     error
+}
+
+var (
+	embeddedCovers   = map[string]embeddedCover{}
+	embeddedCoversMu sync.Mutex
+)
+
+// EmbeddedCover extracts the cover art embedded in the tags of a local media
+// file (ID3v2 for MP3, FLAC pictures, MP4/M4A covr atoms) into the cache and
+// returns the cached file. Each file is only read again once it changes.
+func EmbeddedCover(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	embeddedCoversMu.Lock()
+	defer embeddedCoversMu.Unlock()
+
+	if cached, ok := embeddedCovers[path]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.file, cached.err
+	}
+
+	file, err := extractEmbeddedCover(path)
+	embeddedCovers[path] = embeddedCover{modTime: info.ModTime(), file: file, err: err}
+	return file, err
+}
+
+func extractEmbeddedCover(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 12)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return "", errNoCover
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	var data []byte
+	switch {
+	case bytes.HasPrefix(magic, []byte("ID3")):
+		data, err = readID3Cover(f)
+	case bytes.HasPrefix(magic, []byte("fLaC")):
+		data, err = readFLACCover(f)
+	case string(magic[4:8]) == "ftyp":
+		data, err = readMP4Cover(f)
+	default:
+		return "", errNoCover
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 || len(data) > maxArtworkBytes {
+		return "", errNoCover
+	}
+
+	ext, ok := artworkExtensions[http.DetectContentType(data)]
+	if !ok || ext == ".svg" {
+		return "", fmt.Errorf("unsupported embedded cover format")
+	}
+	name := fmt.Sprintf("%x%s", md5.Sum(data), ext)
+	return store.WriteCache(store.CacheEmbedded, name, bytes.NewReader(data))
+}
+
+// readID3Cover returns the front cover, else the first picture, of an ID3v2 tag
+func readID3Cover(r io.Reader) ([]byte, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	version, flags := header[3], header[5]
+	size := syncsafe(header[6:10])
+	if size > maxTagBytes {
+		return nil, fmt.Errorf("ID3 tag is too large")
+	}
+
+	tag := make([]byte, size)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return nil, err
+	}
+	// v2.3 and older unsynchronise the whole tag, v2.4 frame by frame
+	if flags&0x80 != 0 && version < 4 {
+		tag = bytes.ReplaceAll(tag, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+	if flags&0x40 != 0 && len(tag) >= 4 {
+		extended := int(binary.BigEndian.Uint32(tag))
+		if version >= 4 {
+			extended = syncsafe(tag[:4])
+		} else {
+			extended += 4
+		}
+		if extended > len(tag) {
+			return nil, errNoCover
+		}
+		tag = tag[extended:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	var first []byte
+	for len(tag) >= headerSize && tag[0] != 0 {
+		id := string(tag[:idSize])
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(tag[4:8]))
+		default:
+			frameSize = syncsafe(tag[4:8])
+		}
+		if frameSize <= 0 || headerSize+frameSize > len(tag) {
+			break
+		}
+		frame := tag[headerSize : headerSize+frameSize]
+		if version >= 4 && tag[9]&0x02 != 0 {
+			frame = bytes.ReplaceAll(frame, []byte{0xFF, 0x00}, []byte{0xFF})
+		}
+		tag = tag[headerSize+frameSize:]
+
+		if id != "APIC" && id != "PIC" {
+			continue
+		}
+		pictureType, data, ok := parseID3Picture(frame, version == 2)
+		if !ok {
+			continue
+		}
+		if pictureType == 3 {
+			return data, nil
+		}
+		if first == nil {
+			first = data
+		}
+	}
+
+	if first == nil {
+		return nil, errNoCover
+	}
+	return first, nil
+}
+
+// parseID3Picture splits an APIC (or v2.2 PIC) frame into its picture type and image data
+func parseID3Picture(frame []byte, v22 bool) (byte, []byte, bool) {
+	if len(frame) < 2 {
+		return 0, nil, false
+	}
+	encoding := frame[0]
+	rest := frame[1:]
+
+	// The MIME type (v2.2: a three letter format) is always Latin-1
+	if v22 {
+		if len(rest) < 3 {
+			return 0, nil, false
+		}
+		rest = rest[3:]
+	} else {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return 0, nil, false
+		}
+		rest = rest[end+1:]
+	}
+	if len(rest) < 1 {
+		return 0, nil, false
+	}
+	pictureType := rest[0]
+	rest = rest[1:]
+
+	// The description ends with one NUL, or two for the UTF-16 encodings
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(rest); i += 2 {
+			if rest[i] == 0 && rest[i+1] == 0 {
+				return pictureType, rest[i+2:], true
+			}
+		}
+		return 0, nil, false
+	}
+	end := bytes.IndexByte(rest, 0)
+	if end < 0 {
+		return 0, nil, false
+	}
+	return pictureType, rest[end+1:], true
+}
+
+// syncsafe decodes a 28-bit ID3 integer stored 7 bits per byte
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// readFLACCover returns the front cover, else the first picture, of a FLAC file
+func readFLACCover(r io.Reader) ([]byte, error) {
+	if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+		return nil, err
+	}
+
+	var first []byte
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		if blockType != 6 || length > maxTagBytes {
+			if _, err := io.CopyN(io.Discard, r, length); err != nil || last {
+				break
+			}
+			continue
+		}
+
+		block := make([]byte, length)
+		if _, err := io.ReadFull(r, block); err != nil {
+			break
+		}
+		if pictureType, data, ok := parseFLACPicture(block); ok {
+			if pictureType == 3 {
+				return data, nil
+			}
+			if first == nil {
+				first = data
+			}
+		}
+		if last {
+			break
+		}
+	}
+
+	if first == nil {
+		return nil, errNoCover
+	}
+	return first, nil
+}
+
+// parseFLACPicture splits a PICTURE metadata block into its picture type and image data
+func parseFLACPicture(block []byte) (uint32, []byte, bool) {
+	field := func(offset int) (int, bool) {
+		if offset+4 > len(block) {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint32(block[offset:])), true
+	}
+
+	pictureType, ok := field(0)
+	if !ok {
+		return 0, nil, false
+	}
+	mimeLength, ok := field(4)
+	if !ok {
+		return 0, nil, false
+	}
+	offset := 8 + mimeLength
+	descriptionLength, ok := field(offset)
+	if !ok {
+		return 0, nil, false
+	}
+	// Skip the description, width, height, depth and color count
+	offset += 4 + descriptionLength + 16
+	dataLength, ok := field(offset)
+	if !ok || offset+4+dataLength > len(block) {
+		return 0, nil, false
+	}
+	return uint32(pictureType), block[offset+4 : offset+4+dataLength], true
+}
+
+// readMP4Cover returns the first picture of moov/udta/meta/ilst/covr
+func readMP4Cover(f io.ReadSeeker) ([]byte, error) {
+	// moov may sit at either end of the file, so walk the top level by seeking
+	for {
+		size, name, headerSize, err := readMP4AtomHeader(f)
+		if err != nil {
+			return nil, errNoCover
+		}
+		if size != 0 && size < headerSize {
+			return nil, errNoCover
+		}
+		if name != "moov" {
+			if size == 0 {
+				return nil, errNoCover
+			}
+			if _, err := f.Seek(size-headerSize, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if size == 0 || size-headerSize > maxTagBytes {
+			return nil, fmt.Errorf("moov atom is too large")
+		}
+		moov := make([]byte, size-headerSize)
+		if _, err := io.ReadFull(f, moov); err != nil {
+			return nil, err
+		}
+
+		atom := moov
+		for _, path := range []string{"udta", "meta", "ilst", "covr", "data"} {
+			child, ok := findMP4Atom(atom, path)
+			if !ok {
+				return nil, errNoCover
+			}
+			// meta is a full atom: version and flags precede its children
+			if path == "meta" && len(child) >= 4 {
+				child = child[4:]
+			}
+			atom = child
+		}
+		// data starts with the type indicator and the locale
+		if len(atom) < 8 {
+			return nil, errNoCover
+		}
+		return atom[8:], nil
+	}
+}
+
+// readMP4AtomHeader reads an atom's total size, type and header length
+func readMP4AtomHeader(r io.Reader) (int64, string, int64, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, "", 0, err
+	}
+	size := int64(binary.BigEndian.Uint32(header))
+	name := string(header[4:8])
+	if size != 1 {
+		return size, name, 8, nil
+	}
+
+	extended := make([]byte, 8)
+	if _, err := io.ReadFull(r, extended); err != nil {
+		return 0, "", 0, err
+	}
+	return int64(binary.BigEndian.Uint64(extended)), name, 16, nil
+}
+
+// findMP4Atom returns the body of the first child atom called name
+func findMP4Atom(data []byte, name string) ([]byte, bool) {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data))
+		headerSize := 8
+		if size == 1 && len(data) >= 16 {
+			size = int(binary.BigEndian.Uint64(data[8:]))
+			headerSize = 16
+		} else if size == 0 {
+			size = len(data)
+		}
+		if size < headerSize || size > len(data) {
+			return nil, false
+		}
+		if string(data[4:8]) == name {
+			return data[headerSize:size], true
+		}
+		data = data[size:]
+	}
+	return nil, false
+}
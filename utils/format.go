@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unit systems of data rates
+const (
+	UnitsBits  = "bits"  // kbps, Mbps, Gbps
+	UnitsBytes = "bytes" // kB/s, MB/s, GB/s
+)
+
+// Formatter renders values the way a client asked for in its preferences,
+// so every client shows the same durations and rates
+type Formatter struct {
+	Locale string `json:"locale"` // Language of the decimal separator and unit names, e.g. "de"
+	Units  string `json:"units"`  // "bits" (default) or "bytes"
+}
+
+// Formattable payloads add readable versions of their raw values, keyed by
+// the name of the field they format
+type Formattable interface {
+	Formatted(f Formatter) map[string]string
+}
+
+// commaLocales write decimals with a comma
+var commaLocales = map[string]bool{"de": true, "es": true, "fr": true}
+
+// dayUnits abbreviate "days" in durations longer than a day
+var dayUnits = map[string]string{"de": "T", "fr": "j"}
+
+// byteUnits are the names of kB/s, MB/s and GB/s where they differ
+var byteUnits = map[string][3]string{"fr": {"ko/s", "Mo/s", "Go/s"}}
+
+// NewFormatter checks client preferences
+func NewFormatter(locale, units string) (*Formatter, error) {
+	if units == "" {
+		units = UnitsBits
+	}
+	if units != UnitsBits && units != UnitsBytes {
+		return nil, fmt.Errorf("units must be %s or %s", UnitsBits, UnitsBytes)
+	}
+	locale, _, _ = strings.Cut(strings.ToLower(locale), "-")
+	locale, _, _ = strings.Cut(locale, "_")
+	return &Formatter{Locale: locale, Units: units}, nil
+}
+
+// Duration formats like a player does: "3:07", "1:02:07", or "2d 1:02:07"
+func (f Formatter) Duration(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	days, hours, minutes := seconds/86400, seconds/3600%24, seconds/60%60
+	seconds %= 60
+
+	switch {
+	case days > 0:
+		unit, ok := dayUnits[f.Locale]
+		if !ok {
+			unit = "d"
+		}
+		return fmt.Sprintf("%d%s %d:%02d:%02d", days, unit, hours, minutes, seconds)
+	case hours > 0:
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%d:%02d", minutes, seconds)
+}
+
+// Rate formats a data rate given in bits per second in the client's units
+func (f Formatter) Rate(bitsPerSecond float64) string {
+	value, units := bitsPerSecond/1000, [3]string{"kbps", "Mbps", "Gbps"}
+	if f.Units == UnitsBytes {
+		value /= 8
+		units = [3]string{"kB/s", "MB/s", "GB/s"}
+		if localized, ok := byteUnits[f.Locale]; ok {
+			units = localized
+		}
+	}
+
+	unit := 0
+	for unit < len(units)-1 && value >= 1000 {
+		value /= 1000
+		unit++
+	}
+	return f.Number(value) + " " + units[unit]
+}
+
+// Number formats with one decimal and the locale's decimal separator
+func (f Formatter) Number(value float64) string {
+	number := strconv.FormatFloat(value, 'f', 1, 64)
+	if commaLocales[f.Locale] {
+		number = strings.Replace(number, ".", ",", 1)
+	}
+	return number
+}
+
+// Formatted implements Formattable with the position and length
+func (info MediaInfo) Formatted(f Formatter) map[string]string {
+	formatted := map[string]string{}
+	for field, value := range map[string]string{"Position": info.Position, "Length": info.Length} {
+		if microseconds, err := strconv.ParseInt(value, 10, 64); err == nil && microseconds >= 0 {
+			formatted[field] = f.Duration(time.Duration(microseconds) * time.Microsecond)
+		}
+	}
+	return formatted
+}
+
+// Formatted implements Formattable with the uptime
+func (info HostInfo) Formatted(f Formatter) map[string]string {
+	return map[string]string{"uptimeSeconds": f.Duration(time.Duration(info.UptimeSeconds) * time.Second)}
+}
+
+// Formatted implements Formattable with the link and transfer speeds
+func (info WiFiInfo) Formatted(f Formatter) map[string]string {
+	return map[string]string{
+		"linkSpeed":     f.Rate(float64(info.LinkSpeed) * 1e6),
+		"downloadSpeed": f.Rate(info.DownloadSpeed * 1e6),
+		"uploadSpeed":   f.Rate(info.UploadSpeed * 1e6),
+	}
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Gamepad is a connected game controller
+type Gamepad struct {
+	ID         string `json:"id"` // Joystick device, e.g. "js0"
+	Name       string `json:"name"`
+	Connection string `json:"connection"` // "usb", "bluetooth" or "other"
+	Battery    int    `json:"battery"`    // Percent, -1 if not available
+	Charging   bool   `json:"charging"`
+}
+
+// Gamepads is the list of connected controllers, as broadcast
+type Gamepads []Gamepad
+
+// Topic implements models.Payload
+func (Gamepads) Topic() string { return "gamepads" }
+
+// Version implements models.Payload
+func (Gamepads) Version() int { return 1 }
+
+// GamepadEvent reports a controller connecting or disconnecting
+type GamepadEvent struct {
+	Type    string  `json:"type"` // "connected" or "disconnected"
+	Gamepad Gamepad `json:"gamepad"`
+}
+
+// Topic implements models.Payload
+func (GamepadEvent) Topic() string { return "gamepad_event" }
+
+// Version implements models.Payload
+func (GamepadEvent) Version() int { return 1 }
+
+var (
+	knownGamepads   = map[string]Gamepad{}
+	knownGamepadsMu sync.Mutex
+)
+
+// GetGamepads lists the connected game controllers from sysfs. The battery is
+// read from the power supply the kernel driver registers for the controller
+// (DualSense, DualShock, Xbox and Switch controllers have one).
+func GetGamepads() ([]Gamepad, error) {
+	joysticks, err := filepath.Glob("/sys/class/input/js*")
+	if err != nil {
+		return nil, err
+	}
+
+	gamepads := []Gamepad{}
+	for _, joystick := range joysticks {
+		device := filepath.Join(joystick, "device")
+		gamepad := Gamepad{
+			ID:         filepath.Base(joystick),
+			Name:       readSysfs(filepath.Join(device, "name")),
+			Connection: "other",
+			Battery:    -1,
+		}
+
+		switch readSysfs(filepath.Join(device, "id", "bustype")) {
+		case "0003":
+			gamepad.Connection = "usb"
+		case "0005":
+			gamepad.Connection = "bluetooth"
+		}
+
+		// The power supply hangs off the HID device above the input device
+		supplies, _ := filepath.Glob(filepath.Join(device, "device", "power_supply", "*"))
+		for _, supply := range supplies {
+			if capacity, err := strconv.Atoi(readSysfs(filepath.Join(supply, "capacity"))); err == nil {
+				gamepad.Battery = capacity
+				status := readSysfs(filepath.Join(supply, "status"))
+				gamepad.Charging = status == "Charging" || status == "Full"
+				break
+			}
+		}
+
+		gamepads = append(gamepads, gamepad)
+	}
+
+	sort.Slice(gamepads, func(i, j int) bool { return gamepads[i].ID < gamepads[j].ID })
+	return gamepads, nil
+}
+
+// DetectGamepadChanges compares the controllers with the previous call and
+// returns connect and disconnect events
+func DetectGamepadChanges(gamepads []Gamepad) []GamepadEvent {
+	knownGamepadsMu.Lock()
+	defer knownGamepadsMu.Unlock()
+
+	events := []GamepadEvent{}
+	current := map[string]Gamepad{}
+	for _, gamepad := range gamepads {
+		key := gamepad.ID + "|" + gamepad.Name
+		current[key] = gamepad
+		if _, ok := knownGamepads[key]; !ok {
+			events = append(events, GamepadEvent{Type: "connected", Gamepad: gamepad})
+		}
+	}
+	for key, gamepad := range knownGamepads {
+		if _, ok := current[key]; !ok {
+			events = append(events, GamepadEvent{Type: "disconnected", Gamepad: gamepad})
+		}
+	}
+	knownGamepads = current
+	return events
+}
+
+func readSysfs(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
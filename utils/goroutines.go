@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Kinds of goroutines Blitz starts for every client or command
+const (
+	GoroutineClientWorker = "client_worker" // Runs a client's commands
+	GoroutineClientWriter = "client_writer" // Delivers a client's broadcasts
+	GoroutineClientPinger = "client_pinger" // Keeps a WebSocket client alive
+	GoroutinePeerPinger   = "peer_pinger"   // Keeps the relay or an upstream connection alive
+	GoroutineLogFollower  = "log_follower"  // Streams the log to a client
+	GoroutineCommand      = "command"       // Runs a command, possibly past its timeout
+)
+
+// goroutineCounts counts the running goroutines by kind, so ones that outlive
+// their client show up in /status before they add up
+var (
+	goroutineCounts   = map[string]*atomic.Int64{}
+	goroutineCountsMu sync.Mutex
+)
+
+// TrackGoroutine counts a goroutine of a kind as running. Call the returned
+// func when it ends.
+func TrackGoroutine(kind string) func() {
+	goroutineCountsMu.Lock()
+	count, ok := goroutineCounts[kind]
+	if !ok {
+		count = &atomic.Int64{}
+		goroutineCounts[kind] = count
+	}
+	goroutineCountsMu.Unlock()
+
+	count.Add(1)
+	return func() { count.Add(-1) }
+}
+
+// GoroutineStatus reports the goroutines in /status
+type GoroutineStatus struct {
+	Total   int              `json:"total"`   // All goroutines, including the runtime's
+	Tracked map[string]int64 `json:"tracked"` // Running goroutines by kind
+}
+
+// GetGoroutineStatus returns how many goroutines are running
+func GetGoroutineStatus() GoroutineStatus {
+	status := GoroutineStatus{Total: runtime.NumGoroutine(), Tracked: map[string]int64{}}
+
+	goroutineCountsMu.Lock()
+	defer goroutineCountsMu.Unlock()
+	for kind, count := range goroutineCounts {
+		status.Tracked[kind] = count.Load()
+	}
+	return status
+}
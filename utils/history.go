@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/store"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// historyDocument is the store document holding the history when persisted
+	historyDocument = "history"
+	// historySize is how many recently played tracks are kept
+	historySize = 20
+)
+
+// HistoryEntry is a recently played track
+type HistoryEntry struct {
+	TrackSummary
+	URL      string `json:"url,omitempty"`
+	PlayedAt int64  `json:"playedAt"`
+}
+
+// History is the list of recently played tracks, newest first
+type History []HistoryEntry
+
+// Topic implements models.Payload
+func (History) Topic() string { return "history" }
+
+// Version implements models.Payload
+func (History) Version() int { return 1 }
+
+var (
+	history       []HistoryEntry // newest first
+	historyLoaded bool
+	historyMu     sync.Mutex
+)
+
+func loadHistory() {
+	if historyLoaded {
+		return
+	}
+	historyLoaded = true
+	if !config.Get().History.Persist {
+		return
+	}
+	if err := store.Load(historyDocument, &history); err != nil {
+		log.Println("Failed to load play history:", err)
+	}
+	if len(history) > historySize {
+		history = history[:historySize]
+	}
+}
+
+// RecordHistory adds the track a change switched to. It returns false when the
+// history didn't change, e.g. when the same track resumed after a stop.
+func RecordHistory(change *TrackChange, info MediaInfo) bool {
+	if change == nil || change.Current == nil {
+		return false
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	loadHistory()
+
+	// Stored normalized, so one track isn't listed under several spellings
+	current := NormalizeTrack(*change.Current)
+	if len(history) > 0 && sameTrack(&history[0].TrackSummary, &current) {
+		return false
+	}
+
+	entry := HistoryEntry{TrackSummary: current, URL: info.URL, PlayedAt: time.Now().Unix()}
+	history = append([]HistoryEntry{entry}, history...)
+	if len(history) > historySize {
+		history = history[:historySize]
+	}
+
+	if config.Get().History.Persist {
+		if err := store.Save(historyDocument, history); err != nil {
+			log.Println("Failed to save play history:", err)
+		}
+	}
+	return true
+}
+
+// GetHistory returns the recently played tracks, newest first
+func GetHistory() []HistoryEntry {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	loadHistory()
+	return append([]HistoryEntry{}, history...)
+}
+
+// PlayFromHistory plays a history entry again, given its index (0 is the newest).
+// Spotify tracks play through the Spotify app or Web API, other tracks are
+// reopened in their player when it exposes a URL for them.
+func PlayFromHistory(index int) (*HistoryEntry, error) {
+	entries := GetHistory()
+	if index < 0 || index >= len(entries) {
+		return nil, models.NewParamError("index", i18n.T("no history entry %d", index))
+	}
+	entry := entries[index]
+
+	spotifyID := SpotifyTrackID(entry.TrackID)
+	isSpotifyApp := strings.HasPrefix(entry.Player, "spotify")
+
+	switch {
+	case spotifyID != "" && !isSpotifyApp:
+		client, err := requireSpotify()
+		if err != nil {
+			return nil, err
+		}
+		if err := client.PlayTracks([]string{"spotify:track:" + spotifyID}, ""); err != nil {
+			return nil, err
+		}
+	case spotifyID != "":
+		if err := (MPRISProvider{Player: entry.Player}).playerctl(context.Background(), "open", "spotify:track:"+spotifyID); err != nil {
+			return nil, err
+		}
+	case entry.URL != "":
+		if err := (MPRISProvider{Player: entry.Player}).playerctl(context.Background(), "open", entry.URL); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s can't be played again from %s", entry.Title, entry.Player)
+	}
+
+	return &entry, nil
+}
+
+// ReloadStored drops the history, saved positions and plays held in memory,
+// so they're read from the store again, e.g. after an import replaced it
+func ReloadStored() {
+	historyMu.Lock()
+	history, historyLoaded = nil, false
+	historyMu.Unlock()
+
+	savedPositionsMu.Lock()
+	savedPositions, savedPositionsDirty = nil, false
+	savedPositionsMu.Unlock()
+
+	statsMu.Lock()
+	plays, playsLoaded = nil, false
+	statsMu.Unlock()
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// HostInfo identifies the machine Blitz runs on
+type HostInfo struct {
+	Hostname      string `json:"hostname"`
+	Kernel        string `json:"kernel"`
+	Distro        string `json:"distro"`
+	SessionType   string `json:"sessionType"` // "wayland", "x11" or "tty"
+	Desktop       string `json:"desktop,omitempty"`
+	User          string `json:"user"`
+	UptimeSeconds int64  `json:"uptimeSeconds"`
+}
+
+// Topic implements models.Payload
+func (HostInfo) Topic() string { return "host" }
+
+// Version implements models.Payload
+func (HostInfo) Version() int { return 1 }
+
+// GetHostInfo collects the host details from /proc, /etc/os-release and the session environment
+func GetHostInfo() *HostInfo {
+	info := &HostInfo{
+		Kernel:  readSysfs("/proc/sys/kernel/osrelease"),
+		Distro:  osReleaseName(),
+		Desktop: os.Getenv("XDG_CURRENT_DESKTOP"),
+	}
+
+	info.Hostname, _ = os.Hostname()
+
+	if current, err := user.Current(); err == nil {
+		info.User = current.Username
+	}
+
+	switch {
+	case os.Getenv("XDG_SESSION_TYPE") != "":
+		info.SessionType = os.Getenv("XDG_SESSION_TYPE")
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		info.SessionType = "wayland"
+	case os.Getenv("DISPLAY") != "":
+		info.SessionType = "x11"
+	default:
+		info.SessionType = "tty"
+	}
+
+	// /proc/uptime: "seconds-up seconds-idle"
+	if fields := strings.Fields(readSysfs("/proc/uptime")); len(fields) > 0 {
+		if uptime, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			info.UptimeSeconds = int64(uptime)
+		}
+	}
+
+	return info
+}
+
+// osReleaseName returns PRETTY_NAME from /etc/os-release
+func osReleaseName() string {
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
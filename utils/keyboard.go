@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxTypedText bounds type_text, long enough for a search or a URL
+const maxTypedText = 500
+
+// typeCommand returns the command that types text in the running session:
+// wtype on Wayland compositors with a virtual keyboard, which GNOME lacks,
+// xdotool on X11 and ydotool, which types through uinput, everywhere else
+func typeCommand(text string) (string, []string, error) {
+	wayland := os.Getenv("WAYLAND_DISPLAY") != ""
+	gnome := strings.Contains(os.Getenv("XDG_CURRENT_DESKTOP"), "GNOME")
+	switch {
+	case wayland && !gnome && toolInstalled("wtype"):
+		return "wtype", []string{"--", text}, nil
+	case !wayland && os.Getenv("DISPLAY") != "" && toolInstalled("xdotool"):
+		return "xdotool", []string{"type", "--clearmodifiers", "--", text}, nil
+	case toolInstalled("ydotool"):
+		return "ydotool", []string{"type", "--", text}, nil
+	}
+	return "", nil, models.NewError(models.ErrExternalToolMissing, i18n.T("%s is not installed", "wtype, xdotool or ydotool"), nil)
+}
+
+func toolInstalled(tool string) bool {
+	_, err := exec.LookPath(tool)
+	return err == nil
+}
+
+// TypeText types text on the host as if it came from the keyboard, e.g. a
+// search in a TV app typed on the phone. A newline presses Enter, so "\n"
+// at the end submits the search.
+func TypeText(text string) error {
+	if text == "" || utf8.RuneCountInString(text) > maxTypedText {
+		return models.NewError(models.ErrInvalidParams, i18n.T("text must be 1 to %d characters", maxTypedText), nil)
+	}
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.IsControl(r) && r != '\n' && r != '\t' }) >= 0 {
+		return models.NewError(models.ErrInvalidParams, i18n.T("text must not contain control characters other than newlines and tabs"), nil)
+	}
+
+	command, args, err := typeCommand(text)
+	if err != nil {
+		return err
+	}
+	_, err = SpawnProcess(command, args)
+	return err
+}
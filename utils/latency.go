@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/models"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyResult is one ping round against a target
+type LatencyResult struct {
+	Target    string  `json:"target"`
+	Host      string  `json:"host"`            // Resolved address, differs from Target for "gateway"
+	RTTMs     float64 `json:"rttMs"`           // Average round trip time
+	JitterMs  float64 `json:"jitterMs"`        // Standard deviation of the round trip times
+	Loss      float64 `json:"loss"`            // Packet loss in percent
+	Error     string  `json:"error,omitempty"` // Set when the target couldn't be pinged at all
+	Timestamp int64   `json:"timestamp"`
+}
+
+// LatencyResults are the results of one round of pings
+type LatencyResults []LatencyResult
+
+// Topic implements models.Payload
+func (LatencyResults) Topic() string { return "latency" }
+
+// Version implements models.Payload
+func (LatencyResults) Version() int { return 1 }
+
+// LatencyAlert is raised when a target keeps losing packets, and cleared when it recovers
+type LatencyAlert struct {
+	Target    string  `json:"target"`
+	State     string  `json:"state"` // "raised" or "cleared"
+	Loss      float64 `json:"loss"`
+	Rounds    int     `json:"rounds"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Topic implements models.Payload
+func (LatencyAlert) Topic() string { return "latency_alert" }
+
+// Version implements models.Payload
+func (LatencyAlert) Version() int { return 1 }
+
+var (
+	pingLossPattern = regexp.MustCompile(`([\d.]+)% packet loss`)
+	pingRTTPattern  = regexp.MustCompile(`= [\d.]+/([\d.]+)/[\d.]+/([\d.]+) ms`)
+
+	latestLatency []LatencyResult
+	lossStreaks   = map[string]int{}
+	lossAlerted   = map[string]bool{}
+	latencyMu     sync.Mutex
+)
+
+// MeasureLatency pings every configured target once and returns the results
+// together with the alerts that were raised or cleared by this round
+func MeasureLatency() ([]LatencyResult, []LatencyAlert) {
+	settings := config.Get().Latency
+	count := max(settings.Count, 1)
+
+	results := make([]LatencyResult, len(settings.Targets))
+	var wg sync.WaitGroup
+	for i, target := range settings.Targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			results[i] = pingTarget(target, count)
+		}(i, target)
+	}
+	wg.Wait()
+
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+
+	latestLatency = results
+
+	alerts := []LatencyAlert{}
+	for _, result := range results {
+		if result.Loss >= float64(settings.AlertLossPercent) {
+			lossStreaks[result.Target]++
+		} else {
+			lossStreaks[result.Target] = 0
+		}
+
+		streak := lossStreaks[result.Target]
+		switch {
+		case streak >= settings.AlertRounds && !lossAlerted[result.Target]:
+			lossAlerted[result.Target] = true
+			alerts = append(alerts, LatencyAlert{Target: result.Target, State: "raised", Loss: result.Loss, Rounds: streak, Timestamp: result.Timestamp})
+		case streak == 0 && lossAlerted[result.Target]:
+			lossAlerted[result.Target] = false
+			alerts = append(alerts, LatencyAlert{Target: result.Target, State: "cleared", Loss: result.Loss, Timestamp: result.Timestamp})
+		}
+	}
+
+	return results, alerts
+}
+
+// GetLatency returns the results of the last ping round
+func GetLatency() []LatencyResult {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	return append([]LatencyResult{}, latestLatency...)
+}
+
+func pingTarget(target string, count int) LatencyResult {
+	result := LatencyResult{Target: target, Host: target, Timestamp: time.Now().Unix()}
+
+	if target == "gateway" {
+		gateway, err := defaultGateway()
+		if err != nil {
+			result.Error = models.AsError(err).Message
+			result.Loss = 100
+			return result
+		}
+		result.Host = gateway
+	}
+
+	// ping exits with 1 when replies are missing, so parse whatever it printed
+	output, err := SpawnProcess("ping", []string{"-q", "-n", "-c", strconv.Itoa(count), "-i", "0.2", "-w", strconv.Itoa(count + 1), result.Host})
+	match := pingLossPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		result.Loss = 100
+		if err != nil {
+			result.Error = models.AsError(err).Message
+		} else {
+			result.Error = "unexpected ping output"
+		}
+		return result
+	}
+
+	result.Loss, _ = strconv.ParseFloat(match[1], 64)
+	if match := pingRTTPattern.FindStringSubmatch(string(output)); match != nil {
+		result.RTTMs, _ = strconv.ParseFloat(match[1], 64)
+		result.JitterMs, _ = strconv.ParseFloat(match[2], 64)
+	}
+	return result
+}
+
+// defaultGateway returns the address of the default route
+func defaultGateway() (string, error) {
+	gateway, _, err := defaultRoute()
+	if err != nil {
+		return "", err
+	}
+	if gateway == "" {
+		return "", fmt.Errorf("no default gateway")
+	}
+	return gateway, nil
+}
+
+// defaultRoute returns the gateway and interface of the default route
+func defaultRoute() (gateway, device string, err error) {
+	output, err := SpawnProcess("ip", []string{"route", "show", "default"})
+	if err != nil {
+		return "", "", err
+	}
+	// default via 192.168.1.1 dev wlan0 ...
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	fields := strings.Fields(lines[0])
+	for i := 0; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "via":
+			gateway = fields[i+1]
+		case "dev":
+			device = fields[i+1]
+		}
+	}
+	if device == "" {
+		return "", "", fmt.Errorf("no default route")
+	}
+	return gateway, device, nil
+}
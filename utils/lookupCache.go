@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// lookupCache remembers the results of lookups on web APIs by key, so each
+// is made once. Failed lookups, whether the API had nothing or couldn't be
+// reached, are retried after retry; once max entries are held the oldest
+// makes room.
+type lookupCache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]lookupEntry[V]
+	max     int
+	retry   time.Duration
+}
+
+type lookupEntry[V any] struct {
+	value  V
+	found  bool
+	stored time.Time
+}
+
+func newLookupCache[V any](max int, retry time.Duration) *lookupCache[V] {
+	return &lookupCache[V]{entries: map[string]lookupEntry[V]{}, max: max, retry: retry}
+}
+
+// get returns the remembered result for key; cached is false when key has to
+// be looked up (again)
+func (c *lookupCache[V]) get(key string) (value V, cached bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || (!entry.found && time.Since(entry.stored) > c.retry) {
+		return value, false
+	}
+	return entry.value, true
+}
+
+// put remembers the result of looking up key; found is false for failures
+func (c *lookupCache[V]) put(key string, value V, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.max {
+		var oldest string
+		var oldestStored time.Time
+		for k, entry := range c.entries {
+			if oldestStored.IsZero() || entry.stored.Before(oldestStored) {
+				oldest, oldestStored = k, entry.stored
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = lookupEntry[V]{value: value, found: found, stored: time.Now()}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/store"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// maintenanceDocument is the store document holding maintenance mode, so
+	// it survives the restarts of a host update
+	maintenanceDocument = "maintenance"
+	// maxMaintenance bounds how long clients are told to wait
+	maxMaintenance = 24 * time.Hour
+)
+
+// Maintenance is broadcast as "maintenance" when maintenance mode is turned
+// on or off. While it is on, pollers are paused and only admin and open
+// commands run; clients show the message as a banner.
+type Maintenance struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	Since   int64  `json:"since,omitempty"` // Unix seconds
+	Until   int64  `json:"until,omitempty"` // Unix seconds it is expected to end; 0 when unknown
+}
+
+// Topic implements models.Payload
+func (Maintenance) Topic() string { return "maintenance" }
+
+// Version implements models.Payload
+func (Maintenance) Version() int { return 1 }
+
+var (
+	maintenance       Maintenance
+	maintenanceLoaded bool
+	maintenanceMu     sync.Mutex
+)
+
+func loadMaintenance() {
+	if maintenanceLoaded {
+		return
+	}
+	maintenanceLoaded = true
+	if err := store.Load(maintenanceDocument, &maintenance); err != nil {
+		log.Println("Failed to load maintenance mode:", err)
+	}
+}
+
+// SetMaintenance turns maintenance mode on or off. Minutes is how long the
+// maintenance is expected to take; 0 leaves it open.
+func SetMaintenance(enabled bool, message string, minutes int) (Maintenance, error) {
+	expected := time.Duration(minutes) * time.Minute
+	if minutes < 0 || expected > maxMaintenance {
+		return Maintenance{}, models.NewError(models.ErrInvalidParams, i18n.T("minutes must be between 0 and %d", int(maxMaintenance/time.Minute)), nil)
+	}
+
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	loadMaintenance()
+
+	state := Maintenance{}
+	if enabled {
+		now := time.Now()
+		state = Maintenance{Enabled: true, Message: message, Since: now.Unix()}
+		// Changing the message or estimate keeps when it started
+		if maintenance.Enabled {
+			state.Since = maintenance.Since
+		}
+		if expected > 0 {
+			state.Until = now.Add(expected).Unix()
+		}
+	}
+	maintenance = state
+	if err := store.Save(maintenanceDocument, maintenance); err != nil {
+		log.Println("Failed to save maintenance mode:", err)
+	}
+
+	if enabled {
+		log.Println("🚧 Maintenance mode on:", message)
+	} else {
+		log.Println("🚧 Maintenance mode off")
+	}
+	return state, nil
+}
+
+// GetMaintenance returns the maintenance mode
+func GetMaintenance() Maintenance {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	loadMaintenance()
+	return maintenance
+}
+
+// InMaintenance reports whether maintenance mode is on
+func InMaintenance() bool {
+	return GetMaintenance().Enabled
+}
+
+// RetryAfter is the number of seconds until the maintenance is expected to
+// end, or 0 when it is unknown or overdue
+func (m Maintenance) RetryAfter() int {
+	if m.Until == 0 {
+		return 0
+	}
+	return max(int(time.Until(time.Unix(m.Until, 0))/time.Second), 0)
+}
@@ -14,27 +14,76 @@ type MediaInfo struct {
 	Length   string
 	Status   string
 	Player   string
+	// Instance is the player's MPRIS bus name suffix, which D-Bus calls need:
+	// "firefox.instance_1_84" where Player is "firefox"
+	Instance string `json:"-"`
+	TrackID  string
+	URL      string          `json:",omitempty"` // xesam:url, used to reopen the track from history
+	Details  *TrackDetails   `json:",omitempty"`
+	Context  *SpotifyContext `json:",omitempty"`
+	Station  *RadioStation   `json:",omitempty"` // The radio station play_radio tuned the player to
+
+	Capabilities *PlayerCapabilities `json:",omitempty"`
+	Output       string              `json:",omitempty"` // Audio sink the player is playing to
+	ArtworkURL   string              `json:",omitempty"` // Artwork served over HTTP, e.g. "/artwork/spotify/<id>.jpg"
+
+	// The display templates rendered over the fields above
+	DisplayText  string            `json:"display_text,omitempty"`
+	DisplayTexts map[string]string `json:"display_texts,omitempty"`
 }
 
+// Topic implements models.Payload
+func (MediaInfo) Topic() string { return "media_info" }
+
+// Version implements models.Payload
+func (MediaInfo) Version() int { return 1 }
+
+// GetPlayerInfo reports what the active player is playing. Web players the
+// browser extension reports replace the browser's own MPRIS entry, and local
+// players that aren't playing.
 func GetPlayerInfo() (MediaInfo, error) {
-	// Run one command to get everything: title, artwork, artist, album, position, length, status, player name
-	// Format: title|||artUrl|||artist|||album|||position|||length|||status|||playerName
+	info, err := mprisPlayerInfo()
+	if browser := activeBrowserPlayer(); browser != nil {
+		if err != nil || info.Player == "" || isBrowserPlayer(info.Player) || (browser.Playing && info.Status != "Playing") {
+			return browser.MediaInfo(), nil
+		}
+	}
+	if err == nil {
+		info = withRadioStation(info)
+	}
+	return info, err
+}
+
+func mprisPlayerInfo() (MediaInfo, error) {
+	// Run one command to get everything: title, artwork, artist, album, position, length, status, player name, track id, url
+	// Format: title|||artUrl|||artist|||album|||position|||length|||status|||playerName|||trackid|||url|||playerInstance
 	output, err := SpawnProcess(
 		`playerctl`,
-		[]string{"metadata", `--format`, `{{title}}|||{{mpris:artUrl}}|||{{artist}}|||{{album}}|||{{position}}|||{{mpris:length}}|||{{status}}|||{{playerName}}`})
+		[]string{"metadata", `--format`, `{{title}}|||{{mpris:artUrl}}|||{{artist}}|||{{album}}|||{{position}}|||{{mpris:length}}|||{{status}}|||{{playerName}}|||{{mpris:trackid}}|||{{xesam:url}}|||{{playerInstance}}`})
 	if err != nil {
 		// playerctl not available or no player running
 		fmt.Print("Error getting player info:", err)
 		return MediaInfo{}, err
 	}
 
+	mediaInfo := parsePlayerctlMetadata(string(output))
+	if mediaInfo.Player != "" {
+		mediaInfo.Capabilities = GetPlayerCapabilities(mediaInfo.Instance, mediaInfo.TrackID+mediaInfo.Title)
+	}
+
+	return mediaInfo, nil
+}
+
+// parsePlayerctlMetadata parses the metadata mprisPlayerInfo asks playerctl
+// for, ||| separated
+func parsePlayerctlMetadata(output string) MediaInfo {
 	// Split the output by |||
-	parts := strings.Split(strings.TrimSpace(string(output)), "|||")
+	parts := strings.Split(strings.TrimSpace(output), "|||")
 
 	// Make sure we have all 8 parts (if not, player might not be running)
 	if len(parts) < 8 {
-		return MediaInfo{}, nil
-	} 
+		return MediaInfo{}
+	}
 
 	// Parse each part
 	mediaInfo := MediaInfo{
@@ -47,11 +96,19 @@ func GetPlayerInfo() (MediaInfo, error) {
 		Status:   strings.TrimSpace(parts[6]),
 		Player:   strings.TrimSpace(parts[7]),
 	}
-
-	return mediaInfo, nil
+	if len(parts) > 8 {
+		mediaInfo.TrackID = strings.TrimSpace(parts[8])
+	}
+	if len(parts) > 9 {
+		mediaInfo.URL = strings.TrimSpace(parts[9])
+	}
+	mediaInfo.Instance = mediaInfo.Player
+	if len(parts) > 10 && strings.TrimSpace(parts[10]) != "" {
+		mediaInfo.Instance = strings.TrimSpace(parts[10])
+	}
+	return mediaInfo
 }
 
-
 func GetAllActivePlayers() ([]string, error) {
 	// Run playerctl to get the list of all active players
 	output, err := SpawnProcess(
@@ -64,8 +121,16 @@ func GetAllActivePlayers() ([]string, error) {
 		return []string{}, err
 	}
 
-	// Split the output by new lines to get individual player names
-	players := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return parsePlayerList(string(output)), nil
+}
 
-	return players, nil
-}
\ No newline at end of file
+// parsePlayerList splits `playerctl -l` into the player names
+func parsePlayerList(output string) []string {
+	players := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if player := strings.TrimSpace(line); player != "" {
+			players = append(players, player)
+		}
+	}
+	return players
+}
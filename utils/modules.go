@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Modules that can be turned off in the config
+const (
+	ModuleSpotify   = "spotify"
+	ModuleBluetooth = "bluetooth"
+	ModuleWiFi      = "wifi"
+	ModuleSystem    = "system"
+	ModuleLauncher  = "launcher"
+	ModuleReceiver  = "receiver"
+	ModuleRadio     = "radio"
+	ModuleDisplay   = "display"
+	ModulePointer   = "pointer"
+	ModuleKeyboard  = "keyboard"
+)
+
+// module is a group of features with the tools and settings it can't work without
+type module struct {
+	name    string
+	enabled func(config.Modules) bool
+	tools   []string // Executables that must be in $PATH
+	env     []string // Environment variables that must be set
+}
+
+var modules = []module{
+	{ModuleSpotify, func(m config.Modules) bool { return m.Spotify }, nil, []string{"SPOTIFY_CLIENT_ID", "SPOTIFY_CLIENT_SECRET"}},
+	{ModuleBluetooth, func(m config.Modules) bool { return m.Bluetooth }, []string{"bluetoothctl", "dbus-send"}, nil},
+	{ModuleWiFi, func(m config.Modules) bool { return m.WiFi }, []string{"nmcli"}, nil},
+	// The system module reads /proc and sysfs; upower and timedatectl only add details
+	{ModuleSystem, func(m config.Modules) bool { return m.System }, nil, nil},
+	{ModuleLauncher, func(m config.Modules) bool { return m.Launcher }, []string{"gtk-launch"}, nil},
+	{ModuleReceiver, func(m config.Modules) bool { return m.Receiver }, []string{"mpv"}, nil},
+	{ModuleRadio, func(m config.Modules) bool { return m.Radio }, nil, nil},
+	// Screens are switched with the session's own tools, and inputs with ddcutil
+	{ModuleDisplay, func(m config.Modules) bool { return m.Display }, nil, nil},
+	// ydotool injects through uinput, so it works on Wayland and X11 alike
+	{ModulePointer, func(m config.Modules) bool { return m.Pointer }, []string{"ydotool"}, nil},
+	// Text is typed with wtype on Wayland and xdotool on X11, else with ydotool
+	{ModuleKeyboard, func(m config.Modules) bool { return m.Keyboard }, nil, nil},
+}
+
+// ModuleStatus reports a module in /status
+type ModuleStatus struct {
+	Name      string   `json:"name"`
+	Enabled   bool     `json:"enabled"`
+	Available bool     `json:"available"`         // Enabled and nothing is missing
+	Missing   []string `json:"missing,omitempty"` // Tools and environment variables the module lacks
+}
+
+var (
+	moduleStatus     map[string]ModuleStatus
+	moduleStatusOnce sync.Once
+)
+
+// checkModules looks up the dependencies of the enabled modules once, so
+// pollers don't find out that a tool is missing every second
+func checkModules() map[string]ModuleStatus {
+	moduleStatusOnce.Do(func() {
+		moduleStatus = map[string]ModuleStatus{}
+		for _, m := range modules {
+			status := ModuleStatus{Name: m.name, Enabled: m.enabled(config.Get().Modules)}
+			if status.Enabled {
+				for _, tool := range m.tools {
+					if _, err := exec.LookPath(tool); err != nil {
+						status.Missing = append(status.Missing, tool)
+					}
+				}
+				for _, name := range m.env {
+					if os.Getenv(name) == "" {
+						status.Missing = append(status.Missing, name)
+					}
+				}
+				status.Available = len(status.Missing) == 0
+			}
+			moduleStatus[m.name] = status
+		}
+	})
+	return moduleStatus
+}
+
+// CheckModules verifies the dependencies of the enabled modules and logs
+// the ones that can't run
+func CheckModules() {
+	for _, status := range GetModuleStatus() {
+		switch {
+		case !status.Enabled:
+			log.Printf("Module %s is disabled", status.Name)
+		case !status.Available:
+			log.Printf("⚠️ Module %s is unavailable, missing %s", status.Name, strings.Join(status.Missing, ", "))
+		}
+	}
+}
+
+// GetModuleStatus reports every module in a fixed order
+func GetModuleStatus() []ModuleStatus {
+	statuses := checkModules()
+	list := make([]ModuleStatus, 0, len(modules))
+	for _, m := range modules {
+		list = append(list, statuses[m.name])
+	}
+	return list
+}
+
+// ModuleAvailable reports whether a module is enabled and has its dependencies
+func ModuleAvailable(name string) bool {
+	return checkModules()[name].Available
+}
+
+// ModuleError explains why a module can't be used, or returns nil when it can
+func ModuleError(name string) error {
+	status := checkModules()[name]
+	switch {
+	case !status.Enabled:
+		return models.NewError(models.ErrModuleDisabled, i18n.T("the %s module is disabled", name), nil)
+	case !status.Available && name == ModuleSpotify:
+		// Clients already handle this code by offering to set up Spotify
+		return models.NewError(models.ErrSpotifyUnauthenticated, i18n.T("the %s module is missing %s", name, strings.Join(status.Missing, ", ")), nil)
+	case !status.Available:
+		return models.NewError(models.ErrExternalToolMissing, i18n.T("the %s module is missing %s", name, strings.Join(status.Missing, ", ")), nil)
+	}
+	return nil
+}
+
+// HandleStatus serves GET /status with the state of the modules, maintenance
+// mode, the build, the running goroutines and the external services
+func HandleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"modules": GetModuleStatus(), "maintenance": GetMaintenance(), "build": GetBuildInfo(), "goroutines": GetGoroutineStatus(), "dependencies": GetDependencyStatus()})
+}
@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MusicProvider is a playback backend the generic player commands are routed
+// to. The methods that change playback give up once ctx is done.
+type MusicProvider interface {
+	// Name identifies the provider in responses ("mpris" or "spotify_connect")
+	Name() string
+	// Control runs one of PlayerActions (play, pause, play-pause, next, previous, stop)
+	Control(ctx context.Context, action string) error
+	Seek(ctx context.Context, positionMs int) error
+	SetVolume(ctx context.Context, percent int) error
+	SetShuffle(ctx context.Context, enabled bool) error
+	// SetRepeat accepts "off", "track" or "context"
+	SetRepeat(ctx context.Context, mode string) error
+	// State reads the current playback state back from the backend
+	State() (*PlayerState, error)
+}
+
+// PlayerState is the playback state reported back after a player command
+type PlayerState struct {
+	Playing    bool  `json:"playing"`
+	PositionMs int64 `json:"positionMs"`
+	Volume     int   `json:"volume"` // 0-100
+}
+
+// ActiveMusicProvider picks the backend the user is most likely listening to:
+// a playing web player reported by the browser extension or local MPRIS
+// player first, then a Spotify Connect device that is playing (or any Spotify
+// Connect device when no local player exists)
+func ActiveMusicProvider() MusicProvider {
+	local, err := GetPlayerInfo()
+	hasLocal := err == nil && local.Player != ""
+	if browser := activeBrowserPlayer(); browser != nil && hasLocal && local.Player == browser.MediaInfo().Player {
+		return BrowserProvider{player: browser}
+	}
+	if hasLocal && local.Status == "Playing" {
+		return MPRISProvider{}
+	}
+
+	if spotify := activeSpotifyConnect(); spotify != nil {
+		playing := spotify.state.Track != nil && spotify.state.Track.IsPlaying
+		if playing || !hasLocal {
+			return spotify
+		}
+	}
+
+	return MPRISProvider{}
+}
+
+// MPRISProvider controls local players through playerctl
+type MPRISProvider struct {
+	// Player targets a specific MPRIS player; empty means whichever playerctl picks
+	Player string
+}
+
+func (MPRISProvider) Name() string { return "mpris" }
+
+// playerctl runs playerctl against the provider's player
+func (p MPRISProvider) playerctl(ctx context.Context, args ...string) error {
+	if p.Player != "" {
+		args = append([]string{"--player=" + p.Player}, args...)
+	}
+	_, err := SpawnProcessContext(ctx, "playerctl", args)
+	return err
+}
+
+func (p MPRISProvider) Control(ctx context.Context, action string) error {
+	if p.Player == "" {
+		return PlayerControl(ctx, action)
+	}
+	if !isPlayerAction(action) {
+		return fmt.Errorf("unsupported player action: %s", action)
+	}
+	return p.playerctl(ctx, action)
+}
+
+func (p MPRISProvider) Seek(ctx context.Context, positionMs int) error {
+	if positionMs < 0 {
+		return models.NewParamError("position_ms", i18n.T("position must not be negative"))
+	}
+	seconds := strconv.FormatFloat(float64(positionMs)/1000, 'f', 3, 64)
+	return p.playerctl(ctx, "position", seconds)
+}
+
+func (p MPRISProvider) SetVolume(ctx context.Context, percent int) error {
+	if percent < 0 || percent > 100 {
+		return models.NewParamError("volume", i18n.T("volume must be between 0 and 100"))
+	}
+	level := strconv.FormatFloat(float64(percent)/100, 'f', 2, 64)
+	return p.playerctl(ctx, "volume", level)
+}
+
+func (p MPRISProvider) SetShuffle(ctx context.Context, enabled bool) error {
+	state := "Off"
+	if enabled {
+		state = "On"
+	}
+	return p.playerctl(ctx, "shuffle", state)
+}
+
+func (p MPRISProvider) SetRepeat(ctx context.Context, mode string) error {
+	loops := map[string]string{"off": "None", "track": "Track", "context": "Playlist"}
+	loop, ok := loops[mode]
+	if !ok {
+		return models.NewParamError("mode", i18n.T("repeat mode must be off, track or context"))
+	}
+	return p.playerctl(ctx, "loop", loop)
+}
+
+func (p MPRISProvider) State() (*PlayerState, error) {
+	args := []string{"metadata", "--format", "{{status}}|||{{position}}|||{{volume}}"}
+	if p.Player != "" {
+		args = append([]string{"--player=" + p.Player}, args...)
+	}
+	output, err := SpawnProcess("playerctl", args)
+	if err != nil {
+		return nil, err
+	}
+	return parsePlayerState(string(output))
+}
+
+// parsePlayerState parses the status, position and volume State asks
+// playerctl for, ||| separated
+func parsePlayerState(output string) (*PlayerState, error) {
+	parts := strings.Split(strings.TrimSpace(output), "|||")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("unexpected playerctl output")
+	}
+	position, _ := strconv.ParseInt(parts[1], 10, 64)
+	volume, _ := strconv.ParseFloat(parts[2], 64)
+
+	return &PlayerState{
+		Playing:    parts[0] == "Playing",
+		PositionMs: position / 1000,
+		Volume:     int(volume*100 + 0.5),
+	}, nil
+}
+
+// SpotifyConnectProvider controls playback on the active Spotify Connect device
+type SpotifyConnectProvider struct {
+	client *SpotifyClient
+	state  *SpotifyPlaybackState
+}
+
+// NewSpotifyDeviceProvider targets a Spotify Connect device by name or ID,
+// whether or not it is the active device
+func NewSpotifyDeviceProvider(device string) (*SpotifyConnectProvider, error) {
+	client, err := requireSpotify()
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := client.GetDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range devices {
+		if d.ID == device || strings.EqualFold(d.Name, device) {
+			state := &SpotifyPlaybackState{DeviceID: d.ID, DeviceName: d.Name, DeviceType: d.Type, Volume: d.Volume}
+			// Only the active device has a meaningful playback state
+			if d.IsActive {
+				if active, err := client.GetPlaybackState(); err == nil && active != nil {
+					state = active
+				}
+			}
+			return &SpotifyConnectProvider{client: client, state: state}, nil
+		}
+	}
+
+	return nil, models.NewError(models.ErrPlayerNotFound, fmt.Sprintf("spotify device %q not found", device), nil)
+}
+
+// activeSpotifyConnect returns a provider for the current Spotify Connect device, if any
+func activeSpotifyConnect() *SpotifyConnectProvider {
+	client := GetSpotifyClient()
+	if client == nil || !client.IsAuthenticated() {
+		return nil
+	}
+
+	state, err := client.GetPlaybackState()
+	if err != nil || state == nil || state.DeviceID == "" {
+		return nil
+	}
+
+	return &SpotifyConnectProvider{client: client, state: state}
+}
+
+func (p *SpotifyConnectProvider) Name() string { return "spotify_connect" }
+
+func (p *SpotifyConnectProvider) Control(ctx context.Context, action string) error {
+	deviceID := p.state.DeviceID
+	switch action {
+	case "play":
+		return p.client.Play(ctx, deviceID)
+	case "pause", "stop":
+		return p.client.Pause(ctx, deviceID)
+	case "play-pause":
+		if p.state.Track != nil && p.state.Track.IsPlaying {
+			return p.client.Pause(ctx, deviceID)
+		}
+		return p.client.Play(ctx, deviceID)
+	case "next":
+		return p.client.Next(ctx, deviceID)
+	case "previous":
+		return p.client.Previous(ctx, deviceID)
+	}
+	return fmt.Errorf("unsupported player action: %s", action)
+}
+
+func (p *SpotifyConnectProvider) Seek(ctx context.Context, positionMs int) error {
+	return p.client.Seek(ctx, positionMs, p.state.DeviceID)
+}
+
+func (p *SpotifyConnectProvider) SetVolume(ctx context.Context, percent int) error {
+	return p.client.SetVolume(ctx, percent, p.state.DeviceID)
+}
+
+func (p *SpotifyConnectProvider) SetShuffle(ctx context.Context, enabled bool) error {
+	return p.client.SetShuffle(ctx, enabled, p.state.DeviceID)
+}
+
+func (p *SpotifyConnectProvider) SetRepeat(ctx context.Context, mode string) error {
+	return p.client.SetRepeat(ctx, mode, p.state.DeviceID)
+}
+
+func (p *SpotifyConnectProvider) State() (*PlayerState, error) {
+	state, err := p.client.GetPlaybackState()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return &PlayerState{}, nil
+	}
+
+	result := &PlayerState{Volume: state.Volume}
+	if state.Track != nil {
+		result.Playing = state.Track.IsPlaying
+		result.PositionMs = int64(state.Track.Progress)
+	}
+	return result, nil
+}
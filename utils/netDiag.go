@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Diagnosis step states
+const (
+	DiagPassed  = "passed"
+	DiagFailed  = "failed"
+	DiagSkipped = "skipped" // An earlier step failed, so this one can't tell anything
+)
+
+// connectivityCheckURL answers 204 when the internet is reachable without a captive portal
+const connectivityCheckURL = "http://connectivitycheck.gstatic.com/generate_204"
+
+// DiagStep is one check of the connectivity diagnosis
+type DiagStep struct {
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// NetDiagnosis is the result of RunNetDiag, ordered like a checklist
+type NetDiagnosis struct {
+	OK    bool       `json:"ok"`
+	Steps []DiagStep `json:"steps"`
+}
+
+// RunNetDiag checks the connection step by step: link, IP address, gateway,
+// DNS and internet reachability. A failed step skips the ones depending on it.
+// Every step is bounded so the whole run stays within the command timeout.
+func RunNetDiag() *NetDiagnosis {
+	diagnosis := &NetDiagnosis{OK: true}
+	var device, gateway string
+
+	run := func(name, label string, check func() (string, error)) bool {
+		step := DiagStep{Name: name, Label: label, Status: DiagSkipped}
+		if !diagnosis.OK {
+			diagnosis.Steps = append(diagnosis.Steps, step)
+			return false
+		}
+
+		started := time.Now()
+		detail, err := check()
+		step.DurationMs = time.Since(started).Milliseconds()
+		step.Status = DiagPassed
+		step.Detail = detail
+		if err != nil {
+			step.Status = DiagFailed
+			step.Detail = err.Error()
+			diagnosis.OK = false
+		}
+		diagnosis.Steps = append(diagnosis.Steps, step)
+		return err == nil
+	}
+
+	run("link", "Network link is up", func() (string, error) {
+		var err error
+		gateway, device, err = defaultRoute()
+		if err != nil {
+			return "", fmt.Errorf("no network connection")
+		}
+		iface, err := net.InterfaceByName(device)
+		if err != nil {
+			return "", err
+		}
+		if iface.Flags&net.FlagUp == 0 {
+			return "", fmt.Errorf("%s is down", device)
+		}
+		return device, nil
+	})
+
+	run("ip", "Got an IP address", func() (string, error) {
+		iface, err := net.InterfaceByName(device)
+		if err != nil {
+			return "", err
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return "", err
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() {
+				return ipNet.IP.String(), nil
+			}
+		}
+		return "", fmt.Errorf("%s has no IP address", device)
+	})
+
+	run("gateway", "Router answers", func() (string, error) {
+		if gateway == "" {
+			return "", fmt.Errorf("no default gateway")
+		}
+		result := pingTarget(gateway, 1)
+		if result.Loss >= 100 {
+			return "", fmt.Errorf("%s does not answer", gateway)
+		}
+		return fmt.Sprintf("%s in %.1f ms", gateway, result.RTTMs), nil
+	})
+
+	run("dns", "DNS resolves names", func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, "connectivitycheck.gstatic.com")
+		if err != nil || len(addrs) == 0 {
+			return "", fmt.Errorf("could not resolve connectivitycheck.gstatic.com")
+		}
+		return addrs[0], nil
+	})
+
+	run("internet", "Internet is reachable", func() (string, error) {
+		client := NewHTTPClient("", time.Second)
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		resp, err := client.Get(connectivityCheckURL)
+		if err != nil {
+			return "", fmt.Errorf("no response from the internet")
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			return "", fmt.Errorf("connection is intercepted (captive portal?), got %s", resp.Status)
+		}
+		return "", nil
+	})
+
+	return diagnosis
+}
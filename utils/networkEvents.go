@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// wifiHistorySize is how many WiFi samples are kept (5 minutes at one sample every 5s)
+const wifiHistorySize = 60
+
+// Network event types
+const (
+	NetworkEventConnected    = "connected"
+	NetworkEventDisconnected = "disconnected"
+	NetworkEventRoamed       = "roamed"       // Same network, different access point
+	NetworkEventBandChanged  = "band_changed" // Same access point, different band
+)
+
+// WiFiSample is one point of the signal history
+type WiFiSample struct {
+	Timestamp int64  `json:"timestamp"`
+	Connected bool   `json:"connected"`
+	SSID      string `json:"ssid,omitempty"`
+	BSSID     string `json:"bssid,omitempty"`
+	Band      string `json:"band,omitempty"`
+	Signal    int    `json:"signal"`
+}
+
+// NetworkEvent describes a change of the WiFi access point or band
+type NetworkEvent struct {
+	Type      string     `json:"type"`
+	Previous  WiFiSample `json:"previous"`
+	Current   WiFiSample `json:"current"`
+	Timestamp int64      `json:"timestamp"`
+}
+
+// Topic implements models.Payload
+func (NetworkEvent) Topic() string { return "network_event" }
+
+// Version implements models.Payload
+func (NetworkEvent) Version() int { return 1 }
+
+var (
+	wifiHistory   []WiFiSample
+	wifiHistoryMu sync.Mutex
+)
+
+// RecordWiFiSample adds the current WiFi state to the history and returns an
+// event when the connection, access point (BSSID) or band changed since the last sample
+func RecordWiFiSample(info *WiFiInfo) *NetworkEvent {
+	sample := WiFiSample{
+		Timestamp: time.Now().Unix(),
+		Connected: info.Connected,
+		SSID:      info.SSID,
+		BSSID:     info.BSSID,
+		Band:      info.Band,
+		Signal:    info.SignalStrength,
+	}
+
+	wifiHistoryMu.Lock()
+	defer wifiHistoryMu.Unlock()
+
+	var previous *WiFiSample
+	if len(wifiHistory) > 0 {
+		previous = &wifiHistory[len(wifiHistory)-1]
+	}
+
+	var event *NetworkEvent
+	if previous != nil {
+		eventType := ""
+		switch {
+		case !previous.Connected && sample.Connected:
+			eventType = NetworkEventConnected
+		case previous.Connected && !sample.Connected:
+			eventType = NetworkEventDisconnected
+		case !sample.Connected:
+		case previous.BSSID != sample.BSSID:
+			eventType = NetworkEventRoamed
+		case previous.Band != sample.Band:
+			eventType = NetworkEventBandChanged
+		}
+		if eventType != "" {
+			event = &NetworkEvent{Type: eventType, Previous: *previous, Current: sample, Timestamp: sample.Timestamp}
+		}
+	}
+
+	wifiHistory = append(wifiHistory, sample)
+	if len(wifiHistory) > wifiHistorySize {
+		wifiHistory = wifiHistory[len(wifiHistory)-wifiHistorySize:]
+	}
+
+	return event
+}
+
+// GetWiFiHistory returns the recent WiFi samples, oldest first
+func GetWiFiHistory() []WiFiSample {
+	wifiHistoryMu.Lock()
+	defer wifiHistoryMu.Unlock()
+	return append([]WiFiSample{}, wifiHistory...)
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProcessNetworkUsage is the network activity of one process
+type ProcessNetworkUsage struct {
+	PID          int     `json:"pid"`
+	Name         string  `json:"name"`
+	SentKBps     float64 `json:"sentKBps"`     // Only measured with nethogs
+	ReceivedKBps float64 `json:"receivedKBps"` // Only measured with nethogs
+	Connections  int     `json:"connections"`  // Established TCP connections and UDP sockets
+	QueuedBytes  int64   `json:"queuedBytes"`  // Bytes waiting in the socket queues
+}
+
+// NetworkUsage lists processes by network activity. Source is "nethogs" when
+// real per-process bandwidth was measured, or "sockets" when it could only be
+// estimated from the open sockets in /proc/net.
+type NetworkUsage struct {
+	Source    string                `json:"source"`
+	Processes []ProcessNetworkUsage `json:"processes"`
+}
+
+// GetNetworkUsage reports which processes are using the network right now.
+// nethogs (which needs CAP_NET_ADMIN) gives bandwidth per process; without it
+// the sockets of each process are counted instead.
+func GetNetworkUsage() (*NetworkUsage, error) {
+	if _, err := exec.LookPath("nethogs"); err == nil {
+		if processes, err := nethogsUsage(); err == nil {
+			return &NetworkUsage{Source: "nethogs", Processes: processes}, nil
+		}
+	}
+
+	processes, err := socketUsage()
+	if err != nil {
+		return nil, err
+	}
+	return &NetworkUsage{Source: "sockets", Processes: processes}, nil
+}
+
+// nethogsUsage runs two nethogs refreshes in trace mode and parses the last one:
+//
+//	Refreshing:
+//	/usr/lib/firefox/firefox/2345/1000	12.3	456.7
+func nethogsUsage() ([]ProcessNetworkUsage, error) {
+	output, err := SpawnProcess("nethogs", []string{"-t", "-c", "2", "-d", "1"})
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []ProcessNetworkUsage
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "Refreshing:") {
+			processes = []ProcessNetworkUsage{}
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if processes == nil || len(fields) < 3 {
+			continue
+		}
+
+		// program/pid/uid, where program may itself contain slashes
+		parts := strings.Split(fields[0], "/")
+		if len(parts) < 3 {
+			continue
+		}
+		pid, _ := strconv.Atoi(parts[len(parts)-2])
+		if pid == 0 {
+			continue // Traffic nethogs couldn't attribute
+		}
+		sent, _ := strconv.ParseFloat(fields[1], 64)
+		received, _ := strconv.ParseFloat(fields[2], 64)
+
+		processes = append(processes, ProcessNetworkUsage{
+			PID:          pid,
+			Name:         processName(pid, strings.Join(parts[:len(parts)-2], "/")),
+			SentKBps:     sent,
+			ReceivedKBps: received,
+		})
+	}
+
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].SentKBps+processes[i].ReceivedKBps > processes[j].SentKBps+processes[j].ReceivedKBps
+	})
+	return processes, nil
+}
+
+// netSocket is an active socket from /proc/net/{tcp,udp}[6]
+type netSocket struct {
+	queued int64
+}
+
+// socketUsage matches the active sockets in /proc/net against the socket
+// inodes each process holds open
+func socketUsage() ([]ProcessNetworkUsage, error) {
+	sockets := map[string]netSocket{}
+	for _, table := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		readSocketTable(filepath.Join("/proc/net", table), strings.HasPrefix(table, "tcp"), sockets)
+	}
+
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	processes := []ProcessNetworkUsage{}
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+
+		// Other users' processes are unreadable without root; skip them
+		fds, err := os.ReadDir(filepath.Join("/proc", proc.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+
+		usage := ProcessNetworkUsage{PID: pid}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join("/proc", proc.Name(), "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			if socket, ok := sockets[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")]; ok {
+				usage.Connections++
+				usage.QueuedBytes += socket.queued
+			}
+		}
+
+		if usage.Connections > 0 {
+			usage.Name = processName(pid, "")
+			processes = append(processes, usage)
+		}
+	}
+
+	sort.Slice(processes, func(i, j int) bool {
+		if processes[i].QueuedBytes != processes[j].QueuedBytes {
+			return processes[i].QueuedBytes > processes[j].QueuedBytes
+		}
+		return processes[i].Connections > processes[j].Connections
+	})
+	return processes, nil
+}
+
+// readSocketTable adds the sockets of one /proc/net table, keyed by inode.
+// For TCP only established connections count; listening sockets carry no traffic.
+func readSocketTable(path string, tcp bool, sockets map[string]netSocket) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[9] == "0" {
+			continue
+		}
+		if tcp && fields[3] != "01" {
+			continue
+		}
+
+		var queued int64
+		if tx, rx, ok := strings.Cut(fields[4], ":"); ok {
+			txBytes, _ := strconv.ParseInt(tx, 16, 64)
+			rxBytes, _ := strconv.ParseInt(rx, 16, 64)
+			queued = txBytes + rxBytes
+		}
+		sockets[fields[9]] = netSocket{queued: queued}
+	}
+}
+
+// processName returns the command name of a process, or fallback's base name
+func processName(pid int, fallback string) string {
+	if comm, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm")); err == nil {
+		return strings.TrimSpace(string(comm))
+	}
+	return filepath.Base(fallback)
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"Blitz/config"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	normalizeStrip []*regexp.Regexp
+	normalizeOnce  sync.Once
+
+	// featRegex matches the spellings of "featuring" in titles and artists
+	featRegex  = regexp.MustCompile(`(?i)(^|[\s(\[])(?:feat\.?|ft\.?|featuring)\s+`)
+	spaceRegex = regexp.MustCompile(`\s{2,}`)
+)
+
+// NormalizeTrack cleans up a track's metadata so one track is recorded the
+// same way whatever player or release it came from: edition suffixes such as
+// "(Remastered 2011)" are stripped from title and album, "ft."/"featuring"
+// become "feat.", and multi-artist strings are split into Artists, with the
+// first one kept as Artist. The rules come from the normalize config.
+func NormalizeTrack(track TrackSummary) TrackSummary {
+	settings := config.Get().Normalize
+	if !settings.Enabled {
+		return track
+	}
+
+	normalizeOnce.Do(func() {
+		for _, pattern := range settings.StripPatterns {
+			compiled, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				log.Printf("Ignoring invalid normalize pattern %q: %v", pattern, err)
+				continue
+			}
+			normalizeStrip = append(normalizeStrip, compiled)
+		}
+	})
+
+	track.Title = normalizeTitle(track.Title)
+	track.Album = normalizeTitle(track.Album)
+
+	artists := splitArtists(featRegex.ReplaceAllString(track.Artist, "${1}feat. "), settings.ArtistSeparators)
+	if len(artists) > 0 {
+		track.Artist = artists[0]
+	}
+	if len(artists) > 1 {
+		track.Artists = artists
+	}
+	return track
+}
+
+// normalizeTitle strips the configured patterns and unifies "feat."
+func normalizeTitle(title string) string {
+	normalized := title
+	for _, pattern := range normalizeStrip {
+		normalized = pattern.ReplaceAllString(normalized, "")
+	}
+	normalized = featRegex.ReplaceAllString(normalized, "${1}feat. ")
+	normalized = strings.TrimSpace(spaceRegex.ReplaceAllString(normalized, " "))
+
+	// Never strip a title down to nothing, e.g. a track called "(Remastered)"
+	if normalized == "" {
+		return title
+	}
+	return normalized
+}
+
+// splitArtists splits an artist string at every separator, dropping empty
+// and repeated names
+func splitArtists(artist string, separators []string) []string {
+	parts := []string{artist}
+	for _, separator := range separators {
+		var next []string
+		for _, part := range parts {
+			next = append(next, splitFold(part, separator)...)
+		}
+		parts = next
+	}
+
+	var artists []string
+	seen := map[string]bool{}
+	for _, part := range parts {
+		name := strings.TrimSpace(part)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		artists = append(artists, name)
+	}
+	return artists
+}
+
+// splitFold is strings.Split ignoring case
+func splitFold(s, separator string) []string {
+	if separator == "" {
+		return []string{s}
+	}
+	lower, sep := strings.ToLower(s), strings.ToLower(separator)
+	if len(lower) != len(s) {
+		// Lowercasing changed the byte offsets, fall back to an exact match
+		return strings.Split(s, separator)
+	}
+
+	var parts []string
+	for {
+		index := strings.Index(lower, sep)
+		if index < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:index])
+		s, lower = s[index+len(sep):], lower[index+len(sep):]
+	}
+}
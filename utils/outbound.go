@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"Blitz/config"
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// NewHTTPClient returns a client for requests to the internet that gives up
+// after timeout. It goes through the proxy in $HTTPS_PROXY or $HTTP_PROXY,
+// except for the hosts in $NO_PROXY, and trusts outbound.caFile. Requests
+// are measured and circuit broken as the named dependency; without a name,
+// e.g. for diagnostics that must always try, they are sent as they are.
+func NewHTTPClient(dependency string, timeout time.Duration) *http.Client {
+	transport := outboundRoundTripper{}
+	if dependency != "" {
+		transport.dependency = trackDependency(dependency)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// outboundRoundTripper sets up the transport with the first request, as
+// clients are created before the config is loaded
+type outboundRoundTripper struct {
+	dependency *dependency
+}
+
+func (t outboundRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.dependency == nil {
+		return outboundTransport().RoundTrip(req)
+	}
+	return t.dependency.roundTrip(outboundTransport(), req)
+}
+
+var outboundTransport = sync.OnceValue(func() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.TLSClientConfig = OutboundTLSConfig()
+	return transport
+})
+
+// OutboundTLSConfig returns the TLS configuration of outbound connections,
+// for the WebSocket dialers that can't use NewHTTPClient. It is nil, Go's
+// default, unless outbound.caFile adds CAs to the system's, e.g. the root
+// of a corporate proxy that inspects TLS.
+func OutboundTLSConfig() *tls.Config {
+	return outboundTLSConfig()
+}
+
+var outboundTLSConfig = sync.OnceValue(func() *tls.Config {
+	caFile := config.Get().Outbound.CAFile
+	if caFile == "" {
+		return nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		log.Println("❌ Failed to read the CA bundle, using the system CAs only:", err)
+		return nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		log.Println("❌ No certificates in the CA bundle, using the system CAs only:", caFile)
+		return nil
+	}
+
+	log.Println("🔐 Trusting the CAs in", caFile)
+	return &tls.Config{RootCAs: pool}
+})
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Regenerate the golden files after a deliberate change with
+//
+//	go test ./utils -run TestParsers -update
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// parsers maps each directory under testdata to the parser its captures are
+// fed to. Every <name>.txt is the output of the tool on some distro or
+// device; <name>.golden.json is what the parser must make of it.
+var parsers = map[string]func(output string) any{
+	"bluetoothctl-devices": func(output string) any { return parseBluetoothDevices(output) },
+	"bluetoothctl-info": func(output string) any {
+		device := BluetoothDevice{Battery: -1, BatteryLeft: -1, BatteryRight: -1, BatteryCase: -1, Icon: "bluetooth"}
+		parseBluetoothInfo(&device, output)
+		return device
+	},
+	"nmcli-wifi": func(output string) any { return parseNmcliWiFi(output) },
+	"nmcli-active": func(output string) any {
+		connections := map[string]string{}
+		for _, device := range []string{"wlp2s0", "wlan0", "enp3s0"} {
+			connections[device] = parseNmcliActiveConnection(output, device)
+		}
+		return connections
+	},
+	"nmcli-details": func(output string) any {
+		info := &WiFiInfo{}
+		parseNmcliConnectionDetails(info, output)
+		return map[string]string{"security": info.Security, "ipAddress": info.IPAddress}
+	},
+	"nmcli-connections":  func(output string) any { return parseSavedWiFiConnections(output) },
+	"iw-link":            func(output string) any { return parseIwLinkSpeed(output) },
+	"playerctl-metadata": func(output string) any { return parsePlayerctlMetadata(output) },
+	"playerctl-state": func(output string) any {
+		state, err := parsePlayerState(output)
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+		return state
+	},
+	"playerctl-list": func(output string) any { return parsePlayerList(output) },
+	"ddcutil-input": func(output string) any {
+		value, err := parseDDCInput(output)
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+		return map[string]any{"value": value, "input": displayInputName(value)}
+	},
+}
+
+func TestParsers(t *testing.T) {
+	for dir, parse := range parsers {
+		captures, err := filepath.Glob(filepath.Join("testdata", dir, "*.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(captures) == 0 {
+			t.Errorf("%s: no captures in testdata", dir)
+		}
+
+		for _, capture := range captures {
+			name := strings.TrimSuffix(capture, ".txt")
+			t.Run(strings.TrimPrefix(name, "testdata"+string(filepath.Separator)), func(t *testing.T) {
+				output, err := os.ReadFile(capture)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := json.MarshalIndent(parse(string(output)), "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, '\n')
+
+				golden := name + ".golden.json"
+				if *update {
+					if err := os.WriteFile(golden, got, 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("%v (run with -update to create it)", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("parsed %s differently than %s:\ngot:\n%s\nwant:\n%s", capture, golden, got, want)
+				}
+			})
+		}
+	}
+}
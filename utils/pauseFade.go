@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxPauseFade bounds the fade before pausing, which the command waits for
+	MaxPauseFade = 10 * time.Second
+	// pauseSettleDelay gives the player time to stop its audio before the
+	// faded-out volume is restored
+	pauseSettleDelay = 200 * time.Millisecond
+)
+
+// PauseFade returns the configured fade of pause, stop and resume
+func PauseFade() time.Duration {
+	return time.Duration(config.Get().PauseFade.Ms) * time.Millisecond
+}
+
+// pauseFades are the fades ControlWithFade is running, by player
+var (
+	pauseFades   = map[string]*pauseFade{}
+	pauseFadesMu sync.Mutex
+)
+
+// pauseFade is a fade ControlWithFade started. A fade that starts while
+// another one runs takes over its volume, rather than the dipped one the
+// stream has at that moment, and the one it replaced gives up.
+type pauseFade struct {
+	volume  int  // The stream volume before the first fade, to come back to
+	pausing bool // Fading out, the player pauses at the end
+}
+
+// ControlWithFade runs a player action, fading the player's PipeWire stream
+// out before pausing or stopping and back in when playback resumes. Only
+// local players have a stream to fade; other providers and a fade of 0 run
+// the action as is. When ctx is done during the fade out, or playback is
+// resumed, the volume fades back in and the player doesn't pause.
+func ControlWithFade(ctx context.Context, provider MusicProvider, action string, fade time.Duration) error {
+	mpris, ok := provider.(MPRISProvider)
+	if !ok || fade <= 0 || (action != "play" && action != "pause" && action != "stop" && action != "play-pause") {
+		return provider.Control(ctx, action)
+	}
+	if fade > MaxPauseFade {
+		return models.NewError(models.ErrInvalidParams, i18n.T("the fade must not be longer than %s", MaxPauseFade), nil)
+	}
+
+	player := mpris.Player
+	if player == "" {
+		info, err := GetPlayerInfo()
+		if err != nil || info.Player == "" {
+			return provider.Control(ctx, action)
+		}
+		player = info.Player
+	}
+
+	pauseFadesMu.Lock()
+	running := pauseFades[player]
+	pauseFadesMu.Unlock()
+
+	state, err := provider.State()
+	if err != nil {
+		return provider.Control(ctx, action)
+	}
+	if action == "play-pause" {
+		action = "play"
+		if state.Playing && (running == nil || !running.pausing) {
+			action = "pause"
+		}
+	}
+	// Resuming during the fade out cancels it
+	if action == "play" && running != nil && running.pausing {
+		return fadeIn(player, running.volume, fade)
+	}
+	// Fading into what is already playing would only dip the volume
+	if state.Playing == (action == "play") {
+		return provider.Control(ctx, action)
+	}
+
+	volume := 0
+	if running != nil {
+		volume = running.volume
+	} else if volume, err = GetStreamVolume(player); err != nil {
+		// Players without an audio stream (e.g. paused ones that closed it) can't fade
+		return provider.Control(ctx, action)
+	}
+
+	if action == "play" {
+		SetStreamVolume(player, 0)
+		if err := provider.Control(ctx, action); err != nil {
+			SetStreamVolume(player, volume)
+			return err
+		}
+		return fadeIn(player, volume, fade)
+	}
+
+	fadeOut := &pauseFade{volume: volume, pausing: true}
+	pauseFadesMu.Lock()
+	pauseFades[player] = fadeOut
+	pauseFadesMu.Unlock()
+	defer endPauseFade(player, fadeOut)
+
+	done := make(chan struct{})
+	if _, err := FadeTo(player, 0, fade, done); err != nil {
+		return provider.Control(ctx, action)
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		fadeIn(player, volume, fade)
+		return ctx.Err()
+	}
+
+	// A later fade took over, and pauses or resumes in its own time
+	pauseFadesMu.Lock()
+	replaced := pauseFades[player] != fadeOut
+	pauseFadesMu.Unlock()
+	if replaced {
+		return nil
+	}
+
+	err = provider.Control(ctx, action)
+	// Once the player went quiet the volume can come back for whatever
+	// resumes it, Blitz or not
+	time.Sleep(pauseSettleDelay)
+	SetStreamVolume(player, volume)
+	return err
+}
+
+// fadeIn fades the player's stream up to volume, replacing the fade running
+// for it
+func fadeIn(player string, volume int, fade time.Duration) error {
+	f := &pauseFade{volume: volume}
+	pauseFadesMu.Lock()
+	pauseFades[player] = f
+	pauseFadesMu.Unlock()
+
+	done := make(chan struct{})
+	if _, err := FadeTo(player, volume, fade, done); err != nil {
+		endPauseFade(player, f)
+		return err
+	}
+	go func() {
+		<-done
+		endPauseFade(player, f)
+	}()
+	return nil
+}
+
+// endPauseFade forgets f unless another fade replaced it
+func endPauseFade(player string, f *pauseFade) {
+	pauseFadesMu.Lock()
+	if pauseFades[player] == f {
+		delete(pauseFades, player)
+	}
+	pauseFadesMu.Unlock()
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// peripheralLowBattery is the charge at which a peripheral counts as low
+const peripheralLowBattery = 15
+
+// Peripheral is a battery powered device known to UPower: wireless mice and
+// keyboards, gamepads, headsets, UPSes, the laptop battery, ...
+type Peripheral struct {
+	Path       string  `json:"path"`
+	Type       string  `json:"type"` // UPower device type, e.g. "mouse", "keyboard", "gaming-input", "ups"
+	Model      string  `json:"model"`
+	Vendor     string  `json:"vendor,omitempty"`
+	Percentage float64 `json:"percentage"`
+	State      string  `json:"state,omitempty"` // charging, discharging, fully-charged, ...
+	Low        bool    `json:"low"`
+}
+
+// Peripherals is the list of connected peripherals with a battery
+type Peripherals []Peripheral
+
+// Topic implements models.Payload
+func (Peripherals) Topic() string { return "peripherals" }
+
+// Version implements models.Payload
+func (Peripherals) Version() int { return 1 }
+
+// PeripheralBatteryLow announces a peripheral whose battery just ran low
+type PeripheralBatteryLow struct {
+	Peripheral
+}
+
+// Topic implements models.Payload
+func (PeripheralBatteryLow) Topic() string { return "peripheral_battery_low" }
+
+// Version implements models.Payload
+func (PeripheralBatteryLow) Version() int { return 1 }
+
+var (
+	lowPeripherals   = map[string]bool{}
+	lowPeripheralsMu sync.Mutex
+)
+
+// GetPeripherals lists every UPower device that reports a battery level
+func GetPeripherals() ([]Peripheral, error) {
+	output, err := SpawnProcess("upower", []string{"-e"})
+	if err != nil {
+		return nil, err
+	}
+
+	peripherals := []Peripheral{}
+	for _, path := range strings.Fields(string(output)) {
+		// DisplayDevice is UPower's aggregate of the system batteries
+		if strings.HasSuffix(path, "DisplayDevice") || strings.Contains(path, "line_power") {
+			continue
+		}
+		details, err := SpawnProcess("upower", []string{"-i", path})
+		if err != nil {
+			continue
+		}
+		if peripheral, ok := parseUPowerDevice(path, string(details)); ok {
+			peripherals = append(peripherals, peripheral)
+		}
+	}
+	return peripherals, nil
+}
+
+// NewlyLowPeripherals returns the peripherals that became low since the last
+// call, so the warning is sent once per discharge
+func NewlyLowPeripherals(peripherals []Peripheral) []Peripheral {
+	lowPeripheralsMu.Lock()
+	defer lowPeripheralsMu.Unlock()
+
+	newlyLow := []Peripheral{}
+	for _, peripheral := range peripherals {
+		if peripheral.Low && !lowPeripherals[peripheral.Path] {
+			newlyLow = append(newlyLow, peripheral)
+		}
+		lowPeripherals[peripheral.Path] = peripheral.Low
+	}
+	return newlyLow
+}
+
+// parseUPowerDevice reads the output of `upower -i`:
+//
+//	  model:                MX Master 3
+//	  mouse
+//	    present:             yes
+//	    percentage:          55%
+//	    warning-level:       none
+func parseUPowerDevice(path, details string) (Peripheral, bool) {
+	peripheral := Peripheral{Path: path}
+	hasPercentage := false
+	warning := ""
+
+	for _, line := range strings.Split(details, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			// The device type is the only line without a colon
+			if word := strings.TrimSpace(line); word != "" && !strings.Contains(word, " ") && peripheral.Type == "" {
+				peripheral.Type = word
+			}
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "model":
+			peripheral.Model = value
+		case "vendor":
+			peripheral.Vendor = value
+		case "state":
+			peripheral.State = value
+		case "warning-level":
+			warning = value
+		case "percentage":
+			if percentage, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err == nil {
+				peripheral.Percentage = percentage
+				hasPercentage = true
+			}
+		}
+	}
+
+	if !hasPercentage || peripheral.Type == "line-power" {
+		return peripheral, false
+	}
+
+	charging := peripheral.State == "charging" || peripheral.State == "fully-charged"
+	peripheral.Low = !charging && (peripheral.Percentage <= peripheralLowBattery ||
+		(warning != "" && warning != "none"))
+	return peripheral, true
+}
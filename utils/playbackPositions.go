@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/store"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// positionsDocument is the store document holding saved positions
+	positionsDocument = "positions"
+	// resumeMinLength limits position tracking to long-form content (podcasts, audiobooks)
+	resumeMinLength = 10 * time.Minute
+	// positionsSaveInterval throttles disk writes while a track is playing
+	positionsSaveInterval = 15 * time.Second
+	// maxSavedPositions caps how many tracks are remembered
+	maxSavedPositions = 200
+	// resumeGracePeriod keeps a saved position from being overwritten right after
+	// a player restart reopened the track from the beginning
+	resumeGracePeriod = 5 * time.Minute
+)
+
+// SavedPosition is the last known playback position of a long track on a player
+type SavedPosition struct {
+	Player    string `json:"player"`
+	TrackID   string `json:"trackId,omitempty"`
+	Title     string `json:"title"`
+	Artist    string `json:"artist"`
+	Album     string `json:"album"`
+	Position  int64  `json:"position"` // microseconds
+	Length    int64  `json:"length"`   // microseconds
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+var (
+	savedPositions       map[string]*SavedPosition
+	savedPositionsDirty  bool
+	savedPositionsSaveAt time.Time
+	lastPositionKey      string
+	protectedUntil       time.Time
+	savedPositionsMu     sync.Mutex
+)
+
+// positionKey identifies a track on a player
+func positionKey(info MediaInfo) string {
+	// Instance suffixes (vlc.instance1234) change on every restart
+	player := strings.SplitN(info.Player, ".", 2)[0]
+	if info.TrackID != "" && info.TrackID != "/org/mpris/MediaPlayer2/TrackList/NoTrack" {
+		return player + "|" + info.TrackID
+	}
+	return player + "|" + info.Artist + "|" + info.Album + "|" + info.Title
+}
+
+func loadSavedPositions() {
+	if savedPositions != nil {
+		return
+	}
+	savedPositions = map[string]*SavedPosition{}
+	if err := store.Load(positionsDocument, &savedPositions); err != nil {
+		log.Println("Failed to load saved playback positions:", err)
+	}
+}
+
+// RecordPlaybackPosition remembers the position of long-form content. Called on
+// every poll; writes to disk are throttled.
+func RecordPlaybackPosition(info MediaInfo) {
+	length, _ := strconv.ParseInt(info.Length, 10, 64)
+	position, _ := strconv.ParseInt(info.Position, 10, 64)
+	if info.Player == "" || length < resumeMinLength.Microseconds() || position <= 0 {
+		return
+	}
+
+	savedPositionsMu.Lock()
+	defer savedPositionsMu.Unlock()
+
+	loadSavedPositions()
+
+	key := positionKey(info)
+
+	// A track we see again (e.g. after a restart) starting before its saved
+	// position must not clobber it before resume_last had a chance to run
+	if key != lastPositionKey {
+		lastPositionKey = key
+		protectedUntil = time.Time{}
+		if saved, ok := savedPositions[key]; ok && position < saved.Position {
+			protectedUntil = time.Now().Add(resumeGracePeriod)
+		}
+	}
+	if time.Now().Before(protectedUntil) {
+		if position < savedPositions[key].Position {
+			return
+		}
+		protectedUntil = time.Time{}
+	}
+
+	// Finished content doesn't need resuming
+	if length-position <= trackEndTolerance.Microseconds() {
+		if _, ok := savedPositions[key]; ok {
+			delete(savedPositions, key)
+			savedPositionsDirty = true
+		}
+	} else {
+		savedPositions[key] = &SavedPosition{
+			Player:    info.Player,
+			TrackID:   info.TrackID,
+			Title:     info.Title,
+			Artist:    info.Artist,
+			Album:     info.Album,
+			Position:  position,
+			Length:    length,
+			UpdatedAt: time.Now().Unix(),
+		}
+		savedPositionsDirty = true
+	}
+
+	if savedPositionsDirty && time.Since(savedPositionsSaveAt) >= positionsSaveInterval {
+		flushSavedPositions()
+	}
+}
+
+// flushSavedPositions prunes the oldest entries and writes the positions to disk
+func flushSavedPositions() {
+	if len(savedPositions) > maxSavedPositions {
+		keys := make([]string, 0, len(savedPositions))
+		for key := range savedPositions {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return savedPositions[keys[i]].UpdatedAt > savedPositions[keys[j]].UpdatedAt
+		})
+		for _, key := range keys[maxSavedPositions:] {
+			delete(savedPositions, key)
+		}
+	}
+
+	if err := store.Save(positionsDocument, savedPositions); err != nil {
+		log.Println("Failed to save playback positions:", err)
+		return
+	}
+	savedPositionsDirty = false
+	savedPositionsSaveAt = time.Now()
+}
+
+// FlushPlaybackPositions writes the positions recorded since the last write,
+// so stopping Blitz doesn't lose up to positionsSaveInterval of them
+func FlushPlaybackPositions() {
+	savedPositionsMu.Lock()
+	defer savedPositionsMu.Unlock()
+	if savedPositionsDirty {
+		flushSavedPositions()
+	}
+}
+
+// ResumeLastPosition seeks the active player back to the saved position of the
+// track it is playing, e.g. after the player was restarted
+func ResumeLastPosition(ctx context.Context) (*SavedPosition, error) {
+	info, err := GetPlayerInfo()
+	if err != nil {
+		return nil, err
+	}
+	if info.Player == "" {
+		return nil, models.NewError(models.ErrPlayerNotFound, i18n.T("no active player"), nil)
+	}
+
+	savedPositionsMu.Lock()
+	loadSavedPositions()
+	saved, ok := savedPositions[positionKey(info)]
+	if ok {
+		copied := *saved
+		saved = &copied
+		protectedUntil = time.Time{}
+	}
+	savedPositionsMu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no saved position for %s", info.Title)
+	}
+
+	if err := (MPRISProvider{}).Seek(ctx, int(saved.Position/1000)); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"regexp"
+	"sync"
+)
+
+// PlayerCapabilities mirrors the MPRIS Can* properties so remote UIs can grey out unsupported buttons
+type PlayerCapabilities struct {
+	CanControl    bool `json:"canControl"`
+	CanPlay       bool `json:"canPlay"`
+	CanPause      bool `json:"canPause"`
+	CanSeek       bool `json:"canSeek"`
+	CanGoNext     bool `json:"canGoNext"`
+	CanGoPrevious bool `json:"canGoPrevious"`
+}
+
+var (
+	capabilitiesCache    *PlayerCapabilities
+	capabilitiesCacheKey string
+	capabilitiesMu       sync.Mutex
+	canPropertyRegex     = regexp.MustCompile(`string "(Can\w+)"\s*\n\s*variant\s+boolean (true|false)`)
+)
+
+// GetPlayerCapabilities reads the Can* properties of an MPRIS player over D-Bus.
+// instance is the player's bus name suffix ({{playerInstance}}), as players
+// running more than once own "org.mpris.MediaPlayer2.vlc.instance1234"
+// rather than their plain name. trackKey identifies the current track; capabilities are re-read when it changes
+// because players like browsers toggle CanSeek between live streams and regular media.
+func GetPlayerCapabilities(instance, trackKey string) *PlayerCapabilities {
+	if instance == "" {
+		return nil
+	}
+
+	key := instance + "|" + trackKey
+
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+
+	if key == capabilitiesCacheKey {
+		return capabilitiesCache
+	}
+
+	output, err := SpawnProcess("dbus-send", []string{
+		"--session",
+		"--print-reply",
+		"--dest=org.mpris.MediaPlayer2." + instance,
+		"/org/mpris/MediaPlayer2",
+		"org.freedesktop.DBus.Properties.GetAll",
+		"string:org.mpris.MediaPlayer2.Player",
+	})
+
+	var capabilities *PlayerCapabilities
+	if err == nil {
+		capabilities = parsePlayerCapabilities(string(output))
+	}
+
+	capabilitiesCache = capabilities
+	capabilitiesCacheKey = key
+	return capabilities
+}
+
+// parsePlayerCapabilities extracts the Can* booleans from dbus-send GetAll output
+func parsePlayerCapabilities(output string) *PlayerCapabilities {
+	matches := canPropertyRegex.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	capabilities := &PlayerCapabilities{}
+	for _, match := range matches {
+		value := match[2] == "true"
+		switch match[1] {
+		case "CanControl":
+			capabilities.CanControl = value
+		case "CanPlay":
+			capabilities.CanPlay = value
+		case "CanPause":
+			capabilities.CanPause = value
+		case "CanSeek":
+			capabilities.CanSeek = value
+		case "CanGoNext":
+			capabilities.CanGoNext = value
+		case "CanGoPrevious":
+			capabilities.CanGoPrevious = value
+		}
+	}
+
+	return capabilities
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+)
+
+// PlayerActions lists the playerctl actions accepted by PlayerControl
+var PlayerActions = []string{"play", "pause", "play-pause", "next", "previous", "stop"}
+
+// PlayerControl sends a playback action to the active MPRIS player via playerctl
+func PlayerControl(ctx context.Context, action string) error {
+	if !isPlayerAction(action) {
+		return fmt.Errorf("unsupported player action: %s", action)
+	}
+
+	_, err := SpawnProcessContext(ctx, "playerctl", []string{action})
+	return err
+}
+
+func isPlayerAction(action string) bool {
+	for _, a := range PlayerActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RaisePlayer brings a player's window to the front. It uses the MPRIS Raise
+// method when the player supports it and falls back to compositor IPC
+// (Hyprland, Sway) or wmctrl on X11. It returns the method that worked.
+func RaisePlayer(player string) (string, error) {
+	instance := player
+	if player == "" {
+		info, err := GetPlayerInfo()
+		if err != nil {
+			return "", err
+		}
+		if info.Player == "" {
+			return "", models.NewError(models.ErrPlayerNotFound, i18n.T("no active player"), nil)
+		}
+		player, instance = info.Player, info.Instance
+	} else if players, err := GetAllActivePlayers(); err == nil {
+		instance = playerInstance(player, players)
+	}
+
+	dest := "--dest=org.mpris.MediaPlayer2." + instance
+
+	canRaise, err := SpawnProcess("dbus-send", []string{
+		"--session", "--print-reply", dest,
+		"/org/mpris/MediaPlayer2",
+		"org.freedesktop.DBus.Properties.Get",
+		"string:org.mpris.MediaPlayer2",
+		"string:CanRaise",
+	})
+	if err == nil && strings.Contains(string(canRaise), "boolean true") {
+		if _, err := SpawnProcess("dbus-send", []string{
+			"--session", "--print-reply", dest,
+			"/org/mpris/MediaPlayer2",
+			"org.mpris.MediaPlayer2.Raise",
+		}); err == nil {
+			return "mpris", nil
+		}
+	}
+
+	// Window classes are the plain player name, without the instance suffix
+	// of the bus name (firefox.instance_1_84 -> firefox)
+	windowClass := strings.SplitN(player, ".", 2)[0]
+
+	switch {
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+		if _, err := SpawnProcess("hyprctl", []string{"dispatch", "focuswindow", "class:(?i)" + windowClass}); err != nil {
+			return "", fmt.Errorf("failed to focus %s via hyprctl: %v", windowClass, err)
+		}
+		return "hyprland", nil
+	case os.Getenv("SWAYSOCK") != "":
+		criteria := fmt.Sprintf(`[app_id="(?i)%s"] focus`, windowClass)
+		if _, err := SpawnProcess("swaymsg", []string{criteria}); err != nil {
+			// XWayland windows only have a class
+			criteria = fmt.Sprintf(`[class="(?i)%s"] focus`, windowClass)
+			if _, err := SpawnProcess("swaymsg", []string{criteria}); err != nil {
+				return "", fmt.Errorf("failed to focus %s via swaymsg: %v", windowClass, err)
+			}
+		}
+		return "sway", nil
+	default:
+		if _, err := SpawnProcess("wmctrl", []string{"-x", "-a", windowClass}); err != nil {
+			return "", fmt.Errorf("failed to raise %s: player can't raise itself and wmctrl failed: %v", player, err)
+		}
+		return "wmctrl", nil
+	}
+}
+
+// playerInstance finds the bus name suffix of player among the instances
+// `playerctl -l` lists, so "vlc" reaches "vlc.instance1234"
+func playerInstance(player string, instances []string) string {
+	for _, instance := range instances {
+		if instance == player || strings.HasPrefix(instance, player+".") {
+			return instance
+		}
+	}
+	return player
+}
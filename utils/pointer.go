@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxPointerDelta bounds a single move, so a client bug can't fling the
+// pointer across the screen
+const maxPointerDelta = 1000
+
+// maxClickRate is how many clicks are injected per second at most
+const maxClickRate = 10
+
+// pointerButtons maps button names to ydotool's codes, which press and
+// release the button
+var pointerButtons = map[string]string{
+	"left":   "0xC0",
+	"right":  "0xC1",
+	"middle": "0xC2",
+}
+
+// PointerMove reports a move in pixels, after sensitivity. Merged moves are
+// injected with the next one, or shortly after when none follows.
+type PointerMove struct {
+	DX     int  `json:"dx"`
+	DY     int  `json:"dy"`
+	Merged bool `json:"merged,omitempty"`
+}
+
+var (
+	pointerMu sync.Mutex
+	// pendingX and pendingY are the motion not injected yet, including the
+	// fractions of a pixel slow drags add up to
+	pendingX, pendingY float64
+	lastMove           time.Time
+	flushScheduled     bool
+	// recentClicks are the clicks of the last second
+	recentClicks []time.Time
+)
+
+// moveInterval is the time between injected moves from pointer.maxRate
+func moveInterval() time.Duration {
+	rate := config.Get().Pointer.MaxRate
+	if rate <= 0 {
+		rate = 30
+	}
+	return time.Second / time.Duration(rate)
+}
+
+// takePending returns the whole pixels of the pending motion and keeps the
+// fractions for the next move. Called with pointerMu held.
+func takePending() (int, int) {
+	dx, dy := int(pendingX), int(pendingY)
+	pendingX -= float64(dx)
+	pendingY -= float64(dy)
+	lastMove = time.Now()
+	return dx, dy
+}
+
+// MovePointer moves the mouse pointer by dx and dy, scaled by
+// pointer.sensitivity, turning a phone into a trackpad. Moves arriving faster
+// than pointer.maxRate are merged, so no motion is lost.
+func MovePointer(dx, dy float64) (PointerMove, error) {
+	if math.Abs(dx) > maxPointerDelta || math.Abs(dy) > maxPointerDelta {
+		return PointerMove{}, models.NewError(models.ErrInvalidParams, i18n.T("moves must be at most %d in each direction", maxPointerDelta), nil)
+	}
+	sensitivity := config.Get().Pointer.Sensitivity
+	if sensitivity <= 0 {
+		sensitivity = 1
+	}
+
+	pointerMu.Lock()
+	pendingX += dx * sensitivity
+	pendingY += dy * sensitivity
+	if wait := moveInterval() - time.Since(lastMove); wait > 0 {
+		if !flushScheduled {
+			flushScheduled = true
+			time.AfterFunc(wait, flushPointer)
+		}
+		pointerMu.Unlock()
+		return PointerMove{Merged: true}, nil
+	}
+	x, y := takePending()
+	pointerMu.Unlock()
+
+	if x == 0 && y == 0 {
+		return PointerMove{}, nil
+	}
+	return PointerMove{DX: x, DY: y}, injectMove(x, y)
+}
+
+// flushPointer injects the moves merged since the last one
+func flushPointer() {
+	pointerMu.Lock()
+	flushScheduled = false
+	x, y := takePending()
+	pointerMu.Unlock()
+
+	if x == 0 && y == 0 {
+		return
+	}
+	if err := injectMove(x, y); err != nil {
+		log.Println("❌ Failed to move the pointer:", err)
+	}
+}
+
+func injectMove(dx, dy int) error {
+	_, err := SpawnProcess("ydotool", []string{"mousemove", "-x", strconv.Itoa(dx), "-y", strconv.Itoa(dy)})
+	return err
+}
+
+// ClickPointer clicks a mouse button, twice for a double click. Pending moves
+// are injected first, so the click lands where the pointer was sent.
+func ClickPointer(button string, double bool) error {
+	code, ok := pointerButtons[button]
+	if !ok {
+		return models.NewError(models.ErrInvalidParams, i18n.T("unknown button %q, use left, right or middle", button), nil)
+	}
+
+	pointerMu.Lock()
+	now := time.Now()
+	recent := recentClicks[:0]
+	for _, click := range recentClicks {
+		if now.Sub(click) < time.Second {
+			recent = append(recent, click)
+		}
+	}
+	recentClicks = recent
+	if len(recentClicks) >= maxClickRate {
+		pointerMu.Unlock()
+		err := models.NewError(models.ErrRateLimited, i18n.T("too many clicks, slow down"), nil)
+		err.RetryAfter = 1
+		return err
+	}
+	recentClicks = append(recentClicks, now)
+	x, y := takePending()
+	pointerMu.Unlock()
+
+	if x != 0 || y != 0 {
+		if err := injectMove(x, y); err != nil {
+			return err
+		}
+	}
+	args := []string{"click", code}
+	if double {
+		args = []string{"click", "--repeat", "2", "--next-delay", "50", code}
+	}
+	_, err := SpawnProcess("ydotool", args)
+	return err
+}
@@ -0,0 +1,19 @@
+package poller
+
+import (
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleAlarms rings the alarms that fell due and broadcasts each as `alarm`
+func HandleAlarms() {
+	Poller(utils.PollerAlarms, 5*time.Second, stopped, func() {
+		for _, alarm := range utils.TakeDueAlarms() {
+			go func() {
+				websocket.WriteChannelMessage(models.NewEvent(utils.RingAlarm(alarm)))
+			}()
+		}
+	})
+}
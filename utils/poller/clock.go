@@ -0,0 +1,20 @@
+package poller
+
+import (
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleClock broadcasts the host time and NTP status, so clients can render
+// clocks that match the host and warn when it drifts
+func HandleClock() {
+	if !utils.ModuleAvailable(utils.ModuleSystem) {
+		return
+	}
+
+	Poller(utils.PollerClock, 30*time.Second, stopped, func() {
+		websocket.WriteChannelMessage(models.NewEvent(utils.GetClockInfo()))
+	})
+}
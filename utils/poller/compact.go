@@ -0,0 +1,27 @@
+package poller
+
+import (
+	"Blitz/config"
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleCompact broadcasts the compact summary at a slow rate for
+// microcontroller displays
+func HandleCompact() {
+	settings := config.Get().Compact
+	if !settings.Enabled {
+		return
+	}
+
+	interval := time.Duration(settings.IntervalSec) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	Poller(utils.PollerCompact, interval, stopped, func() {
+		websocket.WriteChannelMessage(models.NewEvent(utils.GetCompact()))
+	})
+}
@@ -0,0 +1,40 @@
+package poller
+
+import (
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"fmt"
+	"time"
+)
+
+// HandleGamepads broadcasts the connected controllers when they change, with a
+// gamepad_event per connect or disconnect (e.g. to switch the dashboard to a game layout)
+func HandleGamepads() {
+	if !utils.ModuleAvailable(utils.ModuleSystem) {
+		return
+	}
+
+	var lastGamepads string
+
+	Poller(utils.PollerGamepads, 5*time.Second, stopped, func() {
+		gamepads, err := utils.GetGamepads()
+		if err != nil {
+			return
+		}
+
+		events := utils.DetectGamepadChanges(gamepads)
+		for _, event := range events {
+			websocket.WriteChannelMessage(models.NewEvent(event))
+		}
+
+		// Only send the list when a controller or its battery changed
+		snapshot := fmt.Sprint(gamepads)
+		if len(events) == 0 && snapshot == lastGamepads {
+			return
+		}
+		lastGamepads = snapshot
+
+		websocket.WriteChannelMessage(models.NewEvent(utils.Gamepads(gamepads)))
+	})
+}
@@ -0,0 +1,13 @@
+package poller
+
+import (
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleGoroutines checks every few minutes that clients that went away
+// didn't leave goroutines behind
+func HandleGoroutines() {
+	Poller(utils.PollerGoroutines, 5*time.Minute, stopped, websocket.CheckGoroutines)
+}
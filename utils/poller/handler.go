@@ -11,7 +11,7 @@ import (
 func Handle() {
 	// fmt.Println("Started poller Handler ....")
 
-	Poller(1*time.Second, make(chan struct{}), func() {
+	Poller(utils.PollerMedia, 1*time.Second, stopped, func() {
 		msg, err := utils.GetPlayerInfo()
 
 		if err != nil {
@@ -19,13 +19,57 @@ func Handle() {
 			return
 		}
 
-		websocket.WriteChannelMessage(
-			models.ServerResponse{
-				Status:  "success",
-				Message: "media_info",
-				Data:    msg,
-			},
-		)
+		// Announce transitions once, separately from the periodic snapshots
+		if change := utils.DetectTrackChange(msg); change != nil {
+			announced := *change
+			if utils.PrivacyMode() {
+				announced = announced.Private()
+			}
+			websocket.WriteChannelMessage(models.NewEvent(announced))
+
+			if utils.RecordHistory(change, msg) {
+				websocket.WriteChannelMessage(models.NewEvent(utils.VisibleHistory()))
+			}
+		}
+
+		// Have the next cover ready before the track changes
+		go utils.PreloadNextArtwork(msg)
+
+		// Show where the sound is going
+		msg.Output = utils.GetPlayerOutput(msg.Player)
+
+		// Remember where long podcasts and audiobooks were left off
+		utils.RecordPlaybackPosition(msg)
+
+		// Count the listening time for the statistics
+		utils.RecordListening(msg)
+
+		// Inline the artwork, looking it up online when the player has none,
+		// and link it for clients that load it over HTTP instead
+		if file, err := utils.ResolveMediaArtworkFile(msg); err == nil {
+			msg.ArtworkURL = utils.ArtworkURL(file)
+			if artwork, err := utils.ArtworkDataURI(file); err == nil {
+				msg.Artwork = artwork
+			}
+		}
+
+		// Attach BPM, energy and release year when the track is on Spotify
+		if trackID := utils.SpotifyTrackID(msg.TrackID); trackID != "" {
+			msg.Details = utils.GetTrackDetails(trackID)
+			msg.Context = utils.GetPlaybackContext(trackID)
+		}
+
+		// Only placeholders while the screen is shared or guests are watching
+		if utils.PrivacyMode() {
+			msg = msg.Private()
+		}
+
+		// Preformatted text for tickers and status bars, after privacy mode
+		// so it shows the placeholder too
+		msg = utils.RenderDisplayText(msg)
+
+		// Paused or stopped, nothing changes from one second to the next
+		websocket.WriteChangedMessage(models.NewEvent(msg))
 	})
 }
 
@@ -0,0 +1,20 @@
+package poller
+
+import (
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleHost broadcasts the host details. They rarely change, so once a minute
+// is enough to keep the uptime current.
+func HandleHost() {
+	if !utils.ModuleAvailable(utils.ModuleSystem) {
+		return
+	}
+
+	Poller(utils.PollerHost, time.Minute, stopped, func() {
+		websocket.WriteChannelMessage(models.NewEvent(utils.GetHostInfo()))
+	})
+}
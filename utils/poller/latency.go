@@ -0,0 +1,33 @@
+package poller
+
+import (
+	"Blitz/config"
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleLatency pings the configured targets and broadcasts the results as
+// `latency`, plus a `latency_alert` when a target keeps losing packets
+func HandleLatency() {
+	settings := config.Get().Latency
+	if !settings.Enabled || len(settings.Targets) == 0 {
+		return
+	}
+
+	interval := time.Duration(settings.IntervalSec) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	Poller(utils.PollerLatency, interval, stopped, func() {
+		results, alerts := utils.MeasureLatency()
+
+		websocket.WriteChannelMessage(models.NewEvent(utils.LatencyResults(results)))
+
+		for _, alert := range alerts {
+			websocket.WriteChannelMessage(models.NewEvent(alert))
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package poller
+
+import (
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleNetwork samples the WiFi connection and broadcasts a network_event
+// when the device roams to another access point or band
+func HandleNetwork() {
+	if !utils.ModuleAvailable(utils.ModuleWiFi) {
+		return
+	}
+
+	Poller(utils.PollerNetwork, 5*time.Second, stopped, func() {
+		info, err := utils.GetWiFiInfo()
+		if err != nil {
+			return
+		}
+
+		if event := utils.RecordWiFiSample(info); event != nil {
+			websocket.WriteChannelMessage(models.NewEvent(event))
+		}
+	})
+}
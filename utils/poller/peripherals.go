@@ -0,0 +1,29 @@
+package poller
+
+import (
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandlePeripherals broadcasts the battery levels of all UPower devices, and a
+// peripheral_battery_low warning when one of them runs low
+func HandlePeripherals() {
+	if !utils.ModuleAvailable(utils.ModuleSystem) {
+		return
+	}
+
+	Poller(utils.PollerPeripherals, time.Minute, stopped, func() {
+		peripherals, err := utils.GetPeripherals()
+		if err != nil {
+			return
+		}
+
+		websocket.WriteChangedMessage(models.NewEvent(utils.Peripherals(peripherals)))
+
+		for _, peripheral := range utils.NewlyLowPeripherals(peripherals) {
+			websocket.WriteChannelMessage(models.NewEvent(utils.PeripheralBatteryLow{Peripheral: peripheral}))
+		}
+	})
+}
@@ -1,23 +1,44 @@
 package poller
 
 import (
+	"Blitz/utils"
 	"fmt"
+	"sync"
 	"time"
 )
 
-// Poller runs fn every interval until quit channel is closed
-func Poller(interval time.Duration, quit <-chan struct{}, fn func()) {
+// stopped is closed by Stop, ending every poller
+var (
+	stopped  = make(chan struct{})
+	stopOnce sync.Once
+)
+
+// Stop ends all pollers, so nothing new is broadcast while Blitz shuts down
+func Stop() {
+	stopOnce.Do(func() { close(stopped) })
+}
+
+// Poller runs fn every interval until quit channel is closed. Runs are
+// skipped while maintenance mode is on, so providers aren't polled while the
+// host is being updated, and while a blackout pauses the poller called name.
+func Poller(name string, interval time.Duration, quit <-chan struct{}, fn func()) {
 	// fmt.Println("Poller started, running every", interval)
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	run := func() {
+		if !utils.InMaintenance() && !utils.InBlackout(name) {
+			fn()
+		}
+	}
+
 	// Run immediately on start
-	fn()
+	run()
 
 	for {
 		select {
 		case <-ticker.C:
-			fn()
+			run()
 		case <-quit:
 			fmt.Println("Poller stopped via quit signal")
 			return
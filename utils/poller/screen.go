@@ -0,0 +1,41 @@
+package poller
+
+import (
+	"Blitz/config"
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"log"
+	"time"
+)
+
+// HandleScreen broadcasts a thumbnail of the desktop every few seconds to
+// the clients that subscribed to the screen topic. Nothing is captured while
+// nobody watches or privacy mode is on.
+func HandleScreen() {
+	settings := config.Get().Screen
+	if !settings.Enabled {
+		return
+	}
+
+	interval := time.Duration(settings.IntervalSec) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	Poller(utils.PollerScreen, interval, stopped, func() {
+		if utils.PrivacyMode() {
+			websocket.ForgetSnapshot("screen")
+			return
+		}
+		if !websocket.Watched("screen") {
+			return
+		}
+		snapshot, err := utils.CaptureScreen()
+		if err != nil {
+			log.Println("❌ Failed to capture the screen:", err)
+			return
+		}
+		websocket.WriteChangedMessage(models.NewEvent(snapshot))
+	})
+}
@@ -0,0 +1,25 @@
+package poller
+
+import (
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleSensors broadcasts the ambient light and the accelerometer of
+// laptops and tablets when they change, and a sensor_event when the device is
+// flipped face down or back up. Polled every second so flipping feels instant.
+func HandleSensors() {
+	if !utils.ModuleAvailable(utils.ModuleSystem) || !utils.HasSensors() {
+		return
+	}
+
+	Poller(utils.PollerSensors, time.Second, stopped, func() {
+		sensors := utils.GetSensors()
+		if event := utils.DetectFlip(sensors); event != nil {
+			websocket.WriteChannelMessage(models.NewEvent(*event))
+		}
+		websocket.WriteChangedMessage(models.NewEvent(sensors))
+	})
+}
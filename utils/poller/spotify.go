@@ -0,0 +1,32 @@
+package poller
+
+import (
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleSpotifyAuth broadcasts where the Spotify login stands whenever it
+// changes, so clients offer to connect Spotify right when the session
+// expires. Every transition is sent as it happens; polling the state, which
+// lives in memory, repeats it for clients that missed one.
+func HandleSpotifyAuth() {
+	go func() {
+		for {
+			select {
+			case state := <-utils.SpotifyAuthChanges():
+				// Paused like the poller, which catches up afterwards
+				if !utils.InMaintenance() && !utils.InBlackout(utils.PollerSpotifyAuth) {
+					websocket.WriteChangedMessage(models.NewEvent(state))
+				}
+			case <-stopped:
+				return
+			}
+		}
+	}()
+
+	Poller(utils.PollerSpotifyAuth, 2*time.Second, stopped, func() {
+		websocket.WriteChangedMessage(models.NewEvent(utils.GetSpotifyAuthState()))
+	})
+}
@@ -0,0 +1,20 @@
+package poller
+
+import (
+	"Blitz/models"
+	"Blitz/utils"
+	"Blitz/utils/websocket"
+	"time"
+)
+
+// HandleZones broadcasts the aggregated state of the configured zones.
+// Zones span remote devices, so they are polled less often than the local player.
+func HandleZones() {
+	if len(utils.ZoneNames()) == 0 {
+		return
+	}
+
+	Poller(utils.PollerZones, 5*time.Second, stopped, func() {
+		websocket.WriteChangedMessage(models.NewEvent(utils.ZoneStates(utils.GetZoneStates())))
+	})
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"sync/atomic"
+)
+
+// privacyMode hides what is playing from broadcasts, e.g. while the screen is
+// shared or guests can see the dashboard. Controls keep working.
+var privacyMode atomic.Bool
+
+// SetPrivacyMode turns privacy mode on or off
+func SetPrivacyMode(enabled bool) {
+	privacyMode.Store(enabled)
+}
+
+// PrivacyMode reports whether privacy mode is on
+func PrivacyMode() bool {
+	return privacyMode.Load()
+}
+
+// Private returns info with everything identifying the track replaced by a
+// placeholder, keeping what controls need: status, position and player
+func (info MediaInfo) Private() MediaInfo {
+	if info.Title == "" && info.TrackID == "" && info.Station == nil {
+		return info
+	}
+	info.Title = i18n.T("Private")
+	info.Artist, info.Album, info.TrackID, info.URL = "", "", "", ""
+	info.Artwork, info.ArtworkURL = "", ""
+	info.Details, info.Context, info.Station = nil, nil, nil
+	return info
+}
+
+// Private returns the track with its title replaced by a placeholder
+func (track TrackSummary) Private() TrackSummary {
+	return TrackSummary{Title: i18n.T("Private"), Player: track.Player}
+}
+
+// Private returns the change with both tracks replaced by placeholders
+func (change TrackChange) Private() TrackChange {
+	if change.Previous != nil {
+		previous := change.Previous.Private()
+		change.Previous = &previous
+	}
+	if change.Current != nil {
+		current := change.Current.Private()
+		change.Current = &current
+	}
+	return change
+}
+
+// Private returns the notification with its text replaced by a placeholder,
+// keeping its priority
+func (notification Notification) Private() Notification {
+	return Notification{Title: i18n.T("Private"), Priority: notification.Priority}
+}
+
+// VisibleHistory returns the history, or nothing while privacy mode is on
+func VisibleHistory() History {
+	if PrivacyMode() {
+		return History{}
+	}
+	return GetHistory()
+}
+
+// PrivacyState is broadcast whenever privacy mode is turned on or off
+type PrivacyState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Topic implements models.Payload
+func (PrivacyState) Topic() string { return "privacy_mode" }
+
+// Version implements models.Payload
+func (PrivacyState) Version() int { return 1 }
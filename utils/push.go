@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Push priorities, from least to most intrusive
+const (
+	PushPriorityLow     = "low"
+	PushPriorityDefault = "default"
+	PushPriorityHigh    = "high"
+)
+
+// maxPushBody bounds the text of a broadcast pushed without a PushNotification
+const maxPushBody = 200
+
+var pushClient = NewHTTPClient(DependencyPush, 10*time.Second)
+
+// Notification is a message for the user, sent with the notify command (e.g.
+// by a doorbell webhook or a script when a long task finished)
+type Notification struct {
+	Title    string `json:"title"`
+	Message  string `json:"message,omitempty"`
+	Priority string `json:"priority"` // "low", "default" or "high"
+}
+
+// Topic implements models.Payload
+func (Notification) Topic() string { return "notification" }
+
+// Version implements models.Payload
+func (Notification) Version() int { return 1 }
+
+// PushNotification implements Pushable
+func (n Notification) PushNotification() Notification { return n }
+
+// Pushable is implemented by payloads that know how to read as a push notification
+type Pushable interface {
+	PushNotification() Notification
+}
+
+// PushNotification implements Pushable
+func (b PeripheralBatteryLow) PushNotification() Notification {
+	name := b.Model
+	if name == "" {
+		name = b.Type
+	}
+	return Notification{
+		Title:    i18n.T("Battery low"),
+		Message:  i18n.T("%s is at %.0f%%", name, b.Percentage),
+		Priority: PushPriorityHigh,
+	}
+}
+
+// PushNotification implements Pushable
+func (a LatencyAlert) PushNotification() Notification {
+	if a.State == "cleared" {
+		return Notification{
+			Title:    i18n.T("Network recovered"),
+			Message:  i18n.T("%s answers again", a.Target),
+			Priority: PushPriorityDefault,
+		}
+	}
+	return Notification{
+		Title:    i18n.T("Network problem"),
+		Message:  i18n.T("%s lost %.0f%% of pings for %d rounds", a.Target, a.Loss, a.Rounds),
+		Priority: PushPriorityHigh,
+	}
+}
+
+// PushEvent forwards a broadcast to the push sinks when its topic is one of
+// push.events, unless quiet hours hold it back. Sending happens in the
+// background; failures are only logged.
+func PushEvent(msg models.ServerResponse) {
+	cfg := config.Get().Push
+	if len(cfg.Sinks) == 0 || !slices.Contains(cfg.Events, msg.Topic) {
+		return
+	}
+	if InQuietHours() && !slices.Contains(config.Get().QuietHours.Allow, msg.Topic) {
+		log.Printf("Quiet hours, not pushing %s", msg.Topic)
+		return
+	}
+
+	var notification Notification
+	if pushable, ok := msg.Data.(Pushable); ok {
+		notification = pushable.PushNotification()
+	} else {
+		body, _ := json.Marshal(msg.Data)
+		notification = Notification{Title: msg.Topic, Message: string(body), Priority: PushPriorityDefault}
+		if len(notification.Message) > maxPushBody {
+			notification.Message = notification.Message[:maxPushBody] + "…"
+		}
+	}
+
+	go func() {
+		if err := SendPush(notification); err != nil {
+			log.Printf("❌ Failed to push %s: %v", msg.Topic, err)
+		}
+	}()
+}
+
+// SendPush delivers a notification to every sink whose minPriority it reaches
+func SendPush(n Notification) error {
+	sinks := config.Get().Push.Sinks
+	if len(sinks) == 0 {
+		return fmt.Errorf("push is not configured")
+	}
+
+	var errs []error
+	for _, sink := range sinks {
+		if pushPriorityRank(n.Priority) < pushPriorityRank(sink.MinPriority) {
+			continue
+		}
+
+		var err error
+		switch sink.Provider {
+		case "ntfy":
+			err = sendNtfy(sink, n)
+		case "gotify":
+			err = sendGotify(sink, n)
+		case "fcm":
+			err = sendFCM(sink, n)
+		default:
+			err = fmt.Errorf("unknown push provider %q", sink.Provider)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Provider, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pushPriorityRank orders the priorities; unknown ones count as low
+func pushPriorityRank(priority string) int {
+	switch priority {
+	case PushPriorityDefault:
+		return 1
+	case PushPriorityHigh:
+		return 2
+	}
+	return 0
+}
+
+// sendNtfy publishes to an ntfy topic, which the ntfy app on the phone
+// subscribes to. The JSON form is used because headers can't carry UTF-8.
+func sendNtfy(sink config.PushSink, n Notification) error {
+	if sink.Topic == "" {
+		return fmt.Errorf("topic is not set")
+	}
+	server := sink.Server
+	if server == "" {
+		server = "https://ntfy.sh"
+	}
+
+	priority := 3
+	switch n.Priority {
+	case PushPriorityLow:
+		priority = 2
+	case PushPriorityHigh:
+		priority = 4
+	}
+	body, err := json.Marshal(map[string]any{
+		"topic":    sink.Topic,
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": priority,
+		"tags":     []string{"zap"},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(server, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv("BLITZ_NTFY_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := DoWithRetry(pushClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ntfy answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sendGotify posts a message to a Gotify server as the application whose
+// token is in $BLITZ_GOTIFY_TOKEN
+func sendGotify(sink config.PushSink, n Notification) error {
+	if sink.Server == "" {
+		return fmt.Errorf("server is not set")
+	}
+	token := os.Getenv("BLITZ_GOTIFY_TOKEN")
+	if token == "" {
+		return fmt.Errorf("BLITZ_GOTIFY_TOKEN is not set")
+	}
+
+	// Gotify priorities go from 0 to 10; Android shows 8 and above as a heads-up
+	priority := 5
+	switch n.Priority {
+	case PushPriorityLow:
+		priority = 2
+	case PushPriorityHigh:
+		priority = 8
+	}
+	body, err := json.Marshal(map[string]any{
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": priority,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(sink.Server, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", token)
+
+	resp, err := DoWithRetry(pushClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("gotify answered %s: %s", resp.Status, strings.TrimSpace(string(answer)))
+	}
+	return nil
+}
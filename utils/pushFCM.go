@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"Blitz/config"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// fcmServiceAccount is the part of a Google service account key file we need
+type fcmServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+var (
+	fcmAccessToken string
+	fcmTokenExpiry time.Time
+	fcmTokenMu     sync.Mutex
+)
+
+// sendFCM sends a notification through the Firebase Cloud Messaging HTTP v1 API
+func sendFCM(sink config.PushSink, n Notification) error {
+	if sink.Topic == "" && sink.Token == "" {
+		return fmt.Errorf("topic or token must be set")
+	}
+
+	account, err := loadFCMServiceAccount()
+	if err != nil {
+		return err
+	}
+	accessToken, err := fcmToken(account)
+	if err != nil {
+		return err
+	}
+
+	androidPriority, apnsPriority := "normal", "5"
+	if n.Priority == PushPriorityHigh {
+		androidPriority, apnsPriority = "high", "10"
+	}
+	message := map[string]any{
+		"notification": map[string]string{"title": n.Title, "body": n.Message},
+		"android":      map[string]string{"priority": androidPriority},
+		"apns":         map[string]any{"headers": map[string]string{"apns-priority": apnsPriority}},
+	}
+	if sink.Token != "" {
+		message["token"] = sink.Token
+	} else {
+		message["topic"] = sink.Topic
+	}
+	body, err := json.Marshal(map[string]any{"message": message})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("https://fcm.googleapis.com/v1/projects/%s/messages:send", account.ProjectID)
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := DoWithRetry(pushClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("FCM answered %s: %s", resp.Status, strings.TrimSpace(string(answer)))
+	}
+	return nil
+}
+
+// loadFCMServiceAccount reads the key file $GOOGLE_APPLICATION_CREDENTIALS points to
+func loadFCMServiceAccount() (*fcmServiceAccount, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		return nil, fmt.Errorf("GOOGLE_APPLICATION_CREDENTIALS is not set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var account fcmServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid service account file: %w", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("service account file lacks project_id, client_email or private_key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &account, nil
+}
+
+// fcmToken returns an OAuth access token for the service account, exchanging
+// a signed JWT for a new one shortly before the cached token expires
+func fcmToken(account *fcmServiceAccount) (string, error) {
+	fcmTokenMu.Lock()
+	defer fcmTokenMu.Unlock()
+
+	if fcmAccessToken != "" && time.Until(fcmTokenExpiry) > time.Minute {
+		return fcmAccessToken, nil
+	}
+
+	assertion, err := fcmAssertion(account)
+	if err != nil {
+		return "", err
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	data.Set("assertion", assertion)
+
+	req, err := http.NewRequest(http.MethodPost, account.TokenURI, strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := DoWithRetry(pushClient, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("token exchange answered %s: %s", resp.Status, strings.TrimSpace(string(answer)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	fcmAccessToken = token.AccessToken
+	fcmTokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return fcmAccessToken, nil
+}
+
+// fcmAssertion builds the RS256-signed JWT a service account authenticates with
+func fcmAssertion(account *fcmServiceAccount) (string, error) {
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("service account private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("invalid service account private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("service account private key is not an RSA key")
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
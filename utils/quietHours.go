@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// icalWeekdays maps the weekday codes of iCal's BYDAY to weekdays
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// quietWindow is a parsed config.QuietWindow
+type quietWindow struct {
+	days                map[time.Weekday]bool // nil for every day
+	startHour, startMin int
+	endHour, endMin     int
+}
+
+// QuietHoursState reports the quiet hours in the quiet_hours command
+type QuietHoursState struct {
+	Active bool  `json:"active"`
+	Until  int64 `json:"until,omitempty"` // Unix seconds when the current quiet hours end
+}
+
+var (
+	quietWindows     []quietWindow
+	quietWindowsOnce sync.Once
+)
+
+// parsedQuietWindows parses the configured windows once, skipping invalid ones
+func parsedQuietWindows() []quietWindow {
+	quietWindowsOnce.Do(func() {
+		for _, cfg := range config.Get().QuietHours.Windows {
+			window, err := parseQuietWindow(cfg)
+			if err != nil {
+				log.Println("❌ Ignoring quiet hours window:", err)
+				continue
+			}
+			quietWindows = append(quietWindows, window)
+		}
+	})
+	return quietWindows
+}
+
+func parseQuietWindow(cfg config.QuietWindow) (quietWindow, error) {
+	var window quietWindow
+	if _, err := fmt.Sscanf(cfg.Start, "%d:%d", &window.startHour, &window.startMin); err != nil {
+		return window, fmt.Errorf("invalid start %q", cfg.Start)
+	}
+	if _, err := fmt.Sscanf(cfg.End, "%d:%d", &window.endHour, &window.endMin); err != nil {
+		return window, fmt.Errorf("invalid end %q", cfg.End)
+	}
+	if window.startHour > 23 || window.endHour > 23 || window.startMin > 59 || window.endMin > 59 {
+		return window, fmt.Errorf("%s-%s is not a time of day", cfg.Start, cfg.End)
+	}
+
+	days, err := parseWeekdays(cfg.Days)
+	if err != nil {
+		return window, err
+	}
+	window.days = days
+	return window, nil
+}
+
+// parseWeekdays parses iCal weekday codes into a set, nil when there are none
+func parseWeekdays(codes []string) (map[time.Weekday]bool, error) {
+	var days map[time.Weekday]bool
+	for _, day := range codes {
+		weekday, ok := icalWeekdays[strings.ToUpper(day)]
+		if !ok {
+			return nil, errors.New(i18n.T("unknown weekday %q, use MO, TU, WE, TH, FR, SA or SU", day))
+		}
+		if days == nil {
+			days = map[time.Weekday]bool{}
+		}
+		days[weekday] = true
+	}
+	return days, nil
+}
+
+// until returns when the window ends, if now falls in it
+func (window quietWindow) until(now time.Time) (time.Time, bool) {
+	// A window past midnight may have started yesterday
+	for daysAgo := 0; daysAgo <= 1; daysAgo++ {
+		day := now.AddDate(0, 0, -daysAgo)
+		if window.days != nil && !window.days[day.Weekday()] {
+			continue
+		}
+
+		year, month, date := day.Date()
+		from := time.Date(year, month, date, window.startHour, window.startMin, 0, 0, now.Location())
+		to := time.Date(year, month, date, window.endHour, window.endMin, 0, 0, now.Location())
+		if !to.After(from) {
+			to = to.AddDate(0, 0, 1)
+		}
+		if !now.Before(from) && now.Before(to) {
+			return to, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// quietUntil returns when the quiet hours now falls in end
+func quietUntil(now time.Time) (time.Time, bool) {
+	for _, window := range parsedQuietWindows() {
+		if to, ok := window.until(now); ok {
+			return to, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// InQuietHours reports whether quiet hours are on right now
+func InQuietHours() bool {
+	_, quiet := quietUntil(time.Now())
+	return quiet
+}
+
+// GetQuietHours reports whether quiet hours are on and until when
+func GetQuietHours() QuietHoursState {
+	until, quiet := quietUntil(time.Now())
+	if !quiet {
+		return QuietHoursState{}
+	}
+	return QuietHoursState{Active: true, Until: until.Unix()}
+}
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RadioStation is an internet radio station, from the presets or the
+// RadioBrowser directory
+type RadioStation struct {
+	ID       string   `json:"id,omitempty"` // RadioBrowser station UUID; empty for presets
+	Name     string   `json:"name"`
+	URL      string   `json:"url"` // Stream URL
+	Favicon  string   `json:"favicon,omitempty"`
+	Homepage string   `json:"homepage,omitempty"`
+	Country  string   `json:"country,omitempty"` // ISO 3166-1 country code
+	Tags     []string `json:"tags,omitempty"`
+	Codec    string   `json:"codec,omitempty"`
+	Bitrate  int      `json:"bitrate,omitempty"` // kbit/s
+}
+
+// radioBrowserStation is a station as the RadioBrowser API returns it
+type radioBrowserStation struct {
+	StationUUID string `json:"stationuuid"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	URLResolved string `json:"url_resolved"` // The stream behind playlist URLs
+	Favicon     string `json:"favicon"`
+	Homepage    string `json:"homepage"`
+	CountryCode string `json:"countrycode"`
+	Tags        string `json:"tags"` // Comma-separated
+	Codec       string `json:"codec"`
+	Bitrate     int    `json:"bitrate"`
+}
+
+func (s radioBrowserStation) station() RadioStation {
+	station := RadioStation{
+		ID:       s.StationUUID,
+		Name:     strings.TrimSpace(s.Name),
+		URL:      s.URLResolved,
+		Favicon:  s.Favicon,
+		Homepage: s.Homepage,
+		Country:  s.CountryCode,
+		Codec:    s.Codec,
+		Bitrate:  s.Bitrate,
+	}
+	if station.URL == "" {
+		station.URL = s.URL
+	}
+	for _, tag := range strings.Split(s.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			station.Tags = append(station.Tags, tag)
+		}
+	}
+	return station
+}
+
+var radioClient = NewHTTPClient(DependencyRadio, 10*time.Second)
+
+// tunedStation is what play_radio played last, and on which player
+type tunedStation struct {
+	station RadioStation
+	player  string
+}
+
+var (
+	tuned   *tunedStation
+	tunedMu sync.Mutex
+)
+
+// GetRadioPresets returns the stations from the config
+func GetRadioPresets() []RadioStation {
+	presets := config.Get().Radio.Presets
+	stations := make([]RadioStation, 0, len(presets))
+	for _, preset := range presets {
+		stations = append(stations, RadioStation{Name: preset.Name, URL: preset.URL, Favicon: preset.Favicon})
+	}
+	return stations
+}
+
+// SearchRadio searches the RadioBrowser directory by name, tag and country
+// code, most popular stations first. Empty filters match every station.
+func SearchRadio(query, tag, country string, limit int) ([]RadioStation, error) {
+	params := url.Values{}
+	params.Set("name", query)
+	params.Set("tag", tag)
+	params.Set("countrycode", strings.ToUpper(country))
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("hidebroken", "true")
+	params.Set("order", "clickcount")
+	params.Set("reverse", "true")
+
+	var results []radioBrowserStation
+	if err := radioBrowser("/json/stations/search?"+params.Encode(), &results); err != nil {
+		return nil, err
+	}
+
+	stations := make([]RadioStation, 0, len(results))
+	for _, result := range results {
+		stations = append(stations, result.station())
+	}
+	return stations, nil
+}
+
+// radioBrowserStationByID looks up a directory station
+func radioBrowserStationByID(id string) (RadioStation, error) {
+	var results []radioBrowserStation
+	if err := radioBrowser("/json/stations/byuuid/"+url.PathEscape(id), &results); err != nil {
+		return RadioStation{}, err
+	}
+	if len(results) == 0 {
+		return RadioStation{}, models.NewError(models.ErrInvalidParams, i18n.T("no radio station with id %s", id), nil)
+	}
+	return results[0].station(), nil
+}
+
+// radioBrowser fetches a RadioBrowser API endpoint into result
+func radioBrowser(endpoint string, result any) error {
+	req, err := http.NewRequest("GET", strings.TrimRight(config.Get().Radio.Directory, "/")+endpoint, nil)
+	if err != nil {
+		return models.NewError(models.ErrCommandFailed, i18n.T("the radio directory is not reachable"), err)
+	}
+	// RadioBrowser asks clients to identify themselves
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := radioClient.Do(req)
+	if err != nil {
+		return models.NewError(models.ErrCommandFailed, i18n.T("the radio directory is not reachable"), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.NewError(models.ErrCommandFailed, i18n.T("the radio directory is not reachable"), fmt.Errorf("radio-browser: HTTP %d", resp.StatusCode))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// PlayRadio plays a preset (by name), a directory station (by id) or a
+// stream URL. Without a player it plays on the receiver; otherwise the
+// stream is handed to that MPRIS player.
+func PlayRadio(preset, id, streamURL, player string) (*RadioStation, error) {
+	var station RadioStation
+	switch {
+	case preset != "" && id == "" && streamURL == "":
+		found := false
+		for _, candidate := range GetRadioPresets() {
+			if strings.EqualFold(candidate.Name, preset) {
+				station, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return nil, models.NewError(models.ErrInvalidParams, i18n.T("no radio preset named %s", preset), nil)
+		}
+	case id != "" && preset == "" && streamURL == "":
+		var err error
+		if station, err = radioBrowserStationByID(id); err != nil {
+			return nil, err
+		}
+	case streamURL != "" && preset == "" && id == "":
+		station = RadioStation{Name: streamURL, URL: streamURL}
+	default:
+		return nil, models.NewError(models.ErrInvalidParams, i18n.T("play_radio needs one of preset, station or url"), nil)
+	}
+
+	if err := checkStreamURL(station.URL); err != nil {
+		return nil, err
+	}
+	if player == "" {
+		if err := ModuleError(ModuleReceiver); err != nil {
+			return nil, err
+		}
+		if _, err := PlayURL(station.URL); err != nil {
+			return nil, err
+		}
+		player = ReceiverPlayer
+	} else if _, err := SpawnProcess("playerctl", []string{"-p", player, "open", station.URL}); err != nil {
+		return nil, err
+	}
+
+	tunedMu.Lock()
+	tuned = &tunedStation{station: station, player: player}
+	tunedMu.Unlock()
+
+	if station.ID != "" {
+		// Clicks rank the directory's search results
+		go func() {
+			var click struct{}
+			if err := radioBrowser("/json/url/"+url.PathEscape(station.ID), &click); err != nil {
+				log.Println("Failed to count radio station click:", err)
+			}
+		}()
+	}
+	return &station, nil
+}
+
+// withRadioStation adds the tuned station to what its player reports. Stations
+// send the current song as an ICY title, "Artist - Title" by convention, and
+// nothing else.
+func withRadioStation(info MediaInfo) MediaInfo {
+	tunedMu.Lock()
+	current := tuned
+	tunedMu.Unlock()
+
+	if current == nil || !strings.HasPrefix(info.Player, current.player) ||
+		(info.URL != "" && info.URL != current.station.URL) {
+		return info
+	}
+
+	station := current.station
+	info.Station = &station
+	if info.Artist == "" {
+		if artist, title, ok := strings.Cut(info.Title, " - "); ok {
+			info.Artist, info.Title = strings.TrimSpace(artist), strings.TrimSpace(title)
+		}
+	}
+	// Players fall back to the URL or its file name before the first ICY title
+	if info.Title == "" || info.Title == station.URL || info.Title == path.Base(station.URL) {
+		info.Title = station.Name
+	}
+	if info.Album == "" {
+		info.Album = station.Name
+	}
+	if info.Artwork == "" {
+		info.Artwork = station.Favicon
+	}
+	return info
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ReceiverPlayer is the MPRIS name the receiver shows up under, so the usual
+// player commands control it
+const ReceiverPlayer = "mpv"
+
+// mpvMPRISScripts are where distributions install the mpv-mpris plugin.
+// /etc/mpv/scripts is loaded by mpv itself.
+var mpvMPRISScripts = []string{
+	"/usr/lib/mpv-mpris/mpris.so",
+	"/usr/lib/mpv/mpris.so",
+	"/usr/lib64/mpv/mpris.so",
+	"/usr/share/mpv/scripts/mpris.so",
+}
+
+var (
+	receiver   *exec.Cmd
+	receiverMu sync.Mutex
+)
+
+// receiverSocket is the mpv IPC socket of the receiver
+func receiverSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "blitz-receiver.sock")
+}
+
+// PlayURL plays an internet radio stream or podcast episode on the host
+// through the receiver, starting it on first use
+func PlayURL(rawURL string) (map[string]string, error) {
+	if err := checkStreamURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	if err := startReceiver(); err != nil {
+		return nil, err
+	}
+	if err := mpvCommand("loadfile", rawURL, "replace"); err != nil {
+		return nil, err
+	}
+	return map[string]string{"player": ReceiverPlayer, "url": rawURL}, nil
+}
+
+// checkStreamURL only lets through http and https URLs, so clients can't
+// have local files played
+func checkStreamURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return models.NewError(models.ErrInvalidParams, i18n.T("url must be an http or https URL"), err)
+	}
+	return nil
+}
+
+// startReceiver starts an idle, windowless mpv unless it is already running
+// and waits for its IPC socket
+func startReceiver() error {
+	receiverMu.Lock()
+	defer receiverMu.Unlock()
+
+	if receiver != nil {
+		return nil
+	}
+
+	socket := receiverSocket()
+	os.Remove(socket)
+	args := []string{"--idle=yes", "--no-video", "--no-terminal", "--input-ipc-server=" + socket}
+	if _, err := os.Stat("/etc/mpv/scripts/mpris.so"); err != nil {
+		script := ""
+		for _, candidate := range mpvMPRISScripts {
+			if _, err := os.Stat(candidate); err == nil {
+				script = candidate
+				break
+			}
+		}
+		if script != "" {
+			args = append(args, "--script="+script)
+		} else {
+			log.Println("⚠️ mpv-mpris is not installed, the receiver won't show up as a media player")
+		}
+	}
+
+	cmd := exec.Command("mpv", args...)
+	// Don't leave the player running when Blitz exits
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+	if err := cmd.Start(); err != nil {
+		return processError("mpv", err)
+	}
+	receiver = cmd
+	log.Println("📻 Receiver started")
+
+	go func() {
+		err := cmd.Wait()
+		log.Println("📻 Receiver stopped:", err)
+		receiverMu.Lock()
+		receiver = nil
+		receiverMu.Unlock()
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil
+		}
+	}
+	cmd.Process.Kill()
+	return models.NewError(models.ErrTimeout, i18n.T("%s did not respond", "mpv"), nil)
+}
+
+// mpvCommand runs a command over the receiver's JSON IPC and waits for its reply
+func mpvCommand(args ...any) error {
+	conn, err := net.DialTimeout("unix", receiverSocket(), time.Second)
+	if err != nil {
+		return models.NewError(models.ErrCommandFailed, i18n.T("%s could not be started", "mpv"), err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(processTimeout))
+
+	request, _ := json.Marshal(map[string]any{"command": args, "request_id": 1})
+	if _, err := conn.Write(append(request, '\n')); err != nil {
+		return models.NewError(models.ErrCommandFailed, i18n.T("%s failed", "mpv"), err)
+	}
+
+	// Events are interleaved with the reply
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var reply struct {
+			RequestID *int   `json:"request_id"`
+			Error     string `json:"error"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &reply) != nil || reply.RequestID == nil {
+			continue
+		}
+		if reply.Error != "success" {
+			return models.NewError(models.ErrCommandFailed, i18n.T("%s failed", "mpv"), fmt.Errorf("mpv: %s", reply.Error))
+		}
+		return nil
+	}
+	return models.NewError(models.ErrCommandFailed, i18n.T("%s failed", "mpv"), scanner.Err())
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Backoff says how often and how patiently a call is retried
+type Backoff struct {
+	Attempts int           // Tries in total, including the first
+	Base     time.Duration // Wait before the first retry, doubled for each further one
+	Max      time.Duration // Longest wait between two tries
+}
+
+// DefaultBackoff rides out network blips without holding a command past
+// its timeout
+var DefaultBackoff = Backoff{Attempts: 3, Base: 200 * time.Millisecond, Max: time.Second}
+
+// permanentError marks an error that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error to stop Retry from trying again
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Retry calls fn until it succeeds, returns an error wrapped with Permanent,
+// has used up the attempts or ctx is done, and returns fn's last error.
+// The waits are jittered, so clients that failed together don't come back
+// in lockstep.
+func Retry(ctx context.Context, backoff Backoff, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if err == nil || attempt >= backoff.Attempts {
+			return err
+		}
+
+		timer := time.NewTimer(backoff.wait(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// wait returns the time before a retry: between half and all of the
+// doubled base, capped at Max
+func (b Backoff) wait(retry int) time.Duration {
+	limit := min(b.Base<<(retry-1), b.Max)
+	if limit <= 1 {
+		return limit
+	}
+	return limit/2 + rand.N(limit/2)
+}
+
+// errRetryableStatus is returned by a try that got a 502, 503 or 504
+var errRetryableStatus = errors.New("server is temporarily unavailable")
+
+// DoWithRetry sends an outbound request, retrying it with DefaultBackoff
+// while it fails transiently: the connection couldn't be made, or the
+// server answered 503. Requests that are safe to repeat (GET, HEAD, PUT,
+// DELETE) are also retried when the connection broke and on 502 and 504.
+// Timeouts aren't retried, as each took the client's whole timeout. The
+// last answer is returned whatever its status, for the caller to handle.
+func DoWithRetry(client *http.Client, req *http.Request) (*http.Response, error) {
+	// Bodies from bytes and strings readers can be read again
+	if req.Body != nil && req.GetBody == nil {
+		return client.Do(req)
+	}
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead ||
+		req.Method == http.MethodPut || req.Method == http.MethodDelete
+
+	var resp *http.Response
+	attempt := 0
+	err := Retry(req.Context(), DefaultBackoff, func() error {
+		try := req
+		if attempt > 0 {
+			if resp != nil {
+				io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+				resp.Body.Close()
+				resp = nil
+			}
+			try = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return Permanent(err)
+				}
+				try.Body = body
+			}
+			log.Printf("🔁 Retrying %s %s (attempt %d)", req.Method, req.URL.Host, attempt+1)
+		}
+		attempt++
+
+		answer, err := client.Do(try)
+		if err != nil {
+			if notConnected(err) || idempotent && connectionBroke(err) {
+				return err
+			}
+			return Permanent(err)
+		}
+		resp = answer
+		switch answer.StatusCode {
+		case http.StatusServiceUnavailable:
+			return errRetryableStatus
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			if idempotent {
+				return errRetryableStatus
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errRetryableStatus) {
+		return resp, nil
+	}
+	return resp, err
+}
+
+// notConnected reports whether a request failed before it reached the
+// server, so sending it again can't do anything twice
+func notConnected(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" && !opErr.Timeout()
+}
+
+// connectionBroke reports whether the connection dropped mid-request
+func connectionBroke(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ScreenSnapshot is a downscaled capture of the desktop, for remote clients
+// to show what is on the screen before they take control
+type ScreenSnapshot struct {
+	Image  string `json:"image"` // data:image/jpeg;base64,...
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Topic implements models.Payload
+func (ScreenSnapshot) Topic() string { return "screen" }
+
+// Version implements models.Payload
+func (ScreenSnapshot) Version() int { return 1 }
+
+// screenshotCommand returns the command that saves the whole desktop to
+// path as a PNG in the running session
+func screenshotCommand(path string) (string, []string, error) {
+	desktop := os.Getenv("XDG_CURRENT_DESKTOP")
+	wayland := os.Getenv("WAYLAND_DISPLAY") != ""
+	switch {
+	case os.Getenv("SWAYSOCK") != "" || os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+		return "grim", []string{"-t", "png", path}, nil
+	case strings.Contains(desktop, "KDE") && wayland:
+		return "spectacle", []string{"--background", "--nonotify", "--fullscreen", "--output", path}, nil
+	case strings.Contains(desktop, "GNOME") && wayland:
+		return "gnome-screenshot", []string{"--file", path}, nil
+	case os.Getenv("DISPLAY") != "":
+		return "import", []string{"-window", "root", "png:" + path}, nil
+	}
+	return "", nil, models.NewError(models.ErrExternalToolMissing, i18n.T("no supported compositor or X server to capture the screen"), nil)
+}
+
+// CaptureScreen takes a screenshot and shrinks it to the configured width as
+// a JPEG, when screen.enabled allows it. Privacy mode hides the screen like
+// it hides the track.
+func CaptureScreen() (ScreenSnapshot, error) {
+	settings := config.Get().Screen
+	if !settings.Enabled {
+		return ScreenSnapshot{}, models.NewError(models.ErrModuleDisabled, i18n.T("screen previews are disabled"), nil)
+	}
+	if PrivacyMode() {
+		return ScreenSnapshot{}, models.NewError(models.ErrForbidden, i18n.T("the screen is hidden in privacy mode"), nil)
+	}
+
+	dir, err := os.MkdirTemp("", "blitz-screen")
+	if err != nil {
+		return ScreenSnapshot{}, models.NewError(models.ErrCommandFailed, i18n.T("failed to capture the screen"), err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "screen.png")
+
+	command, args, err := screenshotCommand(path)
+	if err != nil {
+		return ScreenSnapshot{}, err
+	}
+	if _, err := SpawnProcess(command, args); err != nil {
+		return ScreenSnapshot{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ScreenSnapshot{}, models.NewError(models.ErrCommandFailed, i18n.T("%s failed", command), err)
+	}
+	defer file.Close()
+	screen, _, err := image.Decode(file)
+	if err != nil {
+		return ScreenSnapshot{}, models.NewError(models.ErrCommandFailed, i18n.T("%s failed", command), err)
+	}
+
+	thumbnail := downscale(screen, settings.Width)
+	quality := settings.Quality
+	if quality < 1 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, thumbnail, &jpeg.Options{Quality: quality}); err != nil {
+		return ScreenSnapshot{}, models.NewError(models.ErrCommandFailed, i18n.T("failed to capture the screen"), err)
+	}
+
+	bounds := thumbnail.Bounds()
+	return ScreenSnapshot{
+		Image:  "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buffer.Bytes()),
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+	}, nil
+}
+
+// downscale shrinks src to width, keeping its aspect ratio. Each pixel is the
+// average of a few samples from its area, which is enough for a thumbnail
+// and keeps a 4K screen cheap to shrink every few seconds.
+func downscale(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	if width <= 0 || width >= bounds.Dx() {
+		return src
+	}
+	height := max(bounds.Dy()*width/bounds.Dx(), 1)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	const samples = 4 // Per axis
+	for y := range height {
+		for x := range width {
+			var r, g, b, n uint32
+			for sy := range samples {
+				srcY := bounds.Min.Y + ((y*samples+sy)*bounds.Dy())/(height*samples)
+				for sx := range samples {
+					srcX := bounds.Min.X + ((x*samples+sx)*bounds.Dx())/(width*samples)
+					pr, pg, pb, _ := src.At(srcX, srcY).RGBA()
+					r, g, b, n = r+pr, g+pg, b+pb, n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: 0xffff})
+		}
+	}
+	return dst
+}
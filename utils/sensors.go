@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// iioDevices is where the kernel lists the Industrial I/O sensors
+var iioDevices = "/sys/bus/iio/devices"
+
+// standardGravity in m/s², what an accelerometer at rest reads
+const standardGravity = 9.80665
+
+// Orientations of the device, named after the side facing up
+// (face_up, face_down) or the edge pointing down (top_down is upside down)
+const (
+	OrientationFaceUp    = "face_up"
+	OrientationFaceDown  = "face_down"
+	OrientationUpright   = "upright"
+	OrientationTopDown   = "top_down"
+	OrientationLeftDown  = "left_down"
+	OrientationRightDown = "right_down"
+)
+
+// Acceleration is an accelerometer reading in m/s², in the device's frame:
+// x to the right, y to the top and z out of the screen
+type Acceleration struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Sensors are the readings of the ambient light sensor and the
+// accelerometer of laptops and tablets, as broadcast
+type Sensors struct {
+	Lux          *float64      `json:"lux,omitempty"`          // Ambient light; nil without a light sensor
+	Acceleration *Acceleration `json:"acceleration,omitempty"` // nil without an accelerometer
+	Orientation  string        `json:"orientation,omitempty"`
+}
+
+// Topic implements models.Payload
+func (Sensors) Topic() string { return "sensors" }
+
+// Version implements models.Payload
+func (Sensors) Version() int { return 1 }
+
+// SensorEvent reports the device being turned face down or back up, e.g.
+// for clients that mute when it is flipped over
+type SensorEvent struct {
+	Type string `json:"type"` // "flipped_down" or "flipped_up"
+}
+
+// Topic implements models.Payload
+func (SensorEvent) Topic() string { return "sensor_event" }
+
+// Version implements models.Payload
+func (SensorEvent) Version() int { return 1 }
+
+// iioSensors are the sensor directories found at the first look
+type iioSensors struct {
+	light string
+	accel string
+}
+
+var (
+	lastOrientation   string
+	lastOrientationMu sync.Mutex
+)
+
+// findSensors looks for a light sensor and an accelerometer once; built-in
+// sensors don't come and go
+var findSensors = sync.OnceValue(func() iioSensors {
+	var found iioSensors
+	devices, _ := filepath.Glob(filepath.Join(iioDevices, "iio:device*"))
+	for _, device := range devices {
+		if found.light == "" && (fileExists(device, "in_illuminance_input") || fileExists(device, "in_illuminance_raw") ||
+			fileExists(device, "in_illuminance0_input") || fileExists(device, "in_illuminance0_raw")) {
+			found.light = device
+		}
+		if found.accel == "" && fileExists(device, "in_accel_x_raw") {
+			found.accel = device
+		}
+	}
+	return found
+})
+
+func fileExists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+// HasSensors reports whether the host has a light sensor or an accelerometer
+func HasSensors() bool {
+	found := findSensors()
+	return found.light != "" || found.accel != ""
+}
+
+// GetSensors reads the ambient light and the accelerometer
+func GetSensors() Sensors {
+	found := findSensors()
+	sensors := Sensors{}
+	if found.light != "" {
+		if lux, ok := readIlluminance(found.light); ok {
+			sensors.Lux = &lux
+		}
+	}
+	if found.accel != "" {
+		if accel, ok := readAcceleration(found.accel); ok {
+			sensors.Acceleration = &accel
+			sensors.Orientation = orientation(accel)
+		}
+	}
+	return sensors
+}
+
+// readIlluminance returns lux, from the processed value where the driver
+// offers one, else as (raw + offset) * scale
+func readIlluminance(device string) (float64, bool) {
+	for _, channel := range []string{"in_illuminance", "in_illuminance0"} {
+		if lux, err := readSysfsFloat(device, channel+"_input"); err == nil {
+			return roundLux(lux), true
+		}
+		raw, err := readSysfsFloat(device, channel+"_raw")
+		if err != nil {
+			continue
+		}
+		offset, _ := readSysfsFloat(device, channel+"_offset")
+		scale, err := readSysfsFloat(device, channel+"_scale")
+		if err != nil {
+			scale = 1
+		}
+		return roundLux((raw + offset) * scale), true
+	}
+	return 0, false
+}
+
+// readAcceleration returns the acceleration in m/s², turned into the
+// device's frame by the mount matrix for sensors that are mounted rotated
+func readAcceleration(device string) (Acceleration, bool) {
+	var values [3]float64
+	for i, axis := range []string{"x", "y", "z"} {
+		raw, err := readSysfsFloat(device, "in_accel_"+axis+"_raw")
+		if err != nil {
+			return Acceleration{}, false
+		}
+		scale, err := readSysfsFloat(device, "in_accel_"+axis+"_scale")
+		if err != nil {
+			if scale, err = readSysfsFloat(device, "in_accel_scale"); err != nil {
+				scale = 1
+			}
+		}
+		values[i] = raw * scale
+	}
+
+	if matrix, ok := readMountMatrix(device); ok {
+		var rotated [3]float64
+		for row := range 3 {
+			for col := range 3 {
+				rotated[row] += matrix[row][col] * values[col]
+			}
+		}
+		values = rotated
+	}
+	return Acceleration{X: roundReading(values[0]), Y: roundReading(values[1]), Z: roundReading(values[2])}, true
+}
+
+// readMountMatrix reads how the sensor is mounted, e.g. "0, 1, 0; -1, 0, 0; 0, 0, 1"
+func readMountMatrix(device string) ([3][3]float64, bool) {
+	var matrix [3][3]float64
+	text := readSysfs(filepath.Join(device, "in_accel_mount_matrix"))
+	if text == "" {
+		text = readSysfs(filepath.Join(device, "mount_matrix"))
+	}
+	rows := strings.Split(text, ";")
+	if len(rows) != 3 {
+		return matrix, false
+	}
+	for i, row := range rows {
+		cells := strings.Split(row, ",")
+		if len(cells) != 3 {
+			return matrix, false
+		}
+		for j, cell := range cells {
+			value, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+			if err != nil {
+				return matrix, false
+			}
+			matrix[i][j] = value
+		}
+	}
+	return matrix, true
+}
+
+func readSysfsFloat(device, name string) (float64, error) {
+	return strconv.ParseFloat(readSysfs(filepath.Join(device, name)), 64)
+}
+
+// roundReading keeps one decimal, so sensor noise doesn't make every
+// reading a change
+func roundReading(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+// roundLux keeps two significant digits or so, as more would be noise and
+// make every reading a change
+func roundLux(lux float64) float64 {
+	switch {
+	case lux < 10:
+		return roundReading(lux)
+	case lux < 100:
+		return math.Round(lux)
+	default:
+		return math.Round(lux/10) * 10
+	}
+}
+
+// orientation names the side facing up from where gravity pulls. An axis
+// pointing up reads +1 g, so a device lying on its back reads z = +g.
+func orientation(accel Acceleration) string {
+	x, y, z := accel.X, accel.Y, accel.Z
+	switch {
+	case math.Abs(z) > 0.8*standardGravity && z > 0:
+		return OrientationFaceUp
+	case math.Abs(z) > 0.8*standardGravity:
+		return OrientationFaceDown
+	case math.Abs(y) >= math.Abs(x) && y > 0:
+		return OrientationUpright
+	case math.Abs(y) >= math.Abs(x):
+		return OrientationTopDown
+	case x > 0:
+		return OrientationLeftDown
+	default:
+		return OrientationRightDown
+	}
+}
+
+// DetectFlip returns the event for a device turned face down or back up
+// since the last reading, or nil
+func DetectFlip(sensors Sensors) *SensorEvent {
+	lastOrientationMu.Lock()
+	defer lastOrientationMu.Unlock()
+
+	previous := lastOrientation
+	if sensors.Orientation == "" {
+		return nil
+	}
+	lastOrientation = sensors.Orientation
+
+	switch {
+	case previous == "":
+		return nil
+	case sensors.Orientation == OrientationFaceDown && previous != OrientationFaceDown:
+		return &SensorEvent{Type: "flipped_down"}
+	case previous == OrientationFaceDown && sensors.Orientation != OrientationFaceDown:
+		return &SensorEvent{Type: "flipped_up"}
+	}
+	return nil
+}
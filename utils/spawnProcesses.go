@@ -1,16 +1,64 @@
 package utils
 
 import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
+	"errors"
+	"log"
 	"os/exec"
+	"strings"
+	"time"
 )
 
+// processTimeout kills external tools that hang (e.g. on a stuck D-Bus call).
+// SpawnProcessContext kills them sooner when its ctx ends first.
+const processTimeout = 15 * time.Second
+
 func SpawnProcess(command string, args []string) ([]byte, error) {
-	cmd := exec.Command(command, args...)
+	return SpawnProcessContext(context.Background(), command, args)
+}
+
+// SpawnProcessContext runs command like SpawnProcess, killing it once ctx is
+// done, so a command that timed out or whose client left doesn't leave its
+// tools running
+func SpawnProcessContext(ctx context.Context, command string, args []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, processTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command, args...)
 
 	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, models.NewError(models.ErrTimeout, i18n.T("%s did not respond", command), ctx.Err())
+	}
 	if err != nil {
-		return nil, err
+		// Some tools (ping, ...) print useful output even when they exit non-zero
+		return output, processError(command, err)
 	}
 
 	return output, nil
 }
+
+// processError turns a failed subprocess into a models.Error. Stderr is only
+// logged, so shell details don't reach clients.
+func processError(command string, err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return models.NewError(models.ErrExternalToolMissing, i18n.T("%s is not installed", command), err)
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return models.NewError(models.ErrCommandFailed, i18n.T("%s could not be started", command), err)
+	}
+
+	stderr := strings.TrimSpace(string(exitErr.Stderr))
+	if command == "playerctl" && strings.Contains(stderr, "No player") {
+		return models.NewError(models.ErrPlayerNotFound, i18n.T("no media player is running"), err)
+	}
+
+	if stderr != "" {
+		log.Printf("%s failed: %s", command, stderr)
+	}
+	return models.NewError(models.ErrCommandFailed, i18n.T("%s failed", command), err)
+}
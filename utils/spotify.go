@@ -1,11 +1,16 @@
 package utils
 
 import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -20,16 +25,80 @@ type SpotifyAuth struct {
 }
 
 type SpotifyTrack struct {
-	ID         string   `json:"id"`
-	Name       string   `json:"name"`
-	Artists    []string `json:"artists"`
-	Album      string   `json:"album"`
-	AlbumArt   string   `json:"album_art"`
-	Duration   int      `json:"duration_ms"`
-	Progress   int      `json:"progress_ms"`
-	IsPlaying  bool     `json:"is_playing"`
-	URI        string   `json:"uri"`
-	Popularity int      `json:"popularity"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Artists     []string `json:"artists"`
+	Album       string   `json:"album"`
+	AlbumArt    string   `json:"album_art"`
+	Duration    int      `json:"duration_ms"`
+	Progress    int      `json:"progress_ms"`
+	IsPlaying   bool     `json:"is_playing"`
+	URI         string   `json:"uri"`
+	Popularity  int      `json:"popularity"`
+	ReleaseDate string   `json:"release_date,omitempty"`
+	Type        string   `json:"type"` // "track" or "episode"
+
+	// Episode only
+	ShowID         string `json:"show_id,omitempty"`
+	ShowName       string `json:"show_name,omitempty"`
+	Description    string `json:"description,omitempty"`
+	ResumePosition int    `json:"resume_position_ms,omitempty"`
+	FullyPlayed    bool   `json:"fully_played,omitempty"`
+}
+
+// SpotifyContext is what the current playback was started from (playlist, album, artist, show)
+type SpotifyContext struct {
+	Type string `json:"type"`
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"` // Link to open the context in Spotify
+}
+
+// SpotifyPlaybackState is the full playback state of the user's Spotify account
+type SpotifyPlaybackState struct {
+	DeviceID     string          `json:"device_id"`
+	DeviceName   string          `json:"device_name"`
+	DeviceType   string          `json:"device_type"`
+	Volume       int             `json:"volume_percent"`
+	ShuffleState bool            `json:"shuffle_state"`
+	RepeatState  string          `json:"repeat_state"`
+	Context      *SpotifyContext `json:"context,omitempty"`
+	Track        *SpotifyTrack   `json:"track,omitempty"`
+}
+
+// SpotifyDevice is a Spotify Connect device (phone, speaker, desktop app, ...)
+type SpotifyDevice struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	IsActive bool   `json:"is_active"`
+	Volume   int    `json:"volume_percent"`
+}
+
+// SpotifyShow is a podcast show saved in the user's library
+type SpotifyShow struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Publisher     string `json:"publisher"`
+	Description   string `json:"description"`
+	ImageURL      string `json:"image_url"`
+	URI           string `json:"uri"`
+	TotalEpisodes int    `json:"total_episodes"`
+}
+
+// SpotifyAudioFeatures holds the audio analysis summary Spotify computes for a track
+type SpotifyAudioFeatures struct {
+	ID               string  `json:"id"`
+	Tempo            float64 `json:"tempo"` // Beats per minute
+	Energy           float64 `json:"energy"`
+	Danceability     float64 `json:"danceability"`
+	Valence          float64 `json:"valence"`
+	Acousticness     float64 `json:"acousticness"`
+	Instrumentalness float64 `json:"instrumentalness"`
+	Loudness         float64 `json:"loudness"`
+	Key              int     `json:"key"`
+	Mode             int     `json:"mode"`
+	TimeSignature    int     `json:"time_signature"`
 }
 
 type SpotifyPlaylist struct {
@@ -55,7 +124,7 @@ func NewSpotifyClient(clientID, clientSecret, redirectURI string) *SpotifyClient
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		redirectURI:  redirectURI,
-		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		httpClient:   NewHTTPClient(DependencySpotify, 10*time.Second),
 	}
 }
 
@@ -67,9 +136,12 @@ func (c *SpotifyClient) GetAuthURL(state string) string {
 		"user-read-currently-playing",
 		"playlist-read-private",
 		"playlist-read-collaborative",
+		"playlist-modify-public",
+		"playlist-modify-private",
 		"user-library-read",
 		"user-top-read",
 		"user-read-recently-played",
+		"user-read-playback-position",
 	}
 
 	params := url.Values{}
@@ -99,15 +171,14 @@ func (c *SpotifyClient) ExchangeCode(code string) error {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := DoWithRetry(c.httpClient, req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("spotify auth failed: %s - %s", resp.Status, string(body))
+		return spotifyAPIError("spotify auth failed", resp)
 	}
 
 	var auth SpotifyAuth
@@ -140,15 +211,14 @@ func (c *SpotifyClient) RefreshToken() error {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := DoWithRetry(c.httpClient, req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("token refresh failed: %s - %s", resp.Status, string(body))
+		return spotifyAPIError("token refresh failed", resp)
 	}
 
 	var auth SpotifyAuth
@@ -168,11 +238,13 @@ func (c *SpotifyClient) RefreshToken() error {
 // ensureValidToken checks and refreshes token if needed
 func (c *SpotifyClient) ensureValidToken() error {
 	if c.auth == nil {
-		return fmt.Errorf("not authenticated")
+		return models.NewError(models.ErrSpotifyUnauthenticated, i18n.T("spotify is not authenticated"), nil)
 	}
 
 	if time.Now().After(c.auth.ExpiresAt.Add(-1 * time.Minute)) {
-		return c.RefreshToken()
+		err := c.RefreshToken()
+		noteSpotifyRefresh(err)
+		return err
 	}
 
 	return nil
@@ -180,11 +252,16 @@ func (c *SpotifyClient) ensureValidToken() error {
 
 // apiRequest makes an authenticated request to Spotify API
 func (c *SpotifyClient) apiRequest(method, endpoint string, body io.Reader) (*http.Response, error) {
+	return c.apiRequestContext(context.Background(), method, endpoint, body)
+}
+
+// apiRequestContext is apiRequest giving up, retries included, once ctx is done
+func (c *SpotifyClient) apiRequestContext(ctx context.Context, method, endpoint string, body io.Reader) (*http.Response, error) {
 	if err := c.ensureValidToken(); err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(method, "https://api.spotify.com/v1"+endpoint, body)
+	req, err := http.NewRequestWithContext(ctx, method, "https://api.spotify.com/v1"+endpoint, body)
 	if err != nil {
 		return nil, err
 	}
@@ -194,12 +271,12 @@ func (c *SpotifyClient) apiRequest(method, endpoint string, body io.Reader) (*ht
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	return c.httpClient.Do(req)
+	return DoWithRetry(c.httpClient, req)
 }
 
 // GetCurrentTrack gets the currently playing track
 func (c *SpotifyClient) GetCurrentTrack() (*SpotifyTrack, error) {
-	resp, err := c.apiRequest("GET", "/me/player/currently-playing", nil)
+	resp, err := c.apiRequest("GET", "/me/player/currently-playing?additional_types=episode", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -210,147 +287,572 @@ func (c *SpotifyClient) GetCurrentTrack() (*SpotifyTrack, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get current track: %s - %s", resp.Status, string(body))
+		return nil, spotifyAPIError("failed to get current track", resp)
 	}
 
 	var result struct {
-		Item struct {
-			ID       string `json:"id"`
-			Name     string `json:"name"`
-			URI      string `json:"uri"`
-			Duration int    `json:"duration_ms"`
-			Album    struct {
-				Name   string `json:"name"`
-				Images []struct {
-					URL string `json:"url"`
-				} `json:"images"`
-			} `json:"album"`
-			Artists []struct {
-				Name string `json:"name"`
-			} `json:"artists"`
-			Popularity int `json:"popularity"`
-		} `json:"item"`
-		Progress  int  `json:"progress_ms"`
-		IsPlaying bool `json:"is_playing"`
+		Item        json.RawMessage `json:"item"`
+		Progress    int             `json:"progress_ms"`
+		IsPlaying   bool            `json:"is_playing"`
+		PlayingType string          `json:"currently_playing_type"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
+	// Podcasts come back as episode objects, which have a show instead of an album
+	var track *SpotifyTrack
+	if result.PlayingType == "episode" {
+		var episode spotifyEpisodeObject
+		if err := json.Unmarshal(result.Item, &episode); err != nil {
+			return nil, err
+		}
+		track = episode.toTrack()
+	} else {
+		var item spotifyTrackObject
+		if len(result.Item) > 0 && string(result.Item) != "null" {
+			if err := json.Unmarshal(result.Item, &item); err != nil {
+				return nil, err
+			}
+		}
+		track = item.toTrack()
+	}
+
+	track.Progress = result.Progress
+	track.IsPlaying = result.IsPlaying
+
+	return track, nil
+}
+
+// GetPlaybackState gets the playback state including device, shuffle/repeat and context.
+// It returns nil when nothing is playing on any device.
+func (c *SpotifyClient) GetPlaybackState() (*SpotifyPlaybackState, error) {
+	resp, err := c.apiRequest("GET", "/me/player?additional_types=episode", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, spotifyAPIError("failed to get playback state", resp)
+	}
+
+	var result struct {
+		Device struct {
+			ID     string `json:"id"`
+			Name   string `json:"name"`
+			Type   string `json:"type"`
+			Volume int    `json:"volume_percent"`
+		} `json:"device"`
+		ShuffleState bool   `json:"shuffle_state"`
+		RepeatState  string `json:"repeat_state"`
+		Context      *struct {
+			Type         string `json:"type"`
+			URI          string `json:"uri"`
+			ExternalURLs struct {
+				Spotify string `json:"spotify"`
+			} `json:"external_urls"`
+		} `json:"context"`
+		Item        json.RawMessage `json:"item"`
+		Progress    int             `json:"progress_ms"`
+		IsPlaying   bool            `json:"is_playing"`
+		PlayingType string          `json:"currently_playing_type"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	state := &SpotifyPlaybackState{
+		DeviceID:     result.Device.ID,
+		DeviceName:   result.Device.Name,
+		DeviceType:   result.Device.Type,
+		Volume:       result.Device.Volume,
+		ShuffleState: result.ShuffleState,
+		RepeatState:  result.RepeatState,
+	}
+
+	if result.Context != nil {
+		state.Context = &SpotifyContext{
+			Type: result.Context.Type,
+			URI:  result.Context.URI,
+			URL:  result.Context.ExternalURLs.Spotify,
+		}
+	}
+
+	if len(result.Item) > 0 && string(result.Item) != "null" {
+		if result.PlayingType == "episode" {
+			var episode spotifyEpisodeObject
+			if err := json.Unmarshal(result.Item, &episode); err == nil {
+				state.Track = episode.toTrack()
+			}
+		} else {
+			var item spotifyTrackObject
+			if err := json.Unmarshal(result.Item, &item); err == nil {
+				state.Track = item.toTrack()
+			}
+		}
+		if state.Track != nil {
+			state.Track.Progress = result.Progress
+			state.Track.IsPlaying = result.IsPlaying
+		}
+	}
+
+	return state, nil
+}
+
+// GetDevices lists the user's available Spotify Connect devices
+func (c *SpotifyClient) GetDevices() ([]SpotifyDevice, error) {
+	resp, err := c.apiRequest("GET", "/me/player/devices", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, spotifyAPIError("get devices failed", resp)
+	}
+
+	var result struct {
+		Devices []SpotifyDevice `json:"devices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Devices, nil
+}
+
+// GetContextName looks up the display name of a context URI
+// (spotify:playlist:..., spotify:album:..., spotify:artist:..., spotify:show:...)
+func (c *SpotifyClient) GetContextName(contextURI string) (string, error) {
+	parts := strings.Split(contextURI, ":")
+	if len(parts) < 3 || parts[0] != "spotify" {
+		return "", fmt.Errorf("invalid context uri: %s", contextURI)
+	}
+	kind, id := parts[len(parts)-2], parts[len(parts)-1]
+
+	var endpoint string
+	switch kind {
+	case "playlist":
+		endpoint = "/playlists/" + url.PathEscape(id) + "?fields=name"
+	case "album", "artist", "show":
+		endpoint = "/" + kind + "s/" + url.PathEscape(id)
+	default:
+		return "", fmt.Errorf("unsupported context type: %s", kind)
+	}
+
+	resp, err := c.apiRequest("GET", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", spotifyAPIError("get context failed", resp)
+	}
+
+	var result struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	return result.Name, nil
+}
+
+// PlayContext starts playing a context, optionally at a specific track inside it
+func (c *SpotifyClient) PlayContext(contextURI, offsetURI, deviceID string) error {
+	endpoint := "/me/player/play"
+	if deviceID != "" {
+		endpoint += "?device_id=" + deviceID
+	}
+
+	payload := map[string]interface{}{
+		"context_uri": contextURI,
+	}
+	if offsetURI != "" {
+		payload["offset"] = map[string]string{"uri": offsetURI}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.apiRequest("PUT", endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return spotifyAPIError("play context failed", resp)
+	}
+
+	return nil
+}
+
+// PlayTracks starts playback of the given track URIs
+func (c *SpotifyClient) PlayTracks(uris []string, deviceID string) error {
+	endpoint := "/me/player/play"
+	if deviceID != "" {
+		endpoint += "?device_id=" + deviceID
+	}
+
+	body, err := json.Marshal(map[string][]string{"uris": uris})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.apiRequest("PUT", endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return spotifyAPIError("play tracks failed", resp)
+	}
+
+	return nil
+}
+
+// spotifyTrackObject is the track object returned by the Spotify Web API
+type spotifyTrackObject struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	URI      string `json:"uri"`
+	Duration int    `json:"duration_ms"`
+	Album    struct {
+		Name        string `json:"name"`
+		ReleaseDate string `json:"release_date"`
+		Images      []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	} `json:"album"`
+	Artists []struct {
+		Name string `json:"name"`
+	} `json:"artists"`
+	Popularity int `json:"popularity"`
+}
+
+func (t spotifyTrackObject) toTrack() *SpotifyTrack {
 	track := &SpotifyTrack{
-		ID:         result.Item.ID,
-		Name:       result.Item.Name,
-		Album:      result.Item.Album.Name,
-		Duration:   result.Item.Duration,
-		Progress:   result.Progress,
-		IsPlaying:  result.IsPlaying,
-		URI:        result.Item.URI,
-		Popularity: result.Item.Popularity,
-	}
-
-	// Extract artist names
-	for _, artist := range result.Item.Artists {
+		ID:          t.ID,
+		Name:        t.Name,
+		Album:       t.Album.Name,
+		Duration:    t.Duration,
+		URI:         t.URI,
+		Popularity:  t.Popularity,
+		ReleaseDate: t.Album.ReleaseDate,
+		Type:        "track",
+	}
+
+	for _, artist := range t.Artists {
 		track.Artists = append(track.Artists, artist.Name)
 	}
 
-	// Get album art URL
-	if len(result.Item.Album.Images) > 0 {
-		track.AlbumArt = result.Item.Album.Images[0].URL
+	if len(t.Album.Images) > 0 {
+		track.AlbumArt = t.Album.Images[0].URL
 	}
 
-	return track, nil
+	return track
+}
+
+// spotifyEpisodeObject is the podcast episode object returned by the Spotify Web API
+type spotifyEpisodeObject struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	URI         string `json:"uri"`
+	Description string `json:"description"`
+	Duration    int    `json:"duration_ms"`
+	ReleaseDate string `json:"release_date"`
+	Images      []struct {
+		URL string `json:"url"`
+	} `json:"images"`
+	ResumePoint struct {
+		FullyPlayed      bool `json:"fully_played"`
+		ResumePositionMs int  `json:"resume_position_ms"`
+	} `json:"resume_point"`
+	Show struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Publisher string `json:"publisher"`
+		Images    []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	} `json:"show"`
+}
+
+func (e spotifyEpisodeObject) toTrack() *SpotifyTrack {
+	track := &SpotifyTrack{
+		ID:             e.ID,
+		Name:           e.Name,
+		Album:          e.Show.Name,
+		Duration:       e.Duration,
+		URI:            e.URI,
+		ReleaseDate:    e.ReleaseDate,
+		Type:           "episode",
+		ShowID:         e.Show.ID,
+		ShowName:       e.Show.Name,
+		Description:    e.Description,
+		ResumePosition: e.ResumePoint.ResumePositionMs,
+		FullyPlayed:    e.ResumePoint.FullyPlayed,
+	}
+
+	if e.Show.Publisher != "" {
+		track.Artists = []string{e.Show.Publisher}
+	}
+
+	// Episodes usually have their own artwork, otherwise use the show's
+	if len(e.Images) > 0 {
+		track.AlbumArt = e.Images[0].URL
+	} else if len(e.Show.Images) > 0 {
+		track.AlbumArt = e.Show.Images[0].URL
+	}
+
+	return track
+}
+
+// GetTrack gets the catalog information for a single track
+func (c *SpotifyClient) GetTrack(trackID string) (*SpotifyTrack, error) {
+	resp, err := c.apiRequest("GET", "/tracks/"+url.PathEscape(trackID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, spotifyAPIError("get track failed", resp)
+	}
+
+	var result spotifyTrackObject
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.toTrack(), nil
+}
+
+// GetAudioFeatures gets the audio features (tempo, energy, ...) for a track
+func (c *SpotifyClient) GetAudioFeatures(trackID string) (*SpotifyAudioFeatures, error) {
+	resp, err := c.apiRequest("GET", "/audio-features/"+url.PathEscape(trackID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, spotifyAPIError("get audio features failed", resp)
+	}
+
+	var features SpotifyAudioFeatures
+	if err := json.NewDecoder(resp.Body).Decode(&features); err != nil {
+		return nil, err
+	}
+
+	return &features, nil
+}
+
+// GetRecommendations gets tracks similar to the given seeds (up to 5 seeds in total).
+// params holds optional tuning values such as "limit" or "target_energy".
+func (c *SpotifyClient) GetRecommendations(seedTracks, seedArtists []string, params map[string]string) ([]SpotifyTrack, error) {
+	if len(seedTracks)+len(seedArtists) == 0 {
+		return nil, fmt.Errorf("at least one seed track or artist is required")
+	}
+	if len(seedTracks)+len(seedArtists) > 5 {
+		return nil, fmt.Errorf("at most 5 seeds are allowed")
+	}
+
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	if len(seedTracks) > 0 {
+		query.Set("seed_tracks", strings.Join(seedTracks, ","))
+	}
+	if len(seedArtists) > 0 {
+		query.Set("seed_artists", strings.Join(seedArtists, ","))
+	}
+
+	resp, err := c.apiRequest("GET", "/recommendations?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, spotifyAPIError("get recommendations failed", resp)
+	}
+
+	var result struct {
+		Tracks []spotifyTrackObject `json:"tracks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	tracks := make([]SpotifyTrack, 0, len(result.Tracks))
+	for _, item := range result.Tracks {
+		tracks = append(tracks, *item.toTrack())
+	}
+
+	return tracks, nil
+}
+
+// AddToQueue adds a track or episode URI to the end of the playback queue
+func (c *SpotifyClient) AddToQueue(uri string, deviceID string) error {
+	endpoint := "/me/player/queue?uri=" + url.QueryEscape(uri)
+	if deviceID != "" {
+		endpoint += "&device_id=" + deviceID
+	}
+
+	resp, err := c.apiRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return spotifyAPIError("add to queue failed", resp)
+	}
+
+	return nil
+}
+
+// GetQueue lists the tracks and episodes queued after the current one
+func (c *SpotifyClient) GetQueue() ([]SpotifyTrack, error) {
+	resp, err := c.apiRequest("GET", "/me/player/queue", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, spotifyAPIError("get queue failed", resp)
+	}
+
+	var result struct {
+		Queue []json.RawMessage `json:"queue"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	tracks := make([]SpotifyTrack, 0, len(result.Queue))
+	for _, raw := range result.Queue {
+		var probe struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(raw, &probe)
+
+		if probe.Type == "episode" {
+			var episode spotifyEpisodeObject
+			if err := json.Unmarshal(raw, &episode); err == nil {
+				tracks = append(tracks, *episode.toTrack())
+			}
+			continue
+		}
+		var item spotifyTrackObject
+		if err := json.Unmarshal(raw, &item); err == nil {
+			tracks = append(tracks, *item.toTrack())
+		}
+	}
+
+	return tracks, nil
 }
 
 // Play starts or resumes playback
-func (c *SpotifyClient) Play(deviceID string) error {
+func (c *SpotifyClient) Play(ctx context.Context, deviceID string) error {
 	endpoint := "/me/player/play"
 	if deviceID != "" {
 		endpoint += "?device_id=" + deviceID
 	}
 
-	resp, err := c.apiRequest("PUT", endpoint, nil)
+	resp, err := c.apiRequestContext(ctx, "PUT", endpoint, nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("play failed: %s - %s", resp.Status, string(body))
+		return spotifyAPIError("play failed", resp)
 	}
 
 	return nil
 }
 
 // Pause pauses playback
-func (c *SpotifyClient) Pause(deviceID string) error {
+func (c *SpotifyClient) Pause(ctx context.Context, deviceID string) error {
 	endpoint := "/me/player/pause"
 	if deviceID != "" {
 		endpoint += "?device_id=" + deviceID
 	}
 
-	resp, err := c.apiRequest("PUT", endpoint, nil)
+	resp, err := c.apiRequestContext(ctx, "PUT", endpoint, nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("pause failed: %s - %s", resp.Status, string(body))
+		return spotifyAPIError("pause failed", resp)
 	}
 
 	return nil
 }
 
 // Next skips to next track
-func (c *SpotifyClient) Next(deviceID string) error {
+func (c *SpotifyClient) Next(ctx context.Context, deviceID string) error {
 	endpoint := "/me/player/next"
 	if deviceID != "" {
 		endpoint += "?device_id=" + deviceID
 	}
 
-	resp, err := c.apiRequest("POST", endpoint, nil)
+	resp, err := c.apiRequestContext(ctx, "POST", endpoint, nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("next failed: %s - %s", resp.Status, string(body))
+		return spotifyAPIError("next failed", resp)
 	}
 
 	return nil
 }
 
 // Previous goes to previous track
-func (c *SpotifyClient) Previous(deviceID string) error {
+func (c *SpotifyClient) Previous(ctx context.Context, deviceID string) error {
 	endpoint := "/me/player/previous"
 	if deviceID != "" {
 		endpoint += "?device_id=" + deviceID
 	}
 
-	resp, err := c.apiRequest("POST", endpoint, nil)
+	resp, err := c.apiRequestContext(ctx, "POST", endpoint, nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("previous failed: %s - %s", resp.Status, string(body))
+		return spotifyAPIError("previous failed", resp)
 	}
 
 	return nil
 }
 
 // SetVolume sets the playback volume (0-100)
-func (c *SpotifyClient) SetVolume(volume int, deviceID string) error {
+func (c *SpotifyClient) SetVolume(ctx context.Context, volume int, deviceID string) error {
 	if volume < 0 || volume > 100 {
-		return fmt.Errorf("volume must be between 0 and 100")
+		return models.NewParamError("volume", i18n.T("volume must be between 0 and 100"))
 	}
 
 	endpoint := fmt.Sprintf("/me/player/volume?volume_percent=%d", volume)
@@ -358,15 +860,82 @@ func (c *SpotifyClient) SetVolume(volume int, deviceID string) error {
 		endpoint += "&device_id=" + deviceID
 	}
 
-	resp, err := c.apiRequest("PUT", endpoint, nil)
+	resp, err := c.apiRequestContext(ctx, "PUT", endpoint, nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("set volume failed: %s - %s", resp.Status, string(body))
+		return spotifyAPIError("set volume failed", resp)
+	}
+
+	return nil
+}
+
+// Seek moves playback to a position in the current track
+func (c *SpotifyClient) Seek(ctx context.Context, positionMs int, deviceID string) error {
+	if positionMs < 0 {
+		return models.NewParamError("position_ms", i18n.T("position must not be negative"))
+	}
+
+	endpoint := fmt.Sprintf("/me/player/seek?position_ms=%d", positionMs)
+	if deviceID != "" {
+		endpoint += "&device_id=" + deviceID
+	}
+
+	resp, err := c.apiRequestContext(ctx, "PUT", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return spotifyAPIError("seek failed", resp)
+	}
+
+	return nil
+}
+
+// SetRepeat sets the repeat mode ("track", "context" or "off")
+func (c *SpotifyClient) SetRepeat(ctx context.Context, mode string, deviceID string) error {
+	if mode != "track" && mode != "context" && mode != "off" {
+		return models.NewParamError("mode", i18n.T("repeat mode must be off, track or context"))
+	}
+
+	endpoint := "/me/player/repeat?state=" + mode
+	if deviceID != "" {
+		endpoint += "&device_id=" + deviceID
+	}
+
+	resp, err := c.apiRequestContext(ctx, "PUT", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return spotifyAPIError("set repeat failed", resp)
+	}
+
+	return nil
+}
+
+// SetShuffle turns shuffle on or off
+func (c *SpotifyClient) SetShuffle(ctx context.Context, state bool, deviceID string) error {
+	endpoint := fmt.Sprintf("/me/player/shuffle?state=%t", state)
+	if deviceID != "" {
+		endpoint += "&device_id=" + deviceID
+	}
+
+	resp, err := c.apiRequestContext(ctx, "PUT", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return spotifyAPIError("set shuffle failed", resp)
 	}
 
 	return nil
@@ -383,8 +952,7 @@ func (c *SpotifyClient) GetPlaylists(limit int) ([]SpotifyPlaylist, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("get playlists failed: %s - %s", resp.Status, string(body))
+		return nil, spotifyAPIError("get playlists failed", resp)
 	}
 
 	var result struct {
@@ -424,6 +992,179 @@ func (c *SpotifyClient) GetPlaylists(limit int) ([]SpotifyPlaylist, error) {
 	return playlists, nil
 }
 
+// AddTracksToPlaylist appends track or episode URIs (max 100) to a playlist
+// and returns the new playlist snapshot ID
+func (c *SpotifyClient) AddTracksToPlaylist(playlistID string, uris []string) (string, error) {
+	if len(uris) == 0 {
+		return "", fmt.Errorf("no tracks to add")
+	}
+	if len(uris) > 100 {
+		return "", fmt.Errorf("at most 100 tracks can be added at once")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"uris": uris,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.apiRequest("POST", "/playlists/"+url.PathEscape(playlistID)+"/tracks", strings.NewReader(string(body)))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", spotifyAPIError("add tracks to playlist failed", resp)
+	}
+
+	var result struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	return result.SnapshotID, nil
+}
+
+// GetSavedShows gets the podcast shows saved in the user's library
+func (c *SpotifyClient) GetSavedShows(limit int) ([]SpotifyShow, error) {
+	endpoint := fmt.Sprintf("/me/shows?limit=%d", limit)
+
+	resp, err := c.apiRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, spotifyAPIError("get saved shows failed", resp)
+	}
+
+	var result struct {
+		Items []struct {
+			Show struct {
+				ID            string `json:"id"`
+				Name          string `json:"name"`
+				Publisher     string `json:"publisher"`
+				Description   string `json:"description"`
+				URI           string `json:"uri"`
+				TotalEpisodes int    `json:"total_episodes"`
+				Images        []struct {
+					URL string `json:"url"`
+				} `json:"images"`
+			} `json:"show"`
+		} `json:"items"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	shows := make([]SpotifyShow, 0, len(result.Items))
+	for _, item := range result.Items {
+		show := SpotifyShow{
+			ID:            item.Show.ID,
+			Name:          item.Show.Name,
+			Publisher:     item.Show.Publisher,
+			Description:   item.Show.Description,
+			URI:           item.Show.URI,
+			TotalEpisodes: item.Show.TotalEpisodes,
+		}
+		if len(item.Show.Images) > 0 {
+			show.ImageURL = item.Show.Images[0].URL
+		}
+		shows = append(shows, show)
+	}
+
+	return shows, nil
+}
+
+// GetShowEpisodes gets the latest episodes of a show, including the user's resume points
+func (c *SpotifyClient) GetShowEpisodes(showID string, limit int) ([]SpotifyTrack, error) {
+	endpoint := fmt.Sprintf("/shows/%s/episodes?limit=%d", url.PathEscape(showID), limit)
+
+	resp, err := c.apiRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, spotifyAPIError("get show episodes failed", resp)
+	}
+
+	var result struct {
+		Items []spotifyEpisodeObject `json:"items"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	episodes := make([]SpotifyTrack, 0, len(result.Items))
+	for _, item := range result.Items {
+		episode := item.toTrack()
+		// The episodes endpoint omits the show object
+		if episode.ShowID == "" {
+			episode.ShowID = showID
+		}
+		episodes = append(episodes, *episode)
+	}
+
+	return episodes, nil
+}
+
+// GetEpisode gets a single podcast episode, including the user's resume point
+func (c *SpotifyClient) GetEpisode(episodeID string) (*SpotifyTrack, error) {
+	resp, err := c.apiRequest("GET", "/episodes/"+url.PathEscape(episodeID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, spotifyAPIError("get episode failed", resp)
+	}
+
+	var result spotifyEpisodeObject
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.toTrack(), nil
+}
+
+// ResumeEpisode starts playing an episode from the user's saved resume point
+func (c *SpotifyClient) ResumeEpisode(episodeURI string, resumePositionMs int, deviceID string) error {
+	endpoint := "/me/player/play"
+	if deviceID != "" {
+		endpoint += "?device_id=" + deviceID
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"uris":        []string{episodeURI},
+		"position_ms": resumePositionMs,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.apiRequest("PUT", endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return spotifyAPIError("resume episode failed", resp)
+	}
+
+	return nil
+}
+
 // SetAuth sets the authentication manually (useful for loading from storage)
 func (c *SpotifyClient) SetAuth(auth *SpotifyAuth) {
 	c.auth = auth
@@ -438,3 +1179,44 @@ func (c *SpotifyClient) GetAuth() *SpotifyAuth {
 func (c *SpotifyClient) IsAuthenticated() bool {
 	return c.auth != nil && c.auth.AccessToken != ""
 }
+
+// spotifyAPIError converts a failed Spotify response into a models.Error. The
+// response body is logged rather than returned, so raw API payloads don't
+// reach clients.
+func spotifyAPIError(action string, resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	log.Printf("Spotify %s: %s - %s", action, resp.Status, string(body))
+
+	// API errors are {"error": {"message", "reason"}}, token errors {"error": "invalid_grant"}
+	var apiErr struct {
+		Error json.RawMessage `json:"error"`
+	}
+	var detail struct {
+		Message string `json:"message"`
+		Reason  string `json:"reason"`
+	}
+	var tokenErr string
+	if json.Unmarshal(body, &apiErr) == nil && len(apiErr.Error) > 0 {
+		if json.Unmarshal(apiErr.Error, &detail) != nil {
+			json.Unmarshal(apiErr.Error, &tokenErr)
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || tokenErr == "invalid_grant":
+		return models.NewError(models.ErrSpotifyUnauthenticated, i18n.T("spotify session expired, please log in again"), nil)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		message := "spotify is rate limiting requests"
+		retryErr := models.NewError(models.ErrRateLimited, message, nil)
+		if retry, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryErr.Message += ", try again in " + strconv.Itoa(retry) + "s"
+			retryErr.RetryAfter = retry
+		}
+		return retryErr
+	case detail.Reason == "NO_ACTIVE_DEVICE" || resp.StatusCode == http.StatusNotFound && strings.HasPrefix(resp.Request.URL.Path, "/v1/me/player"):
+		return models.NewError(models.ErrPlayerNotFound, i18n.T("no active spotify device"), nil)
+	case detail.Message != "":
+		return models.NewError(models.ErrCommandFailed, action+": "+detail.Message, nil)
+	}
+	return models.NewError(models.ErrCommandFailed, action+": "+resp.Status, nil)
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"Blitz/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// States of the Spotify login, broadcast on spotify_auth
+const (
+	SpotifyUnconfigured     = "unconfigured"      // No credentials, or the spotify module is off
+	SpotifyUnauthenticated  = "unauthenticated"   // Configured, but nobody logged in since Blitz started
+	SpotifyAwaitingCallback = "awaiting_callback" // Sent to Spotify's login page, waiting for it to redirect back
+	SpotifyAuthenticated    = "authenticated"
+	SpotifyRefreshFailed    = "refresh_failed" // Spotify refused to renew the session, so it needs a new login
+)
+
+// spotifyLoginPath starts the login, see HandleSpotifyAuth
+const spotifyLoginPath = "/spotify/auth"
+
+// SpotifyAuthState is where the Spotify login stands, so clients show a
+// "Connect Spotify" button exactly while it is needed
+type SpotifyAuthState struct {
+	State    string `json:"state"`
+	Previous string `json:"previous,omitempty"` // The state before the last transition
+	Since    int64  `json:"since"`              // Unix seconds of the last transition
+	Error    string `json:"error,omitempty"`    // Why the last login or refresh failed
+	// LoginURL is the page to open to log in, while that is possible
+	LoginURL string `json:"loginUrl,omitempty"`
+}
+
+// Topic implements models.Payload
+func (SpotifyAuthState) Topic() string { return "spotify_auth" }
+
+// Version implements models.Payload
+func (SpotifyAuthState) Version() int { return 1 }
+
+var (
+	spotifyAuthState   SpotifyAuthState
+	spotifyAuthStateMu sync.Mutex
+	// spotifyAuthChanges carries every transition, in order, to the poller
+	spotifyAuthChanges = make(chan SpotifyAuthState, 16)
+)
+
+// GetSpotifyAuthState returns where the Spotify login stands
+func GetSpotifyAuthState() SpotifyAuthState {
+	configured := GetSpotifyClient() != nil
+
+	spotifyAuthStateMu.Lock()
+	defer spotifyAuthStateMu.Unlock()
+	return currentSpotifyAuthState(configured)
+}
+
+// currentSpotifyAuthState is GetSpotifyAuthState with spotifyAuthStateMu held
+func currentSpotifyAuthState(configured bool) SpotifyAuthState {
+	if spotifyAuthState.State == "" {
+		spotifyAuthState = SpotifyAuthState{State: SpotifyUnconfigured, Since: time.Now().Unix()}
+		if configured {
+			spotifyAuthState.State = SpotifyUnauthenticated
+			spotifyAuthState.LoginURL = spotifyLoginPath
+		}
+	}
+	return spotifyAuthState
+}
+
+// SpotifyAuthChanges delivers the login state after each transition, so a
+// login that fails right after starting is broadcast too
+func SpotifyAuthChanges() <-chan SpotifyAuthState {
+	return spotifyAuthChanges
+}
+
+// setSpotifyAuthState moves the login to state, noting why it failed
+func setSpotifyAuthState(state string, err error) {
+	moveSpotifyAuthState(func(SpotifyAuthState) (string, bool) { return state, true }, err)
+}
+
+// moveSpotifyAuthState moves the login to the state next picks from the
+// current one, if it picks one, in a single step, so concurrent logins and
+// refreshes can't interleave
+func moveSpotifyAuthState(next func(current SpotifyAuthState) (string, bool), err error) {
+	message := ""
+	if err != nil {
+		message = models.AsError(err).Message
+	}
+	configured := GetSpotifyClient() != nil
+
+	spotifyAuthStateMu.Lock()
+	defer spotifyAuthStateMu.Unlock()
+	previous := currentSpotifyAuthState(configured)
+	state, ok := next(previous)
+	if !ok || (previous.State == state && previous.Error == message) {
+		return
+	}
+
+	moved := SpotifyAuthState{State: state, Previous: previous.State, Since: time.Now().Unix(), Error: message}
+	if state == previous.State {
+		moved.Previous, moved.Since = previous.Previous, previous.Since
+	}
+	if state != SpotifyAuthenticated {
+		moved.LoginURL = spotifyLoginPath
+	}
+	spotifyAuthState = moved
+
+	select {
+	case spotifyAuthChanges <- moved:
+	default:
+		// Nobody listens; the poller still sends the latest state
+	}
+	if state != previous.State {
+		log.Printf("🎧 Spotify login: %s → %s", previous.State, state)
+	}
+}
+
+// abandonSpotifyLogin returns a login that failed or was cancelled to the
+// state it started from
+func abandonSpotifyLogin(err error) {
+	moveSpotifyAuthState(func(current SpotifyAuthState) (string, bool) {
+		return current.Previous, current.State == SpotifyAwaitingCallback
+	}, err)
+}
+
+// noteSpotifyRefresh records the outcome of renewing the session. Only
+// Spotify refusing the refresh token fails the login; network errors may
+// pass on the next attempt.
+func noteSpotifyRefresh(err error) {
+	switch {
+	case err == nil:
+		moveSpotifyAuthState(func(current SpotifyAuthState) (string, bool) {
+			return SpotifyAuthenticated, current.State == SpotifyRefreshFailed
+		}, nil)
+	case models.AsError(err).Code == models.ErrSpotifyUnauthenticated:
+		setSpotifyAuthState(SpotifyRefreshFailed, err)
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"fmt"
+	"strconv"
+)
+
+// requireSpotify returns the shared Spotify client or an error when it can't be used
+func requireSpotify() (*SpotifyClient, error) {
+	client := GetSpotifyClient()
+	if client == nil {
+		return nil, models.NewError(models.ErrSpotifyUnauthenticated, i18n.T("spotify is not configured"), nil)
+	}
+	if !client.IsAuthenticated() {
+		return nil, models.NewError(models.ErrSpotifyUnauthenticated, i18n.T("spotify is not authenticated"), nil)
+	}
+	return client, nil
+}
+
+// currentSpotifyTrackID returns the Spotify ID of the playing track, looking at
+// the local MPRIS player first and the Spotify Connect state second
+func currentSpotifyTrackID(client *SpotifyClient) (string, error) {
+	if info, err := GetPlayerInfo(); err == nil {
+		if trackID := SpotifyTrackID(info.TrackID); trackID != "" {
+			return trackID, nil
+		}
+	}
+
+	track, err := client.GetCurrentTrack()
+	if err != nil {
+		return "", err
+	}
+	if track.ID == "" {
+		return "", fmt.Errorf("current track is not a Spotify track")
+	}
+	return track.ID, nil
+}
+
+// StartSpotifyRadio queues recommendations seeded by the currently playing track
+func StartSpotifyRadio(limit int) ([]SpotifyTrack, error) {
+	client, err := requireSpotify()
+	if err != nil {
+		return nil, err
+	}
+
+	trackID, err := currentSpotifyTrackID(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	tracks, err := client.GetRecommendations([]string{trackID}, nil, map[string]string{
+		"limit": strconv.Itoa(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	queued := make([]SpotifyTrack, 0, len(tracks))
+	for _, track := range tracks {
+		if track.ID == trackID {
+			continue
+		}
+		if err := client.AddToQueue(track.URI, ""); err != nil {
+			// Return what made it into the queue so far
+			if len(queued) == 0 {
+				return nil, err
+			}
+			break
+		}
+		queued = append(queued, track)
+	}
+
+	return queued, nil
+}
+
+// GetSpotifySavedShows lists the podcasts saved in the user's library
+func GetSpotifySavedShows(limit int) ([]SpotifyShow, error) {
+	client, err := requireSpotify()
+	if err != nil {
+		return nil, err
+	}
+	return client.GetSavedShows(limit)
+}
+
+// GetSpotifyShowEpisodes lists the latest episodes of a show with their resume points
+func GetSpotifyShowEpisodes(showID string, limit int) ([]SpotifyTrack, error) {
+	client, err := requireSpotify()
+	if err != nil {
+		return nil, err
+	}
+	return client.GetShowEpisodes(showID, limit)
+}
+
+// ResumeSpotifyEpisode plays an episode from where the user stopped listening
+func ResumeSpotifyEpisode(episodeID string) (*SpotifyTrack, error) {
+	client, err := requireSpotify()
+	if err != nil {
+		return nil, err
+	}
+
+	episode, err := client.GetEpisode(episodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	position := episode.ResumePosition
+	if episode.FullyPlayed {
+		position = 0
+	}
+
+	if err := client.ResumeEpisode(episode.URI, position, ""); err != nil {
+		return nil, err
+	}
+	return episode, nil
+}
+
+// GetSpotifyPlaylists lists the user's playlists
+func GetSpotifyPlaylists(limit int) ([]SpotifyPlaylist, error) {
+	client, err := requireSpotify()
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPlaylists(limit)
+}
+
+// AddCurrentToPlaylist saves the currently playing track to a playlist
+func AddCurrentToPlaylist(playlistID string) (map[string]string, error) {
+	client, err := requireSpotify()
+	if err != nil {
+		return nil, err
+	}
+
+	trackID, err := currentSpotifyTrackID(client)
+	if err != nil {
+		return nil, err
+	}
+
+	trackURI := "spotify:track:" + trackID
+	snapshotID, err := client.AddTracksToPlaylist(playlistID, []string{trackURI})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"playlist_id": playlistID,
+		"track_uri":   trackURI,
+		"snapshot_id": snapshotID,
+	}, nil
+}
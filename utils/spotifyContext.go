@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// playbackContextTTL is how long a fetched context is reused while the track stays the same
+	playbackContextTTL = 15 * time.Second
+	// maxContextNames bounds how many playlists' and albums' names are remembered
+	maxContextNames = 1000
+	// contextNameRetry is how long a name that couldn't be fetched is remembered
+	contextNameRetry = 5 * time.Minute
+)
+
+var (
+	playbackContext      *SpotifyContext
+	playbackContextTrack string
+	playbackContextAt    time.Time
+	lastContext          *SpotifyContext
+	lastContextTrackURI  string
+	playbackContextMu    sync.Mutex
+
+	contextNames = newLookupCache[string](maxContextNames, contextNameRetry)
+)
+
+// GetPlaybackContext returns what the current Spotify playback was started from.
+// It is refreshed when the track changes and otherwise at most every 15 seconds.
+// Spotify is asked outside the lock, so a slow request doesn't hold up
+// PlaySpotifyContext.
+func GetPlaybackContext(trackID string) *SpotifyContext {
+	client := GetSpotifyClient()
+	if client == nil || !client.IsAuthenticated() {
+		return nil
+	}
+
+	playbackContextMu.Lock()
+	previous := playbackContext
+	if trackID == playbackContextTrack && time.Since(playbackContextAt) < playbackContextTTL {
+		playbackContextMu.Unlock()
+		return previous
+	}
+	playbackContextTrack = trackID
+	playbackContextAt = time.Now()
+	playbackContextMu.Unlock()
+
+	state, err := client.GetPlaybackState()
+	if err != nil {
+		log.Println("Failed to get Spotify playback context:", err)
+		return previous
+	}
+
+	var context *SpotifyContext
+	if state != nil && state.Context != nil {
+		fetched := *state.Context
+		fetched.Name = contextName(client, fetched.URI)
+		context = &fetched
+	}
+
+	playbackContextMu.Lock()
+	defer playbackContextMu.Unlock()
+	// The track changed meanwhile, and a newer fetch is on its way
+	if playbackContextTrack != trackID {
+		return context
+	}
+	playbackContext = context
+	if context != nil {
+		lastContext = context
+		if state.Track != nil {
+			lastContextTrackURI = state.Track.URI
+		}
+	}
+	return context
+}
+
+// contextName returns the name of a playlist or album, fetched once per
+// context
+func contextName(client *SpotifyClient, contextURI string) string {
+	if name, ok := contextNames.get(contextURI); ok {
+		return name
+	}
+	name, err := client.GetContextName(contextURI)
+	contextNames.put(contextURI, name, err == nil)
+	return name
+}
+
+// PlaySpotifyContext starts playing a context. With an empty contextURI it jumps
+// back into the last known context at the last track played from it.
+func PlaySpotifyContext(contextURI, offsetURI string) (*SpotifyContext, error) {
+	client, err := requireSpotify()
+	if err != nil {
+		return nil, err
+	}
+
+	context := &SpotifyContext{URI: contextURI}
+	if contextURI == "" {
+		playbackContextMu.Lock()
+		if lastContext != nil {
+			copied := *lastContext
+			context = &copied
+			if offsetURI == "" {
+				offsetURI = lastContextTrackURI
+			}
+		}
+		playbackContextMu.Unlock()
+
+		if context.URI == "" {
+			return nil, fmt.Errorf("no playback context to return to")
+		}
+	}
+
+	if err := client.PlayContext(context.URI, offsetURI, ""); err != nil {
+		return nil, err
+	}
+	return context, nil
+}
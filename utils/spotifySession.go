@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+var (
+	spotifyClient     *SpotifyClient
+	spotifyClientOnce sync.Once
+	spotifyState      string
+	spotifyStateMu    sync.Mutex
+)
+
+// GetSpotifyClient returns the shared Spotify client configured from the
+// SPOTIFY_* environment variables, or nil when no credentials are set or
+// the spotify module is disabled
+func GetSpotifyClient() *SpotifyClient {
+	spotifyClientOnce.Do(func() {
+		if !config.Get().Modules.Spotify {
+			return
+		}
+		clientID := os.Getenv("SPOTIFY_CLIENT_ID")
+		clientSecret := os.Getenv("SPOTIFY_CLIENT_SECRET")
+		redirectURI := os.Getenv("SPOTIFY_REDIRECT_URI")
+		if clientID == "" || clientSecret == "" {
+			log.Println("Spotify credentials not set, Spotify integration disabled")
+			return
+		}
+		if redirectURI == "" && config.Get().TLS.CertFile != "" {
+			redirectURI = "https://localhost:8765/spotify/callback"
+		} else if redirectURI == "" {
+			redirectURI = "http://localhost:8765/spotify/callback"
+		}
+		spotifyClient = NewSpotifyClient(clientID, clientSecret, redirectURI)
+	})
+	return spotifyClient
+}
+
+// HandleSpotifyAuth redirects the browser to the Spotify authorization page
+func HandleSpotifyAuth(w http.ResponseWriter, r *http.Request) {
+	client := GetSpotifyClient()
+	if client == nil {
+		http.Error(w, "Spotify is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		http.Error(w, "Failed to generate state", http.StatusInternalServerError)
+		return
+	}
+
+	spotifyStateMu.Lock()
+	spotifyState = hex.EncodeToString(buf)
+	state := spotifyState
+	spotifyStateMu.Unlock()
+	setSpotifyAuthState(SpotifyAwaitingCallback, nil)
+
+	http.Redirect(w, r, client.GetAuthURL(state), http.StatusFound)
+}
+
+// HandleSpotifyCallback completes the OAuth flow started by HandleSpotifyAuth
+func HandleSpotifyCallback(w http.ResponseWriter, r *http.Request) {
+	client := GetSpotifyClient()
+	if client == nil {
+		http.Error(w, "Spotify is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	if errMsg := query.Get("error"); errMsg != "" {
+		abandonSpotifyLogin(models.NewError(models.ErrSpotifyUnauthenticated, i18n.T("spotify login failed: %s", errMsg), nil))
+		http.Error(w, "Spotify authorization failed: "+errMsg, http.StatusBadRequest)
+		return
+	}
+
+	spotifyStateMu.Lock()
+	expected := spotifyState
+	spotifyState = ""
+	spotifyStateMu.Unlock()
+
+	if expected == "" || query.Get("state") != expected {
+		http.Error(w, "Invalid state", http.StatusBadRequest)
+		return
+	}
+
+	if err := client.ExchangeCode(query.Get("code")); err != nil {
+		log.Println("Spotify code exchange failed:", err)
+		abandonSpotifyLogin(err)
+		http.Error(w, "Spotify authorization failed", http.StatusBadGateway)
+		return
+	}
+
+	setSpotifyAuthState(SpotifyAuthenticated, nil)
+	log.Println("✅ Spotify authenticated")
+	fmt.Fprintln(w, "Spotify connected. You can close this window.")
+}
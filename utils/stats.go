@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/store"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// playsLog is the store log holding one line per finished play
+	playsLog = "plays"
+	// listeningGap ends a play when the poller hasn't seen it for this long,
+	// e.g. because the player was closed
+	listeningGap = 10 * time.Second
+	// minPlaySeconds drops plays that were skipped right away
+	minPlaySeconds = 10
+)
+
+// Play is one listen of a track, as kept for the statistics
+type Play struct {
+	TrackSummary
+	StartedAt int64 `json:"startedAt"`
+	Seconds   int   `json:"seconds"` // Time actually spent playing, pauses excluded
+}
+
+// StatsEntry is one row of a top list
+type StatsEntry struct {
+	Name    string `json:"name"`
+	Artist  string `json:"artist,omitempty"` // Set for tracks
+	Plays   int    `json:"plays"`
+	Seconds int    `json:"seconds"`
+}
+
+// DayStats is the listening time of one day
+type DayStats struct {
+	Date    string `json:"date"` // Local date, YYYY-MM-DD
+	Plays   int    `json:"plays"`
+	Seconds int    `json:"seconds"`
+}
+
+// Stats aggregates the plays of a period
+type Stats struct {
+	From       int64        `json:"from"`
+	To         int64        `json:"to"`
+	Plays      int          `json:"plays"`
+	Seconds    int          `json:"seconds"`
+	TopArtists []StatsEntry `json:"topArtists"`
+	TopTracks  []StatsEntry `json:"topTracks"`
+	Players    []StatsEntry `json:"players"`
+	Days       []DayStats   `json:"days"`
+}
+
+var (
+	plays         []Play // oldest first
+	playsLoaded   bool
+	currentPlay   *Play
+	lastListenAt  time.Time
+	wasPlaying    bool
+	listeningRest time.Duration // Playing time not yet counted as a whole second
+	statsMu       sync.Mutex
+)
+
+func loadPlays() {
+	if playsLoaded {
+		return
+	}
+	playsLoaded = true
+	err := store.LoadLines(playsLog, func(line []byte) {
+		var play Play
+		if json.Unmarshal(line, &play) == nil {
+			plays = append(plays, play)
+		}
+	})
+	if err != nil {
+		log.Println("Failed to load plays:", err)
+	}
+}
+
+// RecordListening counts the time the current track plays. The poller calls
+// it with every snapshot; a play is stored once another track starts or
+// playback stops for good.
+func RecordListening(info MediaInfo) {
+	if !config.Get().Stats.Enabled {
+		return
+	}
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	now := time.Now()
+	if currentPlay != nil && now.Sub(lastListenAt) > listeningGap {
+		finishPlay()
+	}
+
+	if info.Status != "Playing" || (info.Title == "" && info.TrackID == "") {
+		// A paused play stays open, it continues when the track resumes
+		if currentPlay != nil {
+			lastListenAt = now
+		}
+		wasPlaying = false
+		return
+	}
+
+	track := NormalizeTrack(TrackSummary{
+		Title:   info.Title,
+		Artist:  info.Artist,
+		Album:   info.Album,
+		Player:  info.Player,
+		TrackID: info.TrackID,
+	})
+	if currentPlay != nil && !sameTrack(&currentPlay.TrackSummary, &track) {
+		finishPlay()
+	}
+
+	if currentPlay == nil {
+		currentPlay = &Play{TrackSummary: track, StartedAt: now.Unix()}
+	} else if wasPlaying {
+		listeningRest += now.Sub(lastListenAt)
+		currentPlay.Seconds += int(listeningRest / time.Second)
+		listeningRest %= time.Second
+	}
+	lastListenAt = now
+	wasPlaying = true
+}
+
+// finishPlay stores the current play
+func finishPlay() {
+	play := *currentPlay
+	currentPlay = nil
+	listeningRest = 0
+
+	if play.Seconds < minPlaySeconds {
+		return
+	}
+
+	loadPlays()
+	plays = append(plays, play)
+	if err := store.Append(playsLog, play); err != nil {
+		log.Println("Failed to save play:", err)
+	}
+}
+
+// GetPlays returns the stored plays that started within [from, to)
+func GetPlays(from, to int64) []Play {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	loadPlays()
+
+	start := sort.Search(len(plays), func(i int) bool { return plays[i].StartedAt >= from })
+	var selected []Play
+	for _, play := range plays[start:] {
+		if play.StartedAt >= to {
+			break
+		}
+		selected = append(selected, play)
+	}
+	return selected
+}
+
+// StatsPeriod returns the bounds of a named period ending now: "day",
+// "week", "month", "year" or "all"
+func StatsPeriod(period string) (int64, int64, error) {
+	now := time.Now()
+	var from time.Time
+	switch period {
+	case "day":
+		from = now.AddDate(0, 0, -1)
+	case "", "week":
+		from = now.AddDate(0, 0, -7)
+	case "month":
+		from = now.AddDate(0, -1, 0)
+	case "year":
+		from = now.AddDate(-1, 0, 0)
+	case "all":
+		return 0, now.Unix() + 1, nil
+	default:
+		return 0, 0, models.NewParamError("period", i18n.T("unknown period %q", period))
+	}
+	return from.Unix(), now.Unix() + 1, nil
+}
+
+// GetStats aggregates the plays within [from, to) into top lists of at most
+// limit entries and the listening time per day
+func GetStats(from, to int64, limit int) Stats {
+	stats := Stats{From: from, To: to}
+
+	artists := map[string]*StatsEntry{}
+	tracks := map[string]*StatsEntry{}
+	players := map[string]*StatsEntry{}
+	days := map[string]*DayStats{}
+
+	count := func(entries map[string]*StatsEntry, key string, entry StatsEntry, play Play) {
+		if entries[key] == nil {
+			entries[key] = &entry
+		}
+		entries[key].Plays++
+		entries[key].Seconds += play.Seconds
+	}
+
+	for _, play := range GetPlays(from, to) {
+		stats.Plays++
+		stats.Seconds += play.Seconds
+
+		artistNames := play.Artists
+		if len(artistNames) == 0 && play.Artist != "" {
+			artistNames = []string{play.Artist}
+		}
+		for _, artist := range artistNames {
+			count(artists, artist, StatsEntry{Name: artist}, play)
+		}
+		count(tracks, play.Title+"\x00"+play.Artist, StatsEntry{Name: play.Title, Artist: play.Artist}, play)
+		count(players, play.Player, StatsEntry{Name: play.Player}, play)
+
+		date := time.Unix(play.StartedAt, 0).Format(time.DateOnly)
+		if days[date] == nil {
+			days[date] = &DayStats{Date: date}
+		}
+		days[date].Plays++
+		days[date].Seconds += play.Seconds
+	}
+
+	stats.TopArtists = topEntries(artists, limit)
+	stats.TopTracks = topEntries(tracks, limit)
+	stats.Players = topEntries(players, limit)
+
+	stats.Days = []DayStats{}
+	for _, day := range days {
+		stats.Days = append(stats.Days, *day)
+	}
+	sort.Slice(stats.Days, func(i, j int) bool { return stats.Days[i].Date < stats.Days[j].Date })
+	return stats
+}
+
+// topEntries sorts entries by plays, then listening time, and keeps limit of them
+func topEntries(entries map[string]*StatsEntry, limit int) []StatsEntry {
+	list := make([]StatsEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Plays != list[j].Plays {
+			return list[i].Plays > list[j].Plays
+		}
+		if list[i].Seconds != list[j].Seconds {
+			return list[i].Seconds > list[j].Seconds
+		}
+		return list[i].Name < list[j].Name
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// HandleStats serves the statistics over HTTP for exports:
+// /api/stats?period=week returns the aggregates as JSON, and
+// /api/stats/plays?period=year&format=csv the individual plays as JSON or CSV.
+// from and to (Unix seconds) may replace period.
+func HandleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to, err := StatsPeriod(query.Get("period"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if value, err := strconv.ParseInt(query.Get("from"), 10, 64); err == nil {
+		from = value
+	}
+	if value, err := strconv.ParseInt(query.Get("to"), 10, 64); err == nil {
+		to = value
+	}
+
+	switch r.URL.Path {
+	case "/api/stats":
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil {
+			limit = 10
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(GetStats(from, to, limit))
+	case "/api/stats/plays":
+		selected := GetPlays(from, to)
+		if query.Get("format") != "csv" {
+			w.Header().Set("Content-Type", "application/json")
+			if selected == nil {
+				selected = []Play{}
+			}
+			json.NewEncoder(w).Encode(selected)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="blitz-plays.csv"`)
+		out := csv.NewWriter(w)
+		out.Write([]string{"started_at", "title", "artist", "album", "player", "seconds"})
+		for _, play := range selected {
+			out.Write([]string{
+				time.Unix(play.StartedAt, 0).Format(time.RFC3339),
+				play.Title, play.Artist, play.Album, play.Player,
+				strconv.Itoa(play.Seconds),
+			})
+		}
+		out.Flush()
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
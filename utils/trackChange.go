@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Reasons reported in a TrackChange
+const (
+	TrackChangeStarted       = "started"        // Nothing was playing before
+	TrackChangeStopped       = "stopped"        // The player went away
+	TrackChangeFinished      = "finished"       // The previous track played to its end
+	TrackChangeNext          = "next"           // A next command was sent through Blitz
+	TrackChangePrevious      = "previous"       // A previous command was sent through Blitz
+	TrackChangeSkipped       = "skipped"        // Changed from somewhere else mid-track
+	TrackChangePlayerChanged = "player_changed" // A different player became active
+)
+
+// trackEndTolerance is how close to the end a track must have been to count as finished
+const trackEndTolerance = 5 * time.Second
+
+// playerActionWindow is how long a next/previous command explains a track change
+const playerActionWindow = 5 * time.Second
+
+// TrackSummary identifies a track without the heavy parts of MediaInfo (artwork)
+type TrackSummary struct {
+	Title   string `json:"title"`
+	Artist  string `json:"artist"`
+	Album   string `json:"album"`
+	Player  string `json:"player"`
+	TrackID string `json:"trackId,omitempty"`
+	// Artists lists every artist of a multi-artist track once normalized
+	Artists []string `json:"artists,omitempty"`
+}
+
+// TrackChange is emitted exactly once per transition between two tracks
+type TrackChange struct {
+	Previous  *TrackSummary `json:"previous"`
+	Current   *TrackSummary `json:"current"`
+	Reason    string        `json:"reason"`
+	Timestamp int64         `json:"timestamp"`
+}
+
+// Topic implements models.Payload
+func (TrackChange) Topic() string { return "track_changed" }
+
+// Version implements models.Payload
+func (TrackChange) Version() int { return 1 }
+
+var (
+	lastTrack          *TrackSummary
+	lastTrackPosition  int64 // microseconds
+	lastTrackLength    int64 // microseconds
+	lastPlayerAction   string
+	lastPlayerActionAt time.Time
+	trackChangeMu      sync.Mutex
+)
+
+// RecordPlayerAction remembers a playback command so the next track change can be attributed to it
+func RecordPlayerAction(action string) {
+	trackChangeMu.Lock()
+	defer trackChangeMu.Unlock()
+	lastPlayerAction = action
+	lastPlayerActionAt = time.Now()
+}
+
+// DetectTrackChange compares a media snapshot with the previous one and returns
+// the transition, or nil when the same track is still playing
+func DetectTrackChange(info MediaInfo) *TrackChange {
+	trackChangeMu.Lock()
+	defer trackChangeMu.Unlock()
+
+	var current *TrackSummary
+	if info.Player != "" && (info.Title != "" || info.TrackID != "") {
+		current = &TrackSummary{
+			Title:   info.Title,
+			Artist:  info.Artist,
+			Album:   info.Album,
+			Player:  info.Player,
+			TrackID: info.TrackID,
+		}
+	}
+
+	previous := lastTrack
+	previousPosition, previousLength := lastTrackPosition, lastTrackLength
+
+	lastTrack = current
+	lastTrackPosition, _ = strconv.ParseInt(info.Position, 10, 64)
+	lastTrackLength, _ = strconv.ParseInt(info.Length, 10, 64)
+
+	if sameTrack(previous, current) {
+		return nil
+	}
+
+	change := &TrackChange{
+		Previous:  previous,
+		Current:   current,
+		Timestamp: time.Now().Unix(),
+	}
+
+	switch {
+	case previous == nil:
+		change.Reason = TrackChangeStarted
+	case current == nil:
+		change.Reason = TrackChangeStopped
+	case previous.Player != current.Player:
+		change.Reason = TrackChangePlayerChanged
+	case time.Since(lastPlayerActionAt) < playerActionWindow && (lastPlayerAction == "next" || lastPlayerAction == "previous"):
+		change.Reason = lastPlayerAction
+	case previousLength > 0 && previousLength-previousPosition <= trackEndTolerance.Microseconds():
+		change.Reason = TrackChangeFinished
+	default:
+		change.Reason = TrackChangeSkipped
+	}
+
+	// A command explains only one change
+	lastPlayerAction = ""
+
+	return change
+}
+
+func sameTrack(a, b *TrackSummary) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Player != b.Player {
+		return false
+	}
+	if a.TrackID != "" || b.TrackID != "" {
+		return a.TrackID == b.TrackID
+	}
+	return a.Title == b.Title && a.Artist == b.Artist && a.Album == b.Album
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrackDetails holds extra Spotify data attached to the media broadcast
+type TrackDetails struct {
+	BPM          float64 `json:"bpm,omitempty"`
+	Energy       float64 `json:"energy,omitempty"`
+	Danceability float64 `json:"danceability,omitempty"`
+	Valence      float64 `json:"valence,omitempty"`
+	ReleaseYear  int     `json:"releaseYear,omitempty"`
+	Popularity   int     `json:"popularity,omitempty"`
+}
+
+const (
+	// maxTrackDetails bounds how many tracks' details are remembered
+	maxTrackDetails = 1000
+	// trackDetailsRetry is how long a failed lookup is remembered, so the
+	// poller doesn't ask Spotify again every second
+	trackDetailsRetry = 5 * time.Minute
+)
+
+var trackDetailsCache = newLookupCache[*TrackDetails](maxTrackDetails, trackDetailsRetry)
+
+// SpotifyTrackID extracts the Spotify track ID from an MPRIS track id
+// ("spotify:track:<id>" or "/com/spotify/track/<id>"), or "" for other players
+func SpotifyTrackID(mprisTrackID string) string {
+	switch {
+	case strings.HasPrefix(mprisTrackID, "spotify:track:"):
+		return strings.TrimPrefix(mprisTrackID, "spotify:track:")
+	case strings.HasPrefix(mprisTrackID, "/com/spotify/track/"):
+		return strings.TrimPrefix(mprisTrackID, "/com/spotify/track/")
+	}
+	return ""
+}
+
+// GetTrackDetails returns the Spotify details for a track, fetched once per track.
+// It returns nil when Spotify is not connected, the track is unknown or the
+// lookup failed, which is retried after trackDetailsRetry.
+func GetTrackDetails(trackID string) *TrackDetails {
+	client := GetSpotifyClient()
+	if trackID == "" || client == nil || !client.IsAuthenticated() {
+		return nil
+	}
+
+	if details, ok := trackDetailsCache.get(trackID); ok {
+		return details
+	}
+
+	track, err := client.GetTrack(trackID)
+	if err != nil {
+		log.Println("Failed to get Spotify track details:", err)
+		trackDetailsCache.put(trackID, nil, false)
+		return nil
+	}
+
+	details := &TrackDetails{
+		Popularity:  track.Popularity,
+		ReleaseYear: releaseYear(track.ReleaseDate),
+	}
+
+	// Audio features are optional: Spotify restricts them for some apps
+	if features, err := client.GetAudioFeatures(trackID); err == nil {
+		details.BPM = features.Tempo
+		details.Energy = features.Energy
+		details.Danceability = features.Danceability
+		details.Valence = features.Valence
+	} else {
+		log.Println("Failed to get Spotify audio features:", err)
+	}
+
+	trackDetailsCache.put(trackID, details, true)
+	return details
+}
+
+// releaseYear parses the year from a Spotify release date ("2011", "2011-05" or "2011-05-17")
+func releaseYear(releaseDate string) int {
+	if len(releaseDate) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(releaseDate[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
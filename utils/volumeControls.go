@@ -1,5 +1,126 @@
 package utils
 
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fadeStep is the interval between volume changes during a fade
+const fadeStep = 50 * time.Millisecond
+
+var (
+	activeFades = map[string]chan struct{}{}
+	fadesMu     sync.Mutex
+)
+
 func VolumeControl() {
 
 }
+
+// GetStreamVolume returns the PipeWire stream volume of a player in percent
+func GetStreamVolume(player string) (int, error) {
+	inputs, err := playerSinkInputs(player)
+	if err != nil {
+		return 0, err
+	}
+	if len(inputs) == 0 {
+		return 0, fmt.Errorf("%s has no audio stream", player)
+	}
+
+	// Channels are kept in sync by the fades, the first one is representative
+	for _, channel := range inputs[0].Volume {
+		return strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(channel.ValuePercent), "%"))
+	}
+	return 0, fmt.Errorf("%s stream has no volume", player)
+}
+
+// SetStreamVolume sets the PipeWire stream volume of a player in percent.
+// Only the player's stream changes, so notification sounds are unaffected.
+func SetStreamVolume(player string, percent int) error {
+	if percent < 0 || percent > 150 {
+		return models.NewParamError("volume", i18n.T("volume must be between 0 and 150"))
+	}
+
+	inputs, err := playerSinkInputs(player)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("%s has no audio stream", player)
+	}
+
+	for _, input := range inputs {
+		if _, err := SpawnProcess("pactl", []string{"set-sink-input-volume", strconv.Itoa(input.Index), strconv.Itoa(percent) + "%"}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FadeTo smoothly changes a player's stream volume to target over duration.
+// The fade runs in the background and replaces any fade already running for
+// the player; done (if not nil) is closed when it finishes or is replaced.
+func FadeTo(player string, target int, duration time.Duration, done chan<- struct{}) (int, error) {
+	if target < 0 || target > 150 {
+		return 0, models.NewParamError("volume", i18n.T("volume must be between 0 and 150"))
+	}
+
+	start, err := GetStreamVolume(player)
+	if err != nil {
+		return 0, err
+	}
+
+	cancel := make(chan struct{})
+	fadesMu.Lock()
+	if previous, ok := activeFades[player]; ok {
+		close(previous)
+	}
+	activeFades[player] = cancel
+	fadesMu.Unlock()
+
+	go func() {
+		if done != nil {
+			defer close(done)
+		}
+		defer func() {
+			fadesMu.Lock()
+			if activeFades[player] == cancel {
+				delete(activeFades, player)
+			}
+			fadesMu.Unlock()
+		}()
+
+		steps := int(duration / fadeStep)
+		if steps < 1 {
+			steps = 1
+		}
+
+		ticker := time.NewTicker(fadeStep)
+		defer ticker.Stop()
+
+		last := start
+		for i := 1; i <= steps; i++ {
+			select {
+			case <-cancel:
+				return
+			case <-ticker.C:
+			}
+
+			level := start + (target-start)*i/steps
+			if level == last {
+				continue
+			}
+			if err := SetStreamVolume(player, level); err != nil {
+				return
+			}
+			last = level
+		}
+	}()
+
+	return start, nil
+}
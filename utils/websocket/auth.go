@@ -0,0 +1,200 @@
+package websocket
+
+import (
+	"Blitz/config"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Scopes a client can be granted
+const (
+	ScopeControl = "control" // Run commands
+	ScopeAdmin   = "admin"   // Manage device tokens
+	ScopeGuest   = "guest"   // Watch, and run only the commands in guest.commands
+	ScopeRead    = "read"    // Watch only, e.g. a now-playing view shared with guests
+	ScopePointer = "pointer" // Move the mouse pointer, click and type on the host
+)
+
+// knownScopes are the scopes device tokens may be issued with
+var knownScopes = []string{ScopeControl, ScopeAdmin, ScopeGuest, ScopeRead, ScopePointer}
+
+// privateTopics are broadcast only to clients with the scope they map to, and
+// only when the client subscribed to them by name, as they are heavy and
+// show more than the player does
+var privateTopics = map[string]string{
+	"screen": ScopeControl,
+}
+
+// Grant is what an authenticated client may do
+type Grant struct {
+	Device string // ID of the device token used, empty for the shared token
+	Scopes []string
+}
+
+// fullGrant is held by clients using the shared token, and by everyone when
+// no token is configured
+func fullGrant() *Grant {
+	return &Grant{Scopes: []string{ScopeControl, ScopeAdmin, ScopePointer}}
+}
+
+// Allows reports whether the grant includes scope
+func (g *Grant) Allows(scope string) bool {
+	return g != nil && slices.Contains(g.Scopes, scope)
+}
+
+// CanRun reports whether the grant lets a client run command. Guests may run
+// the control commands the config whitelists, e.g. on a wall-mounted tablet;
+// read-only clients only the open ones, such as ping and subscribe.
+func (g *Grant) CanRun(command string) bool {
+	scope := commandScope(command)
+	if scope == "" || g.Allows(scope) {
+		return true
+	}
+	if scope != ScopeControl || !g.Allows(ScopeGuest) {
+		return false
+	}
+	// Aliases are allowed along with the command they name
+	if entry, ok := lookupCommand(command); ok {
+		command = entry.Name
+	}
+	return slices.Contains(config.Get().Guest.Commands, command)
+}
+
+// authToken is the shared secret clients authenticate with. Without it,
+// direct connections are trusted (the LAN-only default).
+func authToken() string {
+	return os.Getenv("BLITZ_TOKEN")
+}
+
+// RequestGrant authenticates an HTTP request by the token it carries, as
+// "Authorization: Bearer <token>" or a ?token= query parameter. The token is
+// the shared one or a device token. Returns nil when it is neither.
+func RequestGrant(r *http.Request) *Grant {
+	token := authToken()
+	if token == "" {
+		return fullGrant()
+	}
+
+	presented := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		presented = bearer
+	}
+	return tokenGrant(presented)
+}
+
+// RequireScope wraps an HTTP handler so it only serves requests whose token
+// grants scope, and that no other web page sent
+func RequireScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if refuseCrossSite(w, r) {
+			return
+		}
+		if !RequestGrant(r).Allows(scope) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// tokenGrant returns the grant of a plain token
+func tokenGrant(presented string) *Grant {
+	if presented == "" {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(authToken())) == 1 {
+		return fullGrant()
+	}
+	return deviceGrant(presented)
+}
+
+// newNonce returns a random challenge for in-band authentication
+func newNonce() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// verifyAuth checks an in-band auth message and returns the grant it earns, or
+// nil. Clients prove they know the token with
+// hmac = hex(HMAC-SHA256(token, nonce)), so the token itself never passes
+// through a relay; device tokens add "device": <device id>. Direct
+// connections may also send the plain token.
+func verifyAuth(client *Client, msg map[string]interface{}) *Grant {
+	if authToken() == "" {
+		return nil
+	}
+
+	if proof, ok := msg["hmac"].(string); ok {
+		token := authToken()
+		grant := fullGrant()
+		if id, _ := msg["device"].(string); id != "" {
+			token, grant = deviceSecret(id)
+			if token == "" {
+				return nil
+			}
+		}
+
+		mac := hmac.New(sha256.New, []byte(token))
+		mac.Write([]byte(client.nonce))
+		expected := hex.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(strings.ToLower(proof)), []byte(expected)) {
+			return nil
+		}
+		return grant
+	}
+
+	if plain, ok := msg["token"].(string); ok && !client.relayed {
+		return tokenGrant(plain)
+	}
+	return nil
+}
+
+type clientContextKey struct{}
+
+// withClient attaches the client running a command to its context
+func withClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// clientFromContext returns the client a command runs for, or nil for commands
+// Blitz runs on its own
+func clientFromContext(ctx context.Context) *Client {
+	client, _ := ctx.Value(clientContextKey{}).(*Client)
+	return client
+}
+
+type grantContextKey struct{}
+
+// withGrant attaches the grant of an HTTP request to the context of the
+// command it runs, which has no client
+func withGrant(ctx context.Context, grant *Grant) context.Context {
+	return context.WithValue(ctx, grantContextKey{}, grant)
+}
+
+// grantFromContext returns what the caller of a command may do: the grant of
+// its client or HTTP request. ok is false for commands Blitz runs on its own.
+func grantFromContext(ctx context.Context) (grant *Grant, ok bool) {
+	if client := clientFromContext(ctx); client != nil {
+		return client.grant.Load(), true
+	}
+	grant, ok = ctx.Value(grantContextKey{}).(*Grant)
+	return grant, ok
+}
+
+// commandScope is the scope a client needs to run command, empty when any
+// authenticated client may
+func commandScope(command string) string {
+	if entry, ok := lookupCommand(command); ok {
+		return entry.scope()
+	}
+	return ScopeControl
+}
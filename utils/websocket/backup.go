@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/store"
+	"Blitz/utils"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// maxImportBytes bounds an uploaded archive
+const maxImportBytes = 256 << 20
+
+// ExportState archives the config and stored state, base64 encoded so it
+// fits in a command result. Device tokens are only included with tokens set.
+func ExportState(tokens bool) (map[string]interface{}, error) {
+	var archive bytes.Buffer
+	files, err := store.Export(&archive, tokens)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"archive": base64.StdEncoding.EncodeToString(archive.Bytes()),
+		"files":   files,
+	}, nil
+}
+
+// ImportState restores an archive written by ExportState and reloads the
+// restored state. A restored config only applies after a restart, and keeps
+// its plugins only with plugins set.
+func ImportState(archive io.Reader, plugins bool) (map[string]interface{}, error) {
+	files, dropped, err := store.Import(archive, plugins)
+	if err != nil {
+		return nil, invalidParam("archive", i18n.T("archive is not a Blitz backup: %v", err))
+	}
+
+	utils.ReloadStored()
+	devicesMu.Lock()
+	devices, devicesLoaded = nil, false
+	devicesMu.Unlock()
+	variablesMu.Lock()
+	variables, variablesLoaded = nil, false
+	variablesMu.Unlock()
+
+	log.Printf("Imported %d files from an archive", len(files))
+	if dropped {
+		log.Println("⚠️ Dropped the plugins of the imported config")
+	}
+	return map[string]interface{}{
+		"files":           files,
+		"restartRequired": slices.Contains(files, "config.json"),
+		"pluginsDropped":  dropped,
+	}, nil
+}
+
+// HandleBackup serves GET /api/export, downloading the archive
+// (?tokens=1 includes the device tokens), and POST /api/import, restoring
+// one sent as the request body (?plugins=1 keeps the plugins of its config).
+// Requires the admin scope.
+func HandleBackup(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/export":
+		var archive bytes.Buffer
+		if _, err := store.Export(&archive, r.URL.Query().Get("tokens") == "1"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		name := fmt.Sprintf("blitz-%s.tar.gz", time.Now().Format(time.DateOnly))
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		w.Write(archive.Bytes())
+	case r.Method == http.MethodPost && r.URL.Path == "/api/import":
+		// Replacing the config is too much to allow other pages without a
+		// token, even those in origins.allowed
+		if authToken() == "" && !sameOrigin(r) {
+			http.Error(w, "Importing from another page needs a token", http.StatusForbidden)
+			return
+		}
+		result, err := ImportState(http.MaxBytesReader(w, r.Body, maxImportBytes), r.URL.Query().Get("plugins") == "1")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
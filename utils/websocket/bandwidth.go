@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"Blitz/config"
+	"Blitz/models"
+	"Blitz/utils"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"strings"
+)
+
+// Bandwidths a client may ask for when it says hello
+const (
+	BandwidthNormal = "normal"
+	BandwidthLow    = "low"
+)
+
+func validBandwidth(bandwidth string) bool {
+	return bandwidth == "" || bandwidth == BandwidthNormal || bandwidth == BandwidthLow
+}
+
+// setLowBandwidth switches the client in and out of low bandwidth mode,
+// which slows down the topics in lowBandwidth.rates that it set no rate for
+// and shrinks its broadcasts
+func (c *Client) setLowBandwidth(low bool) {
+	if c.lowBandwidth.Swap(low) == low {
+		return
+	}
+	c.throttleMu.Lock()
+	requested := c.requestedRates
+	c.throttleMu.Unlock()
+	c.setRates(requested)
+	c.resetDeltas()
+}
+
+// budgetRates adds the rates of low bandwidth mode to those the client
+// asked for, which win
+func (c *Client) budgetRates(requested map[string]float64) map[string]float64 {
+	rates := map[string]float64{}
+	if c.lowBandwidth.Load() {
+		for topic, rate := range config.Get().LowBandwidth.Rates {
+			if rate > 0 && rate <= maxTopicRate {
+				rates[topic] = rate
+			}
+		}
+	}
+	maps.Copy(rates, requested)
+	return rates
+}
+
+// budgeted shrinks a broadcast for a client in low bandwidth mode. The
+// inline artwork is dropped, as ArtworkURL lets the client download each
+// cover once, and snapshots only carry the fields that changed since the
+// last one on their topic. False means nothing changed, so nothing is sent.
+func (c *Client) budgeted(msg models.ServerResponse) (models.ServerResponse, bool) {
+	if !c.lowBandwidth.Load() || msg.Type != models.TypeEvent {
+		return msg, true
+	}
+	if media, ok := msg.Data.(utils.MediaInfo); ok && strings.HasPrefix(media.Artwork, "data:") {
+		media.Artwork = ""
+		msg.Data = media
+	}
+	return c.delta(msg)
+}
+
+// delta replaces the data of a snapshot with the top-level fields that
+// differ from the last one written to the client on its topic; removed
+// fields are null. The first snapshot after connecting or subscribing is
+// sent whole.
+func (c *Client) delta(msg models.ServerResponse) (models.ServerResponse, bool) {
+	c.deltaMu.Lock()
+	defer c.deltaMu.Unlock()
+
+	var fields map[string]any
+	data, err := json.Marshal(msg.Data)
+	if !isSnapshotTopic(msg.Topic) || err != nil || json.Unmarshal(data, &fields) != nil || fields == nil {
+		delete(c.sent, msg.Topic)
+		return msg, true
+	}
+
+	last, ok := c.sent[msg.Topic]
+	c.sent[msg.Topic] = fields
+	if !ok {
+		return msg, true
+	}
+
+	changed := map[string]any{}
+	for key, value := range fields {
+		if old, ok := last[key]; !ok || !reflect.DeepEqual(old, value) {
+			changed[key] = value
+		}
+	}
+	for key := range last {
+		if _, ok := fields[key]; !ok {
+			changed[key] = nil
+		}
+	}
+	if len(changed) == 0 {
+		return msg, false
+	}
+	msg.Data = changed
+	msg.Delta = true
+	return msg, true
+}
+
+// resetDeltas makes the next snapshot on every topic go out whole
+func (c *Client) resetDeltas() {
+	c.deltaMu.Lock()
+	c.sent = map[string]map[string]any{}
+	c.deltaMu.Unlock()
+}
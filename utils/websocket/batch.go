@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
+	"log"
+)
+
+// maxBatchCommands bounds the commands of one batch
+const maxBatchCommands = 32
+
+// BatchResult is the outcome of one command of a batch, in the order sent
+type BatchResult struct {
+	Command string           `json:"command"`
+	ID      any              `json:"id,omitempty"` // The command's own id, if it had one
+	Data    any              `json:"data,omitempty"`
+	Error   string           `json:"error,omitempty"`
+	Code    models.ErrorCode `json:"code,omitempty"`
+	Param   string           `json:"param,omitempty"`
+	Skipped bool             `json:"skipped,omitempty"` // Not run, as an earlier command failed with stopOnError
+}
+
+// RunBatch runs commands in order, e.g. the select player, set volume and
+// play of a macro button, saving the round trips in between. Each command is
+// checked and timed like one sent on its own, with the caller's scopes. A
+// failing command doesn't stop the others unless stopOnError is set.
+func RunBatch(ctx context.Context, commands []interface{}, stopOnError bool) ([]BatchResult, error) {
+	if len(commands) == 0 || len(commands) > maxBatchCommands {
+		return nil, invalidParam("commands", i18n.T("commands must list 1 to %d commands", maxBatchCommands))
+	}
+	steps := make([]map[string]interface{}, len(commands))
+	for i, raw := range commands {
+		step, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, invalidParam("commands", i18n.T("commands must be objects like {\"command\": \"play\"}"))
+		}
+		steps[i] = step
+	}
+
+	results := make([]BatchResult, len(steps))
+	failed := false
+	for i, step := range steps {
+		command, _ := step["command"].(string)
+		results[i].Command = command
+		results[i].ID = step["id"]
+		if failed && stopOnError {
+			results[i].Skipped = true
+			continue
+		}
+
+		var data any
+		var err error
+		switch command {
+		case "":
+			err = invalidParam("command", i18n.T("missing command"))
+		case "batch":
+			err = models.NewError(models.ErrForbidden, i18n.T("%s can't run in a batch", command), nil)
+		default:
+			data, err = ExecuteCommand(ctx, command, step)
+		}
+		if err != nil {
+			log.Printf("❌ Batch step %s failed: %v", command, err)
+			stepErr := CommandError(err)
+			results[i].Error = stepErr.Message
+			results[i].Code = stepErr.Code
+			results[i].Param = stepErr.Param
+			failed = true
+			continue
+		}
+		results[i].Data = data
+	}
+	return results, nil
+}
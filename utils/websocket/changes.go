@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"Blitz/models"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// unchangedResendInterval is how long an unchanged snapshot is held back at
+// most, so clients that missed the last one because they were busy catch up
+const unchangedResendInterval = 30 * time.Second
+
+// snapshot is the last message broadcast on a topic by WriteChangedMessage
+type snapshot struct {
+	msg  models.ServerResponse
+	hash [sha256.Size]byte
+	sent time.Time
+}
+
+var (
+	snapshots   = map[string]snapshot{}
+	snapshotsMu sync.Mutex
+)
+
+// WriteChangedMessage is WriteChannelMessage for snapshots that are polled
+// on a timer. It drops msg when its data is the same as the last one on its
+// topic, so e.g. a paused track doesn't resend its artwork every second.
+func WriteChangedMessage(msg models.ServerResponse) {
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		WriteChannelMessage(msg)
+		return
+	}
+	hash := sha256.Sum256(data)
+
+	snapshotsMu.Lock()
+	last, ok := snapshots[msg.Topic]
+	if ok && last.hash == hash && time.Since(last.sent) < unchangedResendInterval {
+		snapshotsMu.Unlock()
+		return
+	}
+	snapshots[msg.Topic] = snapshot{msg: msg, hash: hash, sent: time.Now()}
+	snapshotsMu.Unlock()
+
+	WriteChannelMessage(msg)
+}
+
+// replaySnapshots sends a client that just authenticated or subscribed the
+// latest snapshot of each of its topics, which it would otherwise only get
+// with the next change
+func replaySnapshots(client *Client) {
+	snapshotsMu.Lock()
+	replay := []models.ServerResponse{}
+	for topic, last := range snapshots {
+		if client.subscribed(topic) {
+			replay = append(replay, last.msg)
+		}
+	}
+	snapshotsMu.Unlock()
+
+	for _, msg := range replay {
+		client.push(msg)
+	}
+}
+
+// isSnapshotTopic reports whether topic carries snapshots, which replace
+// the one before rather than add to it
+func isSnapshotTopic(topic string) bool {
+	snapshotsMu.Lock()
+	defer snapshotsMu.Unlock()
+	_, ok := snapshots[topic]
+	return ok
+}
+
+// ForgetSnapshot drops the last snapshot of topic, so clients that subscribe
+// later aren't sent what is no longer meant to be seen
+func ForgetSnapshot(topic string) {
+	snapshotsMu.Lock()
+	delete(snapshots, topic)
+	snapshotsMu.Unlock()
+}
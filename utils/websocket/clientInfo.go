@@ -0,0 +1,207 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxHelloLength bounds each field a client describes itself with
+	maxHelloLength = 64
+	// maxKickMinutes bounds how long a kicked client is told to stay away
+	maxKickMinutes = 24 * 60
+)
+
+// ClientHello is how a client describes itself, so people can tell their
+// devices apart in list_clients. Sent as ?name=...&deviceType=...&appVersion=...
+// when connecting, or with the hello command by clients that can't.
+type ClientHello struct {
+	Name       string `json:"name,omitempty"`       // e.g. "Kitchen tablet"
+	DeviceType string `json:"deviceType,omitempty"` // e.g. "tablet", "phone", "esp32"
+	AppVersion string `json:"appVersion,omitempty"` // e.g. "blitz-android 1.4.0"
+	// Bandwidth is BandwidthLow for clients saving data; empty means normal
+	Bandwidth string `json:"bandwidth,omitempty"`
+}
+
+// ClientInfo describes a connected client
+type ClientInfo struct {
+	ID            string `json:"id"`
+	Address       string `json:"address"`
+	Transport     string `json:"transport"` // "websocket", "tcp" or "relay"
+	ConnectedAt   int64  `json:"connectedAt"`
+	Authenticated bool   `json:"authenticated"`
+	// Token is the ID of the device token the client authenticated with,
+	// empty for the shared token
+	Token    string   `json:"token,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Topics   []string `json:"topics,omitempty"` // Subscribed topics; empty means all
+	Encoding string   `json:"encoding"`
+	// Queued is how many broadcasts wait to be written to the client; a full
+	// queue (16) means it doesn't keep up
+	Queued       int   `json:"queued"`
+	LastActivity int64 `json:"lastActivity"` // Unix seconds the client last sent a message
+	// Rates are the broadcasts per second the client limited topics to,
+	// including those of low bandwidth mode
+	Rates map[string]float64 `json:"rates,omitempty"`
+	ClientHello
+}
+
+// helloFromQuery reads a client's description from its connection URL
+func helloFromQuery(query url.Values) ClientHello {
+	hello := newClientHello(query.Get("name"), query.Get("deviceType"), query.Get("appVersion"))
+	if bandwidth := query.Get("bandwidth"); validBandwidth(bandwidth) {
+		hello.Bandwidth = bandwidth
+	} else {
+		log.Println("Ignoring unknown bandwidth:", bandwidth)
+	}
+	return hello
+}
+
+func newClientHello(name, deviceType, appVersion string) ClientHello {
+	return ClientHello{
+		Name:       clipHello(name),
+		DeviceType: clipHello(deviceType),
+		AppVersion: clipHello(appVersion),
+	}
+}
+
+func clipHello(value string) string {
+	value = strings.TrimSpace(value)
+	if runes := []rune(value); len(runes) > maxHelloLength {
+		value = string(runes[:maxHelloLength])
+	}
+	return value
+}
+
+// String names the client in the log
+func (h ClientHello) String() string {
+	parts := slices.DeleteFunc([]string{h.Name, h.DeviceType, h.AppVersion}, func(part string) bool { return part == "" })
+	return strings.Join(parts, ", ")
+}
+
+// setHello records how the client describes itself
+func (c *Client) setHello(hello ClientHello) {
+	c.hello.Store(&hello)
+	c.setLowBandwidth(hello.Bandwidth == BandwidthLow)
+	if described := hello.String(); described != "" {
+		log.Printf("👋 Client %s is %s", c.ID, described)
+	}
+}
+
+// info describes the client for list_clients
+func (c *Client) info() ClientInfo {
+	info := ClientInfo{
+		ID:           c.ID,
+		Address:      c.Conn.RemoteAddr().String(),
+		Transport:    "websocket",
+		ConnectedAt:  c.connectedAt.Unix(),
+		Encoding:     EncodingJSON,
+		Queued:       c.Send.len(),
+		LastActivity: c.lastActivity.Load(),
+	}
+	switch {
+	case c.relayed:
+		info.Transport = "relay"
+	case isLineTransport(c.Conn):
+		info.Transport = "tcp"
+	}
+	if grant := c.grant.Load(); grant != nil {
+		info.Authenticated = true
+		info.Token = grant.Device
+		info.Scopes = slices.Clone(grant.Scopes)
+	}
+	if topics := c.topics.Load(); topics != nil {
+		info.Topics = slices.Clone(*topics)
+	}
+	if rates := c.rates(); len(rates) > 0 {
+		info.Rates = rates
+	}
+	if c.msgpack != nil {
+		info.Encoding = EncodingMsgpack
+	}
+	if hello := c.hello.Load(); hello != nil {
+		info.ClientHello = *hello
+	}
+	return info
+}
+
+func isLineTransport(conn Transport) bool {
+	_, ok := conn.(*lineTransport)
+	return ok
+}
+
+// ListClients returns the connected clients, longest connected first
+func ListClients() []ClientInfo {
+	clientsMu.RLock()
+	list := make([]ClientInfo, 0, len(clients))
+	for _, client := range clients {
+		list = append(list, client.info())
+	}
+	clientsMu.RUnlock()
+
+	slices.SortFunc(list, func(a, b ClientInfo) int {
+		if a.ConnectedAt != b.ConnectedAt {
+			return int(a.ConnectedAt - b.ConnectedAt)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return list
+}
+
+// KickClient disconnects a client, e.g. an old kiosk build that misbehaves,
+// telling it to stay away for minutes; 0 gives no hint
+func KickClient(id string, minutes int) error {
+	if minutes < 0 || minutes > maxKickMinutes {
+		return invalidParam("minutes", i18n.T("minutes must be between 0 and %d", maxKickMinutes))
+	}
+
+	clientsMu.RLock()
+	client, ok := clients[id]
+	clientsMu.RUnlock()
+	if !ok {
+		return invalidParam("id", i18n.T("no client %s", id))
+	}
+
+	log.Println("🥾 Kicking client", id)
+	err := models.NewError(models.ErrForbidden, i18n.T("disconnected by an admin"), nil)
+	err.RetryAfter = int((time.Duration(minutes) * time.Minute).Seconds())
+	closeWithRetry(client.Conn, client.WriteJSON, websocket.ClosePolicyViolation, err)
+	client.disconnect()
+	return nil
+}
+
+// HandleClients serves the client API: GET /api/clients lists the connected
+// clients and DELETE /api/clients/<id>?minutes=... kicks one
+func HandleClients(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/clients"), "/")
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ListClients())
+	case r.Method == http.MethodDelete && id != "":
+		minutes := 0
+		if raw := r.URL.Query().Get("minutes"); raw != "" {
+			if _, err := fmt.Sscan(raw, &minutes); err != nil {
+				http.Error(w, "minutes must be an integer", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := KickClient(id, minutes); err != nil {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,460 @@
+package websocket
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// clientWorkers is how many commands of one client run at the same time
+	clientWorkers = 4
+	// clientQueueSize is how many commands may wait for a worker before the
+	// reader stops accepting new messages from that client
+	clientQueueSize = 32
+	// clientSendBuffer lets back-to-back broadcasts (e.g. latency and
+	// latency_alert) queue up instead of being dropped; snapshots replace
+	// the one waiting on their topic
+	clientSendBuffer = 16
+	// retryAfterFull and retryAfterShutdown are the least clients turned away
+	// are told to wait before reconnecting, when Blitz is full or restarting
+	retryAfterFull     = 30 * time.Second
+	retryAfterShutdown = 5 * time.Second
+	// shutdownFlushTimeout bounds waiting for clients to take their queued
+	// broadcasts before they are disconnected on shutdown
+	shutdownFlushTimeout = 2 * time.Second
+	// retryAfterMaintenance is how long commands rejected during maintenance
+	// wait when the admin gave no estimate
+	retryAfterMaintenance = time.Minute
+)
+
+// Client is a connected client, over a WebSocket or the TCP line protocol
+type Client struct {
+	ID   string
+	Conn Transport
+	Send *sendQueue
+
+	jobs    chan func()
+	writeMu sync.Mutex
+	// done is closed when the connection failed or the client was
+	// unregistered; queued commands are dropped from then on
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// grant is set once the client authenticated; only then does it receive
+	// broadcasts and may run commands
+	grant atomic.Pointer[Grant]
+	// relayed clients reached us through the remote relay
+	relayed bool
+	// topics limits the broadcasts the client receives; nil means all
+	topics atomic.Pointer[[]string]
+	// throttles limit the broadcasts per second on some topics, the ones
+	// the client asked for in requestedRates and those of low bandwidth mode
+	throttleMu     sync.Mutex
+	throttles      map[string]*topicThrottle
+	requestedRates map[string]float64
+	// lowBandwidth shrinks the broadcasts, which then only carry what
+	// changed since the last one in sent
+	lowBandwidth atomic.Bool
+	deltaMu      sync.Mutex
+	sent         map[string]map[string]any
+	// format adds formatted fields to the messages; nil means raw values only
+	format atomic.Pointer[utils.Formatter]
+	// msgpack sends the messages as MessagePack in binary frames; nil means JSON
+	msgpack binaryTransport
+	// nonce is the challenge for in-band authentication
+	nonce string
+	// hello is how the client described itself; nil until it did
+	hello       atomic.Pointer[ClientHello]
+	connectedAt time.Time
+	// lastActivity is when the client last sent a message, in Unix seconds
+	lastActivity atomic.Int64
+
+	// stopLogs ends the log stream started by logs_tail with follow
+	logsMu   sync.Mutex
+	stopLogs func()
+}
+
+var (
+	clients   = map[string]*Client{}
+	clientsMu sync.RWMutex
+	// broadcastSeq numbers the broadcasts
+	broadcastSeq atomic.Uint64
+)
+
+// Encodings of the messages to a client
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+)
+
+// ConnOptions are what a client asked for when connecting
+type ConnOptions struct {
+	Topics   []string         // Only receive broadcasts on these topics; empty means all
+	Format   *utils.Formatter // Add formatted fields to the messages
+	Encoding string           // EncodingJSON (default) or EncodingMsgpack
+	Hello    ClientHello      // How the client describes itself
+	// Rates limits the broadcasts per second on some topics, e.g. 1 for
+	// media_info on a watch
+	Rates map[string]float64
+}
+
+// RegisterClient adds a new connection to the client registry. Clients without
+// a grant get no broadcasts until they send an auth message.
+func RegisterClient(conn Transport, grant *Grant, relayed bool, options ConnOptions) *Client {
+	client := &Client{
+		ID:          fmt.Sprintf("%s-%d", conn.RemoteAddr(), time.Now().UnixNano()),
+		Conn:        conn,
+		Send:        newSendQueue(),
+		jobs:        make(chan func(), clientQueueSize),
+		done:        make(chan struct{}),
+		relayed:     relayed,
+		nonce:       newNonce(),
+		connectedAt: time.Now(),
+	}
+	client.lastActivity.Store(client.connectedAt.Unix())
+	client.grant.Store(grant)
+	client.lowBandwidth.Store(options.Hello.Bandwidth == BandwidthLow)
+	client.subscribe(options.Topics)
+	client.setRates(options.Rates)
+	client.format.Store(options.Format)
+	if options.Encoding == EncodingMsgpack {
+		if transport, ok := conn.(binaryTransport); ok {
+			client.msgpack = transport
+		} else {
+			log.Println("MessagePack is not available on this connection, sending JSON to", client.ID)
+		}
+	}
+
+	for i := 0; i < clientWorkers; i++ {
+		go client.worker()
+	}
+
+	clientsMu.Lock()
+	clients[client.ID] = client
+	clientsMu.Unlock()
+
+	log.Println("Client registered:", client.ID)
+	if options.Hello != (ClientHello{}) {
+		client.setHello(options.Hello)
+	}
+	return client
+}
+
+// admitClient turns a new connection away with a hint when the configured
+// number of clients is reached
+func admitClient(conn Transport) bool {
+	clientsMu.RLock()
+	count := len(clients)
+	clientsMu.RUnlock()
+
+	limit := config.Get().MaxClients
+	if limit <= 0 || count < limit {
+		return true
+	}
+
+	log.Println("Too many clients, turning away", conn.RemoteAddr())
+	err := models.NewError(models.ErrUnavailable, i18n.T("too many clients are connected"), nil)
+	err.RetryAfter = retryHint(retryAfterFull)
+	closeWithRetry(conn, func(v any) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(data)
+	}, websocket.CloseTryAgainLater, err)
+	return false
+}
+
+// DisconnectAll closes every client with a hint to reconnect once Blitz is
+// back, so restarting doesn't leave them retrying in a tight loop. The
+// broadcasts queued for a client are delivered first, for up to
+// shutdownFlushTimeout.
+func DisconnectAll() {
+	clientsMu.RLock()
+	all := slices.Collect(maps.Values(clients))
+	clientsMu.RUnlock()
+
+	deadline := time.Now().Add(shutdownFlushTimeout)
+	var wg sync.WaitGroup
+	for _, client := range all {
+		wg.Go(func() {
+			client.flush(deadline)
+			err := models.NewError(models.ErrUnavailable, i18n.T("Blitz is shutting down"), nil)
+			err.RetryAfter = retryHint(retryAfterShutdown)
+			closeWithRetry(client.Conn, client.WriteJSON, websocket.CloseServiceRestart, err)
+		})
+	}
+	wg.Wait()
+}
+
+// flush waits until the writer took the queued broadcasts, or the deadline.
+// The last one may still be written; writes after it wait for it to finish.
+func (c *Client) flush(deadline time.Time) {
+	for c.Send.len() > 0 && !c.closed() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// retryHint spreads the reconnects of clients turned away together over
+// base to 1.5 × base, in seconds
+func retryHint(base time.Duration) int {
+	return int((base + rand.N(base/2)).Round(time.Second).Seconds())
+}
+
+// closeWithRetry sends err as the last message and closes the connection,
+// repeating the hint in the close reason for clients that only see that
+func closeWithRetry(conn Transport, write func(any) error, code int, err *models.Error) {
+	if writeErr := write(models.NewErrorResponse("", err, nil)); writeErr != nil {
+		log.Println("Failed to send disconnect reason:", writeErr)
+	}
+	conn.CloseWith(code, fmt.Sprintf(`{"retry_after":%d}`, err.RetryAfter))
+}
+
+// UnregisterClient removes a client from the registry and stops its writer and workers
+func UnregisterClient(client *Client) {
+	client.disconnect()
+
+	clientsMu.Lock()
+	if _, ok := clients[client.ID]; ok {
+		delete(clients, client.ID)
+		client.Send.close()
+		close(client.jobs)
+	}
+	clientsMu.Unlock()
+
+	client.followLogs(false)
+	utils.RemoveBrowserPlayers(client.ID)
+	log.Println("Client unregistered:", client.ID)
+}
+
+// goroutineExcess is how many more client goroutines than connected clients
+// need were running at the last check
+var goroutineExcess int64
+
+// CheckGoroutines logs the goroutines and warns when more of them run than
+// the connected clients need. Goroutines finishing a command for a client
+// that just left are normal, so only an excess that stays until the next
+// check is reported as a leak.
+func CheckGoroutines() {
+	clientsMu.RLock()
+	count := int64(len(clients))
+	clientsMu.RUnlock()
+
+	status := utils.GetGoroutineStatus()
+	log.Printf("🔎 %d clients, %d goroutines, tracked: %v", count, status.Total, status.Tracked)
+
+	excess := max(status.Tracked[utils.GoroutineClientWorker]-count*clientWorkers, 0) +
+		max(status.Tracked[utils.GoroutineClientWriter]-count, 0) +
+		max(status.Tracked[utils.GoroutineClientPinger]-count, 0)
+	if excess > 0 && goroutineExcess > 0 {
+		log.Printf("⚠️ %d client goroutines outlived their clients", min(excess, goroutineExcess))
+	}
+	goroutineExcess = excess
+}
+
+// BroadcastMessage sends a message to every connected client without
+// blocking, at the rates the clients asked for
+func BroadcastMessage(msg models.ServerResponse) {
+	msg.Seq = broadcastSeq.Add(1)
+	msg.TS = time.Now().UnixMilli()
+
+	clientsMu.RLock()
+	defer clientsMu.RUnlock()
+
+	for _, client := range clients {
+		if !client.isAuthenticated() || !client.subscribed(msg.Topic) {
+			continue
+		}
+		client.deliver(msg)
+	}
+}
+
+// push queues a message for this client alone without blocking. Unlike
+// BroadcastMessage it drops silently, as it also carries the log stream.
+func (c *Client) push(msg models.ServerResponse) {
+	msg.TS = time.Now().UnixMilli()
+	c.Send.put(msg, isSnapshotTopic(msg.Topic))
+}
+
+// StartBroadcaster forwards everything written to the shared channel to all
+// clients, and the important messages to the push gateway
+func StartBroadcaster() {
+	for msg := range CreateChannel() {
+		BroadcastMessage(msg)
+		utils.PushEvent(msg)
+	}
+}
+
+// WriteJSON writes a message to the client as JSON, or as MessagePack when
+// it asked for that, serialized with other writers
+func (c *Client) WriteJSON(v any) error {
+	encode := json.Marshal
+	if c.msgpack != nil {
+		encode = models.MarshalMsgpack
+	}
+	data, err := encode(v)
+	if err != nil {
+		return err
+	}
+	return c.write(data)
+}
+
+// WriteMessage writes a raw JSON message to the client, re-encoded for
+// clients that asked for MessagePack
+func (c *Client) WriteMessage(data []byte) error {
+	if c.msgpack != nil {
+		packed, err := models.MsgpackFromJSON(data)
+		if err != nil {
+			return err
+		}
+		data = packed
+	}
+	return c.write(data)
+}
+
+// write sends an encoded message, serialized with other writers. A failed
+// write disconnects the client: the connection is broken, or the client
+// stopped reading for writeTimeout.
+func (c *Client) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	var err error
+	if c.msgpack != nil {
+		err = c.msgpack.WriteBinary(data)
+	} else {
+		err = c.Conn.WriteMessage(data)
+	}
+	if err != nil {
+		c.disconnect()
+	}
+	return err
+}
+
+// disconnect closes the connection, which ends the client's reader, and
+// stops its queued commands from running
+func (c *Client) disconnect() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.Conn.Close()
+	})
+}
+
+// closed reports whether the client was disconnected
+func (c *Client) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// writePump delivers broadcasts queued on Send until it is closed
+func (c *Client) writePump() {
+	defer utils.TrackGoroutine(utils.GoroutineClientWriter)()
+	for {
+		msg, ok := c.Send.next()
+		if !ok {
+			return
+		}
+		if c.closed() {
+			continue
+		}
+		msg, ok = c.budgeted(c.formatted(msg))
+		if !ok {
+			continue
+		}
+		if err := c.WriteJSON(msg); err != nil {
+			log.Println("Error writing broadcast to", c.ID, ":", err)
+		}
+	}
+}
+
+// Dispatch queues a command for the client's workers, so a slow command
+// doesn't hold up the messages after it. Blocks while the queue is full,
+// unless the client disconnects meanwhile.
+func (c *Client) Dispatch(job func()) {
+	select {
+	case c.jobs <- job:
+	case <-c.done:
+	}
+}
+
+// worker runs queued commands until the client is unregistered. Commands
+// still queued when the client disconnects are dropped.
+func (c *Client) worker() {
+	defer utils.TrackGoroutine(utils.GoroutineClientWorker)()
+	for job := range c.jobs {
+		if !c.closed() {
+			job()
+		}
+	}
+}
+
+// subscribe limits the broadcasts the client receives to topics, or lifts
+// the limit when topics is empty
+func (c *Client) subscribe(topics []string) {
+	c.resetDeltas()
+	if len(topics) == 0 {
+		c.topics.Store(nil)
+		return
+	}
+	c.topics.Store(&topics)
+}
+
+// subscribed reports whether the client receives broadcasts on topic
+func (c *Client) subscribed(topic string) bool {
+	topics := c.topics.Load()
+	if scope, ok := privateTopics[topic]; ok {
+		return topics != nil && slices.Contains(*topics, topic) && c.grant.Load().Allows(scope)
+	}
+	return topics == nil || slices.Contains(*topics, topic)
+}
+
+// Watched reports whether an authenticated client receives broadcasts on
+// topic, so pollers can skip work nobody would see
+func Watched(topic string) bool {
+	clientsMu.RLock()
+	defer clientsMu.RUnlock()
+	for _, client := range clients {
+		if client.isAuthenticated() && client.subscribed(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatted adds the formatting the client asked for to a message whose
+// data supports it
+func (c *Client) formatted(msg models.ServerResponse) models.ServerResponse {
+	format := c.format.Load()
+	if format == nil {
+		return msg
+	}
+	if payload, ok := msg.Data.(utils.Formattable); ok {
+		msg.Formatted = payload.Formatted(*format)
+	}
+	return msg
+}
+
+func (c *Client) isAuthenticated() bool {
+	return c.grant.Load() != nil
+}
+
+func (c *Client) setGrant(grant *Grant) {
+	c.grant.Store(grant)
+	touchDevice(grant.Device)
+}
@@ -0,0 +1,362 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Commands take their params as a struct, one per command, whose fields are
+// named by their json tags. paramsOf lists the fields for the registry, so
+// messages are checked against them before the handler runs, and typed
+// hands the handler the struct filled from the message. A field tagged
+// param:"required" must be given; a required string must not be empty
+// either, unless tagged param:"required,empty". Optional fields whose
+// default isn't the zero value are pointers, nil when not given.
+
+// paramsOf describes the fields of P as command params
+func paramsOf[P any]() []Param {
+	t := reflect.TypeFor[P]()
+	params := make([]Param, 0, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, ok := paramName(field)
+		if !ok {
+			continue
+		}
+		required, _ := paramOptions(field)
+		params = append(params, Param{Name: name, Type: paramType(field.Type), Required: required})
+	}
+	return params
+}
+
+// typed adapts a handler taking its params as a struct to a CommandHandler
+func typed[P any](handler func(ctx context.Context, params P) (any, error)) CommandHandler {
+	return func(ctx context.Context, raw map[string]interface{}) (any, error) {
+		params, err := decodeParams[P](raw)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, params)
+	}
+}
+
+// decodeParams fills P from the params of a message. The message holds the
+// command and id too, which P doesn't name and so ignores.
+func decodeParams[P any](raw map[string]interface{}) (P, error) {
+	var params P
+	data, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(data, &params)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return params, paramTypeError[P](strings.SplitN(typeErr.Field, ".", 2)[0])
+	}
+	if err != nil {
+		return params, invalidParam("", i18n.T("params could not be read"))
+	}
+
+	value := reflect.ValueOf(params)
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		name, ok := paramName(field)
+		required, empty := paramOptions(field)
+		if ok && required && !empty && field.Type.Kind() == reflect.String && value.Field(i).String() == "" {
+			return params, invalidParam(name, i18n.T("%s must be a non-empty string", name))
+		}
+	}
+	return params, nil
+}
+
+// paramTypeError reports a param of P that holds a value of the wrong type,
+// which validate only catches at the top level, e.g. a number in a list of
+// strings
+func paramTypeError[P any](name string) error {
+	t := reflect.TypeFor[P]()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if fieldName, _ := paramName(field); fieldName != name {
+			continue
+		}
+		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String {
+			return invalidParam(name, i18n.T("%s must be strings", name))
+		}
+		return invalidParam(name, i18n.T("%s must be of type %s", name, paramType(field.Type)))
+	}
+	return invalidParam(name, i18n.T("params could not be read"))
+}
+
+// paramName is the name of the param a struct field holds, from its json tag
+func paramName(field reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" || !field.IsExported() {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// paramOptions reads the param tag of a struct field
+func paramOptions(field reflect.StructField) (required, empty bool) {
+	for option := range strings.SplitSeq(field.Tag.Get("param"), ",") {
+		switch option {
+		case "required":
+			required = true
+		case "empty":
+			empty = true
+		}
+	}
+	return required, empty
+}
+
+// paramType is the Param type of a struct field's type
+func paramType(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return "any"
+}
+
+// orDefault returns the value of an optional param, or fallback when it
+// wasn't given
+func orDefault[T any](value *T, fallback T) T {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+// The params of the commands, in the order of commandList
+type (
+	// fadeParams let play, pause and stop override the configured fade
+	fadeParams struct {
+		FadeMs *int `json:"fadeMs"`
+	}
+	seekParams struct {
+		PositionMs int `json:"position_ms" param:"required"`
+	}
+	volumeParams struct {
+		Volume int `json:"volume" param:"required"`
+	}
+	shuffleParams struct {
+		Enabled bool `json:"enabled" param:"required"`
+	}
+	repeatParams struct {
+		Mode string `json:"mode" param:"required"`
+	}
+	setOutputParams struct {
+		Sink   string `json:"sink" param:"required"`
+		Player string `json:"player"`
+	}
+	fadeToParams struct {
+		Volume  int     `json:"volume" param:"required"`
+		Seconds float64 `json:"seconds" param:"required"`
+		Player  string  `json:"player"`
+	}
+	playerParams struct {
+		Player string `json:"player"`
+	}
+	zoneCommandParams struct {
+		Zone   string `json:"zone" param:"required"`
+		Action string `json:"action" param:"required"`
+		Value  int    `json:"value"`
+	}
+	statsParams struct {
+		Period string `json:"period"`
+		From   *int64 `json:"from"`
+		To     *int64 `json:"to"`
+		Limit  *int   `json:"limit"`
+	}
+	historyIndexParams struct {
+		Index int `json:"index" param:"required"`
+	}
+	browserReportParams struct {
+		Player utils.BrowserPlayer `json:"player" param:"required"`
+	}
+	privacyModeParams struct {
+		Enabled *bool `json:"enabled"` // nil reports the mode
+	}
+	relayCommandParams struct {
+		Host string `json:"host" param:"required"`
+		// A legacy message: {"command": ..., params}
+		Relay map[string]interface{} `json:"relay" param:"required"`
+	}
+	varGetParams struct {
+		Key string `json:"key"` // Empty reads all variables
+	}
+	varSetParams struct {
+		Key   string `json:"key" param:"required"`
+		Value any    `json:"value"` // nil deletes the variable
+	}
+	notifyParams struct {
+		Title    string `json:"title" param:"required"`
+		Message  string `json:"message"`
+		Priority string `json:"priority"`
+	}
+	profileActivateParams struct {
+		Profile string `json:"profile" param:"required"`
+	}
+	batchParams struct {
+		Commands    []interface{} `json:"commands" param:"required"`
+		StopOnError bool          `json:"stopOnError"`
+	}
+	pluginCommandParams struct {
+		Plugin string `json:"plugin" param:"required"`
+		// "command" already names plugin_command in legacy messages
+		Action string                 `json:"action" param:"required"`
+		Params map[string]interface{} `json:"params"`
+	}
+	kickClientParams struct {
+		ID      string `json:"id" param:"required"`
+		Minutes int    `json:"minutes"`
+	}
+	devicePairParams struct {
+		Name   string   `json:"name" param:"required"`
+		Scopes []string `json:"scopes"`
+	}
+	deviceRevokeParams struct {
+		Device string `json:"device" param:"required"`
+	}
+	logsTailParams struct {
+		Lines  *int `json:"lines"`
+		Follow bool `json:"follow"`
+	}
+	exportParams struct {
+		Tokens bool `json:"tokens"`
+	}
+	importParams struct {
+		Archive string `json:"archive" param:"required"` // Base64 encoded
+		Plugins bool   `json:"plugins"`
+	}
+	maintenanceParams struct {
+		Enabled *bool  `json:"enabled"` // nil reports the mode
+		Message string `json:"message"`
+		Minutes int    `json:"minutes"`
+	}
+	blackoutParams struct {
+		Pollers []string `json:"pollers"` // nil lists the paused pollers
+		Minutes *int     `json:"minutes"` // Required with pollers
+	}
+	preferencesParams struct {
+		Locale string `json:"locale"`
+		Units  string `json:"units"`
+	}
+	helloParams struct {
+		Name       string `json:"name"`
+		DeviceType string `json:"deviceType"`
+		AppVersion string `json:"appVersion"`
+		Bandwidth  string `json:"bandwidth"`
+	}
+	subscribeParams struct {
+		Topics []string               `json:"topics"`
+		Rates  map[string]interface{} `json:"rates"`
+	}
+	bluetoothSetAliasParams struct {
+		MAC   string `json:"mac" param:"required"`
+		Alias string `json:"alias" param:"required,empty"` // Empty resets the name
+	}
+	wifiConnectionParams struct {
+		Connection string `json:"connection" param:"required"`
+	}
+	wifiAutoconnectParams struct {
+		Connection string `json:"connection" param:"required"`
+		Enabled    bool   `json:"enabled" param:"required"`
+	}
+	wifiPriorityParams struct {
+		Connection string `json:"connection" param:"required"`
+		Priority   int    `json:"priority" param:"required"`
+	}
+	launchAppParams struct {
+		App string `json:"app" param:"required"`
+	}
+	displayPowerParams struct {
+		On bool `json:"on" param:"required"`
+	}
+	displayInputParams struct {
+		Input   string `json:"input"` // Empty reports the input
+		Display *int   `json:"display"`
+	}
+	pointerMoveParams struct {
+		DX float64 `json:"dx" param:"required"`
+		DY float64 `json:"dy" param:"required"`
+	}
+	pointerClickParams struct {
+		Button string `json:"button"`
+		Double bool   `json:"double"`
+	}
+	typeTextParams struct {
+		Text  string `json:"text" param:"required"`
+		Enter bool   `json:"enter"`
+	}
+	playURLParams struct {
+		URL string `json:"url" param:"required"`
+	}
+	radioSearchParams struct {
+		Query   string `json:"query"`
+		Tag     string `json:"tag"`
+		Country string `json:"country"`
+		Limit   *int   `json:"limit"`
+	}
+	playRadioParams struct {
+		Preset  string `json:"preset"`
+		Station string `json:"station"`
+		URL     string `json:"url"`
+		Player  string `json:"player"`
+	}
+	// alarmSetParams are an utils.Alarm, with defaults for volume and ramp
+	alarmSetParams struct {
+		ID       string   `json:"id"` // Empty adds an alarm
+		Label    string   `json:"label"`
+		Time     string   `json:"time" param:"required"`
+		Days     []string `json:"days"`
+		Playlist string   `json:"playlist"`
+		Preset   string   `json:"preset"`
+		Station  string   `json:"station"`
+		URL      string   `json:"url"`
+		Player   string   `json:"player"`
+		Volume   *int     `json:"volume"`
+		RampSec  *int     `json:"rampSec"`
+	}
+	alarmDeleteParams struct {
+		ID string `json:"id" param:"required"`
+	}
+	// limitParams cap the number of items a listing returns
+	limitParams struct {
+		Limit *int `json:"limit"`
+	}
+	spotifyShowEpisodesParams struct {
+		ShowID string `json:"show_id" param:"required"`
+		Limit  *int   `json:"limit"`
+	}
+	spotifyResumeEpisodeParams struct {
+		EpisodeID string `json:"episode_id" param:"required"`
+	}
+	addCurrentToPlaylistParams struct {
+		PlaylistID string `json:"playlist_id" param:"required"`
+	}
+	playContextParams struct {
+		ContextURI string `json:"context_uri"` // Empty returns to the last context
+		OffsetURI  string `json:"offset_uri"`
+	}
+)
@@ -0,0 +1,1135 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/utils"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// commandSettleDelay is how long a player gets to apply a command before its state is read back
+const commandSettleDelay = 250 * time.Millisecond
+
+var (
+	// ErrUnknownCommand is returned when a client sends a command Blitz does not know
+	ErrUnknownCommand = errors.New("unknown command")
+	// ErrInvalidParams is returned when a command is missing a parameter or has a bad value
+	ErrInvalidParams = errors.New("invalid params")
+)
+
+// ParamError is an ErrInvalidParams about a single parameter, which the
+// error sent to the client names, so it can point at the field
+type ParamError struct {
+	Param   string
+	Message string
+}
+
+func (e *ParamError) Error() string { return ErrInvalidParams.Error() + ": " + e.Message }
+
+func (e *ParamError) Unwrap() error { return ErrInvalidParams }
+
+// invalidParam reports a missing or invalid parameter with a translated
+// message; name is empty when the command can't run with any parameters, e.g.
+// one that needs a connection run over HTTP
+func invalidParam(name, message string) error {
+	return &ParamError{Param: name, Message: message}
+}
+
+// HandleMessage handles a legacy {"command": ...} message from a WebSocket client.
+// An optional "id" is echoed in the response, so clients sending several
+// commands at once can match the replies, which may arrive in any order.
+func HandleMessage(ctx context.Context, client *Client, msg ClientMessage) {
+	command, id := msg.Command, msg.ID
+
+	// Ping keeps its dedicated pong response for existing clients
+	if command == "ping" {
+		SendPong(client, id)
+		return
+	}
+
+	data, err := ExecuteCommand(ctx, command, msg.Params)
+	if err != nil {
+		log.Printf("❌ Command %s failed: %v", command, err)
+		writeResponse(client, models.NewErrorResponse(command, CommandError(err), id))
+		return
+	}
+
+	writeResponse(client, models.NewResponse(command, data, id))
+}
+
+// CommandError classifies a command failure into the error taxonomy of models
+func CommandError(err error) *models.Error {
+	switch {
+	case errors.Is(err, ErrUnknownCommand):
+		return models.NewError(models.ErrUnknownCommand, translatePrefix(err, ErrUnknownCommand), nil)
+	case errors.Is(err, ErrInvalidParams):
+		commandErr := models.NewError(models.ErrInvalidParams, translatePrefix(err, ErrInvalidParams), nil)
+		var paramErr *ParamError
+		if errors.As(err, &paramErr) {
+			commandErr.Param = paramErr.Param
+		}
+		return commandErr
+	}
+	return models.AsError(err)
+}
+
+// translatePrefix translates the "invalid params: " style prefix sentinel
+// adds to an error message
+func translatePrefix(err, sentinel error) string {
+	message := err.Error()
+	if rest, ok := strings.CutPrefix(message, sentinel.Error()); ok {
+		return i18n.T(sentinel.Error()) + rest
+	}
+	return message
+}
+
+// HandlePlayerCommand executes a single command with its params and returns the response data
+func HandlePlayerCommand(ctx context.Context, command string, params map[string]interface{}) (any, error) {
+	entry, ok := lookupCommand(command)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCommand, command)
+	}
+	if entry.Module != "" {
+		if err := utils.ModuleError(entry.Module); err != nil {
+			return nil, err
+		}
+	}
+	if err := entry.validate(params); err != nil {
+		return nil, err
+	}
+	return entry.Handler(ctx, params)
+}
+
+// commandList defines every command clients can run
+func commandList() []*Command {
+	return []*Command{
+		{
+			Name:        "ping",
+			Description: "Check the connection; answers with the server time",
+			Open:        true,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return PongData(), nil
+			},
+		},
+		{
+			Name:        "version",
+			Description: "Report the version, commit and compiled features of this build",
+			Open:        true,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetBuildInfo(), nil
+			},
+		},
+		{
+			Name:        "play",
+			Description: "Start playback on the active player",
+			Params:      paramsOf[fadeParams](),
+			Timeout:     fadeTimeout,
+			Handler:     playerControl("play"),
+		},
+		{
+			Name:        "pause",
+			Description: "Pause the active player",
+			Params:      paramsOf[fadeParams](),
+			Timeout:     fadeTimeout,
+			Handler:     playerControl("pause"),
+		},
+		{
+			Name:        "play-pause",
+			Aliases:     []string{"play_pause"},
+			Description: "Toggle playback on the active player",
+			Params:      paramsOf[fadeParams](),
+			Timeout:     fadeTimeout,
+			Handler:     playerControl("play-pause"),
+		},
+		{
+			Name:        "next",
+			Description: "Skip to the next track",
+			Handler:     playerControl("next"),
+		},
+		{
+			Name:        "previous",
+			Description: "Go back to the previous track",
+			Handler:     playerControl("previous"),
+		},
+		{
+			Name:        "stop",
+			Description: "Stop playback",
+			Params:      paramsOf[fadeParams](),
+			Timeout:     fadeTimeout,
+			Handler:     playerControl("stop"),
+		},
+		{
+			Name:        "ensure_playing",
+			Description: "Start playback unless it is already playing",
+			Params:      paramsOf[fadeParams](),
+			Timeout:     fadeTimeout,
+			Handler:     ensurePlayback(true),
+		},
+		{
+			Name:        "ensure_paused",
+			Description: "Pause playback unless it is already paused",
+			Params:      paramsOf[fadeParams](),
+			Timeout:     fadeTimeout,
+			Handler:     ensurePlayback(false),
+		},
+		{
+			Name:        "seek",
+			Description: "Seek the active player to a position",
+			Params:      paramsOf[seekParams](),
+			Handler: typed(func(ctx context.Context, params seekParams) (any, error) {
+				provider := utils.ActiveMusicProvider()
+				return playerResult(provider, provider.Seek(ctx, params.PositionMs))
+			}),
+		},
+		{
+			Name:        "volume",
+			Description: "Set the active player's volume in percent",
+			Params:      paramsOf[volumeParams](),
+			Handler: typed(func(ctx context.Context, params volumeParams) (any, error) {
+				provider := utils.ActiveMusicProvider()
+				return playerResult(provider, provider.SetVolume(ctx, params.Volume))
+			}),
+		},
+		{
+			Name:        "shuffle",
+			Description: "Turn shuffle on or off",
+			Params:      paramsOf[shuffleParams](),
+			Handler: typed(func(ctx context.Context, params shuffleParams) (any, error) {
+				provider := utils.ActiveMusicProvider()
+				return playerResult(provider, provider.SetShuffle(ctx, params.Enabled))
+			}),
+		},
+		{
+			Name:        "repeat",
+			Description: "Set the repeat mode: off, track or context",
+			Params:      paramsOf[repeatParams](),
+			Handler: typed(func(ctx context.Context, params repeatParams) (any, error) {
+				provider := utils.ActiveMusicProvider()
+				return playerResult(provider, provider.SetRepeat(ctx, params.Mode))
+			}),
+		},
+		{
+			Name:        "audio_outputs",
+			Description: "List the audio sinks",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.ListAudioOutputs()
+			},
+		},
+		{
+			Name:        "set_output",
+			Description: "Move a player to another audio sink",
+			Params:      paramsOf[setOutputParams](),
+			Handler: typed(func(ctx context.Context, params setOutputParams) (any, error) {
+				return utils.SetPlayerOutput(params.Player, params.Sink)
+			}),
+		},
+		{
+			Name:        "fade_to",
+			Description: "Fade a player's volume over a number of seconds",
+			Params:      paramsOf[fadeToParams](),
+			Handler: typed(func(ctx context.Context, params fadeToParams) (any, error) {
+				target, seconds, player := params.Volume, params.Seconds, params.Player
+				if seconds < 0 || seconds > 600 {
+					return nil, invalidParam("seconds", i18n.T("seconds must be a number between 0 and %d", 600))
+				}
+				if player == "" {
+					info, err := utils.GetPlayerInfo()
+					if err != nil {
+						return nil, err
+					}
+					player = info.Player
+				}
+				from, err := utils.FadeTo(player, target, time.Duration(seconds*float64(time.Second)), nil)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]interface{}{"player": player, "from": from, "to": target, "seconds": seconds}, nil
+			}),
+		},
+		{
+			Name:        "duck",
+			Description: "Lower the player volume for an announcement",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return map[string]bool{"ducked": true}, utils.Duck()
+			},
+		},
+		{
+			Name:        "unduck",
+			Description: "Restore the player volume after ducking",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return map[string]bool{"ducked": false}, utils.Unduck()
+			},
+		},
+		{
+			Name:        "resume_last",
+			Description: "Resume the last long track where it was left off",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.ResumeLastPosition(ctx)
+			},
+		},
+		{
+			Name:        "player_raise",
+			Description: "Bring a player's window to the front",
+			Params:      paramsOf[playerParams](),
+			Handler: typed(func(ctx context.Context, params playerParams) (any, error) {
+				method, err := utils.RaisePlayer(params.Player)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]string{"method": method}, nil
+			}),
+		},
+		{
+			Name:        "zones",
+			Description: "Report the state of every zone",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetZoneStates(), nil
+			},
+		},
+		{
+			Name:        "zone_command",
+			Description: "Run a playback action on every player of a zone",
+			Params:      paramsOf[zoneCommandParams](),
+			Handler: typed(func(ctx context.Context, params zoneCommandParams) (any, error) {
+				return utils.ZoneCommand(ctx, params.Zone, params.Action, params.Value)
+			}),
+		},
+		{
+			Name:        "history",
+			Description: "List the recently played tracks",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.VisibleHistory(), nil
+			},
+		},
+		{
+			Name:        "stats",
+			Description: "Aggregate the listening statistics of a period",
+			Params:      paramsOf[statsParams](),
+			Handler: typed(func(ctx context.Context, params statsParams) (any, error) {
+				from, to, err := utils.StatsPeriod(params.Period)
+				if err != nil {
+					return nil, invalidParam("period", i18n.T("unknown period %q", params.Period))
+				}
+				return utils.GetStats(orDefault(params.From, from), orDefault(params.To, to), orDefault(params.Limit, 10)), nil
+			}),
+		},
+		{
+			Name:        "play_from_history",
+			Description: "Play a history entry again",
+			Params:      paramsOf[historyIndexParams](),
+			Handler: typed(func(ctx context.Context, params historyIndexParams) (any, error) {
+				return utils.PlayFromHistory(params.Index)
+			}),
+		},
+		{
+			Name:        "player_info",
+			Description: "Report what the active player is playing",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				info, err := utils.GetPlayerInfo()
+				if err != nil {
+					return nil, err
+				}
+				if utils.PrivacyMode() {
+					info = info.Private()
+				}
+				return utils.RenderDisplayText(info), nil
+			},
+		},
+		{
+			Name:        "compact",
+			Description: "Report the tiny summary sent to microcontroller displays",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetCompact(), nil
+			},
+		},
+		{
+			Name:        "browser_report",
+			Description: "Report a web player from the browser extension, which then receives browser_control messages",
+			Params:      paramsOf[browserReportParams](),
+			Handler: typed(func(ctx context.Context, params browserReportParams) (any, error) {
+				client := clientFromContext(ctx)
+				if client == nil {
+					return nil, invalidParam("", i18n.T("browser_report needs a connection"))
+				}
+				player := params.Player
+				if player.ID == "" {
+					return nil, invalidParam("player", i18n.T("player needs an id"))
+				}
+				utils.ReportBrowserPlayer(client.ID, player, func(control utils.BrowserControl) error {
+					return client.WriteJSON(models.NewEvent(control))
+				})
+				return map[string]string{"id": player.ID}, nil
+			}),
+		},
+		{
+			Name:        "privacy_mode",
+			Description: "Turn privacy mode on or off, or report it",
+			Params:      paramsOf[privacyModeParams](),
+			Handler: typed(func(ctx context.Context, params privacyModeParams) (any, error) {
+				if params.Enabled != nil {
+					utils.SetPrivacyMode(*params.Enabled)
+					WriteChannelMessage(models.NewEvent(utils.PrivacyState{Enabled: *params.Enabled}))
+				}
+				return utils.PrivacyState{Enabled: utils.PrivacyMode()}, nil
+			}),
+		},
+		{
+			Name:        "players",
+			Description: "List the running media players",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetAllActivePlayers()
+			},
+		},
+		{
+			Name:        "bluetooth_info",
+			Description: "List the Bluetooth devices",
+			Module:      utils.ModuleBluetooth,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetBluetoothDevices()
+			},
+		},
+		{
+			Name:        "upstreams",
+			Description: "List the relayed Blitz instances",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return GetUpstreams(), nil
+			},
+		},
+		{
+			Name:        "relay_command",
+			Description: "Run a command on an upstream",
+			Params:      paramsOf[relayCommandParams](),
+			Handler: typed(func(ctx context.Context, params relayCommandParams) (any, error) {
+				command, err := stringParam(params.Relay, "command")
+				if err != nil {
+					return nil, err
+				}
+				// The upstream runs it with our credentials, so the caller needs
+				// the scope it would need here
+				if grant, ok := grantFromContext(ctx); ok && !grant.CanRun(command) {
+					return nil, models.NewError(models.ErrForbidden, i18n.T("%s needs the %s scope", command, commandScope(command)), nil)
+				}
+				return RelayCommand(params.Host, command, params.Relay)
+			}),
+		},
+		{
+			Name:        "var_get",
+			Description: "Read one or all shared variables",
+			Params:      paramsOf[varGetParams](),
+			Handler: typed(func(ctx context.Context, params varGetParams) (any, error) {
+				if params.Key == "" {
+					return GetVariables(), nil
+				}
+				return VariableChange{Key: params.Key, Value: GetVariable(params.Key)}, nil
+			}),
+		},
+		{
+			Name:        "var_set",
+			Description: "Set a shared variable, or delete it with null",
+			Params:      paramsOf[varSetParams](),
+			Handler: typed(func(ctx context.Context, params varSetParams) (any, error) {
+				if err := SetVariable(params.Key, params.Value); err != nil {
+					return nil, err
+				}
+				return VariableChange{Key: params.Key, Value: params.Value}, nil
+			}),
+		},
+		{
+			Name:        "quiet_hours",
+			Description: "Report whether quiet hours hold back pushes right now",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetQuietHours(), nil
+			},
+		},
+		{
+			Name:        "notify",
+			Description: "Broadcast a notification, and push it to phones when push is configured",
+			Params:      paramsOf[notifyParams](),
+			Handler: typed(func(ctx context.Context, params notifyParams) (any, error) {
+				notification := utils.Notification{Title: params.Title, Message: params.Message, Priority: utils.PushPriorityDefault}
+				switch params.Priority {
+				case "":
+				case utils.PushPriorityLow, utils.PushPriorityDefault, utils.PushPriorityHigh:
+					notification.Priority = params.Priority
+				default:
+					return nil, invalidParam("priority", i18n.T("priority must be low, default or high"))
+				}
+				// Screens and phones around only learn that something happened
+				broadcast := notification
+				if utils.PrivacyMode() {
+					broadcast = notification.Private()
+				}
+				WriteChannelMessage(models.NewEvent(broadcast))
+				return notification, nil
+			}),
+		},
+		{
+			Name:        "profiles",
+			Description: "List the profiles and the active one",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return GetProfiles(), nil
+			},
+		},
+		{
+			Name:        "profile_activate",
+			Description: "Run the commands of a profile",
+			Params:      paramsOf[profileActivateParams](),
+			Timeout:     profileTimeout,
+			Handler: typed(func(ctx context.Context, params profileActivateParams) (any, error) {
+				return ActivateProfile(ctx, params.Profile)
+			}),
+		},
+		{
+			Name:        "batch",
+			Description: "Run several commands in order and return the result of each",
+			Open:        true,
+			Params:      paramsOf[batchParams](),
+			Timeout:     batchTimeout,
+			Handler: typed(func(ctx context.Context, params batchParams) (any, error) {
+				return RunBatch(ctx, params.Commands, params.StopOnError)
+			}),
+		},
+		{
+			Name:        "plugins",
+			Description: "List the plugins with their topics and commands",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return GetPlugins(), nil
+			},
+		},
+		{
+			Name:        "plugin_command",
+			Description: "Send a command to a plugin",
+			Params:      paramsOf[pluginCommandParams](),
+			Handler: typed(func(ctx context.Context, params pluginCommandParams) (any, error) {
+				return PluginCommand(params.Plugin, params.Action, params.Params)
+			}),
+		},
+		{
+			Name:        "devices",
+			Description: "List the device tokens",
+			Scope:       ScopeAdmin,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return GetDevices(), nil
+			},
+		},
+		{
+			Name:        "list_clients",
+			Description: "List the connected clients and how they described themselves",
+			Scope:       ScopeAdmin,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return ListClients(), nil
+			},
+		},
+		{
+			Name:        "kick_client",
+			Description: "Disconnect a client, telling it to stay away for some minutes",
+			Params:      paramsOf[kickClientParams](),
+			Scope:       ScopeAdmin,
+			Handler: typed(func(ctx context.Context, params kickClientParams) (any, error) {
+				if err := KickClient(params.ID, params.Minutes); err != nil {
+					return nil, err
+				}
+				return map[string]string{"id": params.ID}, nil
+			}),
+		},
+		{
+			Name:        "device_pair",
+			Description: "Issue a token for a new device",
+			Params:      paramsOf[devicePairParams](),
+			Scope:       ScopeAdmin,
+			Handler: typed(func(ctx context.Context, params devicePairParams) (any, error) {
+				return PairDevice(params.Name, params.Scopes)
+			}),
+		},
+		{
+			Name:        "device_revoke",
+			Description: "Revoke a device token and disconnect the device",
+			Params:      paramsOf[deviceRevokeParams](),
+			Scope:       ScopeAdmin,
+			Handler: typed(func(ctx context.Context, params deviceRevokeParams) (any, error) {
+				if err := RevokeDevice(params.Device); err != nil {
+					return nil, err
+				}
+				return map[string]string{"device": params.Device}, nil
+			}),
+		},
+		{
+			Name:        "logs_tail",
+			Description: "Return the latest log lines, optionally following the log",
+			Params:      paramsOf[logsTailParams](),
+			Scope:       ScopeAdmin,
+			Handler:     typed(tailLogsCommand),
+		},
+		{
+			Name:        "export",
+			Description: "Archive the config and stored state",
+			Params:      paramsOf[exportParams](),
+			Scope:       ScopeAdmin,
+			Handler: typed(func(ctx context.Context, params exportParams) (any, error) {
+				return ExportState(params.Tokens)
+			}),
+		},
+		{
+			Name:        "import",
+			Description: "Restore an archive made by export, with the plugins of its config only when asked",
+			Params:      paramsOf[importParams](),
+			Scope:       ScopeAdmin,
+			Handler: typed(func(ctx context.Context, params importParams) (any, error) {
+				archive, err := base64.StdEncoding.DecodeString(params.Archive)
+				if err != nil {
+					return nil, invalidParam("archive", i18n.T("archive must be base64 encoded"))
+				}
+				return ImportState(bytes.NewReader(archive), params.Plugins)
+			}),
+		},
+		{
+			Name:        "maintenance",
+			Description: "Turn maintenance mode on or off, or report it",
+			Params:      paramsOf[maintenanceParams](),
+			Scope:       ScopeAdmin,
+			Handler: typed(func(ctx context.Context, params maintenanceParams) (any, error) {
+				if params.Enabled == nil {
+					return utils.GetMaintenance(), nil
+				}
+				state, err := utils.SetMaintenance(*params.Enabled, params.Message, params.Minutes)
+				if err != nil {
+					return nil, err
+				}
+				WriteChannelMessage(models.NewEvent(state))
+				return state, nil
+			}),
+		},
+		{
+			Name:        "blackout",
+			Description: "Pause pollers for some minutes, or list the paused ones",
+			Params:      paramsOf[blackoutParams](),
+			Handler: typed(func(ctx context.Context, params blackoutParams) (any, error) {
+				if params.Pollers == nil {
+					return utils.GetBlackouts(), nil
+				}
+				if params.Minutes == nil {
+					return nil, invalidParam("minutes", i18n.T("%s is required", "minutes"))
+				}
+				return utils.SetBlackout(params.Pollers, *params.Minutes)
+			}),
+		},
+		{
+			Name:        "commands",
+			Description: "List the available commands",
+			Open:        true,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return ListAvailableCommands(), nil
+			},
+		},
+		{
+			Name:        "preferences",
+			Description: "Add durations and data rates formatted for this client to the messages",
+			Params:      paramsOf[preferencesParams](),
+			Open:        true,
+			Handler: typed(func(ctx context.Context, params preferencesParams) (any, error) {
+				client := clientFromContext(ctx)
+				if client == nil {
+					return nil, invalidParam("", i18n.T("preferences needs a connection"))
+				}
+				format, err := utils.NewFormatter(params.Locale, params.Units)
+				if err != nil {
+					return nil, invalidParam("units", i18n.T("units must be bits or bytes"))
+				}
+				client.format.Store(format)
+				return format, nil
+			}),
+		},
+		{
+			Name:        "hello",
+			Description: "Describe this client for list_clients, and ask for low bandwidth mode",
+			Params:      paramsOf[helloParams](),
+			Open:        true,
+			Handler: typed(func(ctx context.Context, params helloParams) (any, error) {
+				client := clientFromContext(ctx)
+				if client == nil {
+					return nil, invalidParam("", i18n.T("hello needs a connection"))
+				}
+				if !validBandwidth(params.Bandwidth) {
+					return nil, invalidParam("bandwidth", i18n.T("bandwidth must be %q or %q", BandwidthNormal, BandwidthLow))
+				}
+				hello := newClientHello(params.Name, params.DeviceType, params.AppVersion)
+				hello.Bandwidth = params.Bandwidth
+				client.setHello(hello)
+				return hello, nil
+			}),
+		},
+		{
+			Name:        "subscribe",
+			Description: "Receive only the broadcasts on these topics, or all of them without topics, at most at the given rates per second",
+			Params:      paramsOf[subscribeParams](),
+			Open:        true,
+			Handler: typed(func(ctx context.Context, params subscribeParams) (any, error) {
+				client := clientFromContext(ctx)
+				if client == nil {
+					return nil, invalidParam("", i18n.T("subscribe needs a connection"))
+				}
+				topics := params.Topics
+				if topics == nil {
+					topics = []string{}
+				}
+				rates, err := ratesParam(params.Rates)
+				if err != nil {
+					return nil, err
+				}
+				client.subscribe(topics)
+				client.setRates(rates)
+				replaySnapshots(client)
+				return map[string]interface{}{"topics": topics, "rates": rates}, nil
+			}),
+		},
+		{
+			Name:        "host_info",
+			Description: "Describe the host machine",
+			Module:      utils.ModuleSystem,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetHostInfo(), nil
+			},
+		},
+		{
+			Name:        "clock",
+			Description: "Report the host time, timezone and NTP status",
+			Module:      utils.ModuleSystem,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetClockInfo(), nil
+			},
+		},
+		{
+			Name:        "gamepads",
+			Description: "List the connected game controllers",
+			Module:      utils.ModuleSystem,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetGamepads()
+			},
+		},
+		{
+			Name:        "sensors",
+			Description: "Read the ambient light sensor and the accelerometer",
+			Module:      utils.ModuleSystem,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetSensors(), nil
+			},
+		},
+		{
+			Name:        "screen",
+			Description: "Capture a thumbnail of the desktop, as broadcast on the screen topic",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.CaptureScreen()
+			},
+		},
+		{
+			Name:        "peripherals",
+			Description: "List the battery powered peripherals",
+			Module:      utils.ModuleSystem,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetPeripherals()
+			},
+		},
+		{
+			Name:        "bluetooth_set_alias",
+			Description: "Rename a Bluetooth device",
+			Module:      utils.ModuleBluetooth,
+			Params:      paramsOf[bluetoothSetAliasParams](),
+			Handler: typed(func(ctx context.Context, params bluetoothSetAliasParams) (any, error) {
+				if err := utils.SetBluetoothAlias(params.MAC, params.Alias); err != nil {
+					return nil, err
+				}
+				return map[string]string{"mac": params.MAC, "alias": params.Alias}, nil
+			}),
+		},
+		{
+			Name:        "wifi_info",
+			Description: "Report the current Wi-Fi connection",
+			Module:      utils.ModuleWiFi,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetWiFiInfo()
+			},
+		},
+		{
+			Name:        "wifi_saved",
+			Description: "List the saved Wi-Fi networks",
+			Module:      utils.ModuleWiFi,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetSavedWiFiConnections()
+			},
+		},
+		{
+			Name:        "wifi_forget",
+			Description: "Delete a saved Wi-Fi network",
+			Module:      utils.ModuleWiFi,
+			Params:      paramsOf[wifiConnectionParams](),
+			Handler: typed(func(ctx context.Context, params wifiConnectionParams) (any, error) {
+				return utils.ForgetWiFiConnection(params.Connection)
+			}),
+		},
+		{
+			Name:        "wifi_autoconnect",
+			Description: "Turn autoconnect of a saved Wi-Fi network on or off",
+			Module:      utils.ModuleWiFi,
+			Params:      paramsOf[wifiAutoconnectParams](),
+			Handler: typed(func(ctx context.Context, params wifiAutoconnectParams) (any, error) {
+				return utils.SetWiFiAutoconnect(params.Connection, params.Enabled)
+			}),
+		},
+		{
+			Name:        "wifi_priority",
+			Description: "Set the autoconnect priority of a saved Wi-Fi network",
+			Module:      utils.ModuleWiFi,
+			Params:      paramsOf[wifiPriorityParams](),
+			Handler: typed(func(ctx context.Context, params wifiPriorityParams) (any, error) {
+				return utils.SetWiFiPriority(params.Connection, params.Priority)
+			}),
+		},
+		{
+			Name:        "wifi_history",
+			Description: "List the recent Wi-Fi events",
+			Module:      utils.ModuleWiFi,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetWiFiHistory(), nil
+			},
+		},
+		{
+			Name:        "latency",
+			Description: "Report the latest ping results",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetLatency(), nil
+			},
+		},
+		{
+			Name:        "network_usage",
+			Description: "Report the network traffic per process",
+			Module:      utils.ModuleSystem,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetNetworkUsage()
+			},
+		},
+		{
+			Name:        "net_diag",
+			Description: "Diagnose the network connection step by step",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.RunNetDiag(), nil
+			},
+		},
+		{
+			Name:        "launch_app",
+			Description: "Start an application",
+			Module:      utils.ModuleLauncher,
+			Params:      paramsOf[launchAppParams](),
+			Handler: typed(func(ctx context.Context, params launchAppParams) (any, error) {
+				return utils.LaunchApp(params.App)
+			}),
+		},
+		{
+			Name:        "display_power",
+			Description: "Turn the screens on or off",
+			Module:      utils.ModuleDisplay,
+			Params:      paramsOf[displayPowerParams](),
+			Handler: typed(func(ctx context.Context, params displayPowerParams) (any, error) {
+				if err := utils.SetDisplayPower(params.On); err != nil {
+					return nil, err
+				}
+				return map[string]bool{"on": params.On}, nil
+			}),
+		},
+		{
+			Name:        "display_input",
+			Description: "Switch a monitor to another input over DDC/CI, or report its input",
+			Module:      utils.ModuleDisplay,
+			Params:      paramsOf[displayInputParams](),
+			Timeout:     ddcTimeout,
+			Handler: typed(func(ctx context.Context, params displayInputParams) (any, error) {
+				display := orDefault(params.Display, 1)
+				if params.Input == "" {
+					return utils.GetDisplayInput(display)
+				}
+				return utils.SetDisplayInput(display, params.Input)
+			}),
+		},
+		{
+			Name:        "pointer_move",
+			Description: "Move the mouse pointer by dx and dy, e.g. from a phone used as a trackpad",
+			Module:      utils.ModulePointer,
+			Scope:       ScopePointer,
+			Params:      paramsOf[pointerMoveParams](),
+			Handler: typed(func(ctx context.Context, params pointerMoveParams) (any, error) {
+				return utils.MovePointer(params.DX, params.DY)
+			}),
+		},
+		{
+			Name:        "pointer_click",
+			Description: "Click a mouse button: left (default), right or middle",
+			Module:      utils.ModulePointer,
+			Scope:       ScopePointer,
+			Params:      paramsOf[pointerClickParams](),
+			Handler: typed(func(ctx context.Context, params pointerClickParams) (any, error) {
+				button := params.Button
+				if button == "" {
+					button = "left"
+				}
+				if err := utils.ClickPointer(button, params.Double); err != nil {
+					return nil, err
+				}
+				return map[string]interface{}{"button": button, "double": params.Double}, nil
+			}),
+		},
+		{
+			Name:        "type_text",
+			Description: "Type text on the host, with enter pressing Enter afterwards, e.g. to search in a TV app",
+			Module:      utils.ModuleKeyboard,
+			Scope:       ScopePointer,
+			Params:      paramsOf[typeTextParams](),
+			Timeout:     typeTimeout,
+			Handler: typed(func(ctx context.Context, params typeTextParams) (any, error) {
+				text := params.Text
+				if params.Enter {
+					text += "\n"
+				}
+				if err := utils.TypeText(text); err != nil {
+					return nil, err
+				}
+				return map[string]int{"typed": utf8.RuneCountInString(text)}, nil
+			}),
+		},
+		{
+			Name:        "play_url",
+			Description: "Play an internet radio stream or podcast on the host",
+			Module:      utils.ModuleReceiver,
+			Params:      paramsOf[playURLParams](),
+			Handler: typed(func(ctx context.Context, params playURLParams) (any, error) {
+				return utils.PlayURL(params.URL)
+			}),
+		},
+		{
+			Name:        "radio_presets",
+			Description: "List the radio station presets",
+			Module:      utils.ModuleRadio,
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetRadioPresets(), nil
+			},
+		},
+		{
+			Name:        "radio_search",
+			Description: "Search the RadioBrowser directory for stations",
+			Module:      utils.ModuleRadio,
+			Params:      paramsOf[radioSearchParams](),
+			Handler: typed(func(ctx context.Context, params radioSearchParams) (any, error) {
+				return utils.SearchRadio(params.Query, params.Tag, params.Country, orDefault(params.Limit, 20))
+			}),
+		},
+		{
+			Name:        "play_radio",
+			Description: "Play a radio preset, directory station or stream URL",
+			Module:      utils.ModuleRadio,
+			Params:      paramsOf[playRadioParams](),
+			Handler: typed(func(ctx context.Context, params playRadioParams) (any, error) {
+				return utils.PlayRadio(params.Preset, params.Station, params.URL, params.Player)
+			}),
+		},
+		{
+			Name:        "alarm_set",
+			Description: "Add an alarm, or change the one with the given id",
+			Params:      paramsOf[alarmSetParams](),
+			Handler: typed(func(ctx context.Context, params alarmSetParams) (any, error) {
+				return utils.SetAlarm(utils.Alarm{
+					ID:       params.ID,
+					Label:    params.Label,
+					Time:     params.Time,
+					Days:     params.Days,
+					Playlist: params.Playlist,
+					Preset:   params.Preset,
+					Station:  params.Station,
+					URL:      params.URL,
+					Player:   params.Player,
+					Volume:   orDefault(params.Volume, 50),
+					RampSec:  orDefault(params.RampSec, 60),
+				})
+			}),
+		},
+		{
+			Name:        "alarm_list",
+			Description: "List the alarms, the next one to ring first",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetAlarms(), nil
+			},
+		},
+		{
+			Name:        "alarm_delete",
+			Description: "Delete an alarm",
+			Params:      paramsOf[alarmDeleteParams](),
+			Handler: typed(func(ctx context.Context, params alarmDeleteParams) (any, error) {
+				if err := utils.DeleteAlarm(params.ID); err != nil {
+					return nil, err
+				}
+				return map[string]string{"id": params.ID}, nil
+			}),
+		},
+		{
+			Name:        "start_radio",
+			Description: "Start a Spotify radio based on the current track",
+			Module:      utils.ModuleSpotify,
+			Params:      paramsOf[limitParams](),
+			Handler: typed(func(ctx context.Context, params limitParams) (any, error) {
+				return utils.StartSpotifyRadio(orDefault(params.Limit, 20))
+			}),
+		},
+		{
+			Name:        "spotify_auth_status",
+			Description: "Report where the Spotify login stands",
+			Handler: func(ctx context.Context, params map[string]interface{}) (any, error) {
+				return utils.GetSpotifyAuthState(), nil
+			},
+		},
+		{
+			Name:        "spotify_saved_shows",
+			Description: "List the saved Spotify podcasts",
+			Module:      utils.ModuleSpotify,
+			Params:      paramsOf[limitParams](),
+			Handler: typed(func(ctx context.Context, params limitParams) (any, error) {
+				return utils.GetSpotifySavedShows(orDefault(params.Limit, 20))
+			}),
+		},
+		{
+			Name:        "spotify_show_episodes",
+			Description: "List the episodes of a Spotify podcast",
+			Module:      utils.ModuleSpotify,
+			Params:      paramsOf[spotifyShowEpisodesParams](),
+			Handler: typed(func(ctx context.Context, params spotifyShowEpisodesParams) (any, error) {
+				return utils.GetSpotifyShowEpisodes(params.ShowID, orDefault(params.Limit, 20))
+			}),
+		},
+		{
+			Name:        "spotify_resume_episode",
+			Description: "Resume a Spotify episode where it was left off",
+			Module:      utils.ModuleSpotify,
+			Params:      paramsOf[spotifyResumeEpisodeParams](),
+			Handler: typed(func(ctx context.Context, params spotifyResumeEpisodeParams) (any, error) {
+				return utils.ResumeSpotifyEpisode(params.EpisodeID)
+			}),
+		},
+		{
+			Name:        "spotify_playlists",
+			Description: "List the Spotify playlists",
+			Module:      utils.ModuleSpotify,
+			Params:      paramsOf[limitParams](),
+			Handler: typed(func(ctx context.Context, params limitParams) (any, error) {
+				return utils.GetSpotifyPlaylists(orDefault(params.Limit, 50))
+			}),
+		},
+		{
+			Name:        "add_current_to_playlist",
+			Description: "Add the current track to a Spotify playlist",
+			Module:      utils.ModuleSpotify,
+			Params:      paramsOf[addCurrentToPlaylistParams](),
+			Handler: typed(func(ctx context.Context, params addCurrentToPlaylistParams) (any, error) {
+				return utils.AddCurrentToPlaylist(params.PlaylistID)
+			}),
+		},
+		{
+			Name:        "play_context",
+			Description: "Play a Spotify album, playlist or show",
+			Module:      utils.ModuleSpotify,
+			Params:      paramsOf[playContextParams](),
+			Handler: typed(func(ctx context.Context, params playContextParams) (any, error) {
+				return utils.PlaySpotifyContext(params.ContextURI, params.OffsetURI)
+			}),
+		},
+	}
+}
+
+// playerControl runs a playback action on the active player
+func playerControl(action string) CommandHandler {
+	return typed(func(ctx context.Context, params fadeParams) (any, error) {
+		fade, err := params.fade()
+		if err != nil {
+			return nil, err
+		}
+		provider := utils.ActiveMusicProvider()
+		// A failed action must not take credit for the next track change
+		err = utils.ControlWithFade(ctx, provider, action, fade)
+		if err == nil {
+			utils.RecordPlayerAction(action)
+		}
+		return playerResult(provider, err)
+	})
+}
+
+// fade returns the fadeMs parameter, or the configured fade
+func (params fadeParams) fade() (time.Duration, error) {
+	fadeMs := orDefault(params.FadeMs, int(utils.PauseFade().Milliseconds()))
+	if fadeMs < 0 || time.Duration(fadeMs)*time.Millisecond > utils.MaxPauseFade {
+		return 0, invalidParam("fadeMs", i18n.T("the fade must not be longer than %s", utils.MaxPauseFade))
+	}
+	return time.Duration(fadeMs) * time.Millisecond, nil
+}
+
+// ensurePlayback starts or pauses playback unless it already is in that
+// state. Unlike play-pause it never flips playback, so repeated or racing
+// automation triggers are harmless.
+func ensurePlayback(playing bool) CommandHandler {
+	return typed(func(ctx context.Context, params fadeParams) (any, error) {
+		fade, err := params.fade()
+		if err != nil {
+			return nil, err
+		}
+		provider := utils.ActiveMusicProvider()
+		if state, err := provider.State(); err == nil && state.Playing == playing {
+			return map[string]interface{}{"provider": provider.Name(), "state": state, "changed": false}, nil
+		}
+		action := "pause"
+		if playing {
+			action = "play"
+		}
+		result, err := playerResult(provider, utils.ControlWithFade(ctx, provider, action, fade))
+		if err != nil {
+			return nil, err
+		}
+		result["changed"] = true
+		return result, nil
+	})
+}
+
+// stringParam returns a required non-empty string parameter
+func stringParam(params map[string]interface{}, name string) (string, error) {
+	value, ok := params[name].(string)
+	if !ok || value == "" {
+		return "", invalidParam(name, i18n.T("%s must be a non-empty string", name))
+	}
+	return value, nil
+}
+
+// playerResult acknowledges a player command with the backend that handled it
+// and the state it left the player in, so clients can update right away
+func playerResult(provider utils.MusicProvider, err error) (map[string]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{"provider": provider.Name()}
+
+	// Give the player a moment to apply the command before reading it back
+	time.Sleep(commandSettleDelay)
+	if state, err := provider.State(); err == nil {
+		result["state"] = state
+	} else {
+		log.Println("Failed to read player state after command:", err)
+	}
+	return result, nil
+}
+
+func writeResponse(client *Client, response models.ServerResponse) {
+	if err := client.WriteJSON(client.formatted(response)); err != nil {
+		log.Printf("❌ Failed to send response: %v", err)
+	}
+}
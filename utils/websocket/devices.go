@@ -0,0 +1,248 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/store"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// devicesDocument is the store document holding the issued device tokens
+const devicesDocument = "devices"
+
+// device is an issued token as persisted. The token is kept so that devices
+// can answer the HMAC challenge, which needs the secret on both sides.
+type device struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Token     string   `json:"token"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt int64    `json:"createdAt"`
+	LastSeen  int64    `json:"lastSeen"`
+}
+
+// DeviceInfo describes an issued token to clients; the token itself is only
+// ever sent once, when it is issued
+type DeviceInfo struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt int64    `json:"createdAt"`
+	LastSeen  int64    `json:"lastSeen"`
+	Connected bool     `json:"connected"`
+}
+
+var (
+	devices       []device
+	devicesLoaded bool
+	devicesMu     sync.Mutex
+)
+
+func loadDevices() {
+	if devicesLoaded {
+		return
+	}
+	devicesLoaded = true
+	if err := store.Load(devicesDocument, &devices); err != nil {
+		log.Println("Failed to load device tokens:", err)
+	}
+}
+
+func saveDevices() {
+	if err := store.Save(devicesDocument, devices); err != nil {
+		log.Println("Failed to save device tokens:", err)
+	}
+}
+
+// PairDevice issues a token for a new device. Clients keep it and
+// authenticate with it from then on, so each device can be revoked on its own.
+func PairDevice(name string, scopes []string) (map[string]interface{}, error) {
+	if len(scopes) == 0 {
+		scopes = []string{ScopeControl}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(knownScopes, scope) {
+			return nil, invalidParam("scopes", i18n.T("unknown scope %q", scope))
+		}
+	}
+
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	issued := device{
+		ID:        newNonce()[:12],
+		Name:      name,
+		Token:     "blz_" + hex.EncodeToString(secret),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedAt: time.Now().Unix(),
+	}
+
+	devicesMu.Lock()
+	loadDevices()
+	devices = append(devices, issued)
+	saveDevices()
+	devicesMu.Unlock()
+
+	log.Printf("🔑 Issued device token %s for %q", issued.ID, name)
+	return map[string]interface{}{
+		"device": issued.info(false),
+		"token":  issued.Token,
+	}, nil
+}
+
+// GetDevices lists the issued device tokens
+func GetDevices() []DeviceInfo {
+	connected := connectedDevices()
+
+	devicesMu.Lock()
+	defer devicesMu.Unlock()
+	loadDevices()
+
+	list := make([]DeviceInfo, 0, len(devices))
+	for _, d := range devices {
+		list = append(list, d.info(connected[d.ID]))
+	}
+	return list
+}
+
+// RevokeDevice deletes a device token and disconnects the clients using it
+func RevokeDevice(id string) error {
+	devicesMu.Lock()
+	loadDevices()
+	index := slices.IndexFunc(devices, func(d device) bool { return d.ID == id })
+	if index < 0 {
+		devicesMu.Unlock()
+		return invalidParam("device", i18n.T("no device %s", id))
+	}
+	devices = slices.Delete(devices, index, index+1)
+	saveDevices()
+	devicesMu.Unlock()
+
+	clientsMu.RLock()
+	for _, client := range clients {
+		if client.grant.Load().deviceID() == id {
+			client.disconnect()
+		}
+	}
+	clientsMu.RUnlock()
+
+	log.Println("🔒 Revoked device token", id)
+	return nil
+}
+
+// deviceGrant returns the grant of a device token, or nil when no device has it
+func deviceGrant(token string) *Grant {
+	devicesMu.Lock()
+	defer devicesMu.Unlock()
+	loadDevices()
+
+	for _, d := range devices {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(d.Token)) == 1 {
+			return d.grant()
+		}
+	}
+	return nil
+}
+
+// deviceSecret returns the token and grant of device id, for checking an HMAC
+func deviceSecret(id string) (string, *Grant) {
+	devicesMu.Lock()
+	defer devicesMu.Unlock()
+	loadDevices()
+
+	for _, d := range devices {
+		if d.ID == id {
+			return d.Token, d.grant()
+		}
+	}
+	return "", nil
+}
+
+// touchDevice records that a device was just seen
+func touchDevice(id string) {
+	if id == "" {
+		return
+	}
+
+	devicesMu.Lock()
+	defer devicesMu.Unlock()
+	loadDevices()
+
+	for i := range devices {
+		if devices[i].ID == id {
+			devices[i].LastSeen = time.Now().Unix()
+			saveDevices()
+			return
+		}
+	}
+}
+
+// connectedDevices returns the IDs of the devices with a connected client
+func connectedDevices() map[string]bool {
+	clientsMu.RLock()
+	defer clientsMu.RUnlock()
+
+	connected := map[string]bool{}
+	for _, client := range clients {
+		if id := client.grant.Load().deviceID(); id != "" {
+			connected[id] = true
+		}
+	}
+	return connected
+}
+
+func (d device) grant() *Grant {
+	return &Grant{Device: d.ID, Scopes: slices.Clone(d.Scopes)}
+}
+
+func (d device) info(connected bool) DeviceInfo {
+	return DeviceInfo{
+		ID:        d.ID,
+		Name:      d.Name,
+		Scopes:    d.Scopes,
+		CreatedAt: d.CreatedAt,
+		LastSeen:  d.LastSeen,
+		Connected: connected,
+	}
+}
+
+func (g *Grant) deviceID() string {
+	if g == nil {
+		return ""
+	}
+	return g.Device
+}
+
+// HandleDevices serves the device management API: GET /api/devices lists the
+// device tokens and DELETE /api/devices/<id> revokes one. Requires the admin scope.
+func HandleDevices(w http.ResponseWriter, r *http.Request) {
+	if refuseCrossSite(w, r) {
+		return
+	}
+	if !RequestGrant(r).Allows(ScopeAdmin) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/devices"), "/")
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(GetDevices())
+	case r.Method == http.MethodDelete && id != "":
+		if err := RevokeDevice(id); err != nil {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
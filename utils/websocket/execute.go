@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/utils"
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// commandTimeout bounds how long a client waits for a single command
+	commandTimeout = 5 * time.Second
+	// profileTimeout bounds activating a profile, which runs several commands
+	profileTimeout = 30 * time.Second
+	// fadeTimeout bounds the playback commands, which may fade out first
+	fadeTimeout = 15 * time.Second
+	// ddcTimeout bounds the display commands, as DDC/CI is slow on some monitors
+	ddcTimeout = 20 * time.Second
+	// typeTimeout bounds type_text, as the tools type a key at a time
+	typeTimeout = 15 * time.Second
+	// batchTimeout bounds a batch, whose commands each have their own timeout
+	batchTimeout = 30 * time.Second
+)
+
+// ExecuteCommand runs a command with a deadline. When the deadline passes or
+// ctx is cancelled (the client went away) it returns a timeout error right
+// away. Handlers that pass ctx on (the playback commands) stop their
+// subprocesses and Spotify requests with it; the others finish in the
+// background and their result is dropped.
+func ExecuteCommand(ctx context.Context, command string, params map[string]interface{}) (any, error) {
+	if grant, ok := grantFromContext(ctx); ok && !grant.CanRun(command) {
+		return nil, models.NewError(models.ErrForbidden, i18n.T("%s needs the %s scope", command, commandScope(command)), nil)
+	}
+	if err := maintenanceError(command); err != nil {
+		return nil, err
+	}
+
+	timeout := commandTimeout
+	if entry, ok := lookupCommand(command); ok {
+		timeout = entry.timeout()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		data any
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer utils.TrackGoroutine(utils.GoroutineCommand)()
+		data, err := HandlePlayerCommand(ctx, command, params)
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, models.NewError(models.ErrTimeout, i18n.T("%s did not finish within %s", command, timeout), ctx.Err())
+		}
+		return nil, models.NewError(models.ErrTimeout, i18n.T("%s was cancelled", command), ctx.Err())
+	}
+}
+
+// maintenanceError rejects commands that would reach a provider while
+// maintenance mode is on. Admin commands still run, so it can be turned off
+// again, and so do open ones.
+func maintenanceError(command string) error {
+	state := utils.GetMaintenance()
+	if !state.Enabled {
+		return nil
+	}
+	if scope := commandScope(command); scope == "" || scope == ScopeAdmin {
+		return nil
+	}
+
+	err := models.NewError(models.ErrUnavailable, i18n.T("Blitz is under maintenance"), nil)
+	if state.Message != "" {
+		err = models.NewError(models.ErrUnavailable, i18n.T("Blitz is under maintenance: %s", state.Message), nil)
+	}
+	err.RetryAfter = state.RetryAfter()
+	if err.RetryAfter == 0 {
+		err.RetryAfter = retryHint(retryAfterMaintenance)
+	}
+	return err
+}
@@ -1,41 +1,168 @@
 package websocket
 
 import (
+	"Blitz/i18n"
 	"Blitz/models"
-	"net/http" 
+	"Blitz/utils"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 func Handle(res http.ResponseWriter, req *http.Request) {
 	conn, err := CreateWebSocketConnection(res, req)
 	if err != nil {
-		http.Error(res, "Failed to upgrade connection", http.StatusInternalServerError)
+		// The upgrader has answered the request already
 		return
 	}
+
+	stop := keepAlive(conn)
+	defer stop()
+	ServeConn(wsTransport{conn}, RequestGrant(req), false, connOptions(req.URL.Query()))
+}
+
+// connOptions reads the options of a connection from its query string.
+// ?topics=compact,clock limits the broadcasts to those topics, for clients
+// that can't cope with the big ones, and ?rates=media_info:1 how many per
+// second they get; ?locale=de&units=bytes adds formatted
+// fields, and ?encoding=msgpack sends MessagePack instead of JSON.
+// ?name=...&deviceType=...&appVersion=... say who is connecting, and
+// ?bandwidth=low asks for smaller and fewer broadcasts.
+func connOptions(query url.Values) ConnOptions {
+	options := ConnOptions{Encoding: query.Get("encoding"), Hello: helloFromQuery(query)}
+	if options.Encoding != "" && options.Encoding != EncodingJSON && options.Encoding != EncodingMsgpack {
+		log.Println("Ignoring unknown encoding:", options.Encoding)
+		options.Encoding = EncodingJSON
+	}
+	if list := query.Get("topics"); list != "" {
+		options.Topics = strings.Split(list, ",")
+	}
+	if list := query.Get("rates"); list != "" {
+		options.Rates = parseRates(list)
+	}
+	if query.Has("locale") || query.Has("units") {
+		format, err := utils.NewFormatter(query.Get("locale"), query.Get("units"))
+		if err != nil {
+			log.Println("Ignoring formatting preferences:", err)
+		}
+		options.Format = format
+	}
+	return options
+}
+
+// ServeConn runs a client connection until it closes. It serves direct
+// connections as well as relayed ones, which always authenticate in-band.
+// A nil grant means the client has to authenticate before anything else.
+// Options apply from the first message on.
+func ServeConn(conn Transport, grant *Grant, relayed bool, options ConnOptions) {
 	defer conn.Close()
 
+	if !admitClient(conn) {
+		return
+	}
+
+	client := RegisterClient(conn, grant, relayed, options)
+	defer UnregisterClient(client)
+	touchDevice(grant.deviceID())
+	defer func() { touchDevice(client.grant.Load().deviceID()) }()
+
+	// Cancels commands still running when the client disconnects
+	ctx, cancel := context.WithCancel(withClient(context.Background(), client))
+	defer cancel()
+
 	msg := models.ServerResponse{
 		Message: "Welcome to the WebSocket server!",
 	}
-	if err := SendWebSocketMessage(msg); err != nil {
-		http.Error(res, "Failed to send welcome message", http.StatusInternalServerError)
+	if grant == nil {
+		// Clients answer the challenge with {"command": "auth", "hmac": ...}
+		msg.Data = map[string]string{"auth": "required", "nonce": client.nonce}
+	}
+	if err := client.WriteJSON(msg); err != nil {
+		log.Println("Failed to send welcome message:", err)
 		return
 	}
 
+	if client.isAuthenticated() {
+		announceMaintenance(client)
+		replaySnapshots(client)
+	}
+
+	go client.writePump()
+
 	chh := GetChannel()
 	if chh == nil {
-		http.Error(res, "Failed to get response channel", http.StatusInternalServerError)
+		log.Println("❌ Failed to get response channel")
 		return
 	}
- 
 
 	// Reader goroutine - receives messages from client
 	for {
-		var msg map[string]interface{}
-		if err := conn.ReadJSON(&msg); err != nil {
+		raw, err := conn.ReadMessage()
+		if err != nil {
+			if peerVanished(err) {
+				log.Println("💀 Client stopped responding, dropping it:", client.ID)
+			}
 			break
 		}
+		client.lastActivity.Store(time.Now().Unix())
+
+		if !client.isAuthenticated() {
+			handleAuth(client, raw)
+			continue
+		}
+
+		// JSON-RPC 2.0 clients get JSON-RPC framed replies
+		if IsJSONRPC(raw) {
+			client.Dispatch(func() {
+				if reply := HandleJSONRPC(ctx, raw); reply != nil {
+					if err := client.WriteMessage(reply); err != nil {
+						log.Printf("❌ Failed to send JSON-RPC response: %v", err)
+					}
+				}
+			})
+			continue
+		}
+
+		msg, msgErr := ParseClientMessage(raw)
+		if msgErr != nil {
+			writeResponse(client, models.NewErrorResponse("", msgErr, msg.ID))
+			continue
+		}
+
+		client.Dispatch(func() {
+			HandleMessage(ctx, client, msg)
+		})
+	}
+}
+
+// handleAuth processes the messages of a client that hasn't authenticated yet
+func handleAuth(client *Client, raw []byte) {
+	var msg map[string]interface{}
+	json.Unmarshal(raw, &msg)
+
+	command, _ := msg["command"].(string)
+	grant := verifyAuth(client, msg)
+	if command != "auth" || grant == nil {
+		log.Println("❌ Rejected unauthenticated message from", client.ID)
+		writeResponse(client, models.NewErrorResponse(command, models.NewError(models.ErrUnauthorized, i18n.T("authentication required"), nil), msg["id"]))
+		return
+	}
+
+	client.setGrant(grant)
+	log.Println("✅ Client authenticated:", client.ID)
+	writeResponse(client, models.NewResponse("auth", map[string]interface{}{"device": grant.Device, "scopes": grant.Scopes}, msg["id"]))
+	announceMaintenance(client)
+	replaySnapshots(client)
+}
 
-		// Handle ping/pong
-		HandlePingPong(conn, msg)
+// announceMaintenance shows clients connecting during maintenance the banner
+// right away rather than with the next change
+func announceMaintenance(client *Client) {
+	if state := utils.GetMaintenance(); state.Enabled {
+		client.push(models.NewEvent(state))
 	}
 }
@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"Blitz/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	RPCServerError    = -32000
+)
+
+// RPCRequest is a single JSON-RPC 2.0 request or notification
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"` // nil for notifications
+}
+
+// RPCError is the error object of a failed JSON-RPC call
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// RPCResponse is a single JSON-RPC 2.0 response
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+var nullID = json.RawMessage("null")
+
+// IsJSONRPC reports whether a raw client message uses JSON-RPC 2.0 framing
+// (a batch array or an object carrying "jsonrpc": "2.0"). Arrays of
+// {"command": ...} messages are batches of commands instead.
+func IsJSONRPC(raw []byte) bool {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return !isCommandArray(trimmed)
+	}
+
+	var probe struct {
+		JSONRPC string `json:"jsonrpc"`
+	}
+	if err := json.Unmarshal(trimmed, &probe); err != nil {
+		return false
+	}
+	return probe.JSONRPC == "2.0"
+}
+
+// HandleJSONRPC processes a JSON-RPC 2.0 request or batch and returns the encoded
+// reply, or nil when nothing must be sent back (notifications only)
+func HandleJSONRPC(ctx context.Context, raw []byte) []byte {
+	trimmed := bytes.TrimSpace(raw)
+
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return encodeRPC(rpcError(nullID, RPCParseError, "Parse error"))
+		}
+		if len(batch) == 0 {
+			return encodeRPC(rpcError(nullID, RPCInvalidRequest, "Invalid Request"))
+		}
+
+		responses := make([]RPCResponse, 0, len(batch))
+		for _, item := range batch {
+			if response := handleRPCRequest(ctx, item); response != nil {
+				responses = append(responses, *response)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return encodeRPC(responses)
+	}
+
+	response := handleRPCRequest(ctx, trimmed)
+	if response == nil {
+		return nil
+	}
+	return encodeRPC(response)
+}
+
+// handleRPCRequest executes one request and returns nil for notifications
+func handleRPCRequest(ctx context.Context, raw json.RawMessage) *RPCResponse {
+	var req RPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if !json.Valid(raw) {
+			return rpcError(nullID, RPCParseError, "Parse error")
+		}
+		return rpcError(nullID, RPCInvalidRequest, "Invalid Request")
+	}
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = nullID
+		}
+		return rpcError(id, RPCInvalidRequest, "Invalid Request")
+	}
+
+	params := map[string]interface{}{}
+	if len(req.Params) > 0 && !bytes.Equal(req.Params, nullID) {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			if req.ID == nil {
+				return nil
+			}
+			return rpcError(req.ID, RPCInvalidParams, "Invalid params: params must be an object")
+		}
+	}
+
+	data, err := ExecuteCommand(ctx, req.Method, params)
+
+	// Notifications never get a response, even on failure
+	if req.ID == nil {
+		if err != nil {
+			log.Printf("❌ JSON-RPC notification %s failed: %v", req.Method, err)
+		}
+		return nil
+	}
+
+	if err != nil {
+		log.Printf("❌ JSON-RPC %s failed: %v", req.Method, err)
+		commandErr := CommandError(err)
+
+		var response *RPCResponse
+		switch commandErr.Code {
+		case models.ErrUnknownCommand:
+			response = rpcError(req.ID, RPCMethodNotFound, "Method not found")
+		case models.ErrInvalidParams:
+			response = rpcError(req.ID, RPCInvalidParams, commandErr.Message)
+		default:
+			response = rpcError(req.ID, RPCServerError, commandErr.Message)
+		}
+		data := map[string]any{"code": commandErr.Code}
+		if commandErr.RetryAfter > 0 {
+			data["retry_after"] = commandErr.RetryAfter
+		}
+		if commandErr.Param != "" {
+			data["param"] = commandErr.Param
+		}
+		response.Error.Data = data
+		return response
+	}
+
+	result, err := json.Marshal(data)
+	if err != nil {
+		return rpcError(req.ID, RPCInternalError, "Internal error")
+	}
+
+	return &RPCResponse{
+		JSONRPC: "2.0",
+		Result:  result,
+		ID:      req.ID,
+	}
+}
+
+func rpcError(id json.RawMessage, code int, message string) *RPCResponse {
+	return &RPCResponse{
+		JSONRPC: "2.0",
+		Error: &RPCError{
+			Code:    code,
+			Message: message,
+		},
+		ID: id,
+	}
+}
+
+func encodeRPC(v any) []byte {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("❌ Failed to encode JSON-RPC response: %v", err)
+		return nil
+	}
+	return encoded
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"Blitz/utils"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// pingInterval and pongWait detect WebSocket clients that went away
+	// without closing the connection
+	pingInterval = 30 * time.Second
+	pongWait     = 2 * pingInterval
+	// keepAliveProbes are the unanswered TCP keepalive probes, sent every
+	// keepAliveProbeInterval, after which a line client counts as gone
+	keepAliveProbes        = 3
+	keepAliveProbeInterval = 10 * time.Second
+)
+
+// keepAlive pings a WebSocket client until the returned func is called.
+// Reads fail once a client misses its pongs, so the reader of a client that
+// vanished returns instead of waiting forever. Browsers answer pings on
+// their own.
+func keepAlive(conn *websocket.Conn) func() {
+	return pingPeer(conn, utils.GoroutineClientPinger)
+}
+
+// keepPeerAlive is keepAlive for the connections Blitz opens itself, to the
+// relay and to upstreams, whose pingers aren't counted as clients'
+func keepPeerAlive(conn *websocket.Conn) func() {
+	return pingPeer(conn, utils.GoroutinePeerPinger)
+}
+
+// pingPeer pings conn every pingInterval in a goroutine of kind and fails
+// its reads once pongWait passes without a pong
+func pingPeer(conn *websocket.Conn, kind string) func() {
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer utils.TrackGoroutine(kind)()
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// peerVanished reports whether a read failed because the peer stopped
+// answering pings or keepalive probes, rather than closing the connection
+func peerVanished(err error) bool {
+	// gorilla/websocket wraps deadline errors in a net.Error of its own
+	var netErr net.Error
+	return (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, syscall.ETIMEDOUT)
+}
+
+// probePeer has the kernel probe a line client for being gone. Embedded
+// clients can't answer pings, so a pending read fails once the probes go
+// unanswered instead, roughly after pongWait.
+func probePeer(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetKeepAliveConfig(net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     pingInterval,
+			Interval: keepAliveProbeInterval,
+			Count:    keepAliveProbes,
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"Blitz/logging"
+	"Blitz/models"
+	"Blitz/utils"
+	"context"
+)
+
+// LogLine is one line of the followed log
+type LogLine struct {
+	Line string `json:"line"`
+}
+
+// Topic implements models.Payload
+func (LogLine) Topic() string { return "log" }
+
+// Version implements models.Payload
+func (LogLine) Version() int { return 1 }
+
+// tailLogsCommand runs logs_tail for the calling client
+func tailLogsCommand(ctx context.Context, params logsTailParams) (any, error) {
+	client := clientFromContext(ctx)
+	if client == nil {
+		return nil, models.NewError(models.ErrCommandFailed, "logs_tail needs a WebSocket connection", nil)
+	}
+	return client.tailLogs(orDefault(params.Lines, 100), params.Follow), nil
+}
+
+// tailLogs returns the most recent log lines. With follow, every new line is
+// then sent to the client as a "log" message until it asks for the tail
+// again without follow or disconnects.
+func (c *Client) tailLogs(lines int, follow bool) map[string]interface{} {
+	tail := logging.Tail(lines)
+	c.followLogs(follow)
+	return map[string]interface{}{"lines": tail, "follow": follow}
+}
+
+// followLogs starts or stops streaming the log to the client
+func (c *Client) followLogs(follow bool) {
+	c.logsMu.Lock()
+	defer c.logsMu.Unlock()
+
+	if c.stopLogs != nil {
+		c.stopLogs()
+		c.stopLogs = nil
+	}
+	if !follow {
+		return
+	}
+
+	stream, stop := logging.Subscribe()
+	c.stopLogs = stop
+	go func() {
+		defer utils.TrackGoroutine(utils.GoroutineLogFollower)()
+		for line := range stream {
+			c.push(models.NewEvent(LogLine{Line: line}))
+		}
+	}()
+}
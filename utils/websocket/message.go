@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"bytes"
+	"encoding/json"
+)
+
+// ClientMessage is a {"command": ...} message from a client. The params sit
+// next to the command, as in {"command": "volume", "volume": 40}, and an
+// optional "id" is echoed in the response.
+type ClientMessage struct {
+	Command string
+	ID      any // A string or number; nil when the client sent none
+	// Params is the whole message, command and id included, as handlers
+	// written for the untyped messages expect
+	Params map[string]interface{}
+}
+
+// ParseClientMessage checks that raw is a JSON object with a command name
+// and a usable id. A message that fails keeps its id where it is valid, so
+// the error still reaches the request it answers. An array of commands is
+// run as a batch.
+func ParseClientMessage(raw []byte) (ClientMessage, *models.Error) {
+	if isCommandArray(raw) {
+		var commands []interface{}
+		json.Unmarshal(raw, &commands)
+		return ClientMessage{Command: "batch", Params: map[string]interface{}{"command": "batch", "commands": commands}}, nil
+	}
+
+	var params map[string]interface{}
+	if err := json.Unmarshal(raw, &params); err != nil || params == nil {
+		return ClientMessage{}, models.NewError(models.ErrInvalidParams, i18n.T("invalid JSON message"), err)
+	}
+
+	msg := ClientMessage{Params: params}
+	switch id := params["id"].(type) {
+	case nil:
+	case string, float64:
+		msg.ID = id
+	default:
+		err := models.NewError(models.ErrInvalidParams, i18n.T("id must be a string or a number"), nil)
+		err.Param = "id"
+		return msg, err
+	}
+
+	command, ok := params["command"].(string)
+	if !ok || command == "" {
+		err := models.NewError(models.ErrInvalidParams, i18n.T("missing command"), nil)
+		err.Param = "command"
+		return msg, err
+	}
+	msg.Command = command
+	return msg, nil
+}
+
+// isCommandArray reports whether raw is an array of {"command": ...}
+// messages, which JSON-RPC batches are not
+func isCommandArray(raw []byte) bool {
+	var items []map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(raw), &items); err != nil || len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if _, ok := item["command"]; !ok || item["jsonrpc"] != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -4,34 +4,33 @@ import (
 	"Blitz/models"
 	"log"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 // HandlePingPong handles ping/pong command from WebSocket client
-func HandlePingPong(conn *websocket.Conn, msg map[string]interface{}) {
+func HandlePingPong(client *Client, msg map[string]interface{}) {
 	command, ok := msg["command"].(string)
 	if !ok {
 		return
 	}
 
 	if command == "ping" {
-		SendPong(conn)
+		SendPong(client, msg["id"])
 	}
 }
 
-// SendPong sends pong response to client
-func SendPong(conn *websocket.Conn) {
-	response := models.ServerResponse{
-		Status:  "success",
-		Message: "pong",
-		Data: map[string]interface{}{
-			"timestamp": time.Now().Unix(),
-			"server":    "Blitz WebSocket",
-		},
+// PongData returns the payload sent back for a ping
+func PongData() map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp": time.Now().Unix(),
+		"server":    "Blitz WebSocket",
 	}
+}
+
+// SendPong sends pong response to client, echoing the ping's id if it had one
+func SendPong(client *Client, id any) {
+	response := models.NewResponse("pong", PongData(), id)
 
-	if err := conn.WriteJSON(response); err != nil {
+	if err := client.WriteJSON(response); err != nil {
 		log.Printf("❌ Failed to send pong: %v", err)
 	} else {
 		log.Println("🏓 Pong sent")
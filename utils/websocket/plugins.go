@@ -0,0 +1,299 @@
+package websocket
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// pluginMaxBackoff caps the wait between restarts of a plugin that exits
+	pluginMaxBackoff = time.Minute
+	// pluginTimeout bounds a command sent to a plugin
+	pluginTimeout = 10 * time.Second
+	// maxPluginLine bounds one message a plugin writes
+	maxPluginLine = 1 << 20
+	// pluginQueue is how many commands may wait for a plugin to read them
+	pluginQueue = 16
+)
+
+// pluginMessage is one JSON line exchanged with a plugin. Plugins write
+// "hello" (announcing topics and commands), "data" (a value for a topic) and
+// "result" (the answer to a command); Blitz writes "command".
+type pluginMessage struct {
+	Type     string                 `json:"type"`
+	ID       string                 `json:"id,omitempty"`
+	Topic    string                 `json:"topic,omitempty"`
+	Topics   []string               `json:"topics,omitempty"`
+	Command  string                 `json:"command,omitempty"`
+	Commands []string               `json:"commands,omitempty"`
+	Params   map[string]interface{} `json:"params,omitempty"`
+	Data     any                    `json:"data,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// plugin is an external program providing data sources
+type plugin struct {
+	name    string
+	command []string
+
+	mu       sync.Mutex
+	lines    chan []byte // Written to the plugin's stdin in order, nil while it isn't running
+	topics   []string
+	commands []string
+	nextID   int
+	pending  map[string]chan pluginMessage
+}
+
+// PluginStatus reports a plugin in the plugins command
+type PluginStatus struct {
+	Name     string   `json:"name"`
+	Running  bool     `json:"running"`
+	Topics   []string `json:"topics"`
+	Commands []string `json:"commands"`
+}
+
+var (
+	plugins   = map[string]*plugin{}
+	pluginsMu sync.RWMutex
+)
+
+// StartPlugins launches the plugins listed in the config and broadcasts the
+// data they emit, prefixed with the plugin's name ("weather/current"), so
+// users can add data sources without recompiling Blitz
+func StartPlugins() {
+	for _, cfg := range config.Get().Plugins {
+		if cfg.Name == "" || len(cfg.Command) == 0 || strings.Contains(cfg.Name, "/") {
+			log.Println("❌ Ignoring plugin without a valid name or command")
+			continue
+		}
+		p := &plugin{name: cfg.Name, command: cfg.Command, pending: map[string]chan pluginMessage{}}
+
+		pluginsMu.Lock()
+		plugins[cfg.Name] = p
+		pluginsMu.Unlock()
+
+		go p.run()
+	}
+}
+
+// GetPlugins lists the configured plugins with what they announced
+func GetPlugins() []PluginStatus {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+
+	statuses := []PluginStatus{}
+	for _, p := range plugins {
+		p.mu.Lock()
+		statuses = append(statuses, PluginStatus{
+			Name:     p.name,
+			Running:  p.lines != nil,
+			Topics:   append([]string{}, p.topics...),
+			Commands: append([]string{}, p.commands...),
+		})
+		p.mu.Unlock()
+	}
+	return statuses
+}
+
+// PluginCommand sends a command to a plugin and returns its result
+func PluginCommand(name, command string, params map[string]interface{}) (any, error) {
+	pluginsMu.RLock()
+	p, ok := plugins[name]
+	pluginsMu.RUnlock()
+	if !ok {
+		return nil, invalidParam("plugin", i18n.T("unknown plugin %s", name))
+	}
+
+	// A plugin that stopped reading its stdin counts against the timeout too
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+
+	p.mu.Lock()
+	lines := p.lines
+	if lines == nil {
+		p.mu.Unlock()
+		return nil, models.NewError(models.ErrCommandFailed, i18n.T("plugin %s is not running", name), nil)
+	}
+	if !slices.Contains(p.commands, command) {
+		p.mu.Unlock()
+		return nil, invalidParam("action", i18n.T("plugin %s has no command %s", name, command))
+	}
+	p.nextID++
+	id := fmt.Sprintf("%d", p.nextID)
+	reply := make(chan pluginMessage, 1)
+	p.pending[id] = reply
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, id)
+		p.mu.Unlock()
+	}()
+
+	line, err := json.Marshal(pluginMessage{Type: "command", ID: id, Command: command, Params: params})
+	if err != nil {
+		return nil, models.NewError(models.ErrCommandFailed, "failed to reach plugin "+name, err)
+	}
+	select {
+	case lines <- append(line, '\n'):
+	case <-ctx.Done():
+		return nil, models.NewError(models.ErrTimeout, i18n.T("plugin %s did not answer", name), ctx.Err())
+	}
+
+	select {
+	case result := <-reply:
+		if result.Error != "" {
+			return nil, models.NewError(models.ErrCommandFailed, name+": "+result.Error, nil)
+		}
+		return result.Data, nil
+	case <-ctx.Done():
+		return nil, models.NewError(models.ErrTimeout, i18n.T("plugin %s did not answer", name), ctx.Err())
+	}
+}
+
+// run keeps the plugin running, restarting it with backoff when it exits
+func (p *plugin) run() {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		if err := p.serve(); err != nil {
+			log.Printf("❌ Plugin %s stopped: %v", p.name, err)
+		} else {
+			log.Printf("Plugin %s exited", p.name)
+		}
+
+		p.mu.Lock()
+		p.lines = nil
+		p.topics, p.commands = nil, nil
+		p.mu.Unlock()
+
+		// A plugin that ran for a while gets restarted quickly again
+		if time.Since(started) > pluginMaxBackoff {
+			backoff = time.Second
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, pluginMaxBackoff)
+	}
+}
+
+// serve starts the plugin and handles its messages until it exits
+func (p *plugin) serve() error {
+	cmd := exec.Command(p.command[0], p.command[1:]...)
+	// Plugins must not outlive Blitz
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	log.Printf("✅ Started plugin %s", p.name)
+
+	// Commands are written from here, so a plugin that doesn't read its
+	// stdin blocks only this goroutine, never the callers or p.mu
+	lines := make(chan []byte, pluginQueue)
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		for {
+			select {
+			case line := <-lines:
+				if _, err := stdin.Write(line); err != nil {
+					return
+				}
+			case <-stopped:
+				return
+			}
+		}
+	}()
+
+	p.mu.Lock()
+	p.lines = lines
+	p.mu.Unlock()
+
+	// Whatever the plugin prints to stderr ends up in our log
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Printf("[%s] %s", p.name, scanner.Text())
+		}
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxPluginLine)
+	for scanner.Scan() {
+		var msg pluginMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			log.Printf("Plugin %s sent an invalid message: %v", p.name, err)
+			continue
+		}
+		p.handle(msg)
+	}
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		// The plugin would block on a pipe nobody reads anymore
+		cmd.Process.Kill()
+	}
+
+	stdin.Close()
+	if err := cmd.Wait(); err != nil && scanErr == nil {
+		return err
+	}
+	return scanErr
+}
+
+// handle processes one message from the plugin
+func (p *plugin) handle(msg pluginMessage) {
+	switch msg.Type {
+	case "hello":
+		p.mu.Lock()
+		p.topics, p.commands = msg.Topics, msg.Commands
+		p.mu.Unlock()
+		log.Printf("Plugin %s provides topics %v and commands %v", p.name, msg.Topics, msg.Commands)
+	case "data":
+		p.mu.Lock()
+		announced := slices.Contains(p.topics, msg.Topic)
+		p.mu.Unlock()
+		if !announced {
+			log.Printf("Plugin %s sent data for unannounced topic %q", p.name, msg.Topic)
+			return
+		}
+		WriteChannelMessage(models.NewTopicEvent(p.name+"/"+msg.Topic, msg.Data))
+	case "result":
+		p.mu.Lock()
+		reply, waiting := p.pending[msg.ID]
+		p.mu.Unlock()
+		if !waiting {
+			return
+		}
+		select {
+		case reply <- msg:
+		default:
+			// A second result for the same command
+		}
+	default:
+		log.Printf("Plugin %s sent an unknown message type %q", p.name, msg.Type)
+	}
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
+	"log"
+	"sort"
+	"sync"
+)
+
+// ProfileStepResult is the outcome of one command of a profile
+type ProfileStepResult struct {
+	Command string           `json:"command"`
+	Error   string           `json:"error,omitempty"`
+	Code    models.ErrorCode `json:"code,omitempty"`
+}
+
+// ProfileState lists the configured profiles and the one activated last.
+// It is broadcast as a "profile" message on every activation.
+type ProfileState struct {
+	Active   string   `json:"active"`
+	Profiles []string `json:"profiles"`
+}
+
+// Topic implements models.Payload
+func (ProfileState) Topic() string { return "profile" }
+
+// Version implements models.Payload
+func (ProfileState) Version() int { return 1 }
+
+var (
+	activeProfile   string
+	activeProfileMu sync.Mutex
+)
+
+// GetProfiles reports the configured profiles and the active one
+func GetProfiles() ProfileState {
+	names := []string{}
+	for name := range config.Get().Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	activeProfileMu.Lock()
+	defer activeProfileMu.Unlock()
+	return ProfileState{Active: activeProfile, Profiles: names}
+}
+
+// ActivateProfile runs the commands of a profile ("Movie night": dim the
+// lights, switch the sink, set the volume, ...) in order. A failing command
+// doesn't stop the others; its error is reported in its result. Each command
+// runs like one the caller sent: with its own timeout, held back during
+// maintenance and refused when the caller's token lacks its scope. Admin
+// commands and nested profiles are refused for everyone.
+func ActivateProfile(ctx context.Context, name string) ([]ProfileStepResult, error) {
+	steps, ok := config.Get().Profiles[name]
+	if !ok {
+		return nil, invalidParam("profile", i18n.T("unknown profile %s", name))
+	}
+
+	results := make([]ProfileStepResult, len(steps))
+	for i, step := range steps {
+		command, _ := step["command"].(string)
+		results[i].Command = command
+
+		var err error
+		switch {
+		case command == "":
+			err = invalidParam("command", i18n.T("missing command"))
+		case command == "profile_activate" || commandScope(command) == ScopeAdmin:
+			err = models.NewError(models.ErrForbidden, i18n.T("%s can't run in a profile", command), nil)
+		default:
+			_, err = ExecuteCommand(ctx, command, step)
+		}
+		if err != nil {
+			stepErr := CommandError(err)
+			results[i].Error = stepErr.Message
+			results[i].Code = stepErr.Code
+			log.Printf("Profile %s: %s failed: %v", name, command, err)
+		}
+	}
+
+	activeProfileMu.Lock()
+	activeProfile = name
+	activeProfileMu.Unlock()
+
+	WriteChannelMessage(models.NewEvent(GetProfiles()))
+	return results, nil
+}
@@ -0,0 +1,254 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Param describes a command parameter
+type Param struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // "string", "int", "number", "bool", "object", "array" or "any"
+	Required bool   `json:"required,omitempty"`
+}
+
+// CommandHandler runs a command. ctx carries the calling client, if any.
+type CommandHandler func(ctx context.Context, params map[string]interface{}) (any, error)
+
+// Command is an entry of the command registry
+type Command struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Params      []Param
+	// Module is the module the command belongs to; it is refused while the
+	// module is disabled or misses its dependencies
+	Module string
+	// Scope is what a client needs to run the command, ScopeControl when
+	// empty. Open commands may be run by every authenticated client.
+	Scope string
+	Open  bool
+	// Timeout replaces commandTimeout for commands that take longer
+	Timeout time.Duration
+	Handler CommandHandler
+}
+
+// CommandInfo describes a command in ListAvailableCommands
+type CommandInfo struct {
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Description string   `json:"description"`
+	Params      []Param  `json:"params"`
+	Module      string   `json:"module,omitempty"`
+	Scope       string   `json:"scope,omitempty"` // Empty for open commands
+}
+
+var (
+	registry     map[string]*Command
+	registryOnce sync.Once
+)
+
+// lookupCommand finds a command by its name or an alias
+func lookupCommand(name string) (*Command, bool) {
+	registryOnce.Do(func() {
+		registry = map[string]*Command{}
+		for _, command := range commandList() {
+			for _, name := range append([]string{command.Name}, command.Aliases...) {
+				if _, taken := registry[name]; taken {
+					log.Fatalf("Command %s is registered twice", name)
+				}
+				registry[name] = command
+			}
+		}
+	})
+	command, ok := registry[name]
+	return command, ok
+}
+
+// scope is what a client needs to run the command, empty when it is open
+func (c *Command) scope() string {
+	switch {
+	case c.Open:
+		return ""
+	case c.Scope == "":
+		return ScopeControl
+	}
+	return c.Scope
+}
+
+// timeout bounds how long a client waits for the command
+func (c *Command) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return commandTimeout
+}
+
+// validate checks that the required params are present with the right type.
+// Handlers still read and check the values they use.
+func (c *Command) validate(params map[string]interface{}) error {
+	for _, param := range c.Params {
+		value, ok := params[param.Name]
+		if !ok || value == nil {
+			if param.Required {
+				return invalidParam(param.Name, i18n.T("%s is required", param.Name))
+			}
+			continue
+		}
+
+		valid := true
+		switch param.Type {
+		case "string":
+			_, valid = value.(string)
+		case "int":
+			number, isNumber := value.(float64)
+			valid = isNumber && number == float64(int64(number))
+		case "number":
+			_, valid = value.(float64)
+		case "bool":
+			_, valid = value.(bool)
+		case "object":
+			_, valid = value.(map[string]interface{})
+		case "array":
+			_, valid = value.([]interface{})
+		}
+		if !valid {
+			return invalidParam(param.Name, i18n.T("%s must be of type %s", param.Name, param.Type))
+		}
+	}
+	return nil
+}
+
+// ListAvailableCommands describes every command, sorted by name
+func ListAvailableCommands() []CommandInfo {
+	lookupCommand("")
+
+	list := []CommandInfo{}
+	for name, command := range registry {
+		if name != command.Name {
+			continue
+		}
+		params := command.Params
+		if params == nil {
+			params = []Param{}
+		}
+		list = append(list, CommandInfo{
+			Name:        command.Name,
+			Aliases:     command.Aliases,
+			Description: command.Description,
+			Params:      params,
+			Module:      command.Module,
+			Scope:       command.scope(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// RegisterCommandRoutes serves every command over HTTP: GET /api/commands
+// lists them and POST /api/commands/<name> runs one with a JSON object of
+// params as the body. Requests authenticate like the other APIs and need the
+// same scope as over the WebSocket.
+func RegisterCommandRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/commands", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if RequestGrant(r) == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ListAvailableCommands())
+	})
+
+	lookupCommand("")
+	for name := range registry {
+		mux.HandleFunc("/api/commands/"+name, func(w http.ResponseWriter, r *http.Request) {
+			serveCommand(w, r, name)
+		})
+	}
+}
+
+// serveCommand runs a command for an HTTP request. Bodies must be sent as
+// application/json, which web pages can't do on other sites without Blitz
+// allowing it first.
+func serveCommand(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if refuseCrossSite(w, r) {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); r.ContentLength != 0 && mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	grant := RequestGrant(r)
+	if grant == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := map[string]interface{}{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBytes)).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Body must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	// Like on the WebSocket, an "id" in the body is echoed in the reply; so
+	// is an X-Request-ID header, for proxies and HTTP clients that add one
+	id := params["id"]
+	if requestID := r.Header.Get("X-Request-ID"); requestID != "" {
+		w.Header().Set("X-Request-ID", requestID)
+		if id == nil {
+			id = requestID
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !grant.CanRun(name) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(models.NewErrorResponse(name, models.NewError(models.ErrForbidden, i18n.T("%s needs the %s scope", name, commandScope(name)), nil), id))
+		return
+	}
+
+	data, err := ExecuteCommand(withGrant(r.Context(), grant), name, params)
+	if err != nil {
+		log.Printf("❌ Command %s failed: %v", name, err)
+		commandErr := CommandError(err)
+		status := http.StatusInternalServerError
+		switch commandErr.Code {
+		case models.ErrInvalidParams:
+			status = http.StatusBadRequest
+		case models.ErrPlayerNotFound:
+			status = http.StatusNotFound
+		case models.ErrTimeout:
+			status = http.StatusGatewayTimeout
+		case models.ErrRateLimited:
+			status = http.StatusTooManyRequests
+		case models.ErrModuleDisabled, models.ErrExternalToolMissing, models.ErrUnavailable:
+			status = http.StatusServiceUnavailable
+		}
+		if commandErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(commandErr.RetryAfter))
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.NewErrorResponse(name, commandErr, id))
+		return
+	}
+	json.NewEncoder(w).Encode(models.NewResponse(name, data, id))
+}
@@ -0,0 +1,111 @@
+package websocket
+
+import (
+	"Blitz/config"
+	"Blitz/utils"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// relayMessage is sent by the relay on the control connection
+type relayMessage struct {
+	Type    string `json:"type"`    // "session" when a client connected to the relay
+	Session string `json:"session"` // Session to attach to
+}
+
+// StartRelay keeps a control connection to the configured relay so clients
+// outside the LAN can reach Blitz without port forwarding. For every client
+// the relay announces a session, and Blitz opens a data connection for it
+// that is served like a direct one, except that the client must always
+// authenticate (with the HMAC challenge, so the relay never sees the token).
+// Only that login is checked end to end: frames after it are not signed, so
+// the relay is trusted with the sessions it carries.
+func StartRelay() {
+	settings := config.Get().Relay
+	if settings.URL == "" {
+		return
+	}
+	if settings.ID == "" {
+		log.Println("❌ Relay disabled: relay.id is not set")
+		return
+	}
+	if authToken() == "" {
+		log.Println("❌ Relay disabled: set BLITZ_TOKEN so remote clients have to authenticate")
+		return
+	}
+
+	backoff := time.Second
+	for {
+		connected, err := runRelay(settings)
+		if err != nil {
+			log.Println("❌ Relay connection failed:", err)
+		}
+		if connected {
+			backoff = time.Second
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, upstreamMaxBackoff)
+	}
+}
+
+// runRelay serves the control connection until it fails
+func runRelay(settings config.Relay) (bool, error) {
+	conn, err := dialRelay(settings, "agent", nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	log.Println("✅ Connected to relay", settings.URL)
+
+	// The pings keep the connection alive through NATs and proxies, and the
+	// pongs tell a relay that went away from a quiet one
+	stop := keepPeerAlive(conn)
+	defer stop()
+
+	for {
+		var msg relayMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return true, err
+		}
+		if msg.Type != "session" || msg.Session == "" {
+			continue
+		}
+
+		go func(session string) {
+			sessionConn, err := dialRelay(settings, "session", url.Values{"session": {session}})
+			if err != nil {
+				log.Println("❌ Failed to open relay session:", err)
+				return
+			}
+			stop := keepAlive(sessionConn)
+			defer stop()
+			ServeConn(wsTransport{sessionConn}, nil, true, ConnOptions{})
+		}(msg.Session)
+	}
+}
+
+// dialRelay opens <relay.url>/<path>?id=<relay.id>, authenticating this
+// instance to the relay with $BLITZ_RELAY_KEY when set
+func dialRelay(settings config.Relay, path string, query url.Values) (*websocket.Conn, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("id", settings.ID)
+	endpoint := strings.TrimSuffix(settings.URL, "/") + "/" + path + "?" + query.Encode()
+
+	header := http.Header{}
+	if key := os.Getenv("BLITZ_RELAY_KEY"); key != "" {
+		header.Set("Authorization", "Bearer "+key)
+	}
+
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = utils.OutboundTLSConfig()
+	conn, _, err := dialer.Dial(endpoint, header)
+	return conn, err
+}
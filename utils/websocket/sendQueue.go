@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"Blitz/models"
+	"slices"
+	"sync"
+)
+
+// sendQueue holds the messages waiting to be written to a client, at most
+// clientSendBuffer of them. A snapshot replaces the one still waiting on its
+// topic, so a client that falls behind skips the states in between rather
+// than the latest, and the queue never holds more than one per topic.
+type sendQueue struct {
+	mu     sync.Mutex
+	items  []queuedMessage
+	ready  chan struct{} // Signalled when a message was queued, closed with the queue
+	closed bool
+}
+
+type queuedMessage struct {
+	msg      models.ServerResponse
+	coalesce bool // Replaced by newer messages on its topic
+}
+
+func newSendQueue() *sendQueue {
+	return &sendQueue{ready: make(chan struct{}, 1)}
+}
+
+// put queues msg, replacing the message waiting on its topic when coalesce
+// is set. A full queue makes room for a snapshot by dropping the oldest
+// event, or the oldest snapshot when it holds nothing else; other messages
+// are dropped when it is full, which false reports.
+func (q *sendQueue) put(msg models.ServerResponse, coalesce bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return true
+	}
+
+	if coalesce {
+		q.items = slices.DeleteFunc(q.items, func(item queuedMessage) bool {
+			return item.coalesce && item.msg.Topic == msg.Topic
+		})
+	}
+	if len(q.items) >= clientSendBuffer {
+		if !coalesce {
+			return false
+		}
+		oldest := max(slices.IndexFunc(q.items, func(item queuedMessage) bool { return !item.coalesce }), 0)
+		q.items = slices.Delete(q.items, oldest, oldest+1)
+	}
+	q.items = append(q.items, queuedMessage{msg: msg, coalesce: coalesce})
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// next waits for the oldest message; false once the queue was closed and
+// everything in it taken
+func (q *sendQueue) next() (models.ServerResponse, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			item := q.items[0]
+			q.items = slices.Delete(q.items, 0, 1)
+			q.mu.Unlock()
+			return item.msg, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+
+		if closed {
+			return models.ServerResponse{}, false
+		}
+		<-q.ready
+	}
+}
+
+// len returns how many messages wait
+func (q *sendQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// close ends next once the queue is empty; later messages are dropped
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.ready)
+	}
+}
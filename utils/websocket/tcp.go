@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"Blitz/config"
+	"errors"
+	"log"
+	"net"
+)
+
+// StartTCP serves the line protocol on tcp.listen for embedded clients
+// (ESPHome, Arduino) whose HTTP and WebSocket stacks are painful: the same
+// messages and broadcasts as the WebSocket, one JSON object per line.
+func StartTCP() {
+	address := config.Get().TCP.Listen
+	if address == "" {
+		return
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Println("❌ Failed to start the TCP listener:", err)
+		return
+	}
+	log.Println("✅ TCP line protocol listening on", address)
+
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Println("TCP accept failed:", err)
+			continue
+		}
+
+		// There are no headers to carry a token, so with BLITZ_TOKEN set
+		// clients authenticate in-band like relayed ones
+		var grant *Grant
+		if authToken() == "" {
+			grant = fullGrant()
+		}
+		go ServeConn(newLineTransport(conn), grant, false, ConnOptions{})
+	}
+}
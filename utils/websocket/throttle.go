@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTopicRate bounds the broadcasts per second a client may ask for on a
+// topic; no topic is broadcast faster
+const maxTopicRate = 100
+
+// topicThrottle holds back the broadcasts on a topic whose rate a client
+// limited, keeping only the latest
+type topicThrottle struct {
+	rate     float64 // Broadcasts per second
+	interval time.Duration
+	last     time.Time
+	pending  *models.ServerResponse
+	timer    *time.Timer
+}
+
+// parseRates reads ?rates=media_info:1,sensors:0.5 from a connection URL,
+// skipping invalid entries
+func parseRates(list string) map[string]float64 {
+	rates := map[string]float64{}
+	for _, entry := range strings.Split(list, ",") {
+		topic, value, _ := strings.Cut(entry, ":")
+		rate, err := strconv.ParseFloat(value, 64)
+		if topic == "" || err != nil || rate <= 0 || rate > maxTopicRate {
+			log.Println("Ignoring invalid topic rate:", entry)
+			continue
+		}
+		rates[topic] = rate
+	}
+	return rates
+}
+
+// ratesParam reads the rates parameter of subscribe, e.g. {"media_info": 1}
+func ratesParam(raw map[string]interface{}) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for topic, value := range raw {
+		rate, ok := value.(float64)
+		if !ok || rate <= 0 || rate > maxTopicRate {
+			return nil, invalidParam("rates", i18n.T("rates must be numbers of broadcasts per second above 0 and at most %d", maxTopicRate))
+		}
+		rates[topic] = rate
+	}
+	return rates, nil
+}
+
+// setRates limits the broadcasts per second the client receives on topics,
+// replacing the limits it had; topics without a rate are sent as they come,
+// unless the client is in low bandwidth mode. A broadcast held back under
+// the old limits is kept for the new ones, or sent now when its topic is no
+// longer limited.
+func (c *Client) setRates(rates map[string]float64) {
+	c.throttleMu.Lock()
+	c.requestedRates = rates
+	previous := c.throttles
+	c.throttles = map[string]*topicThrottle{}
+	for topic, rate := range c.budgetRates(rates) {
+		throttle := &topicThrottle{rate: rate, interval: time.Duration(float64(time.Second) / rate)}
+		if old := previous[topic]; old != nil {
+			delete(previous, topic)
+			if old.timer != nil {
+				old.timer.Stop()
+			}
+			throttle.last, throttle.pending = old.last, old.pending
+			if throttle.pending != nil {
+				wait := max(throttle.interval-time.Since(throttle.last), 0)
+				throttle.timer = time.AfterFunc(wait, func() { c.flushThrottled(topic) })
+			}
+		}
+		c.throttles[topic] = throttle
+	}
+
+	var released []models.ServerResponse
+	for _, old := range previous {
+		if old.timer != nil {
+			old.timer.Stop()
+		}
+		if old.pending != nil {
+			released = append(released, *old.pending)
+		}
+	}
+	c.throttleMu.Unlock()
+
+	for _, msg := range released {
+		c.Send.put(msg, isSnapshotTopic(msg.Topic))
+	}
+}
+
+// rates returns the limits set with setRates
+func (c *Client) rates() map[string]float64 {
+	c.throttleMu.Lock()
+	defer c.throttleMu.Unlock()
+
+	rates := map[string]float64{}
+	for topic, throttle := range c.throttles {
+		rates[topic] = throttle.rate
+	}
+	return rates
+}
+
+// deliver queues a broadcast for the client, or holds it back when the
+// client limited the rate of its topic. A held back message is replaced by
+// newer ones, and the latest is sent once the interval has passed, so the
+// client still ends up with the current state. Called with clientsMu held.
+func (c *Client) deliver(msg models.ServerResponse) {
+	c.throttleMu.Lock()
+	if throttle := c.throttles[msg.Topic]; throttle != nil {
+		if wait := throttle.interval - time.Since(throttle.last); wait > 0 {
+			throttle.pending = &msg
+			if throttle.timer == nil {
+				throttle.timer = time.AfterFunc(wait, func() { c.flushThrottled(msg.Topic) })
+			}
+			c.throttleMu.Unlock()
+			return
+		}
+		throttle.last = time.Now()
+	}
+	c.throttleMu.Unlock()
+
+	if !c.Send.put(msg, isSnapshotTopic(msg.Topic)) {
+		log.Println("Client busy, broadcast dropped for:", c.ID)
+	}
+}
+
+// flushThrottled sends the latest broadcast held back on topic
+func (c *Client) flushThrottled(topic string) {
+	c.throttleMu.Lock()
+	throttle := c.throttles[topic]
+	if throttle == nil || throttle.pending == nil {
+		c.throttleMu.Unlock()
+		return
+	}
+	msg := *throttle.pending
+	throttle.pending, throttle.timer = nil, nil
+	c.throttleMu.Unlock()
+
+	clientsMu.RLock()
+	defer clientsMu.RUnlock()
+	if _, ok := clients[c.ID]; ok {
+		c.deliver(msg)
+	}
+}
@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxLineMessage bounds one message of a line protocol client
+	maxLineMessage = 64 * 1024
+	// writeTimeout gives up on a client that stopped reading, e.g. one whose
+	// WiFi dropped, instead of blocking its writers for good
+	writeTimeout = 10 * time.Second
+)
+
+// Transport is a connection a client talks to Blitz over, carrying one JSON
+// message at a time. Writes are serialized by the Client.
+type Transport interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(data []byte) error
+	RemoteAddr() net.Addr
+	Close() error
+	// CloseWith closes the connection telling the client why, where the
+	// protocol has a way to: a WebSocket close frame with code and reason
+	CloseWith(code int, reason string) error
+}
+
+// binaryTransport can carry binary messages, for clients that asked for
+// MessagePack
+type binaryTransport interface {
+	WriteBinary(data []byte) error
+}
+
+// wsTransport carries messages as WebSocket text frames, or binary ones
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+func (t wsTransport) ReadMessage() ([]byte, error) {
+	_, data, err := t.conn.ReadMessage()
+	return data, err
+}
+
+func (t wsTransport) WriteMessage(data []byte) error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return t.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (t wsTransport) WriteBinary(data []byte) error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return t.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (t wsTransport) RemoteAddr() net.Addr { return t.conn.RemoteAddr() }
+
+func (t wsTransport) Close() error { return t.conn.Close() }
+
+func (t wsTransport) CloseWith(code int, reason string) error {
+	t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	return t.conn.Close()
+}
+
+// lineTransport carries messages as lines of JSON over a plain TCP socket,
+// for embedded clients without a usable WebSocket stack
+type lineTransport struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func newLineTransport(conn net.Conn) *lineTransport {
+	probePeer(conn)
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxLineMessage)
+	return &lineTransport{conn: conn, scanner: scanner}
+}
+
+func (t *lineTransport) ReadMessage() ([]byte, error) {
+	for t.scanner.Scan() {
+		// Blank lines keep the connection alive and are skipped. The scanner
+		// reuses its buffer, while the message may be handled concurrently.
+		if line := t.scanner.Bytes(); len(line) > 0 {
+			return bytes.Clone(line), nil
+		}
+	}
+	if err := t.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, net.ErrClosed
+}
+
+func (t *lineTransport) WriteMessage(data []byte) error {
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := t.conn.Write(data)
+	return err
+}
+
+func (t *lineTransport) RemoteAddr() net.Addr { return t.conn.RemoteAddr() }
+
+func (t *lineTransport) Close() error { return t.conn.Close() }
+
+// CloseWith just closes; line clients read the reason from the error
+// message sent before
+func (t *lineTransport) CloseWith(code int, reason string) error { return t.conn.Close() }
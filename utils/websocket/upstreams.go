@@ -0,0 +1,250 @@
+package websocket
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// upstreamMaxBackoff caps the wait between reconnects to an upstream
+	upstreamMaxBackoff = 30 * time.Second
+	// relayTimeout bounds a command forwarded to an upstream
+	relayTimeout = 4 * time.Second
+)
+
+// upstream is a connection to another Blitz instance
+type upstream struct {
+	name  string
+	url   string
+	token string
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	nextID  int
+	pending map[string]chan models.ServerResponse
+}
+
+// UpstreamStatus reports an upstream in the upstreams command
+type UpstreamStatus struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Connected bool   `json:"connected"`
+}
+
+var (
+	upstreams   = map[string]*upstream{}
+	upstreamsMu sync.RWMutex
+)
+
+// StartUpstreams connects to the Blitz instances listed in the config and
+// relays their broadcasts to our clients, prefixed with the upstream's name
+// ("laptop/media_info"), so one dashboard can show several machines
+func StartUpstreams() {
+	for _, cfg := range config.Get().Upstreams {
+		if cfg.Name == "" || cfg.URL == "" {
+			log.Println("❌ Ignoring upstream without name or url")
+			continue
+		}
+		u := &upstream{name: cfg.Name, url: cfg.URL, token: cfg.Token, pending: map[string]chan models.ServerResponse{}}
+
+		upstreamsMu.Lock()
+		upstreams[cfg.Name] = u
+		upstreamsMu.Unlock()
+
+		go u.run()
+	}
+}
+
+// GetUpstreams lists the configured upstreams and whether they are connected
+func GetUpstreams() []UpstreamStatus {
+	upstreamsMu.RLock()
+	defer upstreamsMu.RUnlock()
+
+	statuses := []UpstreamStatus{}
+	for _, u := range upstreams {
+		u.mu.Lock()
+		statuses = append(statuses, UpstreamStatus{Name: u.name, URL: u.url, Connected: u.conn != nil})
+		u.mu.Unlock()
+	}
+	return statuses
+}
+
+// RelayCommand runs a command on an upstream and returns its response data
+func RelayCommand(host, command string, params map[string]interface{}) (any, error) {
+	upstreamsMu.RLock()
+	u, ok := upstreams[host]
+	upstreamsMu.RUnlock()
+	if !ok {
+		return nil, invalidParam("host", i18n.T("unknown host %s", host))
+	}
+
+	u.mu.Lock()
+	if u.conn == nil {
+		u.mu.Unlock()
+		return nil, models.NewError(models.ErrPlayerNotFound, host+" is not connected", nil)
+	}
+	u.nextID++
+	id := fmt.Sprintf("relay-%d", u.nextID)
+	reply := make(chan models.ServerResponse, 1)
+	u.pending[id] = reply
+
+	message := map[string]interface{}{}
+	for key, value := range params {
+		message[key] = value
+	}
+	message["command"] = command
+	message["id"] = id
+	err := u.conn.WriteJSON(message)
+	u.mu.Unlock()
+
+	defer func() {
+		u.mu.Lock()
+		delete(u.pending, id)
+		u.mu.Unlock()
+	}()
+	if err != nil {
+		return nil, models.NewError(models.ErrCommandFailed, "failed to reach "+host, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+	select {
+	case response := <-reply:
+		if response.Status == "error" {
+			code := response.Code
+			if code == "" {
+				code = models.ErrCommandFailed
+			}
+			return nil, models.NewError(code, host+": "+response.Message, nil)
+		}
+		return response.Data, nil
+	case <-ctx.Done():
+		return nil, models.NewError(models.ErrTimeout, host+" did not answer", ctx.Err())
+	}
+}
+
+// run keeps the upstream connected, reconnecting with backoff
+func (u *upstream) run() {
+	// Upstreams relay the full media_info, so they are worth compressing too
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = config.Get().Compression.Enabled
+	dialer.TLSClientConfig = utils.OutboundTLSConfig()
+
+	backoff := time.Second
+	for {
+		conn, _, err := dialer.Dial(u.url, nil)
+		if err == nil {
+			if err = u.authenticate(conn); err != nil {
+				conn.Close()
+			}
+		}
+		if err != nil {
+			log.Printf("❌ Upstream %s unreachable: %v", u.name, err)
+		} else {
+			log.Printf("✅ Connected to upstream %s", u.name)
+			backoff = time.Second
+
+			u.mu.Lock()
+			u.conn = conn
+			u.mu.Unlock()
+
+			stop := keepPeerAlive(conn)
+			u.relay(conn)
+			stop()
+
+			u.mu.Lock()
+			u.conn = nil
+			u.mu.Unlock()
+			conn.Close()
+			log.Printf("Upstream %s disconnected", u.name)
+		}
+
+		time.Sleep(backoff)
+		backoff = min(backoff*2, upstreamMaxBackoff)
+	}
+}
+
+// authenticate reads the upstream's welcome message and answers its
+// challenge with an HMAC of the token, as relayed clients do, so the token
+// never crosses the network
+func (u *upstream) authenticate(conn *websocket.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(relayTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var welcome models.ServerResponse
+	if err := conn.ReadJSON(&welcome); err != nil {
+		return err
+	}
+	challenge, _ := welcome.Data.(map[string]interface{})
+	nonce, _ := challenge["nonce"].(string)
+	if challenge["auth"] != "required" || nonce == "" {
+		return nil
+	}
+	if u.token == "" {
+		return errors.New("it requires a token; set token for it in upstreams")
+	}
+
+	mac := hmac.New(sha256.New, []byte(u.token))
+	mac.Write([]byte(nonce))
+	if err := conn.WriteJSON(map[string]string{"command": "auth", "hmac": hex.EncodeToString(mac.Sum(nil)), "id": "auth"}); err != nil {
+		return err
+	}
+	var reply models.ServerResponse
+	if err := conn.ReadJSON(&reply); err != nil {
+		return err
+	}
+	if reply.Status == "error" {
+		return fmt.Errorf("it refused the token: %s", reply.Message)
+	}
+	return nil
+}
+
+// relay forwards the upstream's broadcasts until the connection fails
+func (u *upstream) relay(conn *websocket.Conn) {
+	for {
+		var msg models.ServerResponse
+		if err := conn.ReadJSON(&msg); err != nil {
+			if peerVanished(err) {
+				log.Printf("💀 Upstream %s stopped responding", u.name)
+			}
+			return
+		}
+
+		// Replies to relayed commands
+		if id, ok := msg.ID.(string); ok {
+			u.mu.Lock()
+			reply, waiting := u.pending[id]
+			u.mu.Unlock()
+			if waiting {
+				reply <- msg
+			}
+			continue
+		}
+
+		// Skip the welcome message, and messages the upstream relays itself,
+		// so two instances relaying each other don't loop
+		if msg.Status == "" || strings.Contains(msg.Message, "/") {
+			continue
+		}
+
+		msg.Message = u.name + "/" + msg.Message
+		if msg.Topic != "" {
+			msg.Topic = u.name + "/" + msg.Topic
+		}
+		WriteChannelMessage(msg)
+	}
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"Blitz/i18n"
+	"Blitz/models"
+	"Blitz/store"
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+const (
+	// variablesDocument is the store document holding the shared variables
+	variablesDocument = "variables"
+	// maxVariables and maxVariableBytes keep clients from filling the disk
+	maxVariables     = 256
+	maxVariableBytes = 16 * 1024
+)
+
+// VariableChange is broadcast as a "variables" message whenever a variable
+// is set; a nil Value means it was deleted
+type VariableChange struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// Topic implements models.Payload
+func (VariableChange) Topic() string { return "variables" }
+
+// Version implements models.Payload
+func (VariableChange) Version() int { return 1 }
+
+var (
+	variables       map[string]any
+	variablesLoaded bool
+	variablesMu     sync.Mutex
+)
+
+func loadVariables() {
+	if variablesLoaded {
+		return
+	}
+	variablesLoaded = true
+	variables = map[string]any{}
+	if err := store.Load(variablesDocument, &variables); err != nil {
+		log.Println("Failed to load variables:", err)
+	}
+}
+
+// GetVariables returns all shared variables
+func GetVariables() map[string]any {
+	variablesMu.Lock()
+	defer variablesMu.Unlock()
+	loadVariables()
+
+	all := make(map[string]any, len(variables))
+	for key, value := range variables {
+		all[key] = value
+	}
+	return all
+}
+
+// GetVariable returns one shared variable, nil when it isn't set
+func GetVariable(key string) any {
+	variablesMu.Lock()
+	defer variablesMu.Unlock()
+	loadVariables()
+	return variables[key]
+}
+
+// SetVariable stores a variable shared by all clients, such as the selected
+// page or dark mode, and broadcasts the change. A nil value deletes it.
+func SetVariable(key string, value any) error {
+	if encoded, err := json.Marshal(value); err != nil || len(encoded) > maxVariableBytes {
+		return invalidParam("value", i18n.T("value must be JSON of at most %d bytes", maxVariableBytes))
+	}
+
+	variablesMu.Lock()
+	loadVariables()
+	if value == nil {
+		if _, ok := variables[key]; !ok {
+			variablesMu.Unlock()
+			return nil
+		}
+		delete(variables, key)
+	} else {
+		if _, ok := variables[key]; !ok && len(variables) >= maxVariables {
+			variablesMu.Unlock()
+			return invalidParam("key", i18n.T("at most %d variables can be set", maxVariables))
+		}
+		variables[key] = value
+	}
+	err := store.Save(variablesDocument, variables)
+	variablesMu.Unlock()
+
+	if err != nil {
+		log.Println("Failed to save variables:", err)
+	}
+	WriteChannelMessage(models.NewEvent(VariableChange{Key: key, Value: value}))
+	return nil
+}
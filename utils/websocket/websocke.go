@@ -1,26 +1,94 @@
 package websocket
 
 import (
+	"Blitz/config"
 	"Blitz/models"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"strings"
 
 	"github.com/gorilla/websocket"
 )
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	}}
+	CheckOrigin: originAllowed,
+}
 var Conn *websocket.Conn
 
+// errOriginNotAllowed is returned for upgrades from pages not in origins.allowed
+var errOriginNotAllowed = errors.New("origin not allowed")
+
+// originAllowed accepts the page Blitz serves itself, the origins in
+// origins.allowed, and requests without an Origin, which come from apps and
+// scripts rather than browsers
+func originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	settings := config.Get().Origins
+	if sameOrigin(r) || settings.Any {
+		return true
+	}
+	for _, pattern := range settings.Allowed {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(origin)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin accepts requests without an Origin and those from the page
+// Blitz serves itself, ignoring origins.allowed
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// refuseCrossSite answers requests that change something and come from a
+// web page not allowed to connect. Browsers send simple POSTs to other sites
+// without asking, and Blitz trusts the LAN when no token is set, so any page
+// open on the LAN could otherwise run commands.
+func refuseCrossSite(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || originAllowed(r) {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	log.Println("❌ Rejected", r.Method, r.URL.Path, "from origin", origin)
+	http.Error(w, fmt.Sprintf("Origin %s is not allowed; add it to origins.allowed in the Blitz config", origin), http.StatusForbidden)
+	return true
+}
+
+// CreateWebSocketConnection upgrades a request from an allowed origin,
+// negotiating permessage-deflate when it is enabled and the client offers it
 func CreateWebSocketConnection(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+	if !originAllowed(r) {
+		origin := r.Header.Get("Origin")
+		log.Println("❌ Rejected WebSocket from origin", origin)
+		http.Error(w, fmt.Sprintf("Origin %s is not allowed; add it to origins.allowed in the Blitz config", origin), http.StatusForbidden)
+		return nil, errOriginNotAllowed
+	}
+
+	compression := config.Get().Compression
+	upgrader := upgrader
+	upgrader.EnableCompression = compression.Enabled
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	Conn = conn
 	if err != nil {
 		log.Println("WebSocket upgrade error:", err)
 		return nil, err
 	}
+	if compression.Enabled {
+		if err := conn.SetCompressionLevel(compression.Level); err != nil {
+			log.Println("Invalid compression level, using the default:", err)
+		}
+	}
 	log.Println("WebSocket Connection established for :=", Conn.LocalAddr())
 	return Conn, nil
 }
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"Blitz/models"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SavedWiFiConnection is a WiFi profile stored by NetworkManager
+type SavedWiFiConnection struct {
+	Name        string `json:"name"`
+	UUID        string `json:"uuid"`
+	Autoconnect bool   `json:"autoconnect"`
+	Priority    int    `json:"priority"` // Higher priorities are preferred when several networks are in range
+	Active      bool   `json:"active"`
+}
+
+// GetSavedWiFiConnections lists the saved WiFi profiles, highest priority first
+func GetSavedWiFiConnections() ([]SavedWiFiConnection, error) {
+	output, err := SpawnProcess("nmcli", []string{"-t", "-f", "NAME,UUID,TYPE,AUTOCONNECT,AUTOCONNECT-PRIORITY,ACTIVE", "connection", "show"})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSavedWiFiConnections(string(output)), nil
+}
+
+// parseSavedWiFiConnections parses
+// `nmcli -t -f NAME,UUID,TYPE,AUTOCONNECT,AUTOCONNECT-PRIORITY,ACTIVE connection show`,
+// keeping the WiFi profiles, highest priority first
+func parseSavedWiFiConnections(output string) []SavedWiFiConnection {
+	connections := []SavedWiFiConnection{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := splitNmcliFields(line)
+		if len(fields) < 6 || fields[2] != "802-11-wireless" {
+			continue
+		}
+		priority, _ := strconv.Atoi(fields[4])
+		connections = append(connections, SavedWiFiConnection{
+			Name:        fields[0],
+			UUID:        fields[1],
+			Autoconnect: fields[3] == "yes",
+			Priority:    priority,
+			Active:      fields[5] == "yes",
+		})
+	}
+
+	sort.SliceStable(connections, func(i, j int) bool {
+		return connections[i].Priority > connections[j].Priority
+	})
+	return connections
+}
+
+// ForgetWiFiConnection deletes a saved WiFi profile, given by UUID or name
+func ForgetWiFiConnection(connection string) (*SavedWiFiConnection, error) {
+	saved, err := findSavedWiFiConnection(connection)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := SpawnProcess("nmcli", []string{"connection", "delete", "uuid", saved.UUID}); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// SetWiFiAutoconnect enables or disables connecting to a saved network automatically
+func SetWiFiAutoconnect(connection string, enabled bool) (*SavedWiFiConnection, error) {
+	value := "no"
+	if enabled {
+		value = "yes"
+	}
+	saved, err := modifyWiFiConnection(connection, "connection.autoconnect", value)
+	if err != nil {
+		return nil, err
+	}
+	saved.Autoconnect = enabled
+	return saved, nil
+}
+
+// SetWiFiPriority sets the autoconnect priority of a saved network
+func SetWiFiPriority(connection string, priority int) (*SavedWiFiConnection, error) {
+	// NetworkManager accepts -999 to 999
+	if priority < -999 || priority > 999 {
+		return nil, models.NewError(models.ErrInvalidParams, "priority must be between -999 and 999", nil)
+	}
+	saved, err := modifyWiFiConnection(connection, "connection.autoconnect-priority", strconv.Itoa(priority))
+	if err != nil {
+		return nil, err
+	}
+	saved.Priority = priority
+	return saved, nil
+}
+
+func modifyWiFiConnection(connection, setting, value string) (*SavedWiFiConnection, error) {
+	saved, err := findSavedWiFiConnection(connection)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := SpawnProcess("nmcli", []string{"connection", "modify", "uuid", saved.UUID, setting, value}); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// findSavedWiFiConnection resolves a WiFi profile by UUID or name, so only
+// WiFi profiles (not wired or VPN ones) can be changed
+func findSavedWiFiConnection(connection string) (*SavedWiFiConnection, error) {
+	connections, err := GetSavedWiFiConnections()
+	if err != nil {
+		return nil, err
+	}
+	for i := range connections {
+		if connections[i].UUID == connection {
+			return &connections[i], nil
+		}
+	}
+	for i := range connections {
+		if connections[i].Name == connection {
+			return &connections[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no saved WiFi network %q", connection)
+}
@@ -13,6 +13,8 @@ type WiFiInfo struct {
 	SignalStrength int     `json:"signalStrength"` // Signal strength (0-100)
 	LinkSpeed      int     `json:"linkSpeed"`      // Link speed in Mbps
 	Frequency      string  `json:"frequency"`      // e.g., "5 GHz" or "2.4 GHz"
+	BSSID          string  `json:"bssid"`          // MAC address of the access point
+	Band           string  `json:"band"`           // "2.4 GHz", "5 GHz" or "6 GHz"
 	Security       string  `json:"security"`       // Security type (WPA2, WPA3, etc.)
 	IPAddress      string  `json:"ipAddress"`      // IP address of the device
 	Connected      bool    `json:"connected"`
@@ -31,21 +33,39 @@ var (
 // GetWiFiInfo returns current WiFi connection info and network speed
 func GetWiFiInfo() (*WiFiInfo, error) {
 	// Get active WiFi connection using nmcli
-	output, err := SpawnProcess("nmcli", []string{"-t", "-f", "ACTIVE,SSID,SIGNAL,FREQ,DEVICE", "dev", "wifi"})
+	output, err := SpawnProcess("nmcli", []string{"-t", "-f", "ACTIVE,SSID,SIGNAL,FREQ,DEVICE,BSSID", "dev", "wifi"})
 	if err != nil {
 		return nil, err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	info := parseNmcliWiFi(string(output))
+	if !info.Connected {
+		return info, nil
+	}
+
+	// Get additional connection details (security, IP, link speed)
+	getConnectionDetails(info)
+
+	// Get network speed for the interface
+	downloadSpeed, uploadSpeed := getCurrentNetworkSpeed(info.InterfaceName)
+	info.DownloadSpeed = downloadSpeed
+	info.UploadSpeed = uploadSpeed
+
+	return info, nil
+}
+
+// parseNmcliWiFi finds the active network in
+// `nmcli -t -f ACTIVE,SSID,SIGNAL,FREQ,DEVICE,BSSID dev wifi`
+func parseNmcliWiFi(output string) *WiFiInfo {
 	info := &WiFiInfo{
 		Connected:   false,
 		UnitOfSpeed: "Mbps",
 	}
 
 	// Find the active connection (starts with "yes:")
-	for _, line := range lines {
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
 		if strings.HasPrefix(line, "yes:") {
-			parts := strings.Split(line, ":")
+			parts := splitNmcliFields(line)
 			if len(parts) >= 5 {
 				info.Connected = true
 				info.SSID = parts[1]
@@ -56,25 +76,16 @@ func GetWiFiInfo() (*WiFiInfo, error) {
 				}
 
 				info.Frequency = parts[3]
+				info.Band = wifiBand(parts[3])
 				info.InterfaceName = parts[4]
+				if len(parts) >= 6 {
+					info.BSSID = parts[5]
+				}
 				break
 			}
 		}
 	}
-
-	if !info.Connected {
-		return info, nil
-	}
-
-	// Get additional connection details (security, IP, link speed)
-	getConnectionDetails(info)
-
-	// Get network speed for the interface
-	downloadSpeed, uploadSpeed := getCurrentNetworkSpeed(info.InterfaceName)
-	info.DownloadSpeed = downloadSpeed
-	info.UploadSpeed = uploadSpeed
-
-	return info, nil
+	return info
 }
 
 // getCurrentNetworkSpeed calculates current download/upload speed in Mbps
@@ -135,16 +146,7 @@ func getConnectionDetails(info *WiFiInfo) {
 		return
 	}
 
-	var connectionName string
-	lines := strings.Split(strings.TrimSpace(string(connOutput)), "\n")
-	for _, line := range lines {
-		parts := strings.Split(line, ":")
-		if len(parts) >= 2 && parts[1] == info.InterfaceName {
-			connectionName = parts[0]
-			break
-		}
-	}
-
+	connectionName := parseNmcliActiveConnection(string(connOutput), info.InterfaceName)
 	if connectionName == "" {
 		return
 	}
@@ -152,48 +154,109 @@ func getConnectionDetails(info *WiFiInfo) {
 	// Get detailed connection info
 	detailOutput, err := SpawnProcess("nmcli", []string{"-t", "-f", "802-11-wireless-security.key-mgmt,IP4.ADDRESS,GENERAL.DEVICE", "connection", "show", connectionName})
 	if err == nil {
-		detailLines := strings.Split(strings.TrimSpace(string(detailOutput)), "\n")
-		for _, line := range detailLines {
-			parts := strings.Split(line, ":")
-			if len(parts) >= 2 {
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-
-				switch key {
-				case "802-11-wireless-security.key-mgmt":
-					if value != "" && value != "--" {
-						info.Security = strings.ToUpper(value)
-					} else {
-						info.Security = "Open"
-					}
-				case "IP4.ADDRESS":
-					if value != "" && value != "--" {
-						// Extract IP address (remove /24 suffix if present)
-						ipParts := strings.Split(value, "/")
-						info.IPAddress = ipParts[0]
-					}
-				}
-			}
-		}
+		parseNmcliConnectionDetails(info, string(detailOutput))
 	}
 
 	// Get link speed using iw command
 	iwOutput, err := SpawnProcess("iw", []string{"dev", info.InterfaceName, "link"})
 	if err == nil {
-		iwLines := strings.Split(string(iwOutput), "\n")
-		for _, line := range iwLines {
-			if strings.Contains(line, "tx bitrate:") {
-				// Extract speed (e.g., "tx bitrate: 866.7 MBit/s")
-				parts := strings.Fields(line)
-				for i, part := range parts {
-					if part == "bitrate:" && i+1 < len(parts) {
-						if speed, err := strconv.ParseFloat(parts[i+1], 64); err == nil {
-							info.LinkSpeed = int(speed)
-						}
-						break
+		info.LinkSpeed = parseIwLinkSpeed(string(iwOutput))
+	}
+}
+
+// parseNmcliActiveConnection returns the name of the connection active on an
+// interface from `nmcli -t -f NAME,DEVICE connection show --active`
+func parseNmcliActiveConnection(output, interfaceName string) string {
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		parts := splitNmcliFields(line)
+		if len(parts) >= 2 && parts[1] == interfaceName {
+			return parts[0]
+		}
+	}
+	return ""
+}
+
+// parseNmcliConnectionDetails reads the security and IP address from
+// `nmcli -t -f 802-11-wireless-security.key-mgmt,IP4.ADDRESS,... connection show NAME`.
+// Multi-valued fields are numbered, e.g. "IP4.ADDRESS[1]".
+func parseNmcliConnectionDetails(info *WiFiInfo, output string) {
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, _, _ = strings.Cut(strings.TrimSpace(key), "[")
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "802-11-wireless-security.key-mgmt":
+			if value != "" && value != "--" {
+				info.Security = strings.ToUpper(value)
+			} else {
+				info.Security = "Open"
+			}
+		case "IP4.ADDRESS":
+			// The first address wins; extract it without the /24 suffix
+			if value != "" && value != "--" && info.IPAddress == "" {
+				ipParts := strings.Split(value, "/")
+				info.IPAddress = ipParts[0]
+			}
+		}
+	}
+}
+
+// parseIwLinkSpeed returns the transmit bitrate in Mbps from `iw dev IFACE link`,
+// e.g. "tx bitrate: 866.7 MBit/s VHT-MCS 9 80MHz short GI VHT-NSS 2"
+func parseIwLinkSpeed(output string) int {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "tx bitrate:") {
+			parts := strings.Fields(line)
+			for i, part := range parts {
+				if part == "bitrate:" && i+1 < len(parts) {
+					if speed, err := strconv.ParseFloat(parts[i+1], 64); err == nil {
+						return int(speed)
 					}
+					break
 				}
 			}
 		}
 	}
+	return 0
+}
+
+// splitNmcliFields splits a line of `nmcli -t` output, where colons inside a
+// value (like a BSSID) are escaped as "\:"
+func splitNmcliFields(line string) []string {
+	fields := []string{}
+	var field strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			field.WriteByte(line[i])
+		case line[i] == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(line[i])
+		}
+	}
+	return append(fields, field.String())
+}
+
+// wifiBand maps a frequency like "5180 MHz" to its band
+func wifiBand(frequency string) string {
+	mhz, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(frequency), "MHz")))
+	if err != nil {
+		return ""
+	}
+	switch {
+	case mhz >= 5925:
+		return "6 GHz"
+	case mhz >= 5000:
+		return "5 GHz"
+	case mhz >= 2400:
+		return "2.4 GHz"
+	}
+	return ""
 }
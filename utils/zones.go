@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"Blitz/config"
+	"Blitz/i18n"
+	"Blitz/models"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ZoneMemberResult is the outcome of a zone command on one member
+type ZoneMemberResult struct {
+	Provider string           `json:"provider"`
+	Player   string           `json:"player"`
+	Error    string           `json:"error,omitempty"`
+	Code     models.ErrorCode `json:"code,omitempty"`
+}
+
+// ZoneMemberState is the playback status of one member of a zone
+type ZoneMemberState struct {
+	Provider string `json:"provider"`
+	Player   string `json:"player"`
+	Status   string `json:"status"` // Playing, Paused, Stopped or Unavailable
+}
+
+// ZoneState aggregates the members of a zone; a zone is playing when any member is
+type ZoneState struct {
+	Name    string            `json:"name"`
+	Playing bool              `json:"playing"`
+	Members []ZoneMemberState `json:"members"`
+}
+
+// ZoneStates is the state of every zone, as broadcast
+type ZoneStates []ZoneState
+
+// Topic implements models.Payload
+func (ZoneStates) Topic() string { return "zone_state" }
+
+// Version implements models.Payload
+func (ZoneStates) Version() int { return 1 }
+
+// ZoneNames lists the zones defined in the config
+func ZoneNames() []string {
+	names := []string{}
+	for name := range config.Get().Zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// zoneProvider resolves the backend of a zone member
+func zoneProvider(member config.ZoneMember) (MusicProvider, error) {
+	switch member.Provider {
+	case "mpris":
+		return MPRISProvider{Player: member.Player}, nil
+	case "spotify_connect":
+		return NewSpotifyDeviceProvider(member.Player)
+	case "chromecast":
+		return ChromecastProvider{Device: member.Player}, nil
+	}
+	return nil, fmt.Errorf("unknown zone provider: %s", member.Provider)
+}
+
+// ZoneCommand runs a player action on every member of a zone at once.
+// "volume" and "seek" take value as percent and milliseconds respectively.
+// A member failing doesn't stop the others; its error is reported in its result.
+func ZoneCommand(ctx context.Context, zone, action string, value int) ([]ZoneMemberResult, error) {
+	members, ok := config.Get().Zones[zone]
+	if !ok {
+		return nil, models.NewParamError("zone", i18n.T("unknown zone %q", zone))
+	}
+	if action != "volume" && action != "seek" && !isPlayerAction(action) {
+		return nil, models.NewParamError("action", i18n.T("unsupported zone action %q", action))
+	}
+
+	results := make([]ZoneMemberResult, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func(i int, member config.ZoneMember) {
+			defer wg.Done()
+			results[i] = ZoneMemberResult{Provider: member.Provider, Player: member.Player}
+
+			provider, err := zoneProvider(member)
+			if err == nil {
+				switch action {
+				case "volume":
+					err = provider.SetVolume(ctx, value)
+				case "seek":
+					err = provider.Seek(ctx, value)
+				default:
+					err = provider.Control(ctx, action)
+				}
+			}
+			if err != nil {
+				log.Printf("Zone %s: %s failed on %s: %v", zone, action, member.Player, err)
+				memberErr := models.AsError(err)
+				results[i].Error = memberErr.Message
+				results[i].Code = memberErr.Code
+			}
+		}(i, member)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// GetZoneStates reports the status of every configured zone
+func GetZoneStates() []ZoneState {
+	zones := config.Get().Zones
+	states := []ZoneState{}
+	for _, name := range ZoneNames() {
+		state := ZoneState{Name: name, Members: make([]ZoneMemberState, len(zones[name]))}
+
+		var wg sync.WaitGroup
+		for i, member := range zones[name] {
+			wg.Add(1)
+			go func(i int, member config.ZoneMember) {
+				defer wg.Done()
+				state.Members[i] = ZoneMemberState{
+					Provider: member.Provider,
+					Player:   member.Player,
+					Status:   zoneMemberStatus(member),
+				}
+			}(i, member)
+		}
+		wg.Wait()
+
+		for _, member := range state.Members {
+			if member.Status == "Playing" {
+				state.Playing = true
+			}
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+func zoneMemberStatus(member config.ZoneMember) string {
+	switch member.Provider {
+	case "mpris":
+		args := []string{"status"}
+		if member.Player != "" {
+			args = []string{"--player=" + member.Player, "status"}
+		}
+		if output, err := SpawnProcess("playerctl", args); err == nil {
+			return strings.TrimSpace(string(output))
+		}
+	case "spotify_connect":
+		if provider, err := NewSpotifyDeviceProvider(member.Player); err == nil {
+			if provider.state.Track == nil {
+				return "Stopped"
+			}
+			if provider.state.Track.IsPlaying {
+				return "Playing"
+			}
+			return "Paused"
+		}
+	case "chromecast":
+		if status, err := (ChromecastProvider{Device: member.Player}).Status(); err == nil {
+			return status
+		}
+	}
+	return "Unavailable"
+}